@@ -0,0 +1,538 @@
+// Package analyzer runs static checks over a parsed program before it executes.
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+
+	"bpl-plus/ast"
+	"bpl-plus/codes"
+)
+
+type Diagnostic struct {
+	Span    ast.Span
+	Msg     string
+	Code    string // stable code from package codes, if the diagnostic has one
+	Warning bool   // reported, but does not stop the program from running
+}
+
+func (d Diagnostic) Error() string {
+	text := fmt.Sprintf("%s at %d:%d", d.Msg, d.Span.Line, d.Span.Col)
+	if d.Code != "" {
+		text += " [" + d.Code + "]"
+	}
+	if d.Warning {
+		return "Warning: " + text
+	}
+	return text
+}
+
+// FirstError returns the first diagnostic that is not a warning.
+func FirstError(diags []Diagnostic) (Diagnostic, bool) {
+	for _, d := range diags {
+		if !d.Warning {
+			return d, true
+		}
+	}
+	return Diagnostic{}, false
+}
+
+// scope is the set of names declared in one function body (or the top level).
+type scope map[string]bool
+
+type checker struct {
+	explicit bool
+	// option strict: explicit, plus every function must return a value and
+	// warnings are errors.
+	strict bool
+
+	// Reports whether a name is a builtin function, for shadowing warnings.
+	isBuiltin func(name string) bool
+	// Builtin names already warned about, so each is reported once.
+	shadowed map[string]bool
+
+	// All names declared anywhere at top level; visible inside function bodies
+	// because functions run after the top level has been set up.
+	globals scope
+	// Top-level function names, usable as values anywhere.
+	funcs scope
+	// Names declared so far in the body being checked.
+	cur scope
+	// let names of the blocks open inside that body, innermost last; like
+	// the interpreter's block scopes they end with their block.
+	blocks []scope
+	// True while checking a function body.
+	inFunc bool
+	// True while checking the body of a generator (a function that yields).
+	inGen bool
+	// Parameters of the function being checked.
+	params scope
+
+	diags []Diagnostic
+}
+
+// Check returns every diagnostic found in stmts (empty when the program is
+// clean). isBuiltin, when not nil, enables warnings for functions and
+// variables that reuse a builtin's name.
+func Check(stmts []ast.Stmt, isBuiltin func(name string) bool) []Diagnostic {
+	c := &checker{isBuiltin: isBuiltin, shadowed: map[string]bool{}}
+	c.checkOptions(stmts)
+
+	c.globals = scope{}
+	collectDecls(stmts, c.globals)
+	c.funcs = scope{}
+	for _, s := range stmts {
+		if fn, ok := s.(*ast.FunctionDecl); ok {
+			// outside strict mode this is a warning when the program runs
+			if c.strict && c.funcs[fn.Name] && !fn.Override {
+				c.errorf(fn.GetSpan(), "function %q replaces an earlier definition (write \"override function\" if this is intended; option strict)", fn.Name)
+			}
+			c.funcs[fn.Name] = true
+		}
+	}
+
+	c.cur = scope{}
+	c.checkStmts(stmts)
+	c.checkLabels(stmts)
+	return c.diags
+}
+
+func (c *checker) errorf(span ast.Span, format string, args ...any) {
+	c.diags = append(c.diags, Diagnostic{Span: span, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (c *checker) codedf(span ast.Span, code, format string, args ...any) {
+	c.diags = append(c.diags, Diagnostic{Span: span, Msg: fmt.Sprintf(format, args...), Code: code})
+}
+
+// warnf reports a warning, or an error under option strict.
+func (c *checker) warnf(span ast.Span, format string, args ...any) {
+	if c.strict {
+		c.errorf(span, format+" (option strict)", args...)
+		return
+	}
+	c.diags = append(c.diags, Diagnostic{Span: span, Msg: fmt.Sprintf(format, args...), Warning: true})
+}
+
+// checkShadow warns when a user definition takes a builtin's name: a
+// function called len replaces the builtin everywhere, which is rarely meant.
+func (c *checker) checkShadow(span ast.Span, kind, name string) {
+	if c.isBuiltin == nil || c.shadowed[name] || !c.isBuiltin(name) {
+		return
+	}
+	c.shadowed[name] = true
+	c.warnf(span, "%s %q shadows the builtin %s()", kind, name, name)
+}
+
+// Options must come first so they apply to the whole file.
+func (c *checker) checkOptions(stmts []ast.Stmt) {
+	seenOther := false
+	for _, s := range stmts {
+		opt, ok := s.(*ast.OptionStmt)
+		if !ok {
+			seenOther = true
+			continue
+		}
+		if seenOther {
+			c.errorf(opt.GetSpan(), "option %s must appear before any other statement", opt.Name)
+			continue
+		}
+		switch opt.Name {
+		case "explicit":
+			c.explicit = true
+		case "strict":
+			c.explicit, c.strict = true, true
+		}
+	}
+}
+
+// collectDecls gathers names introduced by dim and loop headers, without
+// descending into function bodies (those have their own scope).
+func collectDecls(stmts []ast.Stmt, into scope) {
+	for _, s := range stmts {
+		switch st := s.(type) {
+		case *ast.DimStmt:
+			into[st.Name] = true
+		case *ast.ImportStmt:
+			for _, name := range st.Names {
+				into[name] = true
+			}
+		case *ast.ForStmt:
+			into[st.Var] = true
+			collectDecls(st.Body, into)
+		case *ast.ForEachStmt:
+			into[st.Var] = true
+			if st.IndexVar != "" {
+				into[st.IndexVar] = true
+			}
+			collectDecls(st.Body, into)
+		case *ast.IfStmt:
+			collectDecls(st.Then, into)
+			for _, c := range st.ElseIfs {
+				collectDecls(c.Body, into)
+			}
+			collectDecls(st.Else, into)
+		case *ast.WhileStmt:
+			collectDecls(st.Body, into)
+		case *ast.RepeatStmt:
+			collectDecls(st.Body, into)
+		case *ast.TryStmt:
+			collectDecls(st.Body, into)
+			if st.CatchVar != "" {
+				into[st.CatchVar] = true
+			}
+			collectDecls(st.Catch, into)
+			collectDecls(st.Finally, into)
+		}
+	}
+}
+
+func (c *checker) declared(name string) bool {
+	if c.cur[name] || c.funcs[name] {
+		return true
+	}
+	for _, b := range c.blocks {
+		if b[name] {
+			return true
+		}
+	}
+	return c.inFunc && c.globals[name]
+}
+
+// checkBlock checks an if, loop or try body, whose let declarations are not
+// visible after it.
+func (c *checker) checkBlock(stmts []ast.Stmt) {
+	c.blocks = append(c.blocks, scope{})
+	c.checkStmts(stmts)
+	c.blocks = c.blocks[:len(c.blocks)-1]
+}
+
+// checkStmts checks a program or function body: a let there is an ordinary
+// variable.
+func (c *checker) checkStmts(stmts []ast.Stmt) {
+	for _, s := range stmts {
+		c.checkStmt(s)
+	}
+}
+
+func (c *checker) checkStmt(s ast.Stmt) {
+	switch st := s.(type) {
+	case *ast.DimStmt:
+		for _, d := range st.Dims {
+			c.checkExpr(d)
+		}
+		c.checkExpr(st.Value)
+		c.checkShadow(st.GetSpan(), "Variable", st.Name)
+		c.cur[st.Name] = true
+
+	case *ast.AssignStmt:
+		c.checkExpr(st.Value)
+		c.checkShadow(st.GetSpan(), "Variable", st.Name)
+		if c.explicit && !c.declared(st.Name) {
+			c.codedf(st.GetSpan(), codes.UndeclaredVariable, "Assignment to undeclared variable %q (option explicit)", st.Name)
+		}
+
+	case *ast.ImportStmt:
+		for _, name := range st.Names {
+			c.cur[name] = true
+		}
+
+	case *ast.ReadStmt:
+		for _, name := range st.Names {
+			c.checkShadow(st.GetSpan(), "Variable", name)
+			if c.explicit && !c.declared(name) {
+				c.codedf(st.GetSpan(), codes.UndeclaredVariable, "Read into undeclared variable %q (option explicit)", name)
+			}
+		}
+
+	case *ast.ExportStmt:
+		if c.inFunc {
+			c.errorf(st.GetSpan(), "export must be at the top level of a module")
+		}
+
+	case *ast.LetStmt:
+		c.checkExpr(st.Value)
+		c.checkShadow(st.GetSpan(), "Variable", st.Name)
+		if n := len(c.blocks); n > 0 {
+			c.blocks[n-1][st.Name] = true
+		} else {
+			c.cur[st.Name] = true
+		}
+
+	case *ast.GlobalStmt:
+		c.checkExpr(st.Value)
+		if !c.inFunc {
+			c.errorf(st.GetSpan(), "global can only be used inside a function (top-level variables are already global)")
+			return
+		}
+		for _, name := range st.Names {
+			if c.params[name] {
+				c.errorf(st.GetSpan(), "%q is a parameter and cannot be declared global", name)
+				continue
+			}
+			c.cur[name] = true
+			c.globals[name] = true
+		}
+
+	case *ast.MemberAssignStmt:
+		c.checkExpr(st.Target)
+		c.checkExpr(st.Value)
+
+	case *ast.TypeDecl:
+		c.checkShadow(st.GetSpan(), "Type", st.Name)
+		for _, d := range st.Defaults {
+			c.checkExpr(d)
+		}
+		for _, m := range st.Methods {
+			c.checkFunction(m, "self")
+		}
+
+	case *ast.IndexAssignStmt:
+		c.checkExpr(st.Target)
+		c.checkExpr(st.Index)
+		c.checkExpr(st.Value)
+
+	case *ast.SwapStmt:
+		c.checkExpr(st.Left)
+		c.checkExpr(st.Right)
+
+	case *ast.DestructureStmt:
+		c.checkExpr(st.Value)
+		names := st.Names
+		if st.Rest != "" {
+			names = append(names[:len(names):len(names)], st.Rest)
+		}
+		for _, name := range names {
+			c.checkShadow(st.GetSpan(), "Variable", name)
+			if c.explicit && !c.declared(name) {
+				c.codedf(st.GetSpan(), codes.UndeclaredVariable, "Unpacking into undeclared variable %q (option explicit)", name)
+			}
+		}
+
+	case *ast.ExprStmt:
+		c.checkExpr(st.Expr)
+
+	case *ast.PrintStmt:
+		for _, v := range st.Values {
+			c.checkExpr(v)
+		}
+
+	case *ast.PrintHandleStmt:
+		c.checkExpr(st.Value)
+
+	case *ast.OpenStmt:
+		c.checkExpr(st.Path)
+		c.checkExpr(st.Mode)
+
+	case *ast.ReturnStmt:
+		c.checkExpr(st.Value)
+		if c.inGen && st.Value != nil {
+			c.errorf(st.GetSpan(), "return in a generator cannot have a value (yield it, then return)")
+		}
+
+	case *ast.YieldStmt:
+		c.checkExpr(st.Value)
+		if !c.inFunc {
+			c.errorf(st.GetSpan(), "yield can only be used inside a function")
+		}
+
+	case *ast.IfStmt:
+		c.checkExpr(st.Condition)
+		c.checkBlock(st.Then)
+		for _, ei := range st.ElseIfs {
+			c.checkExpr(ei.Condition)
+			c.checkBlock(ei.Body)
+		}
+		c.checkBlock(st.Else)
+
+	case *ast.WhileStmt:
+		c.checkExpr(st.Condition)
+		c.checkBlock(st.Body)
+
+	case *ast.RepeatStmt:
+		c.checkBlock(st.Body)
+		c.checkExpr(st.Condition)
+
+	case *ast.TryStmt:
+		c.checkBlock(st.Body)
+		if st.CatchVar != "" {
+			c.cur[st.CatchVar] = true
+		}
+		c.checkBlock(st.Catch)
+		c.checkBlock(st.Finally)
+
+	case *ast.RaiseStmt:
+		c.checkExpr(st.Value)
+
+	case *ast.ForStmt:
+		c.checkExpr(st.Start)
+		c.checkExpr(st.End)
+		c.checkExpr(st.Step)
+		c.checkShadow(st.GetSpan(), "Variable", st.Var)
+		c.cur[st.Var] = true
+		c.checkBlock(st.Body)
+
+	case *ast.ForEachStmt:
+		c.checkExpr(st.Iterable)
+		c.checkShadow(st.GetSpan(), "Variable", st.Var)
+		c.cur[st.Var] = true
+		if st.IndexVar != "" {
+			c.cur[st.IndexVar] = true
+		}
+		c.checkBlock(st.Body)
+
+	case *ast.FunctionDecl:
+		if !st.Override {
+			c.checkShadow(st.GetSpan(), "Function", st.Name)
+		}
+		if c.inFunc {
+			c.cur[st.Name] = true // nested functions are locals
+		}
+		c.checkFunction(st)
+	}
+}
+
+// checkFunction checks a function body; implicit names (self in methods)
+// are declared along with the params.
+func (c *checker) checkFunction(fn *ast.FunctionDecl, implicit ...string) {
+	prevScope, prevIn, prevParams, prevGen, prevBlocks := c.cur, c.inFunc, c.params, c.inGen, c.blocks
+	c.cur, c.inFunc, c.params, c.inGen, c.blocks = scope{}, true, scope{}, fn.Generator, nil
+	if prevIn {
+		// a nested function sees the variables of the one it is defined in
+		for name := range prevScope {
+			c.cur[name] = true
+		}
+	}
+	// and a function defined in a block sees that block's let names
+	for _, b := range prevBlocks {
+		for name := range b {
+			c.cur[name] = true
+		}
+	}
+	for _, p := range fn.Params {
+		c.checkShadow(fn.GetSpan(), "Parameter", p)
+		c.cur[p] = true
+		c.params[p] = true
+	}
+	for _, name := range implicit {
+		c.cur[name] = true
+	}
+	c.checkStmts(fn.Body)
+	if c.strict && !fn.Generator {
+		c.checkReturns(fn)
+	}
+	c.cur, c.inFunc, c.params, c.inGen, c.blocks = prevScope, prevIn, prevParams, prevGen, prevBlocks
+}
+
+func (c *checker) checkExpr(e ast.Expr) {
+	switch ex := e.(type) {
+	case nil:
+		return
+
+	case *ast.Identifier:
+		if c.explicit && !c.declared(ex.Name) {
+			c.codedf(ex.GetSpan(), codes.UndeclaredVariable, "Undeclared variable %q (option explicit)", ex.Name)
+		}
+
+	case *ast.UnaryExpr:
+		c.checkExpr(ex.Right)
+
+	case *ast.BinaryExpr:
+		c.checkExpr(ex.Left)
+		c.checkExpr(ex.Right)
+		if ex.ClassicOp {
+			c.warnf(ex.GetSpan(), "<> is the classic BASIC spelling of != (write a != b)")
+		}
+
+	case *ast.CallExpr:
+		for _, a := range ex.Args {
+			c.checkExpr(a)
+		}
+
+	case *ast.CallValueExpr:
+		c.checkExpr(ex.Callee)
+		for _, a := range ex.Args {
+			c.checkExpr(a)
+		}
+
+	case *ast.FunctionLit:
+		c.checkFunction(ex.Fn)
+
+	case *ast.ArrayLiteralExpr:
+		for _, el := range ex.Elements {
+			c.checkExpr(el)
+		}
+
+	case *ast.MapLiteralExpr:
+		for _, ent := range ex.Entries {
+			c.checkExpr(ent.KeyExpr)
+			c.checkExpr(ent.Value)
+		}
+
+	case *ast.SpreadExpr:
+		c.checkExpr(ex.Value)
+
+	case *ast.RangeExpr:
+		c.checkExpr(ex.Start)
+		c.checkExpr(ex.End)
+
+	case *ast.MatchExpr:
+		c.checkExpr(ex.Subject)
+		for _, arm := range ex.Arms {
+			for _, pat := range arm.Patterns {
+				c.checkExpr(pat.Value)
+				c.checkExpr(pat.High)
+			}
+			if arm.Bind == "" {
+				c.checkExpr(arm.Body)
+				continue
+			}
+			// the as name is only visible in its arm's body
+			c.checkShadow(arm.S, "Variable", arm.Bind)
+			c.blocks = append(c.blocks, scope{arm.Bind: true})
+			c.checkExpr(arm.Body)
+			c.blocks = c.blocks[:len(c.blocks)-1]
+		}
+
+	case *ast.IndexExpr:
+		c.checkExpr(ex.Left)
+		c.checkExpr(ex.Index)
+
+	case *ast.SliceExpr:
+		c.checkExpr(ex.Left)
+		if ex.Start != nil {
+			c.checkExpr(ex.Start)
+		}
+		if ex.End != nil {
+			c.checkExpr(ex.End)
+		}
+
+	case *ast.MemberExpr:
+		// str.upper names a builtin, not a field of a variable called str
+		if !c.isNamespaced(ex.Left, ex.Name, ex.Classic) {
+			c.checkExpr(ex.Left)
+		}
+
+	case *ast.MethodCallExpr:
+		if !c.isNamespaced(ex.Object, ex.Name, ex.Classic) {
+			c.checkExpr(ex.Object)
+		}
+		for _, a := range ex.Args {
+			c.checkExpr(a)
+		}
+	}
+}
+
+// isNamespaced reports whether left.name is a namespaced builtin (math.sqrt),
+// written in any case when fold is set (option classic).
+func (c *checker) isNamespaced(left ast.Expr, name string, fold bool) bool {
+	id, ok := left.(*ast.Identifier)
+	if !ok || c.isBuiltin == nil {
+		return false
+	}
+	dotted := id.Name + "." + name
+	if fold {
+		dotted = strings.ToLower(dotted)
+	}
+	return c.isBuiltin(dotted)
+}
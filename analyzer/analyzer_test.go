@@ -0,0 +1,204 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+
+	"bpl-plus/lexer"
+	"bpl-plus/parser"
+)
+
+// check parses src and returns the text of every diagnostic, one per line.
+func check(t *testing.T, src string) string {
+	t.Helper()
+	prog, err := parser.New(lexer.New(src)).ParseProgram()
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var out []string
+	for _, d := range Check(prog, nil) {
+		out = append(out, d.Error())
+	}
+	return strings.Join(out, "\n")
+}
+
+func TestLetIsScopedToItsBlock(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string // "" for a clean program
+	}{
+		{"used inside its block", `option explicit
+if true
+  let t = 1
+  print t
+end
+`, ""},
+		{"used after its block", `option explicit
+if true
+  let t = 1
+end
+print t
+`, `Undeclared variable "t" (option explicit) at 5:7 [E0102]`},
+		{"seen by nested blocks", `option explicit
+while true
+  let t = 1
+  if t > 0
+    print t
+  end
+  break
+end
+`, ""},
+		{"not seen by the next branch", `option explicit
+if true
+  let t = 1
+else
+  print t
+end
+`, `Undeclared variable "t" (option explicit) at 5:9 [E0102]`},
+		{"not seen by until", `option explicit
+repeat
+  let t = 1
+until t > 0
+`, `Undeclared variable "t" (option explicit) at 4:7 [E0102]`},
+		{"function body let is a local", `option explicit
+function f()
+  let t = 1
+  if true
+    print t
+  end
+  return t
+end
+`, ""},
+		{"closure in the block sees it", `option explicit
+dim g
+for each x in [1]
+  let t = x
+  g = function() return t end
+end
+`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := check(t, tt.src); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchBindingIsScopedToItsArm(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"used in its arm", `option explicit
+dim r = match 5
+  case is number as v: v * 2
+  case else: 0
+end
+`, ""},
+		{"used after the match", `option explicit
+dim r = match 5
+  case is number as v: v * 2
+  case else: 0
+end
+print v
+`, `Undeclared variable "v" (option explicit) at 6:7 [E0102]`},
+		{"used in another arm", `option explicit
+dim r = match 5
+  case is string as v: v
+  case else: v
+end
+`, `Undeclared variable "v" (option explicit) at 4:14 [E0102]`},
+		{"closure in the arm sees it", `option explicit
+dim f = match 5
+  case is number as v: function() return v end
+end
+`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := check(t, tt.src); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrictAcceptsEndlessLoopsLeftByReturn(t *testing.T) {
+	const missing = `Function "f" can end without returning a value (option strict) at 2:10 [E0402]`
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"while true", `  while true
+    if n > 10
+      return n
+    end
+    n = n + 1
+  end`, ""},
+		{"repeat until false", `  repeat
+    n = n + 1
+    if n > 10
+      return n
+    end
+  until false`, ""},
+		{"break inside a nested loop", `  while true
+    for each x in [1, 2]
+      break
+    end
+    return n
+  end`, ""},
+		{"while true with break", `  while true
+    if n > 10
+      break
+    end
+    return n
+  end`, missing},
+		{"goto out of a nested loop", `  while true
+    while true
+      goto out
+    end
+  end
+  out:`, missing},
+		{"while with a condition", `  while n < 10
+    return n
+  end`, missing},
+		{"repeat with a condition", `  repeat
+    return n
+  until n > 10`, missing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := "option strict\nfunction f(n)\n" + tt.body + "\nend\n"
+			if got := check(t, src); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassicNotEqualSuggestion(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"modern file", "a = 1\nprint a <> 2\n",
+			"Warning: <> is the classic BASIC spelling of != (write a != b) at 2:9"},
+		{"option strict", "option strict\ndim a = 1\nprint a <> 2\n",
+			"<> is the classic BASIC spelling of != (write a != b) (option strict) at 3:9"},
+		{"!= is fine", "a = 1\nprint a != 2\n", ""},
+		{"option classic", "option classic\na = 1\nprint a <> 2\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := check(t, tt.src); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
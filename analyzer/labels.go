@@ -0,0 +1,116 @@
+package analyzer
+
+import "bpl-plus/ast"
+
+// checkLabels validates goto and gosub in the program and in every function
+// body: each label is defined once per body, and a jump names a label in its
+// own statement list or an enclosing one. Jumping into a block (or into another
+// function) is not allowed.
+func (c *checker) checkLabels(stmts []ast.Stmt) {
+	c.checkJumps(stmts, "program")
+	anywhere := map[string]bool{}
+	var restores []*ast.RestoreStmt
+	for _, s := range stmts {
+		ast.Inspect(s, func(n ast.Node) bool {
+			switch st := n.(type) {
+			case *ast.FunctionDecl:
+				c.checkJumps(st.Body, "function")
+			case *ast.LabelStmt:
+				anywhere[st.Name] = true
+			case *ast.RestoreStmt:
+				restores = append(restores, st)
+			}
+			return true
+		})
+	}
+	// data is file-wide, so restore may name a label in any function
+	for _, r := range restores {
+		if r.Label != "" && !anywhere[r.Label] {
+			c.errorf(r.GetSpan(), "Undefined label %q in restore", r.Label)
+		}
+	}
+}
+
+// checkJumps checks the labels and gotos of one function body (or the top
+// level), not descending into nested functions.
+func (c *checker) checkJumps(body []ast.Stmt, kind string) {
+	all := map[string]bool{}
+	c.collectLabels(body, all)
+	c.checkGotos(body, nil, all, kind)
+}
+
+func (c *checker) collectLabels(stmts []ast.Stmt, into map[string]bool) {
+	for _, s := range stmts {
+		if l, ok := s.(*ast.LabelStmt); ok {
+			if into[l.Name] {
+				c.errorf(l.GetSpan(), "Label %q is defined more than once", l.Name)
+			}
+			into[l.Name] = true
+			continue
+		}
+		for _, b := range childBlocks(s) {
+			c.collectLabels(b, into)
+		}
+	}
+}
+
+// checkGotos walks stmts with outer holding the labels of the enclosing lists.
+func (c *checker) checkGotos(stmts []ast.Stmt, outer, all map[string]bool, kind string) {
+	visible := map[string]bool{}
+	for name := range outer {
+		visible[name] = true
+	}
+	for _, s := range stmts {
+		if l, ok := s.(*ast.LabelStmt); ok {
+			visible[l.Name] = true
+		}
+	}
+
+	for _, s := range stmts {
+		if keyword, label, ok := jumpTarget(s); ok && !visible[label] {
+			if all[label] {
+				c.errorf(s.GetSpan(), "%s %s jumps into a block; a label can only be reached from its own block or one nested inside it", keyword, label)
+			} else {
+				c.errorf(s.GetSpan(), "Undefined label %q in this %s", label, kind)
+			}
+		}
+		for _, b := range childBlocks(s) {
+			c.checkGotos(b, visible, all, kind)
+		}
+	}
+}
+
+// jumpTarget returns the keyword and label of a goto or gosub.
+func jumpTarget(s ast.Stmt) (string, string, bool) {
+	switch st := s.(type) {
+	case *ast.GotoStmt:
+		return "goto", st.Label, true
+	case *ast.GosubStmt:
+		return "gosub", st.Label, true
+	}
+	return "", "", false
+}
+
+// childBlocks returns the statement lists nested directly in s (not
+// function bodies).
+func childBlocks(s ast.Stmt) [][]ast.Stmt {
+	switch st := s.(type) {
+	case *ast.IfStmt:
+		blocks := [][]ast.Stmt{st.Then}
+		for _, ei := range st.ElseIfs {
+			blocks = append(blocks, ei.Body)
+		}
+		return append(blocks, st.Else)
+	case *ast.WhileStmt:
+		return [][]ast.Stmt{st.Body}
+	case *ast.RepeatStmt:
+		return [][]ast.Stmt{st.Body}
+	case *ast.ForStmt:
+		return [][]ast.Stmt{st.Body}
+	case *ast.ForEachStmt:
+		return [][]ast.Stmt{st.Body}
+	case *ast.TryStmt:
+		return [][]ast.Stmt{st.Body, st.Catch, st.Finally}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package analyzer
+
+import (
+	"bpl-plus/ast"
+	"bpl-plus/codes"
+)
+
+// checkReturns reports a function whose body can reach its end without
+// returning a value (option strict); otherwise it would return null there.
+func (c *checker) checkReturns(fn *ast.FunctionDecl) {
+	if alwaysReturns(fn.Body) {
+		return
+	}
+	if fn.Name == "" {
+		c.codedf(fn.GetSpan(), codes.MissingReturn, "Function can end without returning a value (option strict)")
+		return
+	}
+	c.codedf(fn.GetSpan(), codes.MissingReturn, "Function %q can end without returning a value (option strict)", fn.Name)
+}
+
+// alwaysReturns reports whether running body always ends in return value,
+// raise or exit(). Only the last statement counts: an if needs every branch
+// (and an else) to return, a try needs its body and catch to (or its
+// finally). Loops are assumed to finish, except while true and
+// repeat ... until false with no break or goto out of them: those can only
+// be left by return, raise or exit().
+func alwaysReturns(body []ast.Stmt) bool {
+	if len(body) == 0 {
+		return false
+	}
+	switch st := body[len(body)-1].(type) {
+	case *ast.ReturnStmt:
+		return st.Value != nil
+	case *ast.RaiseStmt:
+		return true
+	case *ast.ExprStmt:
+		call, ok := st.Expr.(*ast.CallExpr)
+		return ok && call.Callee == "exit"
+	case *ast.IfStmt:
+		if !alwaysReturns(st.Then) || !alwaysReturns(st.Else) {
+			return false
+		}
+		for _, ei := range st.ElseIfs {
+			if !alwaysReturns(ei.Body) {
+				return false
+			}
+		}
+		return true
+	case *ast.TryStmt:
+		if alwaysReturns(st.Finally) {
+			return true
+		}
+		// without catch, an error in the body leaves the function anyway
+		return alwaysReturns(st.Body) && (!st.HasCatch || alwaysReturns(st.Catch))
+	case *ast.WhileStmt:
+		return isBool(st.Condition, true) && !leavesLoop(st.Body)
+	case *ast.RepeatStmt:
+		return isBool(st.Condition, false) && !leavesLoop(st.Body)
+	}
+	return false
+}
+
+func isBool(e ast.Expr, want bool) bool {
+	b, ok := e.(*ast.BoolLiteral)
+	return ok && b.Value == want
+}
+
+// leavesLoop reports whether a loop body has a break for that loop (not for
+// a loop nested in it) or a goto anywhere in it, which may jump out.
+func leavesLoop(body []ast.Stmt) bool {
+	found := false
+	var visit func(n ast.Node, nested bool) bool
+	visit = func(n ast.Node, nested bool) bool {
+		switch n.(type) {
+		case *ast.GotoStmt:
+			found = true
+		case *ast.BreakStmt:
+			found = found || !nested
+		case *ast.WhileStmt, *ast.RepeatStmt, *ast.ForStmt, *ast.ForEachStmt:
+			if !nested {
+				ast.Inspect(n, func(m ast.Node) bool { return m == n || visit(m, true) })
+				return false
+			}
+		case *ast.FunctionDecl, *ast.FunctionLit:
+			return false
+		}
+		return !found
+	}
+	for _, s := range body {
+		ast.Inspect(s, func(n ast.Node) bool { return visit(n, false) })
+	}
+	return found
+}
@@ -32,6 +32,15 @@ func (n *NumberLiteral) exprNode()        {}
 func (n *NumberLiteral) GetSpan() Span    { return n.S }
 func (n *NumberLiteral) String() string   { return fmt.Sprintf("Number(%s)", n.Lexeme) }
 
+type NullLiteral struct {
+	S Span
+}
+
+func (n *NullLiteral) NodeKind() string { return "NullLiteral" }
+func (n *NullLiteral) exprNode()        {}
+func (n *NullLiteral) GetSpan() Span    { return n.S }
+func (n *NullLiteral) String() string   { return "Null" }
+
 type BoolLiteral struct {
 	S     Span
 	Value bool
@@ -75,6 +84,14 @@ type BinaryExpr struct {
 	Left  Expr
 	Op    string
 	Right Expr
+
+	// Set under option checked: arithmetic that overflows or loses integer
+	// precision is an error.
+	Checked bool
+
+	// Written as classic BASIC's <> (Op is still "!=") outside a --classic
+	// listing, so the analyzer can suggest the modern spelling.
+	ClassicOp bool
 }
 
 func (b *BinaryExpr) NodeKind() string { return "BinaryExpr" }
@@ -97,6 +114,70 @@ func (c *CallExpr) String() string {
 	return fmt.Sprintf("Call(%s, args=%d)", c.Callee, len(c.Args))
 }
 
+// p.x: a field of a record
+type MemberExpr struct {
+	S    Span
+	Left Expr
+	Name string
+	// Written in an option classic file, where Name matches fields and
+	// namespaced builtins in any case (the name itself is kept as written).
+	Classic bool
+}
+
+func (m *MemberExpr) NodeKind() string { return "MemberExpr" }
+func (m *MemberExpr) exprNode()        {}
+func (m *MemberExpr) GetSpan() Span    { return m.S }
+func (m *MemberExpr) String() string {
+	return fmt.Sprintf("Member(%s, %s)", m.Left.String(), m.Name)
+}
+
+// p.move(1, 2): a method call on a record, or a namespaced builtin
+// (math.sqrt(x)) when Object is a name that is not a variable
+type MethodCallExpr struct {
+	S      Span
+	Object Expr
+	Name   string
+	Args   []Expr
+	// Written in an option classic file, where Name matches methods, fields
+	// and namespaced builtins in any case.
+	Classic bool
+}
+
+func (m *MethodCallExpr) NodeKind() string { return "MethodCallExpr" }
+func (m *MethodCallExpr) exprNode()        {}
+func (m *MethodCallExpr) GetSpan() Span    { return m.S }
+func (m *MethodCallExpr) String() string {
+	return fmt.Sprintf("MethodCall(%s.%s, args=%d)", m.Object.String(), m.Name, len(m.Args))
+}
+
+// f(x)(y), arr[0](x): calling whatever value an expression produces
+type CallValueExpr struct {
+	S      Span
+	Callee Expr
+	Args   []Expr
+}
+
+func (c *CallValueExpr) NodeKind() string { return "CallValueExpr" }
+func (c *CallValueExpr) exprNode()        {}
+func (c *CallValueExpr) GetSpan() Span    { return c.S }
+func (c *CallValueExpr) String() string {
+	return fmt.Sprintf("CallValue(%s, args=%d)", c.Callee.String(), len(c.Args))
+}
+
+// function(x) return x * 2 end
+// Fn is an anonymous FunctionDecl (Name "") built by the parser.
+type FunctionLit struct {
+	S  Span
+	Fn *FunctionDecl
+}
+
+func (f *FunctionLit) NodeKind() string { return "FunctionLit" }
+func (f *FunctionLit) exprNode()        {}
+func (f *FunctionLit) GetSpan() Span    { return f.S }
+func (f *FunctionLit) String() string {
+	return fmt.Sprintf("FunctionLit(params=%d, body=%d)", len(f.Fn.Params), len(f.Fn.Body))
+}
+
 // --- Arrays v1 ---
 
 type ArrayLiteralExpr struct {
@@ -119,14 +200,66 @@ func (a *ArrayLiteralExpr) String() string {
 }
 
 type IndexExpr struct {
-	S     Span
-	Left  Expr
-	Index Expr
+	S        Span
+	Left     Expr
+	Index    Expr
+	Optional bool // m["k"]? gives null instead of failing
 }
 
 func (x *IndexExpr) NodeKind() string { return "IndexExpr" }
 func (x *IndexExpr) exprNode()        {}
 func (x *IndexExpr) GetSpan() Span    { return x.S }
 func (x *IndexExpr) String() string {
+	if x.Optional {
+		return fmt.Sprintf("Index?(%s, %s)", x.Left.String(), x.Index.String())
+	}
 	return fmt.Sprintf("Index(%s, %s)", x.Left.String(), x.Index.String())
 }
+
+// SliceExpr is a[start:end]. Either bound may be nil: a[:3], a[2:].
+type SliceExpr struct {
+	S     Span
+	Left  Expr
+	Start Expr
+	End   Expr
+}
+
+func (x *SliceExpr) NodeKind() string { return "SliceExpr" }
+func (x *SliceExpr) exprNode()        {}
+func (x *SliceExpr) GetSpan() Span    { return x.S }
+func (x *SliceExpr) String() string {
+	bound := func(e Expr) string {
+		if e == nil {
+			return "_"
+		}
+		return e.String()
+	}
+	return fmt.Sprintf("Slice(%s, %s, %s)", x.Left.String(), bound(x.Start), bound(x.End))
+}
+
+// RangeExpr is start..end: the whole numbers from start to end inclusive,
+// as a value that is iterated without building an array.
+type RangeExpr struct {
+	S     Span
+	Start Expr
+	End   Expr
+}
+
+func (x *RangeExpr) NodeKind() string { return "RangeExpr" }
+func (x *RangeExpr) exprNode()        {}
+func (x *RangeExpr) GetSpan() Span    { return x.S }
+func (x *RangeExpr) String() string {
+	return fmt.Sprintf("Range(%s, %s)", x.Start.String(), x.End.String())
+}
+
+// SpreadExpr is xs... in an array literal or call arguments: the values of
+// xs in its place.
+type SpreadExpr struct {
+	S     Span
+	Value Expr
+}
+
+func (x *SpreadExpr) NodeKind() string { return "SpreadExpr" }
+func (x *SpreadExpr) exprNode()        {}
+func (x *SpreadExpr) GetSpan() Span    { return x.S }
+func (x *SpreadExpr) String() string   { return fmt.Sprintf("Spread(%s)", x.Value.String()) }
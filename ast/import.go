@@ -1,13 +1,38 @@
 package ast
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
+// import "path"
+// import a, b from "path"
 type ImportStmt struct {
-	S    Span
-	Path string
+	S     Span
+	Path  string
+	Names []string // only these exports; empty means everything exported
+	// Written in an option classic file: exports are found in any case and
+	// bound in lower case, the way that file reads names.
+	Classic bool
 }
 
 func (i *ImportStmt) NodeKind() string { return "ImportStmt" }
 func (i *ImportStmt) stmtNode()        {}
 func (i *ImportStmt) GetSpan() Span    { return i.S }
-func (i *ImportStmt) String() string   { return fmt.Sprintf("Import(%q)", i.Path) }
+func (i *ImportStmt) String() string {
+	if len(i.Names) == 0 {
+		return fmt.Sprintf("Import(%q)", i.Path)
+	}
+	return fmt.Sprintf("Import(%s from %q)", strings.Join(i.Names, ", "), i.Path)
+}
+
+// export a, b: the names a module makes available to its importers.
+type ExportStmt struct {
+	S     Span
+	Names []string
+}
+
+func (e *ExportStmt) NodeKind() string { return "ExportStmt" }
+func (e *ExportStmt) stmtNode()        {}
+func (e *ExportStmt) GetSpan() Span    { return e.S }
+func (e *ExportStmt) String() string   { return fmt.Sprintf("Export(%s)", strings.Join(e.Names, ", ")) }
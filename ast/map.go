@@ -2,9 +2,14 @@ package ast
 
 import "fmt"
 
+// MapEntry is one key: value pair of a map literal. A computed key,
+// {[prefix + id]: v}, is in KeyExpr and Key is then unused. A spread entry,
+// {base...}, has only Value: the map whose entries it copies.
 type MapEntry struct {
-	Key   string
-	Value Expr
+	Key     string
+	KeyExpr Expr
+	Value   Expr
+	Spread  bool
 }
 
 type MapLiteralExpr struct {
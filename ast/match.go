@@ -0,0 +1,38 @@
+package ast
+
+import "fmt"
+
+// MatchExpr is `match Subject case ... end`: the value of the first arm
+// whose pattern fits the subject.
+type MatchExpr struct {
+	S       Span
+	Subject Expr
+	Arms    []MatchArm
+}
+
+// MatchArm is one `case patterns [as name]: body`. An arm without patterns
+// is `case else`, which matches anything.
+type MatchArm struct {
+	S        Span
+	Patterns []MatchPattern
+	Bind     string // `as name` binds the subject for the body; "" if absent
+	Body     Expr
+}
+
+// MatchPattern is one comma-separated alternative of an arm:
+//
+//	value        equal to the subject
+//	low to high  between low and high, inclusive
+//	is kind      a value of that kind (number, string, ...) or record type
+type MatchPattern struct {
+	Value Expr // the value, or the low end of a range
+	High  Expr // the high end of a range; nil otherwise
+	Kind  string
+}
+
+func (m *MatchExpr) NodeKind() string { return "MatchExpr" }
+func (m *MatchExpr) exprNode()        {}
+func (m *MatchExpr) GetSpan() Span    { return m.S }
+func (m *MatchExpr) String() string {
+	return fmt.Sprintf("Match(%s, %d arms)", m.Subject.String(), len(m.Arms))
+}
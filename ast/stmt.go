@@ -1,6 +1,9 @@
 package ast
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type Stmt interface {
 	Node
@@ -9,15 +12,33 @@ type Stmt interface {
 	GetSpan() Span
 }
 
+// print a; tab(20); b;
+// Items print back to back; a trailing ';' keeps the cursor on the line.
 type PrintStmt struct {
-	S     Span
-	Value Expr
+	S      Span
+	Values []Expr
+	// Seps[k] is the separator written after Values[k]: ";" (nothing) or
+	// "," (a space). A trailing one keeps the cursor on the line.
+	Seps      []string
+	NoNewline bool
 }
 
 func (p *PrintStmt) NodeKind() string { return "PrintStmt" }
 func (p *PrintStmt) stmtNode()        {}
 func (p *PrintStmt) GetSpan() Span    { return p.S }
-func (p *PrintStmt) String() string   { return fmt.Sprintf("PrintStmt(%s)", p.Value.String()) }
+func (p *PrintStmt) String() string {
+	var b strings.Builder
+	for k, v := range p.Values {
+		b.WriteString(v.String())
+		if k < len(p.Seps) {
+			b.WriteString(p.Seps[k])
+			if k < len(p.Values)-1 {
+				b.WriteString(" ")
+			}
+		}
+	}
+	return fmt.Sprintf("PrintStmt(%s)", b.String())
+}
 
 type AssignStmt struct {
 	S     Span
@@ -33,19 +54,38 @@ func (a *AssignStmt) String() string {
 }
 
 // --- Arrays/Maps (index assignment) ---
-// a[i] = value   OR   m["k"] = value
+// a[i] = value   OR   m["k"] = value   OR   grid[y, x] = value
+// Target is the container expression being indexed (a, m, grid[y]).
 type IndexAssignStmt struct {
-	S     Span
-	Name  string
-	Index Expr
-	Value Expr
+	S      Span
+	Target Expr
+	Index  Expr
+	Value  Expr
 }
 
 func (x *IndexAssignStmt) NodeKind() string { return "IndexAssignStmt" }
 func (x *IndexAssignStmt) stmtNode()        {}
 func (x *IndexAssignStmt) GetSpan() Span    { return x.S }
 func (x *IndexAssignStmt) String() string {
-	return fmt.Sprintf("IndexAssign(%s[%s] = %s)", x.Name, x.Index.String(), x.Value.String())
+	return fmt.Sprintf("IndexAssign(%s[%s] = %s)", x.Target.String(), x.Index.String(), x.Value.String())
+}
+
+// p.x = expr
+type MemberAssignStmt struct {
+	S      Span
+	Target Expr
+	Name   string
+	Value  Expr
+	// Written in an option classic file, where Name matches a field in any
+	// case.
+	Classic bool
+}
+
+func (x *MemberAssignStmt) NodeKind() string { return "MemberAssignStmt" }
+func (x *MemberAssignStmt) stmtNode()        {}
+func (x *MemberAssignStmt) GetSpan() Span    { return x.S }
+func (x *MemberAssignStmt) String() string {
+	return fmt.Sprintf("MemberAssign(%s.%s = %s)", x.Target.String(), x.Name, x.Value.String())
 }
 
 // --- Expression statements ---
@@ -64,13 +104,24 @@ type IfStmt struct {
 	S         Span
 	Condition Expr
 	Then      []Stmt
+	ElseIfs   []ElseIfClause // tried in order when Condition is false
 	Else      []Stmt
 }
 
+// elseif cond (also spelled elif, or "else if" on one line)
+type ElseIfClause struct {
+	S         Span
+	Condition Expr
+	Body      []Stmt
+}
+
 func (i *IfStmt) NodeKind() string { return "IfStmt" }
 func (i *IfStmt) stmtNode()        {}
 func (i *IfStmt) GetSpan() Span    { return i.S }
 func (i *IfStmt) String() string {
+	if len(i.ElseIfs) > 0 {
+		return fmt.Sprintf("IfStmt(%s, then=%d, elseifs=%d, else=%d)", i.Condition.String(), len(i.Then), len(i.ElseIfs), len(i.Else))
+	}
 	return fmt.Sprintf("IfStmt(%s, then=%d, else=%d)", i.Condition.String(), len(i.Then), len(i.Else))
 }
 
@@ -87,6 +138,47 @@ func (w *WhileStmt) String() string {
 	return fmt.Sprintf("WhileStmt(%s, body=%d)", w.Condition.String(), len(w.Body))
 }
 
+// repeat (or do) ... until cond: the body runs at least once.
+type RepeatStmt struct {
+	S         Span
+	Body      []Stmt
+	Condition Expr
+}
+
+func (r *RepeatStmt) NodeKind() string { return "RepeatStmt" }
+func (r *RepeatStmt) stmtNode()        {}
+func (r *RepeatStmt) GetSpan() Span    { return r.S }
+func (r *RepeatStmt) String() string {
+	return fmt.Sprintf("RepeatStmt(body=%d, until %s)", len(r.Body), r.Condition.String())
+}
+
+// TryStmt is try ... [catch [name] ...] [finally ...] end.
+type TryStmt struct {
+	S        Span
+	Body     []Stmt
+	HasCatch bool
+	CatchVar string // "" when the catch clause names no variable
+	Catch    []Stmt
+	Finally  []Stmt
+}
+
+func (t *TryStmt) NodeKind() string { return "TryStmt" }
+func (t *TryStmt) stmtNode()        {}
+func (t *TryStmt) GetSpan() Span    { return t.S }
+func (t *TryStmt) String() string {
+	return fmt.Sprintf("TryStmt(body=%d, catch=%d, finally=%d)", len(t.Body), len(t.Catch), len(t.Finally))
+}
+
+type RaiseStmt struct {
+	S     Span
+	Value Expr
+}
+
+func (r *RaiseStmt) NodeKind() string { return "RaiseStmt" }
+func (r *RaiseStmt) stmtNode()        {}
+func (r *RaiseStmt) GetSpan() Span    { return r.S }
+func (r *RaiseStmt) String() string   { return fmt.Sprintf("RaiseStmt(%s)", r.Value.String()) }
+
 type ForStmt struct {
 	S     Span
 	Var   string
@@ -189,10 +281,14 @@ func (p *PrintHandleStmt) String() string {
 }
 
 type FunctionDecl struct {
-	S      Span
-	Name   string
-	Params []string
-	Body   []Stmt
+	S         Span
+	Name      string
+	Params    []string
+	Variadic  bool // the last param collects any extra args into an array
+	Body      []Stmt
+	Doc       string // comment block directly above the function line
+	Override  bool   // written "override function": replacing an earlier definition is intended
+	Generator bool   // the body yields: a call makes a generator instead of running it
 }
 
 func (f *FunctionDecl) NodeKind() string { return "FunctionDecl" }
@@ -202,6 +298,8 @@ func (f *FunctionDecl) String() string {
 	return fmt.Sprintf("Function(%s, params=%d, body=%d)", f.Name, len(f.Params), len(f.Body))
 }
 
+// return expr
+// A bare return (Value nil) ends the innermost gosub.
 type ReturnStmt struct {
 	S     Span
 	Value Expr
@@ -210,4 +308,237 @@ type ReturnStmt struct {
 func (r *ReturnStmt) NodeKind() string { return "ReturnStmt" }
 func (r *ReturnStmt) stmtNode()        {}
 func (r *ReturnStmt) GetSpan() Span    { return r.S }
-func (r *ReturnStmt) String() string   { return fmt.Sprintf("Return(%s)", r.Value.String()) }
+func (r *ReturnStmt) String() string {
+	if r.Value == nil {
+		return "Return"
+	}
+	return fmt.Sprintf("Return(%s)", r.Value.String())
+}
+
+// --- Declarations / directives ---
+// dim x
+// dim x = expr
+// dim grid(rows, cols) [= fill]
+type DimStmt struct {
+	S     Span
+	Name  string
+	Dims  []Expr // optional array dimensions
+	Value Expr   // optional (nil means null, or 0 for dimensioned arrays)
+}
+
+func (d *DimStmt) NodeKind() string { return "DimStmt" }
+func (d *DimStmt) stmtNode()        {}
+func (d *DimStmt) GetSpan() Span    { return d.S }
+func (d *DimStmt) String() string {
+	name := d.Name
+	if len(d.Dims) > 0 {
+		name = fmt.Sprintf("%s(dims=%d)", d.Name, len(d.Dims))
+	}
+	if d.Value == nil {
+		return fmt.Sprintf("Dim(%s)", name)
+	}
+	return fmt.Sprintf("Dim(%s = %s)", name, d.Value.String())
+}
+
+// type Point ... end: fields one per line (or comma separated), each with an
+// optional default, and methods declared as functions inside the block.
+type TypeDecl struct {
+	S        Span
+	Name     string
+	Fields   []string
+	Defaults []Expr // one per field; nil means the field starts as null
+	Methods  []*FunctionDecl
+}
+
+func (t *TypeDecl) NodeKind() string { return "TypeDecl" }
+func (t *TypeDecl) stmtNode()        {}
+func (t *TypeDecl) GetSpan() Span    { return t.S }
+func (t *TypeDecl) String() string {
+	return fmt.Sprintf("Type(%s, fields=%d)", t.Name, len(t.Fields))
+}
+
+// option explicit
+type OptionStmt struct {
+	S    Span
+	Name string
+}
+
+func (o *OptionStmt) NodeKind() string { return "OptionStmt" }
+func (o *OptionStmt) stmtNode()        {}
+func (o *OptionStmt) GetSpan() Span    { return o.S }
+func (o *OptionStmt) String() string   { return fmt.Sprintf("Option(%s)", o.Name) }
+
+// global x
+// global x, y
+// global x = expr
+type GlobalStmt struct {
+	S     Span
+	Names []string
+	Value Expr // optional; only with a single name
+}
+
+func (g *GlobalStmt) NodeKind() string { return "GlobalStmt" }
+func (g *GlobalStmt) stmtNode()        {}
+func (g *GlobalStmt) GetSpan() Span    { return g.S }
+func (g *GlobalStmt) String() string {
+	names := strings.Join(g.Names, ", ")
+	if g.Value == nil {
+		return fmt.Sprintf("Global(%s)", names)
+	}
+	return fmt.Sprintf("Global(%s = %s)", names, g.Value.String())
+}
+
+// let x
+// let x = expr
+type LetStmt struct {
+	S     Span
+	Name  string
+	Value Expr // optional (nil means null)
+}
+
+func (l *LetStmt) NodeKind() string { return "LetStmt" }
+func (l *LetStmt) stmtNode()        {}
+func (l *LetStmt) GetSpan() Span    { return l.S }
+func (l *LetStmt) String() string {
+	if l.Value == nil {
+		return fmt.Sprintf("Let(%s)", l.Name)
+	}
+	return fmt.Sprintf("Let(%s = %s)", l.Name, l.Value.String())
+}
+
+// start:
+// A label marks a place in a statement list for goto; it does nothing itself.
+type LabelStmt struct {
+	S    Span
+	Name string
+}
+
+func (l *LabelStmt) NodeKind() string { return "LabelStmt" }
+func (l *LabelStmt) stmtNode()        {}
+func (l *LabelStmt) GetSpan() Span    { return l.S }
+func (l *LabelStmt) String() string   { return fmt.Sprintf("Label(%s)", l.Name) }
+
+// goto start
+type GotoStmt struct {
+	S     Span
+	Label string
+}
+
+func (g *GotoStmt) NodeKind() string { return "GotoStmt" }
+func (g *GotoStmt) stmtNode()        {}
+func (g *GotoStmt) GetSpan() Span    { return g.S }
+func (g *GotoStmt) String() string   { return fmt.Sprintf("Goto(%s)", g.Label) }
+
+// gosub label: run from label until a bare return, then continue here.
+type GosubStmt struct {
+	S     Span
+	Label string
+}
+
+func (g *GosubStmt) NodeKind() string { return "GosubStmt" }
+func (g *GosubStmt) stmtNode()        {}
+func (g *GosubStmt) GetSpan() Span    { return g.S }
+func (g *GosubStmt) String() string   { return fmt.Sprintf("Gosub(%s)", g.Label) }
+
+// data 1, 2, "three", -4
+// Values are literals; a program's data statements form one list that read
+// takes from in source order.
+type DataStmt struct {
+	S      Span
+	Values []Expr
+}
+
+func (d *DataStmt) NodeKind() string { return "DataStmt" }
+func (d *DataStmt) stmtNode()        {}
+func (d *DataStmt) GetSpan() Span    { return d.S }
+func (d *DataStmt) String() string {
+	parts := make([]string, len(d.Values))
+	for k, v := range d.Values {
+		parts[k] = v.String()
+	}
+	return fmt.Sprintf("Data(%s)", strings.Join(parts, ", "))
+}
+
+// read a, b
+type ReadStmt struct {
+	S     Span
+	Names []string
+}
+
+func (r *ReadStmt) NodeKind() string { return "ReadStmt" }
+func (r *ReadStmt) stmtNode()        {}
+func (r *ReadStmt) GetSpan() Span    { return r.S }
+func (r *ReadStmt) String() string   { return fmt.Sprintf("Read(%s)", strings.Join(r.Names, ", ")) }
+
+// restore
+// restore label
+type RestoreStmt struct {
+	S     Span
+	Label string // "" means the first data value
+}
+
+func (r *RestoreStmt) NodeKind() string { return "RestoreStmt" }
+func (r *RestoreStmt) stmtNode()        {}
+func (r *RestoreStmt) GetSpan() Span    { return r.S }
+func (r *RestoreStmt) String() string {
+	if r.Label == "" {
+		return "Restore"
+	}
+	return fmt.Sprintf("Restore(%s)", r.Label)
+}
+
+// swap a, b
+// swap xs[i], xs[j]
+// Each side is an Identifier, IndexExpr or MemberExpr.
+type SwapStmt struct {
+	S     Span
+	Left  Expr
+	Right Expr
+}
+
+func (x *SwapStmt) NodeKind() string { return "SwapStmt" }
+func (x *SwapStmt) stmtNode()        {}
+func (x *SwapStmt) GetSpan() Span    { return x.S }
+func (x *SwapStmt) String() string {
+	return fmt.Sprintf("Swap(%s, %s)", x.Left.String(), x.Right.String())
+}
+
+// yield value
+// Hands one value to the for each (or spread) consuming the generator and
+// pauses the function until the next value is wanted.
+type YieldStmt struct {
+	S     Span
+	Value Expr
+}
+
+func (x *YieldStmt) NodeKind() string { return "YieldStmt" }
+func (x *YieldStmt) stmtNode()        {}
+func (x *YieldStmt) GetSpan() Span    { return x.S }
+func (x *YieldStmt) String() string   { return fmt.Sprintf("Yield(%s)", x.Value.String()) }
+
+// [a, b, ...rest] = xs
+// {name, age} = person
+// An array pattern takes the elements in order (Rest, if given, collects the
+// ones after Names); a map pattern takes the entries, or record fields, with
+// the same names.
+type DestructureStmt struct {
+	S     Span
+	Names []string
+	Rest  string
+	Map   bool
+	Value Expr
+}
+
+func (x *DestructureStmt) NodeKind() string { return "DestructureStmt" }
+func (x *DestructureStmt) stmtNode()        {}
+func (x *DestructureStmt) GetSpan() Span    { return x.S }
+func (x *DestructureStmt) String() string {
+	names := strings.Join(x.Names, ", ")
+	if x.Rest != "" {
+		names += ", ..." + x.Rest
+	}
+	if x.Map {
+		return fmt.Sprintf("Destructure({%s}, %s)", names, x.Value.String())
+	}
+	return fmt.Sprintf("Destructure([%s], %s)", names, x.Value.String())
+}
@@ -0,0 +1,154 @@
+package ast
+
+// Inspect walks the tree rooted at n in depth-first order, calling f for each
+// node. If f returns false, the children of that node are skipped. Optional
+// children that are nil are not visited.
+func Inspect(n Node, f func(Node) bool) {
+	if n == nil || !f(n) {
+		return
+	}
+
+	switch n := n.(type) {
+	// --- expressions ---
+	case *UnaryExpr:
+		inspectExpr(n.Right, f)
+	case *BinaryExpr:
+		inspectExpr(n.Left, f)
+		inspectExpr(n.Right, f)
+	case *CallExpr:
+		inspectExprs(n.Args, f)
+	case *CallValueExpr:
+		inspectExpr(n.Callee, f)
+		inspectExprs(n.Args, f)
+	case *FunctionLit:
+		Inspect(n.Fn, f)
+	case *ArrayLiteralExpr:
+		inspectExprs(n.Elements, f)
+	case *IndexExpr:
+		inspectExpr(n.Left, f)
+		inspectExpr(n.Index, f)
+	case *SliceExpr:
+		inspectExpr(n.Left, f)
+		inspectExpr(n.Start, f)
+		inspectExpr(n.End, f)
+	case *MemberExpr:
+		inspectExpr(n.Left, f)
+	case *MethodCallExpr:
+		inspectExpr(n.Object, f)
+		inspectExprs(n.Args, f)
+	case *MapLiteralExpr:
+		for _, e := range n.Entries {
+			inspectExpr(e.KeyExpr, f)
+			inspectExpr(e.Value, f)
+		}
+	case *SpreadExpr:
+		inspectExpr(n.Value, f)
+	case *RangeExpr:
+		inspectExpr(n.Start, f)
+		inspectExpr(n.End, f)
+	case *MatchExpr:
+		inspectExpr(n.Subject, f)
+		for _, arm := range n.Arms {
+			for _, pat := range arm.Patterns {
+				inspectExpr(pat.Value, f)
+				inspectExpr(pat.High, f)
+			}
+			inspectExpr(arm.Body, f)
+		}
+
+	// --- statements ---
+	case *PrintStmt:
+		inspectExprs(n.Values, f)
+	case *AssignStmt:
+		inspectExpr(n.Value, f)
+	case *MemberAssignStmt:
+		inspectExpr(n.Target, f)
+		inspectExpr(n.Value, f)
+	case *TypeDecl:
+		inspectExprs(n.Defaults, f)
+		for _, m := range n.Methods {
+			Inspect(m, f)
+		}
+	case *IndexAssignStmt:
+		inspectExpr(n.Target, f)
+		inspectExpr(n.Index, f)
+		inspectExpr(n.Value, f)
+	case *ExprStmt:
+		inspectExpr(n.Expr, f)
+	case *IfStmt:
+		inspectExpr(n.Condition, f)
+		inspectStmts(n.Then, f)
+		for _, c := range n.ElseIfs {
+			inspectExpr(c.Condition, f)
+			inspectStmts(c.Body, f)
+		}
+		inspectStmts(n.Else, f)
+	case *WhileStmt:
+		inspectExpr(n.Condition, f)
+		inspectStmts(n.Body, f)
+	case *RepeatStmt:
+		inspectStmts(n.Body, f)
+		inspectExpr(n.Condition, f)
+	case *TryStmt:
+		inspectStmts(n.Body, f)
+		inspectStmts(n.Catch, f)
+		inspectStmts(n.Finally, f)
+	case *RaiseStmt:
+		inspectExpr(n.Value, f)
+	case *ForStmt:
+		inspectExpr(n.Start, f)
+		inspectExpr(n.End, f)
+		inspectExpr(n.Step, f)
+		inspectStmts(n.Body, f)
+	case *ForEachStmt:
+		inspectExpr(n.Iterable, f)
+		inspectStmts(n.Body, f)
+	case *OpenStmt:
+		inspectExpr(n.Path, f)
+		inspectExpr(n.Mode, f)
+	case *PrintHandleStmt:
+		inspectExpr(n.Value, f)
+	case *FunctionDecl:
+		inspectStmts(n.Body, f)
+	case *ReturnStmt:
+		inspectExpr(n.Value, f)
+	case *DimStmt:
+		inspectExprs(n.Dims, f)
+		inspectExpr(n.Value, f)
+	case *GlobalStmt:
+		inspectExpr(n.Value, f)
+	case *LetStmt:
+		inspectExpr(n.Value, f)
+	case *DataStmt:
+		inspectExprs(n.Values, f)
+	case *SwapStmt:
+		inspectExpr(n.Left, f)
+		inspectExpr(n.Right, f)
+	case *DestructureStmt:
+		inspectExpr(n.Value, f)
+	case *YieldStmt:
+		inspectExpr(n.Value, f)
+	}
+}
+
+// inspectExpr skips optional expressions that were left nil.
+func inspectExpr(e Expr, f func(Node) bool) {
+	if e == nil {
+		return
+	}
+	Inspect(e, f)
+}
+
+func inspectExprs(list []Expr, f func(Node) bool) {
+	for _, e := range list {
+		inspectExpr(e, f)
+	}
+}
+
+func inspectStmts(list []Stmt, f func(Node) bool) {
+	for _, s := range list {
+		if s != nil {
+			Inspect(s, f)
+		}
+	}
+}
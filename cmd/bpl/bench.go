@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runBenchCommand implements `bplplus bench [-n N] [-run substr] <file.bpl>`:
+// it loads the file and benchmarks every zero-arg function named bench_*.
+func runBenchCommand(args []string) int {
+	n := 0
+	filter := ""
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		flag := args[0]
+		if len(args) < 2 {
+			fmt.Fprintf(os.Stderr, "bench: %s needs a value\n", flag)
+			return 2
+		}
+		switch flag {
+		case "-n":
+			v, err := strconv.Atoi(args[1])
+			if err != nil || v <= 0 {
+				fmt.Fprintln(os.Stderr, "bench: -n must be a positive integer")
+				return 2
+			}
+			n = v
+		case "-run":
+			filter = args[1]
+		default:
+			fmt.Fprintf(os.Stderr, "bench: unknown flag %s\n", flag)
+			return 2
+		}
+		args = args[2:]
+	}
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: bplplus bench [-n iterations] [-run substr] <file.bpl>")
+		return 2
+	}
+
+	in, err := loadScript(args[0])
+	if err != nil {
+		return 1
+	}
+
+	start := time.Now()
+	failed, ran := false, 0
+	for _, name := range in.FuncNames() {
+		if !strings.HasPrefix(name, "bench_") || !strings.Contains(name, filter) {
+			continue
+		}
+		ran++
+		res, err := in.Bench(name, n)
+		if err != nil {
+			fmt.Printf("%-30s FAIL\n", name)
+			stderrPrinter(false).Error(args[0], err)
+			failed = true
+			continue
+		}
+		fmt.Printf("%-30s %10d iterations %14d ns/op  (min %d, median %d, max %d)\n",
+			name, res.Iterations, res.Mean().Nanoseconds(),
+			res.Min.Nanoseconds(), res.Median.Nanoseconds(), res.Max.Nanoseconds())
+	}
+	if ran == 0 {
+		fmt.Println("no bench_* functions found")
+	}
+
+	status := "ok"
+	if failed {
+		status = "FAIL"
+	}
+	fmt.Printf("%s\t%s\t%.3fs\n", status, args[0], time.Since(start).Seconds())
+	if failed {
+		return 1
+	}
+	return 0
+}
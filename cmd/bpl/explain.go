@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"bpl-plus/codes"
+)
+
+// runExplainCommand implements `bplplus explain [code]`: with a code it prints
+// the long explanation for that diagnostic, without one it lists every code.
+func runExplainCommand(args []string) int {
+	if len(args) == 0 {
+		for _, e := range codes.All() {
+			fmt.Printf("%s  %s\n", e.Code, e.Title)
+		}
+		fmt.Println()
+		fmt.Println("Run 'bplplus explain <code>' for details.")
+		return 0
+	}
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: bplplus explain [code]")
+		return 2
+	}
+	e, ok := codes.Lookup(args[0])
+	if !ok {
+		fmt.Fprintf(os.Stderr, "explain: no explanation for %q (run 'bplplus explain' to list codes)\n", args[0])
+		return 1
+	}
+	fmt.Printf("%s: %s\n\n%s\n", e.Code, e.Title, e.Text)
+	return 0
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"bpl-plus/ast"
+	"bpl-plus/lexer"
+	"bpl-plus/parser"
+)
+
+// fuzzTimeout bounds a single lex+parse; anything slower is reported as a hang.
+const fuzzTimeout = 2 * time.Second
+
+// Fragments spliced into inputs by the mutator: keywords, operators and the
+// bits of syntax the parser has to balance.
+var fuzzFragments = []string{
+	"if ", "else", "end", "while ", "for ", " to ", " step ", "for each ", " in ",
+	"function ", "return ", "try", "catch e", "finally", "raise ", "print ", "print #1, ", "open #1, ", "close #1",
+	"dim ", "global ", "let ", "export ", "option explicit", "option checked", "option classic", "import ", "break", "continue", "goto ", "gosub ", "start:", "data ", "read ", "restore", "swap ", "match ", "case ", "case else: ", "[a, b] = ", "{a} = ", "..", "1..3", "x...", "yield ",
+	"(", ")", "[", "]", "{", "}", ",", ":", "...", "=", "==", "!=", "<>", "<=", ">=",
+	"+", "-", "*", "/", "%", " mod ", " and ", " or ", "not ", "#",
+	"\"", "\"str\"", `"""`, "\n", " ", "x", "y1", "0", "3.14", "true", "false", "null",
+	" ?? ", "]?", "[1:]", ":", " in ", " not in ", "override ", "math.", ".", "type P\n", ".x", ".f(1)", "self",
+}
+
+// Inputs used when no files are given and there is no examples/ folder.
+var fuzzBuiltinSeeds = []string{
+	"x = 1\nprint x + 2 * 3\n",
+	"function f(a, b)\n  return a % b\nend\nprint f(7, 3)\n",
+	"if x < 3\n  print \"small\"\nelse\n  print \"big\"\nend\n",
+	"for i = 1 to 10 step 2\n  print i\nend\n",
+	"for each v, i in [1, 2, 3]\n  print v\nend\n",
+	"m = {\"a\": 1, \"b\": [1, 2]}\nm[\"c\"] = m[\"a\"]\n",
+	"dim grid(2, 3) = 0\noption explicit\n",
+	"open #1, \"out.txt\", \"w\"\nprint #1, \"hi\"\nclose #1\n",
+}
+
+// runFuzzCommand implements `bplplus fuzz [-n N] [-seed S] [-o dir] [files...]`:
+// it mutates the given sources (default: examples/*.bpl) and feeds them to the
+// lexer and parser, checking that neither panics, hangs, or produces spans
+// outside the input. Failing inputs are written to the -o directory.
+func runFuzzCommand(args []string) int {
+	iterations := 10000
+	seed := time.Now().UnixNano()
+	outDir := "fuzz-crashers"
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		flag := args[0]
+		if len(args) < 2 {
+			fmt.Fprintf(os.Stderr, "fuzz: %s needs a value\n", flag)
+			return 2
+		}
+		switch flag {
+		case "-n":
+			v, err := strconv.Atoi(args[1])
+			if err != nil || v <= 0 {
+				fmt.Fprintln(os.Stderr, "fuzz: -n must be a positive integer")
+				return 2
+			}
+			iterations = v
+		case "-seed":
+			v, err := strconv.ParseInt(args[1], 10, 64)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "fuzz: -seed must be an integer")
+				return 2
+			}
+			seed = v
+		case "-o":
+			outDir = args[1]
+		default:
+			fmt.Fprintf(os.Stderr, "fuzz: unknown flag %s\n", flag)
+			return 2
+		}
+		args = args[2:]
+	}
+
+	corpus, err := fuzzCorpus(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fuzz: "+err.Error())
+		return 1
+	}
+
+	fmt.Printf("fuzzing lexer/parser: %d inputs, %d iterations, seed %d\n", len(corpus), iterations, seed)
+	rng := rand.New(rand.NewSource(seed))
+	start := time.Now()
+	failures := 0
+
+	// The seeds themselves must pass before their mutations mean anything.
+	for _, src := range corpus {
+		if problem := fuzzCheck(src); problem != "" {
+			failures++
+			reportFuzzFailure(outDir, failures, src, problem)
+		}
+	}
+	for k := 0; k < iterations; k++ {
+		src := fuzzMutate(rng, corpus)
+		if problem := fuzzCheck(src); problem != "" {
+			failures++
+			reportFuzzFailure(outDir, failures, src, problem)
+		}
+	}
+
+	status := "ok"
+	if failures > 0 {
+		status = "FAIL"
+	}
+	fmt.Printf("%s\t%d failures\t%.3fs\n", status, failures, time.Since(start).Seconds())
+	if failures > 0 {
+		return 1
+	}
+	return 0
+}
+
+// fuzzCorpus reads the seed inputs: the given files, else examples/*.bpl,
+// else a few built-in snippets.
+func fuzzCorpus(files []string) ([]string, error) {
+	if len(files) == 0 {
+		files, _ = filepath.Glob(filepath.Join("examples", "*.bpl"))
+	}
+	var corpus []string
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		corpus = append(corpus, string(b))
+	}
+	if len(corpus) == 0 {
+		corpus = fuzzBuiltinSeeds
+	}
+	return corpus, nil
+}
+
+// fuzzMutate picks a corpus entry and applies one to four random edits.
+func fuzzMutate(rng *rand.Rand, corpus []string) string {
+	b := []byte(corpus[rng.Intn(len(corpus))])
+	for edits := 1 + rng.Intn(4); edits > 0; edits-- {
+		pos := 0
+		if len(b) > 0 {
+			pos = rng.Intn(len(b) + 1)
+		}
+		switch rng.Intn(6) {
+		case 0: // insert a fragment
+			frag := fuzzFragments[rng.Intn(len(fuzzFragments))]
+			b = append(b[:pos], append([]byte(frag), b[pos:]...)...)
+		case 1: // delete a range
+			if pos < len(b) {
+				end := min(len(b), pos+1+rng.Intn(16))
+				b = append(b[:pos], b[end:]...)
+			}
+		case 2: // replace a byte with a random one (may produce invalid UTF-8)
+			if pos < len(b) {
+				b[pos] = byte(rng.Intn(256))
+			}
+		case 3: // truncate
+			b = b[:pos]
+		case 4: // splice in part of another input
+			other := corpus[rng.Intn(len(corpus))]
+			if len(other) > 0 {
+				from := rng.Intn(len(other))
+				to := min(len(other), from+1+rng.Intn(64))
+				b = append(b[:pos], append([]byte(other[from:to]), b[pos:]...)...)
+			}
+		case 5: // duplicate a line
+			lines := strings.Split(string(b), "\n")
+			k := rng.Intn(len(lines))
+			lines = append(lines[:k+1], lines[k:]...)
+			b = []byte(strings.Join(lines, "\n"))
+		}
+	}
+	return string(b)
+}
+
+// fuzzCheck lexes and parses src and returns a description of the first
+// problem found, or "" if the input was handled cleanly (a parse error is a
+// clean result).
+func fuzzCheck(src string) string {
+	done := make(chan string, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Sprintf("panic: %v\n%s", r, debug.Stack())
+			}
+		}()
+		done <- fuzzLexAndParse(src)
+	}()
+
+	select {
+	case problem := <-done:
+		return problem
+	case <-time.After(fuzzTimeout):
+		// The goroutine is leaked; the run is already failing.
+		return fmt.Sprintf("hang: lex+parse took longer than %s", fuzzTimeout)
+	}
+}
+
+func fuzzLexAndParse(src string) string {
+	lines := strings.Count(src, "\n") + 1
+	inSpan := func(line, col int) bool {
+		return line >= 1 && line <= lines && col >= 0
+	}
+
+	// Every character produces at most one token, plus EOF.
+	limit := len([]rune(src)) + 1
+	lx := lexer.New(src)
+	for n := 0; ; n++ {
+		if n > limit {
+			return fmt.Sprintf("lexer: more than %d tokens without reaching EOF", limit)
+		}
+		tok := lx.NextToken()
+		if !inSpan(tok.Line, tok.Col) {
+			return fmt.Sprintf("lexer: token %s %q at %d:%d is outside the input (%d lines)", tok.Type, tok.Lexeme, tok.Line, tok.Col, lines)
+		}
+		if tok.Type == lexer.EOF {
+			break
+		}
+	}
+
+	prog, err := parser.New(lexer.New(src)).ParseProgram()
+	if err != nil {
+		return ""
+	}
+	problem := ""
+	for _, stmt := range prog {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			if problem != "" {
+				return false
+			}
+			if s, ok := ast.SpanOf(n); ok && !inSpan(s.Line, s.Col) {
+				problem = fmt.Sprintf("parser: %s at %d:%d is outside the input (%d lines)", n.NodeKind(), s.Line, s.Col, lines)
+			}
+			return true
+		})
+	}
+	return problem
+}
+
+func reportFuzzFailure(outDir string, n int, src, problem string) {
+	fmt.Printf("--- FAIL #%d: %s\n", n, firstLine(problem))
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		fmt.Fprintln(os.Stderr, "fuzz: "+err.Error())
+		return
+	}
+	base := filepath.Join(outDir, fmt.Sprintf("crash-%d", n))
+	if err := os.WriteFile(base+".bpl", []byte(src), 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, "fuzz: "+err.Error())
+		return
+	}
+	_ = os.WriteFile(base+".txt", []byte(problem+"\n"), 0o644)
+	fmt.Printf("    input saved to %s.bpl\n", base)
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
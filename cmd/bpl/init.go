@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// runInitCommand implements `bplplus init [dir]`: it creates a starter
+// project laid out the way imports and `bplplus test` expect (modules in
+// lib/ next to main.bpl, tests as *_test.bpl files with test_* functions).
+// Without a dir the current directory is used. Existing files are never
+// overwritten.
+func runInitCommand(args []string) int {
+	if len(args) > 1 {
+		fmt.Fprintln(os.Stderr, "Usage: bplplus init [dir]")
+		return 2
+	}
+	dir := "."
+	if len(args) == 1 {
+		dir = args[0]
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "init: %v\n", err)
+		return 1
+	}
+	name := filepath.Base(abs)
+
+	files := starterFiles(name)
+	for _, f := range files {
+		path := filepath.Join(dir, f.path)
+		if _, err := os.Stat(path); err == nil {
+			fmt.Fprintf(os.Stderr, "init: %s already exists; nothing was written\n", path)
+			return 1
+		}
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			fmt.Fprintf(os.Stderr, "init: %v\n", err)
+			return 1
+		}
+		if err := os.WriteFile(path, []byte(f.text), 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "init: %v\n", err)
+			return 1
+		}
+		fmt.Printf("created %s\n", path)
+	}
+
+	fmt.Println()
+	fmt.Printf("Next:\n  cd %s\n  bplplus run .\n  bplplus test tests/greet_test.bpl\n", dir)
+	return 0
+}
+
+type starterFile struct {
+	path string
+	text string
+}
+
+func starterFiles(name string) []starterFile {
+	return []starterFile{
+		{"bpl.mod", fmt.Sprintf(`# Project manifest.
+name %s
+# entry main.bpl    the file "bplplus run ." starts
+# path vendor       more directories to find imports in
+`, name)},
+		{"main.bpl", `# Entry point: run with "bplplus main.bpl".
+# Imports are found next to this file or in lib/.
+import "greet"
+
+print greet("world")
+`},
+		{"lib/greet.bpl", `export greet
+
+# greet returns a friendly greeting for name.
+function greet(name)
+  return "Hello, " + name + "!"
+end
+`},
+		{"tests/greet_test.bpl", `# Run with "bplplus test tests/greet_test.bpl".
+# Every function named test_* is a test; assert() fails it.
+import "../lib/greet"
+
+function test_greet()
+  assert(greet("Ada") == "Hello, Ada!", "greeting")
+end
+`},
+		{".gitignore", `# bplplus fuzz output
+/fuzz-crashers/
+`},
+		{".editorconfig", `root = true
+
+[*.bpl]
+indent_style = space
+indent_size = 2
+end_of_line = lf
+insert_final_newline = true
+trim_trailing_whitespace = true
+`},
+	}
+}
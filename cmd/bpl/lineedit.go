@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+
+	"bpl-plus/interpreter"
+	"github.com/chzyer/readline"
+)
+
+// replEditor is the REPL's line editor while the REPL is running. Scripts
+// started with :load reuse it rather than opening a second editor on stdin.
+var replEditor *readline.Instance
+
+// newLineEditor builds the readline setup shared by the REPL and by input()
+// in interactive scripts. An empty historyFile keeps history in memory only.
+func newLineEditor(prompt, historyFile string) (*readline.Instance, error) {
+	return readline.NewEx(&readline.Config{
+		Prompt:                 prompt,
+		HistoryFile:            historyFile,
+		InterruptPrompt:        "^C",
+		EOFPrompt:              "exit",
+		HistorySearchFold:      true,
+		DisableAutoSaveHistory: false,
+	})
+}
+
+// editorReader adapts a readline instance to the interpreter's input hook.
+type editorReader struct {
+	rl *readline.Instance
+}
+
+func lineReaderFor(rl *readline.Instance) interpreter.LineReader {
+	return editorReader{rl: rl}
+}
+
+func (r editorReader) ReadLine(prompt string) (string, error) {
+	r.rl.SetPrompt(prompt)
+	return r.rl.Readline()
+}
+
+func (r editorReader) ReadSecret(prompt string) (string, error) {
+	b, err := r.rl.ReadPassword(prompt)
+	return string(b), err
+}
+
+func stdinIsTerminal() bool {
+	return readline.IsTerminal(int(os.Stdin.Fd()))
+}
+
+func stderrIsTerminal() bool {
+	return readline.IsTerminal(int(os.Stderr.Fd()))
+}
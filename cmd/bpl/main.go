@@ -20,25 +20,54 @@ func main() {
 		return
 	}
 
+	switch args[0] {
+	case "bench":
+		os.Exit(runBenchCommand(args[1:]))
+	case "test":
+		os.Exit(runTestCommand(args[1:]))
+	case "fuzz":
+		os.Exit(runFuzzCommand(args[1:]))
+	case "explain":
+		os.Exit(runExplainCommand(args[1:]))
+	case "init":
+		os.Exit(runInitCommand(args[1:]))
+	case "syntaxdef":
+		os.Exit(runSyntaxdefCommand(args[1:]))
+	}
+
 	// Compatibility: `bplplus run file.bpl`
 	if len(args) >= 2 && args[0] == "run" {
 		args = args[1:]
 	}
 
-	if len(args) != 1 {
-		fmt.Fprintln(os.Stderr, "Usage:")
-		fmt.Fprintln(os.Stderr, "  bplplus <file.bpl>")
-		fmt.Fprintln(os.Stderr, "  bplplus run <file.bpl>")
-		fmt.Fprintln(os.Stderr, "  bplplus           # REPL")
+	opts, args, err := parseRunOptions(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		args = nil
+	}
+
+	if len(args) < 1 || args[0] == "run" {
+		printUsage()
 		os.Exit(2)
 	}
 
+	// Everything after the script name belongs to the script (see args()).
 	filename := args[0]
+	opts.scriptArgs = args[1:]
 	abs, err := filepath.Abs(filename)
 	if err == nil {
 		filename = abs
 	}
 
+	// A directory is a project: run its entry file (see bpl.mod).
+	if info, err := os.Stat(filename); err == nil && info.IsDir() {
+		filename, opts.importRoots, err = projectEntry(filename)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+	}
+
 	srcBytes, err := os.ReadFile(filename)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to read file: %s\n", err.Error())
@@ -53,16 +82,89 @@ func main() {
 		_ = os.Chdir(dir)
 	}
 
-	if err := runSource(filename, src); err != nil {
+	if err := runSource(filename, src, opts); err != nil {
 		// runSource should already print formatted errors if your interpreter does that.
-		// But we still exit non-zero.
+		// But we still exit non-zero (or with the script's own exit code).
+		if code, ok := exitCode(err); ok {
+			os.Exit(code)
+		}
 		os.Exit(1)
 	}
 }
 
+// runOptions are the CLI flags given before the script name.
+type runOptions struct {
+	scriptArgs  []string
+	crashReport string // --crash-report: write a JSON crash dump here on runtime errors
+	stats       bool   // --stats: print resource usage when the program ends
+
+	jsonDiagnostics bool // --json-diagnostics: errors and warnings as JSON lines on stderr
+	offline         bool // --offline: URL imports only from bpl.lock and the download cache
+	classic         bool // --classic: line numbers are labels (GOTO 100), REM comments
+
+	importRoots []string // from the project's bpl.mod path lines (bplplus run dir)
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage:")
+	fmt.Fprintln(os.Stderr, "  bplplus [options] <file.bpl> [args...]")
+	fmt.Fprintln(os.Stderr, "  bplplus run [options] <file.bpl> [args...]")
+	fmt.Fprintln(os.Stderr, "  bplplus run [options] <dir> [args...]           # run a project's main.bpl (or bpl.mod entry)")
+	fmt.Fprintln(os.Stderr, "  bplplus test [-update] [-run substr] <file.bpl> # run test_* functions")
+	fmt.Fprintln(os.Stderr, "  bplplus bench [-n N] [-run substr] <file.bpl>   # run bench_* functions")
+	fmt.Fprintln(os.Stderr, "  bplplus fuzz [-n N] [-seed S] [-o dir] [files...] # fuzz the lexer and parser")
+	fmt.Fprintln(os.Stderr, "  bplplus explain [code]                          # explain an error code such as E0203")
+	fmt.Fprintln(os.Stderr, "  bplplus init [dir]                              # create a starter project")
+	fmt.Fprintln(os.Stderr, "  bplplus syntaxdef --target vscode|vim|sublime [--out dir] [--force] # editor highlighting files")
+	fmt.Fprintln(os.Stderr, "  bplplus           # REPL")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Options:")
+	fmt.Fprintln(os.Stderr, "  --crash-report <file.json>   write error, stack and variables to JSON if the script crashes")
+	fmt.Fprintln(os.Stderr, "  --stats                      print time, statements run, peak sizes and allocations at exit")
+	fmt.Fprintln(os.Stderr, "  --json-diagnostics           report errors and warnings as JSON lines (file, span, severity, code, message)")
+	fmt.Fprintln(os.Stderr, "  --offline                    never download URL imports; use the copies pinned in bpl.lock")
+	fmt.Fprintln(os.Stderr, "  --classic                    run a line-numbered listing: 10 PRINT ..., GOTO 10, GOSUB 100, REM")
+}
+
+// parseRunOptions consumes leading --options and returns the remaining args
+// (script name first). Options after the script name belong to the script.
+func parseRunOptions(args []string) (runOptions, []string, error) {
+	var opts runOptions
+	for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+		name, value, hasValue := strings.Cut(args[0], "=")
+		args = args[1:]
+
+		switch name {
+		case "--crash-report":
+			if !hasValue {
+				if len(args) == 0 {
+					return opts, nil, fmt.Errorf("%s needs a file path", name)
+				}
+				value, args = args[0], args[1:]
+			}
+			// Resolve now: main changes directory to the script's folder.
+			if abs, err := filepath.Abs(value); err == nil {
+				value = abs
+			}
+			opts.crashReport = value
+		case "--stats":
+			opts.stats = true
+		case "--json-diagnostics":
+			opts.jsonDiagnostics = true
+		case "--offline":
+			opts.offline = true
+		case "--classic":
+			opts.classic = true
+		default:
+			return opts, nil, fmt.Errorf("unknown option %s", name)
+		}
+	}
+	return opts, args, nil
+}
+
 // runSource is the ONLY place you should need to adapt names if your package APIs differ.
 // Keep everything else stable.
-func runSource(filename, src string) error {
+func runSource(filename, src string, opts runOptions) error {
 	// If your interpreter expects source lines for caret rendering,
 	// keep this split exactly as-is.
 	sourceLines := splitLinesKeepIndex(src)
@@ -79,7 +181,7 @@ func runSource(filename, src string) error {
 	// return in.Exec(program)
 
 	// For now we call a helper that you will wire to your actual pipeline.
-	return compileAndRun(filename, src, sourceLines)
+	return compileAndRun(filename, src, sourceLines, opts)
 
 	// --- ADAPTER POINTS END ---
 }
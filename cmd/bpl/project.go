@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const manifestName = "bpl.mod"
+
+// manifest is a project's bpl.mod: one directive per line, # comments.
+//
+//	name myapp
+//	entry src/app.bpl   # the file `bplplus run .` starts (default main.bpl)
+//	path lib vendor     # extra import roots, relative to the manifest
+type manifest struct {
+	name  string
+	entry string
+	paths []string
+}
+
+// readManifest reads dir/bpl.mod. A directory without one gets the defaults.
+func readManifest(dir string) (manifest, error) {
+	m := manifest{entry: "main.bpl"}
+	path := filepath.Join(dir, manifestName)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return m, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		directive, values := fields[0], fields[1:]
+		if len(values) == 0 {
+			return m, fmt.Errorf("%s:%d: %s needs a value", path, n, directive)
+		}
+		switch directive {
+		case "name":
+			m.name = values[0]
+		case "entry":
+			m.entry = values[0]
+		case "path":
+			for _, p := range values {
+				m.paths = append(m.paths, filepath.Join(dir, p))
+			}
+		default:
+			return m, fmt.Errorf("%s:%d: unknown directive %q (expected name, entry or path)", path, n, directive)
+		}
+	}
+	return m, sc.Err()
+}
+
+// projectEntry resolves `bplplus run dir` to the script to start and the
+// import roots from the project's manifest.
+func projectEntry(dir string) (string, []string, error) {
+	m, err := readManifest(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	entry := filepath.Join(dir, m.entry)
+	if _, err := os.Stat(entry); err != nil {
+		if _, statErr := os.Stat(filepath.Join(dir, manifestName)); statErr == nil {
+			return "", nil, fmt.Errorf("%s: entry %s not found", filepath.Join(dir, manifestName), m.entry)
+		}
+		return "", nil, fmt.Errorf("%s has no %s (add one, or a bpl.mod with an entry line)", dir, m.entry)
+	}
+	return entry, m.paths, nil
+}
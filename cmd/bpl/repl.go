@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 
@@ -19,14 +20,7 @@ func runREPL() error {
 		histPath = filepath.Join(home, ".bplplus_history")
 	}
 
-	rl, err := readline.NewEx(&readline.Config{
-		Prompt:                 "bpl> ",
-		HistoryFile:            histPath,
-		InterruptPrompt:        "^C",
-		EOFPrompt:              "exit",
-		HistorySearchFold:      true,
-		DisableAutoSaveHistory: false,
-	})
+	rl, err := newLineEditor("bpl> ", histPath)
 	if err != nil {
 		return err
 	}
@@ -40,9 +34,23 @@ func runREPL() error {
 
 	// ✅ Single interpreter for the whole REPL session (stateful)
 	session := interpreter.New()
+	// input() in the REPL shares the same line editor
+	session.SetLineReader(lineReaderFor(rl))
+	session.SetInteractive(true)
+	diags := stderrPrinter(false)
+	session.SetWarningHandler(diags.Warning)
+	replEditor = rl
+	defer func() { replEditor = nil }()
+	defer func() {
+		if err := session.RunExitHooks(); err != nil {
+			diags.Error("<repl>", err)
+		}
+	}()
 
 	var buf strings.Builder
 	depth := 0
+	brackets := 0      // ( [ { still open: a literal or call continues
+	inHeredoc := false // inside an unfinished """ string
 	chunk := 0
 
 	// Paste mode state
@@ -53,7 +61,7 @@ func runREPL() error {
 		if pasteMode {
 			rl.SetPrompt(pastePrompt())
 		} else {
-			rl.SetPrompt(replPrompt(depth))
+			rl.SetPrompt(replPrompt(depth + brackets))
 		}
 
 		line, err := rl.Readline()
@@ -69,6 +77,8 @@ func runREPL() error {
 			if buf.Len() > 0 || depth > 0 {
 				buf.Reset()
 				depth = 0
+				brackets = 0
+				inHeredoc = false
 				fmt.Println("^C (buffer cleared)")
 			}
 			continue
@@ -100,7 +110,9 @@ func runREPL() error {
 
 				chunk++
 				filename := replChunkFilename(chunk)
-				_ = compileAndRunWith(session, filename, src)
+				if _, exited := exitCode(compileAndRunWith(session, filename, src)); exited {
+					return nil
+				}
 				continue
 			}
 
@@ -125,7 +137,9 @@ func runREPL() error {
 
 				chunk++
 				filename := replChunkFilename(chunk)
-				_ = compileAndRunWith(session, filename, src)
+				if _, exited := exitCode(compileAndRunWith(session, filename, src)); exited {
+					return nil
+				}
 				continue
 			}
 
@@ -141,8 +155,9 @@ func runREPL() error {
 		if depth == 0 && buf.Len() == 0 && strings.HasPrefix(trim, ":") {
 			handled, cmdErr, shouldExit := handleREPLCommand(trim, &buf, &depth, &pasteMode, &pasteBuf, session)
 			if handled {
-				if cmdErr != nil {
-					fmt.Fprintln(os.Stderr, cmdErr.Error())
+				// exit() inside a :load'ed script just ends that script
+				if _, exited := exitCode(cmdErr); cmdErr != nil && !exited {
+					diags.Error("<repl>", cmdErr)
 				}
 				if shouldExit {
 					return nil
@@ -155,10 +170,19 @@ func runREPL() error {
 		buf.WriteString(line)
 		buf.WriteString("\n")
 
+		// Lines inside a """ string are text, not code.
+		if strings.Count(line, `"""`)%2 == 1 {
+			inHeredoc = !inHeredoc
+		}
+		if inHeredoc {
+			continue
+		}
+
 		// Update depth heuristic for multi-line blocks.
 		depth = updateDepth(depth, trim)
+		brackets = max(brackets+bracketBalance(line), 0)
 
-		if depth > 0 {
+		if depth > 0 || brackets > 0 {
 			continue
 		}
 
@@ -171,7 +195,9 @@ func runREPL() error {
 
 		chunk++
 		filename := replChunkFilename(chunk)
-		_ = compileAndRunWith(session, filename, src)
+		if _, exited := exitCode(compileAndRunWith(session, filename, src)); exited {
+			return nil
+		}
 	}
 }
 
@@ -229,6 +255,7 @@ func handleREPLCommand(
 		fmt.Println("  :paste              Start paste mode (end with '.' or :endpaste)")
 		fmt.Println("  :vars               Show global variables (REPL session)")
 		fmt.Println("  :funcs              Show user-defined functions (REPL session)")
+		fmt.Println("  :undef <name>       Remove a user-defined function (REPL session)")
 		fmt.Println("  :modules            Show module load state (REPL session)")
 		fmt.Println()
 		fmt.Println("Paste mode controls:")
@@ -283,7 +310,7 @@ func handleREPLCommand(
 		}
 
 		// Load runs like the CLI: fresh interpreter for the file
-		return true, runSource(path, string(b)), false
+		return true, runSource(path, string(b), runOptions{}), false
 
 	case cmd == ":reset":
 		buf.Reset()
@@ -330,6 +357,17 @@ func handleREPLCommand(
 		}
 		return true, nil, false
 
+	case cmd == ":undef" || strings.HasPrefix(cmd, ":undef "):
+		name := strings.TrimSpace(strings.TrimPrefix(cmd, ":undef"))
+		if name == "" {
+			return true, fmt.Errorf("Usage: :undef <name>"), false
+		}
+		if !session.Undefine(name) {
+			return true, fmt.Errorf("No user function named %q", name), false
+		}
+		fmt.Printf("(%s removed)\n", name)
+		return true, nil, false
+
 	case cmd == ":modules":
 		loading, loaded := session.ModulesSnapshot()
 		if len(loading) == 0 && len(loaded) == 0 {
@@ -371,7 +409,7 @@ func updateDepth(depth int, trimmed string) int {
 		return depth + 1
 	}
 
-	if low == "end" {
+	if low == "end" || strings.HasPrefix(low, "until ") {
 		if depth > 0 {
 			return depth - 1
 		}
@@ -388,9 +426,93 @@ func updateDepth(depth int, trimmed string) int {
 func isBlockOpener(low string) bool {
 	// Explicitly list block headers (readability, and easier future tweaks).
 	// Note: "for " catches both classic for-loops and "for each ...".
-	return strings.HasPrefix(low, "if ") ||
+	// A one-line if closes with its own end: if done return end.
+	return strings.HasPrefix(low, "if ") && !strings.HasSuffix(stripComment(low), " end") ||
 		strings.HasPrefix(low, "while ") ||
 		strings.HasPrefix(low, "for each ") ||
 		strings.HasPrefix(low, "for ") ||
-		strings.HasPrefix(low, "function ")
+		strings.HasPrefix(low, "function ") ||
+		strings.HasPrefix(low, "override function ") ||
+		low == "repeat" || low == "do" || low == "try" ||
+		isTypeHeader(low) ||
+		opensInline(low)
+}
+
+// bracketBalance counts the ( [ { a line opens minus those it closes,
+// ignoring strings and comments.
+func bracketBalance(line string) int {
+	n := 0
+	inString := false
+	for k := 0; k < len(line); k++ {
+		c := line[k]
+		switch {
+		case inString:
+			if c == '\\' {
+				k++
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '#' && (k+1 >= len(line) || line[k+1] < '0' || line[k+1] > '9'):
+			return n
+		case c == '(' || c == '[' || c == '{':
+			n++
+		case c == ')' || c == ']' || c == '}':
+			n--
+		}
+	}
+	return n
+}
+
+// stripComment drops a trailing comment and the space before it. A '#'
+// followed by a digit is a file handle, not a comment.
+func stripComment(line string) string {
+	inString := false
+	for k := 0; k < len(line); k++ {
+		c := line[k]
+		switch {
+		case inString:
+			if c == '\\' {
+				k++
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '#' && (k+1 >= len(line) || line[k+1] < '0' || line[k+1] > '9'):
+			return strings.TrimRight(line[:k], " \t")
+		}
+	}
+	return line
 }
+
+// isTypeHeader reports a `type Name` line (but not an assignment to a
+// variable called type).
+func isTypeHeader(low string) bool {
+	f := strings.Fields(low)
+	return len(f) == 2 && f[0] == "type"
+}
+
+// opensInline reports a line like `f = function(x)` or `g = match score`
+// whose lambda or match continues on the next lines (one-line ones close with
+// their own `end`).
+func opensInline(low string) bool {
+	opens := strings.Count(low, "function(") + len(matchOpener.FindAllString(low, -1))
+	if opens == 0 {
+		return false
+	}
+	ends := 0
+	for _, w := range strings.FieldsFunc(low, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_')
+	}) {
+		if w == "end" {
+			ends++
+		}
+	}
+	return opens > ends
+}
+
+// matchOpener finds `match subject`; match( is a call and match = an
+// assignment.
+var matchOpener = regexp.MustCompile(`(^|[^a-z0-9_])match\s+[a-z0-9_"\[{]`)
@@ -1,56 +1,191 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 
+	"bpl-plus/analyzer"
+	"bpl-plus/ast"
+	"bpl-plus/diagnostics"
 	"bpl-plus/interpreter"
 	"bpl-plus/lexer"
+	"bpl-plus/modcache"
 	"bpl-plus/parser"
 )
 
 // compileAndRun is used by the CLI path (fresh interpreter per file).
-func compileAndRun(filename string, src string, sourceLines []string) error {
+func compileAndRun(filename string, src string, sourceLines []string, opts runOptions) error {
 	// Use your interpreter's source-aware constructor so runtime errors show caret lines.
 	in := interpreter.NewWithSource(filename, src)
+	in.SetArgs(opts.scriptArgs)
+	diags := stderrPrinter(opts.jsonDiagnostics)
+	in.SetWarningHandler(diags.Warning)
+	if opts.crashReport != "" {
+		in.EnableCrashSnapshots()
+	}
+	in.SetOffline(opts.offline)
+	in.SetImportRoots(opts.importRoots)
 
-	lx := lexer.New(src)
-	ps := parser.New(lx)
+	// Interactive runs get arrow keys and per-run history in input().
+	if replEditor != nil {
+		in.SetLineReader(lineReaderFor(replEditor))
+	} else if stdinIsTerminal() {
+		if rl, err := newLineEditor("", ""); err == nil {
+			defer rl.Close()
+			in.SetLineReader(lineReaderFor(rl))
+		}
+	}
 
-	prog, err := ps.ParseProgram()
+	parse := modcache.Parse
+	if opts.classic {
+		parse = modcache.ParseClassic
+	}
+	prog, err := parse(src)
 	if err != nil {
 		// Parser errors are plain errors (not runtimeErr formatted), so print here.
-		fmt.Fprintln(os.Stderr, err.Error())
+		diags.Error(filename, err)
 		return err
 	}
 
-	if err := in.Run(prog); err != nil {
-		// RuntimeError.Error() already renders nicely with caret + stack.
-		fmt.Fprintln(os.Stderr, err.Error())
+	if err := checkProgram(filename, prog, diags); err != nil {
 		return err
 	}
 
-	return nil
+	runErr := in.Run(prog)
+	if _, ok := exitCode(runErr); runErr != nil && !ok {
+		// RuntimeError.Error() already renders nicely with caret + stack.
+		diags.Error(filename, runErr)
+		if opts.crashReport != "" {
+			writeCrashReport(opts.crashReport, runErr)
+		}
+	}
+
+	// atexit() hooks run however the program ended.
+	if err := in.RunExitHooks(); err != nil {
+		diags.Error(filename, err)
+		if runErr == nil {
+			return err
+		}
+	}
+
+	if opts.stats {
+		fmt.Fprint(os.Stderr, in.StatsReport())
+	}
+
+	return runErr
 }
 
 // compileAndRunWith is used by the REPL path (reuses one interpreter for session state).
 func compileAndRunWith(session *interpreter.Interpreter, filename string, src string) error {
 	// Update the interpreter's current source context so runtime errors show the right caret line.
 	session.SetSource(filename, src)
+	diags := stderrPrinter(false)
 
 	lx := lexer.New(src)
 	ps := parser.New(lx)
 
 	prog, err := ps.ParseProgram()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err.Error())
+		diags.Error(filename, err)
+		return err
+	}
+
+	if err := checkProgram(filename, prog, diags); err != nil {
 		return err
 	}
 
 	if err := session.Run(prog); err != nil {
-		fmt.Fprintln(os.Stderr, err.Error())
+		if _, ok := exitCode(err); !ok {
+			diags.Error(filename, err)
+		}
 		return err
 	}
 
 	return nil
 }
+
+// checkProgram runs the static analyzer and prints every diagnostic it
+// finds. Only errors stop the program; warnings are just printed.
+func checkProgram(filename string, prog []ast.Stmt, out *diagnostics.Printer) error {
+	diags := analyzer.Check(prog, interpreter.IsBuiltin)
+	for _, d := range diags {
+		out.Analyzer(filename, d)
+	}
+	if d, ok := analyzer.FirstError(diags); ok {
+		return d
+	}
+	return nil
+}
+
+// stderrPrinter is how the CLI reports errors and warnings: JSON lines for
+// --json-diagnostics, colored text on a terminal (unless NO_COLOR is set),
+// plain text otherwise.
+func stderrPrinter(jsonLines bool) *diagnostics.Printer {
+	format := diagnostics.Plain
+	switch {
+	case jsonLines:
+		format = diagnostics.JSON
+	case stderrIsTerminal() && os.Getenv("NO_COLOR") == "":
+		format = diagnostics.Pretty
+	}
+	return diagnostics.NewPrinter(os.Stderr, format)
+}
+
+// exitCode reports whether err came from the script calling exit(code).
+func exitCode(err error) (int, bool) {
+	var exit interpreter.ExitSignal
+	if errors.As(err, &exit) {
+		return exit.Code, true
+	}
+	return 0, false
+}
+
+func writeCrashReport(path string, runErr error) {
+	data, err := interpreter.CrashReportJSON(runErr)
+	if err == nil {
+		err = os.WriteFile(path, data, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write crash report: %s\n", err.Error())
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Crash report written to %s\n", path)
+}
+
+// loadScript runs a file's top level in a fresh interpreter so its functions
+// can then be called individually (used by `bench` and `test`). Errors are
+// printed before being returned.
+func loadScript(filename string) (*interpreter.Interpreter, error) {
+	if abs, err := filepath.Abs(filename); err == nil {
+		filename = abs
+	}
+	diags := stderrPrinter(false)
+	srcBytes, err := os.ReadFile(filename)
+	if err != nil {
+		err = fmt.Errorf("Failed to read file: %s", err.Error())
+		diags.Error(filename, err)
+		return nil, err
+	}
+	src := string(srcBytes)
+	// Same as a normal run: imports resolve from the script's folder.
+	if dir := filepath.Dir(filename); dir != "" {
+		_ = os.Chdir(dir)
+	}
+
+	in := interpreter.NewWithSource(filename, src)
+	prog, err := modcache.Parse(src)
+	if err != nil {
+		diags.Error(filename, err)
+		return nil, err
+	}
+	if err := checkProgram(filename, prog, diags); err != nil {
+		return nil, err
+	}
+	if err := in.Run(prog); err != nil {
+		diags.Error(filename, err)
+		return nil, err
+	}
+	return in, nil
+}
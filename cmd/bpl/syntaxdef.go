@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"bpl-plus/interpreter"
+	"bpl-plus/lexer"
+)
+
+// runSyntaxdefCommand implements `bplplus syntaxdef --target vscode|vim|sublime
+// [--out dir] [--force]`: it writes editor highlighting files built from the
+// lexer's keyword table and the builtin registry, so regenerating them after a
+// language change keeps editors in step. Files go to bpl-syntax-<target>
+// unless --out says otherwise, and existing files (a project's package.json,
+// say) are only replaced with --force.
+func runSyntaxdefCommand(args []string) int {
+	target, outDir, force := "", "", false
+	for len(args) > 0 {
+		name, value, hasValue := strings.Cut(args[0], "=")
+		args = args[1:]
+		if name == "--force" && !hasValue {
+			force = true
+			continue
+		}
+		if name != "--target" && name != "--out" && name != "-o" {
+			fmt.Fprintf(os.Stderr, "syntaxdef: unknown flag %s\n", name)
+			return 2
+		}
+		if !hasValue {
+			if len(args) == 0 {
+				fmt.Fprintf(os.Stderr, "syntaxdef: %s needs a value\n", name)
+				return 2
+			}
+			value, args = args[0], args[1:]
+		}
+		if name == "--target" {
+			target = value
+		} else {
+			outDir = value
+		}
+	}
+
+	gen, ok := syntaxTargets[target]
+	if !ok {
+		fmt.Fprintln(os.Stderr, "Usage: bplplus syntaxdef --target vscode|vim|sublime [--out dir] [--force]")
+		return 2
+	}
+	if outDir == "" {
+		outDir = "bpl-syntax-" + target
+	}
+	files := gen(languageWords())
+	if !force {
+		for _, f := range files {
+			path := filepath.Join(outDir, f.path)
+			if _, err := os.Stat(path); err == nil {
+				fmt.Fprintf(os.Stderr, "syntaxdef: %s already exists (use --force to replace it)\n", path)
+				return 1
+			}
+		}
+	}
+	for _, f := range files {
+		path := filepath.Join(outDir, f.path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			fmt.Fprintf(os.Stderr, "syntaxdef: %v\n", err)
+			return 1
+		}
+		if err := os.WriteFile(path, []byte(f.text), 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "syntaxdef: %v\n", err)
+			return 1
+		}
+		fmt.Printf("wrote %s\n", path)
+	}
+	return 0
+}
+
+// syntaxWords is the vocabulary an editor needs to highlight, by category.
+// Keywords are case-insensitive; builtin names are not.
+type syntaxWords struct {
+	keywords  []string
+	constants []string // true, false, null
+	operators []string // and, or, not, in, mod
+	builtins  []string // including namespaced ones such as math.sqrt
+}
+
+func languageWords() syntaxWords {
+	var w syntaxWords
+	for _, word := range lexer.Keywords() {
+		switch lexer.LookupIdent(word) {
+		case lexer.TRUE, lexer.FALSE, lexer.NULL:
+			w.constants = append(w.constants, word)
+		case lexer.AND, lexer.OR, lexer.NOT, lexer.IN, lexer.PERCENT:
+			w.operators = append(w.operators, word)
+		default:
+			w.keywords = append(w.keywords, word)
+		}
+	}
+	w.builtins = interpreter.BuiltinNames()
+	return w
+}
+
+var syntaxTargets = map[string]func(syntaxWords) []starterFile{
+	"vscode":  vscodeSyntax,
+	"vim":     vimSyntax,
+	"sublime": sublimeSyntax,
+}
+
+// wordsRegex matches any of words as a whole word. Longer words come first so
+// an alternation never stops at a prefix (math.max before math).
+func wordsRegex(words []string, ignoreCase bool) string {
+	sorted := append([]string{}, words...)
+	sort.SliceStable(sorted, func(a, b int) bool { return len(sorted[a]) > len(sorted[b]) })
+	quoted := make([]string, len(sorted))
+	for k, w := range sorted {
+		quoted[k] = regexp.QuoteMeta(w)
+	}
+	re := `\b(?:` + strings.Join(quoted, "|") + `)\b`
+	if ignoreCase {
+		re = "(?i)" + re
+	}
+	return re
+}
+
+// Lexical rules shared by the TextMate-style grammars. '#' starts a comment
+// unless a digit follows (print #1, ... is a file handle).
+const (
+	commentRegex = `#(?!\d).*$`
+	numberRegex  = `\b\d+(?:\.\d+)?\b`
+	escapeRegex  = `\\[nt"\\]`
+)
+
+func vscodeSyntax(w syntaxWords) []starterFile {
+	grammar := map[string]any{
+		"name":      "BPL+",
+		"scopeName": "source.bpl",
+		"patterns": []any{
+			map[string]any{"name": "comment.line.number-sign.bpl", "match": commentRegex},
+			map[string]any{
+				"name": "string.quoted.triple.bpl", "begin": `"""`, "end": `"""`,
+				"patterns": []any{map[string]any{"name": "constant.character.escape.bpl", "match": escapeRegex}},
+			},
+			map[string]any{
+				"name": "string.quoted.double.bpl", "begin": `"`, "end": `"`,
+				"patterns": []any{map[string]any{"name": "constant.character.escape.bpl", "match": escapeRegex}},
+			},
+			map[string]any{"name": "constant.numeric.bpl", "match": numberRegex},
+			map[string]any{"name": "constant.language.bpl", "match": wordsRegex(w.constants, true)},
+			map[string]any{"name": "keyword.operator.word.bpl", "match": wordsRegex(w.operators, true)},
+			map[string]any{"name": "keyword.control.bpl", "match": wordsRegex(w.keywords, true)},
+			map[string]any{"name": "support.function.builtin.bpl", "match": wordsRegex(w.builtins, false)},
+		},
+	}
+	pkg := map[string]any{
+		"name":        "bpl-plus",
+		"displayName": "BPL+",
+		"description": "Syntax highlighting for BPL+ (generated by bplplus syntaxdef)",
+		"version":     "0.0.1",
+		"engines":     map[string]any{"vscode": "^1.60.0"},
+		"contributes": map[string]any{
+			"languages": []any{map[string]any{
+				"id": "bpl", "aliases": []string{"BPL+", "bpl"}, "extensions": []string{".bpl"},
+				"configuration": "./language-configuration.json",
+			}},
+			"grammars": []any{map[string]any{
+				"language": "bpl", "scopeName": "source.bpl", "path": "./syntaxes/bpl.tmLanguage.json",
+			}},
+		},
+	}
+	config := map[string]any{
+		"comments": map[string]any{"lineComment": "#"},
+		"brackets": [][]string{{"(", ")"}, {"[", "]"}, {"{", "}"}},
+		"autoClosingPairs": []any{
+			map[string]string{"open": "(", "close": ")"},
+			map[string]string{"open": "[", "close": "]"},
+			map[string]string{"open": "{", "close": "}"},
+			map[string]any{"open": `"`, "close": `"`, "notIn": []string{"string"}},
+		},
+	}
+	return []starterFile{
+		{"package.json", toJSON(pkg)},
+		{"language-configuration.json", toJSON(config)},
+		{"syntaxes/bpl.tmLanguage.json", toJSON(grammar)},
+	}
+}
+
+func toJSON(v any) string {
+	data, _ := json.MarshalIndent(v, "", "  ")
+	return string(data) + "\n"
+}
+
+func vimSyntax(w syntaxWords) []starterFile {
+	var b strings.Builder
+	b.WriteString("\" Vim syntax file for BPL+ (generated by bplplus syntaxdef)\n")
+	b.WriteString("if exists(\"b:current_syntax\")\n  finish\nendif\n\n")
+	b.WriteString("syn case ignore\n")
+	writeVimKeywords(&b, "bplKeyword", w.keywords)
+	writeVimKeywords(&b, "bplOperator", w.operators)
+	writeVimKeywords(&b, "bplConstant", w.constants)
+
+	// Keyword groups cannot hold dots, so namespaced builtins are a match.
+	b.WriteString("\nsyn case match\n")
+	var flat []string
+	namespaces := map[string]bool{}
+	for _, name := range w.builtins {
+		if ns, _, ok := strings.Cut(name, "."); ok {
+			namespaces[ns] = true
+			continue
+		}
+		flat = append(flat, name)
+	}
+	writeVimKeywords(&b, "bplBuiltin", flat)
+	if len(namespaces) > 0 {
+		var ns []string
+		for n := range namespaces {
+			ns = append(ns, n)
+		}
+		sort.Strings(ns)
+		fmt.Fprintf(&b, "syn match bplBuiltin \"\\v<(%s)\\.\\w+>\"\n", strings.Join(ns, "|"))
+	}
+
+	b.WriteString("\nsyn match bplNumber \"\\v<\\d+(\\.\\d+)?>\"\n")
+	b.WriteString("syn match bplEscape contained \"\\\\[nt\\\"\\\\]\"\n")
+	b.WriteString("syn region bplString start=+\"\"\"+ end=+\"\"\"+ contains=bplEscape\n")
+	b.WriteString("syn region bplString start=+\"+ skip=+\\\\\\\\\\|\\\\\"+ end=+\"+ oneline contains=bplEscape\n")
+	b.WriteString("syn match bplComment \"#\\d\\@!.*$\"\n\n")
+
+	for _, link := range [][2]string{
+		{"bplKeyword", "Statement"}, {"bplOperator", "Operator"}, {"bplConstant", "Constant"},
+		{"bplBuiltin", "Function"}, {"bplNumber", "Number"}, {"bplString", "String"},
+		{"bplEscape", "SpecialChar"}, {"bplComment", "Comment"},
+	} {
+		fmt.Fprintf(&b, "hi def link %s %s\n", link[0], link[1])
+	}
+	b.WriteString("\nlet b:current_syntax = \"bpl\"\n")
+
+	return []starterFile{
+		{"ftdetect/bpl.vim", "au BufRead,BufNewFile *.bpl set filetype=bpl\n"},
+		{"syntax/bpl.vim", b.String()},
+	}
+}
+
+// writeVimKeywords writes a syn keyword group, a few words per line.
+func writeVimKeywords(b *strings.Builder, group string, words []string) {
+	for start := 0; start < len(words); start += 8 {
+		end := min(start+8, len(words))
+		fmt.Fprintf(b, "syn keyword %s %s\n", group, strings.Join(words[start:end], " "))
+	}
+}
+
+func sublimeSyntax(w syntaxWords) []starterFile {
+	var b strings.Builder
+	b.WriteString("%YAML 1.2\n---\n")
+	b.WriteString("# Sublime Text syntax for BPL+ (generated by bplplus syntaxdef)\n")
+	b.WriteString("name: BPL+\nfile_extensions: [bpl]\nscope: source.bpl\n\n")
+	b.WriteString("contexts:\n  main:\n")
+	match := func(re, scope string) {
+		fmt.Fprintf(&b, "    - match: '%s'\n      scope: %s\n", strings.ReplaceAll(re, "'", "''"), scope)
+	}
+	match(commentRegex, "comment.line.number-sign.bpl")
+	b.WriteString("    - match: '\"\"\"'\n      push: heredoc\n")
+	b.WriteString("    - match: '\"'\n      push: string\n")
+	match(numberRegex, "constant.numeric.bpl")
+	match(wordsRegex(w.constants, true), "constant.language.bpl")
+	match(wordsRegex(w.operators, true), "keyword.operator.word.bpl")
+	match(wordsRegex(w.keywords, true), "keyword.control.bpl")
+	match(wordsRegex(w.builtins, false), "support.function.builtin.bpl")
+
+	for _, ctx := range []struct{ name, end, scope string }{
+		{"heredoc", `"""`, "string.quoted.triple.bpl"},
+		{"string", `"`, "string.quoted.double.bpl"},
+	} {
+		fmt.Fprintf(&b, "\n  %s:\n    - meta_scope: %s\n", ctx.name, ctx.scope)
+		fmt.Fprintf(&b, "    - match: '%s'\n      scope: constant.character.escape.bpl\n", escapeRegex)
+		fmt.Fprintf(&b, "    - match: '%s'\n      pop: true\n", ctx.end)
+	}
+	return []starterFile{{"bpl.sublime-syntax", b.String()}}
+}
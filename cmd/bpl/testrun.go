@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"bpl-plus/diagnostics"
+)
+
+// runTestCommand implements `bplplus test [-update] [-run substr] <file.bpl>`:
+// it loads the file and calls every zero-arg function named test_*. A test
+// fails when it raises a runtime error (assert() and assertgolden() do this).
+func runTestCommand(args []string) int {
+	update := false
+	filter := ""
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		switch args[0] {
+		case "-update":
+			update = true
+			args = args[1:]
+		case "-run":
+			if len(args) < 2 {
+				fmt.Fprintln(os.Stderr, "test: -run needs a value")
+				return 2
+			}
+			filter = args[1]
+			args = args[2:]
+		default:
+			fmt.Fprintf(os.Stderr, "test: unknown flag %s\n", args[0])
+			return 2
+		}
+	}
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: bplplus test [-update] [-run substr] <file.bpl>")
+		return 2
+	}
+
+	start := time.Now()
+	in, err := loadScript(args[0])
+	if err != nil {
+		fmt.Printf("FAIL\t%s\t(setup failed)\n", args[0])
+		return 1
+	}
+	in.SetGoldenUpdate(update)
+
+	passed, failed := 0, 0
+	for _, name := range in.FuncNames() {
+		if !strings.HasPrefix(name, "test_") || !strings.Contains(name, filter) {
+			continue
+		}
+		t0 := time.Now()
+		_, err := in.Call(name)
+		elapsed := time.Since(t0).Seconds()
+		in.ResetMocks()
+		if err != nil {
+			failed++
+			fmt.Printf("--- FAIL: %s (%.3fs)\n", name, elapsed)
+			for _, d := range diagnostics.FromError(args[0], err) {
+				for _, line := range strings.Split(d.Text, "\n") {
+					fmt.Println("    " + line)
+				}
+			}
+			continue
+		}
+		passed++
+		fmt.Printf("--- PASS: %s (%.3fs)\n", name, elapsed)
+	}
+
+	if passed+failed == 0 {
+		fmt.Println("no test_* functions found")
+	}
+	status := "ok"
+	if failed > 0 {
+		status = "FAIL"
+	}
+	fmt.Printf("%s\t%s\t%d passed, %d failed\t%.3fs\n", status, args[0], passed, failed, time.Since(start).Seconds())
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
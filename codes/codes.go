@@ -0,0 +1,62 @@
+// Package codes gives the common diagnostics stable identifiers. Messages
+// carry the code so a learner can run `bplplus explain E0203` for a longer
+// explanation with examples.
+//
+// Codes are grouped by hundreds: E01xx names, E02xx syntax, E03xx values,
+// E04xx functions and calls. Once published a code keeps its meaning; retire
+// a code rather than reuse it.
+package codes
+
+const (
+	UndefinedVariable  = "E0101"
+	UndeclaredVariable = "E0102" // option explicit
+	UndefinedFunction  = "E0103"
+
+	MissingEnd = "E0203"
+
+	IndexOutOfBounds = "E0301"
+	DivisionByZero   = "E0302"
+	FrozenValue      = "E0303"
+	OutOfData        = "E0304"
+	UnpackMismatch   = "E0305"
+
+	WrongArgCount = "E0401"
+	MissingReturn = "E0402"
+	NotCallable   = "E0403"
+)
+
+// Entry is the long-form explanation of one code.
+type Entry struct {
+	Code  string
+	Title string
+	Text  string
+}
+
+// Lookup returns the explanation for code (case-insensitive, "e203" and
+// "E0203" are the same).
+func Lookup(code string) (Entry, bool) {
+	e, ok := entries[normalize(code)]
+	return e, ok
+}
+
+// All returns every explanation in code order.
+func All() []Entry {
+	out := make([]Entry, 0, len(order))
+	for _, c := range order {
+		out = append(out, entries[c])
+	}
+	return out
+}
+
+func normalize(code string) string {
+	if code == "" {
+		return ""
+	}
+	if code[0] == 'e' || code[0] == 'E' {
+		code = code[1:]
+	}
+	for len(code) < 4 {
+		code = "0" + code
+	}
+	return "E" + code
+}
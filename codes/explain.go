@@ -0,0 +1,217 @@
+package codes
+
+var order []string
+
+var entries = map[string]Entry{}
+
+func init() {
+	for _, e := range explanations {
+		order = append(order, e.Code)
+		entries[e.Code] = e
+	}
+}
+
+var explanations = []Entry{
+	{UndefinedVariable, "Undefined variable", `A variable was read before anything was stored in it.
+
+BPL+ creates a variable the first time you assign to it, so reading a name
+that was never assigned (or is misspelled) has nothing to give back:
+
+    total = 10
+    print totl        # E0101: "totl" was never assigned
+
+Check the spelling, and make sure the assignment runs before the read. A
+variable assigned inside a function is local to that function:
+
+    function setup()
+      limit = 5
+      return 0
+    end
+    setup()
+    print limit       # E0101: limit only existed inside setup()
+
+Return the value instead, and store it where you need it:
+
+    function setup()
+      return 5
+    end
+    limit = setup()
+    print limit`},
+
+	{UndeclaredVariable, "Undeclared variable (option explicit)", `With "option explicit" every variable must be declared with dim before it
+is used. This catches typos that would otherwise quietly create a new
+variable:
+
+    option explicit
+    dim count
+    count = 1
+    cuont = count + 1   # E0102: "cuont" was never declared
+
+Declare the variable with dim, or fix the name:
+
+    option explicit
+    dim count
+    dim total
+    count = 1
+    total = count + 1`},
+
+	{UndefinedFunction, "Undefined function", `A call names a function that is neither defined in the program nor a
+builtin:
+
+    print sqaure(3)     # E0103: no function called "sqaure"
+
+Check the spelling, and that the function is defined (or imported) before
+the line that calls it runs:
+
+    function square(x)
+      return x * x
+    end
+    print square(3)
+
+Some builtins only exist under a namespace, for example math.sqrt(x) rather
+than sqrt(x).`},
+
+	{MissingEnd, "Missing 'end'", `A block was opened but never closed. if, while, for, foreach, function,
+try and type blocks all finish with "end"; repeat and do blocks finish with
+"until cond".
+
+The error points at the keyword that opened the block:
+
+    if score > 10
+      print "high"
+    print "done"        # E0203: expected 'end' to close 'if' started at 1:1
+
+Add the missing end where the block should stop:
+
+    if score > 10
+      print "high"
+    end
+    print "done"
+
+When blocks are nested, the reported one is the innermost block that is
+still open. Indenting each block's body makes a missing end easy to spot.`},
+
+	{IndexOutOfBounds, "Array index out of bounds", `An array was read or written at a position it does not have. Arrays are
+indexed from 0, so the last element is at len(a) - 1:
+
+    a = [10, 20, 30]
+    print a[3]          # E0301: index 3, size 3
+
+Loop up to len(a) - 1, or build a longer array with a + [x]:
+
+    for i = 0 to len(a) - 1
+      print a[i]
+    end
+
+To read a position that may be missing, a[i]? gives null instead of an
+error.`},
+
+	{DivisionByZero, "Division by zero", `A number was divided by zero. "mod 0" is always an error. Plain "/" gives
+Inf (or NaN for 0 / 0) unless "option checked" is on, in which case the
+program stops at the division so the bad value cannot spread:
+
+    option checked
+    count = 0
+    print 10 / count    # E0302
+    print 10 mod count  # E0302, with or without the option
+
+Check the divisor first:
+
+    if count != 0
+      print 10 / count
+    end`},
+
+	{FrozenValue, "Cannot modify a frozen value", `The array or map was frozen with freeze(), so it can no longer be changed:
+
+    days = freeze(["mon", "tue"])
+    days[0] = "sun"     # E0303
+
+Freeze values that must stay fixed, such as tables of constants. To change a
+frozen value, build a new one instead:
+
+    more = days + ["wed"]`},
+
+	{OutOfData, "Out of data", `A read statement wanted another value, but every value listed in the
+program's data statements has already been read:
+
+    data 1, 2
+    read a, b, c        # E0304: only two values to read
+
+Add the missing values to a data statement, or use restore to start reading
+from the first value again (or from the first value after a label):
+
+    prices:
+    data 10, 20
+    read a, b
+    restore prices
+    read c`},
+
+	{UnpackMismatch, "Value does not fit the pattern", `An unpacking assignment got a value of a different shape than its pattern.
+[a, b] = ... needs an array with exactly that many elements, and {a, b} = ...
+needs a map with those keys (or a record with those fields):
+
+    [day, month] = split("12/05/2024", "/")    # E0305: 3 values for 2 names
+    {name, email} = {name: "Ed"}               # E0305: no key "email"
+
+Name every element, or collect the extras with ...rest:
+
+    [day, month, ...rest] = split("12/05/2024", "/")
+
+For a key that may be missing, read it with m["email"]? instead.`},
+
+	{WrongArgCount, "Wrong number of arguments", `A function was called with more or fewer arguments than it declares:
+
+    function area(w, h)
+      return w * h
+    end
+    print area(3)       # E0401: expects 2 args, got 1
+
+Pass one value per parameter. A function that should accept any number of
+arguments can collect the extras with ...name:
+
+    function sum(...nums)
+      total = 0
+      foreach n in nums
+        total = total + n
+      end
+      return total
+    end
+    print sum(1, 2, 3)`},
+
+	{MissingReturn, "Function can end without return", `Under option strict, every path through a function must end in return
+with a value (or raise, or exit()). This function returns nothing for
+negative numbers:
+
+    option strict
+    function sign(n)     # E0402
+      if n > 0
+        return 1
+      end
+    end
+
+Without option strict it would quietly return null there. Make sure every
+path returns something:
+
+    function sign(n)
+      if n > 0
+        return 1
+      end
+      return 0
+    end
+
+A loop counts only when nothing but return can leave it: while true or
+repeat ... until false with no break (or goto) out of it.`},
+
+	{NotCallable, "Value cannot be called", `Something that is not a function was followed by ( ... ):
+
+    greeting = "hello"
+    greeting()          # E0403: cannot call a string value
+
+This usually means a variable has the same name as a function and is hiding
+it. Rename one of them:
+
+    function greet()
+      return "hello"
+    end
+    message = greet()`},
+}
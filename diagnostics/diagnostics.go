@@ -0,0 +1,98 @@
+// Package diagnostics gives the errors and warnings of the parser, analyzer
+// and interpreter one shape, and renders them for people (plain or colored
+// text) or for tools (JSON lines). The CLI, the REPL and the test and bench
+// commands all print through it, so the same problem reads the same way
+// wherever it shows up.
+package diagnostics
+
+import (
+	"errors"
+	"fmt"
+
+	"bpl-plus/analyzer"
+	"bpl-plus/ast"
+	"bpl-plus/interpreter"
+	"bpl-plus/parser"
+)
+
+type Severity string
+
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+)
+
+// Diagnostic is one error or warning.
+type Diagnostic struct {
+	File     string
+	Span     ast.Span // zero when the position is unknown
+	Severity Severity
+	Code     string // from package codes, if the problem has one
+	Message  string // without position, source line or stack
+
+	// Text is the full human rendering: message with position, and where
+	// available the source line with a caret and the call stack.
+	Text string
+}
+
+// FromError converts an error from parsing, checking or running file. An
+// error that joins several (such as failing atexit() hooks) gives one
+// diagnostic each.
+func FromError(file string, err error) []Diagnostic {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var out []Diagnostic
+		for _, e := range joined.Unwrap() {
+			out = append(out, FromError(file, e)...)
+		}
+		return out
+	}
+	return []Diagnostic{fromError(file, err)}
+}
+
+func fromError(file string, err error) Diagnostic {
+	d := Diagnostic{File: file, Severity: Error, Message: err.Error(), Text: err.Error()}
+
+	var mod interpreter.ModuleError
+	var block *parser.BlockError
+	var syntax *parser.SyntaxError
+	var check analyzer.Diagnostic
+	var rerr interpreter.RuntimeError
+	switch {
+	case errors.As(err, &mod):
+		d = fromError(mod.File, mod.Err)
+		d.Text = err.Error()
+	case errors.As(err, &block):
+		d.Span, d.Code, d.Message = block.Opener, block.Code, block.Msg
+	case errors.As(err, &syntax):
+		d.Span, d.Message = syntax.Span, syntax.Msg
+	case errors.As(err, &check):
+		d = FromAnalyzer(file, check)
+	case errors.As(err, &rerr):
+		d.Span, d.Code, d.Message = rerr.Span, rerr.Code, rerr.Msg
+		if rerr.File != "" {
+			d.File = rerr.File
+		}
+	}
+	return d
+}
+
+// FromAnalyzer converts a static-analysis diagnostic for file.
+func FromAnalyzer(file string, d analyzer.Diagnostic) Diagnostic {
+	sev := Error
+	if d.Warning {
+		sev = Warning
+	}
+	return Diagnostic{File: file, Span: d.Span, Severity: sev, Code: d.Code, Message: d.Msg, Text: d.Error()}
+}
+
+// NewWarning is a warning raised while file runs (see
+// interpreter.WarningHandler).
+func NewWarning(file string, span ast.Span, msg string) Diagnostic {
+	return Diagnostic{
+		File:     file,
+		Span:     span,
+		Severity: Warning,
+		Message:  msg,
+		Text:     fmt.Sprintf("%s: Warning: %s at %d:%d", file, msg, span.Line, span.Col),
+	}
+}
@@ -0,0 +1,115 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"bpl-plus/analyzer"
+	"bpl-plus/ast"
+)
+
+// Format is how a Printer renders diagnostics.
+type Format int
+
+const (
+	Plain  Format = iota // the text as is
+	Pretty               // text with ANSI colors, for a terminal
+	JSON                 // one JSON object per line, for editors and CI
+)
+
+const (
+	ansiRed    = "\x1b[1;31m"
+	ansiYellow = "\x1b[1;33m"
+	ansiGreen  = "\x1b[32m"
+	ansiDim    = "\x1b[2m"
+	ansiReset  = "\x1b[0m"
+)
+
+// Printer writes diagnostics to W in one Format.
+type Printer struct {
+	W      io.Writer
+	Format Format
+}
+
+func NewPrinter(w io.Writer, f Format) *Printer {
+	return &Printer{W: w, Format: f}
+}
+
+// Error prints everything FromError finds in err.
+func (p *Printer) Error(file string, err error) {
+	for _, d := range FromError(file, err) {
+		p.Print(d)
+	}
+}
+
+// Analyzer prints a static-analysis diagnostic for file.
+func (p *Printer) Analyzer(file string, d analyzer.Diagnostic) {
+	p.Print(FromAnalyzer(file, d))
+}
+
+// Warning prints a runtime warning; it fits interpreter.WarningHandler.
+func (p *Printer) Warning(file string, span ast.Span, msg string) {
+	p.Print(NewWarning(file, span, msg))
+}
+
+func (p *Printer) Print(d Diagnostic) {
+	switch p.Format {
+	case JSON:
+		fmt.Fprintln(p.W, renderJSON(d))
+	case Pretty:
+		fmt.Fprintln(p.W, renderPretty(d))
+	default:
+		fmt.Fprintln(p.W, d.Text)
+	}
+}
+
+type jsonSpan struct {
+	Line int `json:"line"`
+	Col  int `json:"col"`
+}
+
+// jsonDiagnostic is one line of JSON output. Span is left out when the
+// position is unknown (for example a file that cannot be read).
+type jsonDiagnostic struct {
+	File     string    `json:"file"`
+	Span     *jsonSpan `json:"span,omitempty"`
+	Severity Severity  `json:"severity"`
+	Code     string    `json:"code,omitempty"`
+	Message  string    `json:"message"`
+}
+
+func renderJSON(d Diagnostic) string {
+	out := jsonDiagnostic{File: d.File, Severity: d.Severity, Code: d.Code, Message: d.Message}
+	if d.Span.Line > 0 {
+		out.Span = &jsonSpan{Line: d.Span.Line, Col: d.Span.Col}
+	}
+	data, err := json.Marshal(out)
+	if err != nil {
+		return fmt.Sprintf(`{"severity":"error","message":%q}`, err.Error())
+	}
+	return string(data)
+}
+
+// renderPretty colors the plain text: the headline by severity, the caret
+// line green and the explain hint dim.
+func renderPretty(d Diagnostic) string {
+	head := ansiRed
+	if d.Severity == Warning {
+		head = ansiYellow
+	}
+	lines := strings.Split(d.Text, "\n")
+	for idx, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case idx == 0:
+			lines[idx] = head + line + ansiReset
+		case trimmed == "^":
+			lines[idx] = strings.TrimSuffix(line, "^") + ansiGreen + "^" + ansiReset
+		case strings.HasPrefix(trimmed, "(run 'bplplus explain"):
+			lines[idx] = ansiDim + line + ansiReset
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,13 @@
+10 REM Classic line-numbered listing: run with bplplus --classic classic.bas
+20 LET N = 1
+30 GOSUB 100
+40 N = N + 1
+50 IF N <> 4
+60   GOTO 30
+70 END
+80 PRINT "done"
+90 GOTO 999
+100 REM subroutine: print a row of stars
+110 PRINT repeat("*", N)
+120 RETURN
+999 REM end of program
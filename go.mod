@@ -3,6 +3,8 @@ module bpl-plus
 go 1.21
 
 require (
-	github.com/chzyer/readline v1.5.1 // indirect
-	golang.org/x/sys v0.0.0-20220310020820-b874c991c1a5 // indirect
+	github.com/chzyer/readline v1.5.1
+	golang.org/x/text v0.22.0
 )
+
+require golang.org/x/sys v0.0.0-20220310020820-b874c991c1a5 // indirect
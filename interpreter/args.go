@@ -0,0 +1,274 @@
+package interpreter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ---------- Command-line argument parsing ----------
+//
+// parseargs() takes a declarative spec map:
+//
+//	{
+//	  "name": "copytool",                      program name for usage (optional)
+//	  "description": "Copy files around",      shown under the usage line (optional)
+//	  "flags": {"verbose": false, "count": 1}, flag -> default; the default's type
+//	                                           decides how the value is parsed
+//	  "short": {"v": "verbose"},               single-letter aliases (optional)
+//	  "positional": ["src", "dest?", "rest..."],  "?" = optional, "..." = collect the rest
+//	  "help": {"verbose": "print more"}        help text for flags and positionals
+//	}
+
+// ExitSignal unwinds the interpreter when a script calls exit(code).
+type ExitSignal struct{ Code int }
+
+func (e ExitSignal) Error() string { return fmt.Sprintf("exit %d", e.Code) }
+
+// SetArgs sets the values returned by args() (the CLI passes everything after
+// the script name).
+func (i *Interpreter) SetArgs(args []string) {
+	i.scriptArgs = args
+}
+
+type argSpec struct {
+	name        string
+	description string
+	flags       map[string]Value
+	short       map[string]string
+	positional  []string
+	help        map[string]string
+}
+
+func parseArgSpec(spec map[string]Value, defaultName string) (*argSpec, error) {
+	s := &argSpec{name: defaultName, flags: map[string]Value{}, short: map[string]string{}, help: map[string]string{}}
+	for key, v := range spec {
+		switch key {
+		case "name":
+			s.name = v.ToString()
+		case "description":
+			s.description = v.ToString()
+		case "flags":
+			if v.Kind != ValMap {
+				return nil, fmt.Errorf(`spec "flags" must be a map`)
+			}
+			for name, def := range v.mapElems() {
+				switch def.Kind {
+				case ValBool, ValNumber, ValString:
+				default:
+					return nil, fmt.Errorf("flag %q default must be a bool, number or string", name)
+				}
+				s.flags[name] = def
+			}
+		case "short":
+			if v.Kind != ValMap {
+				return nil, fmt.Errorf(`spec "short" must be a map`)
+			}
+			for letter, target := range v.mapElems() {
+				s.short[letter] = target.ToString()
+			}
+		case "positional":
+			if v.Kind != ValArray {
+				return nil, fmt.Errorf(`spec "positional" must be an array`)
+			}
+			for _, p := range v.arrayElems() {
+				s.positional = append(s.positional, p.ToString())
+			}
+		case "help":
+			if v.Kind != ValMap {
+				return nil, fmt.Errorf(`spec "help" must be a map`)
+			}
+			for name, text := range v.mapElems() {
+				s.help[name] = text.ToString()
+			}
+		default:
+			return nil, fmt.Errorf("unknown spec key %q", key)
+		}
+	}
+	for letter, target := range s.short {
+		if _, ok := s.flags[target]; !ok {
+			return nil, fmt.Errorf("short flag -%s refers to unknown flag %q", letter, target)
+		}
+	}
+	return s, nil
+}
+
+// positionalName strips the "?" / "..." markers.
+func positionalName(p string) (name string, optional, rest bool) {
+	switch {
+	case strings.HasSuffix(p, "..."):
+		return strings.TrimSuffix(p, "..."), true, true
+	case strings.HasSuffix(p, "?"):
+		return strings.TrimSuffix(p, "?"), true, false
+	}
+	return p, false, false
+}
+
+func (s *argSpec) usage() string {
+	var b strings.Builder
+	b.WriteString("Usage: " + s.name)
+	if len(s.flags) > 0 {
+		b.WriteString(" [options]")
+	}
+	for _, p := range s.positional {
+		name, optional, rest := positionalName(p)
+		switch {
+		case rest:
+			b.WriteString(" [" + name + "...]")
+		case optional:
+			b.WriteString(" [" + name + "]")
+		default:
+			b.WriteString(" <" + name + ">")
+		}
+	}
+	b.WriteString("\n")
+	if s.description != "" {
+		b.WriteString("\n" + s.description + "\n")
+	}
+
+	if len(s.positional) > 0 {
+		b.WriteString("\nArguments:\n")
+		for _, p := range s.positional {
+			name, _, _ := positionalName(p)
+			b.WriteString(fmt.Sprintf("  %-20s %s\n", name, s.help[name]))
+		}
+	}
+
+	shortFor := map[string]string{}
+	for letter, target := range s.short {
+		shortFor[target] = letter
+	}
+	names := make([]string, 0, len(s.flags))
+	for name := range s.flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b.WriteString("\nOptions:\n")
+	for _, name := range names {
+		label := "--" + name
+		if letter, ok := shortFor[name]; ok {
+			label = "-" + letter + ", " + label
+		}
+		def := s.flags[name]
+		if def.Kind != ValBool {
+			label += " <value>"
+		}
+		text := s.help[name]
+		if def.Kind != ValBool {
+			text = strings.TrimSpace(text + " (default: " + def.ToString() + ")")
+		}
+		b.WriteString(fmt.Sprintf("  %-20s %s\n", label, text))
+	}
+	b.WriteString(fmt.Sprintf("  %-20s %s\n", "-h, --help", "show this help"))
+	return b.String()
+}
+
+// errHelpRequested is returned by parse when -h/--help is given.
+var errHelpRequested = fmt.Errorf("help requested")
+
+func (s *argSpec) parse(argv []string) (map[string]Value, error) {
+	out := map[string]Value{}
+	for name, def := range s.flags {
+		out[name] = def
+	}
+
+	setFlag := func(name, value string, hasValue bool, next func() (string, bool)) error {
+		def := s.flags[name]
+		if def.Kind == ValBool {
+			if !hasValue {
+				out[name] = BoolValue(true)
+				return nil
+			}
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("flag --%s expects true or false, got %q", name, value)
+			}
+			out[name] = BoolValue(b)
+			return nil
+		}
+		if !hasValue {
+			v, ok := next()
+			if !ok {
+				return fmt.Errorf("flag --%s needs a value", name)
+			}
+			value = v
+		}
+		if def.Kind == ValNumber {
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("flag --%s expects a number, got %q", name, value)
+			}
+			out[name] = NumberValue(n)
+			return nil
+		}
+		out[name] = StringValue(value)
+		return nil
+	}
+
+	positionals := []string{}
+	pos := 0
+	next := func() (string, bool) {
+		if pos >= len(argv) {
+			return "", false
+		}
+		pos++
+		return argv[pos-1], true
+	}
+	for pos < len(argv) {
+		arg := argv[pos]
+		pos++
+		switch {
+		case arg == "--":
+			positionals = append(positionals, argv[pos:]...)
+			pos = len(argv)
+		case arg == "-h" || arg == "--help":
+			return nil, errHelpRequested
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(arg[2:], "=")
+			if _, ok := s.flags[name]; !ok {
+				return nil, fmt.Errorf("unknown flag --%s", name)
+			}
+			if err := setFlag(name, value, hasValue, next); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			letter, value, hasValue := strings.Cut(arg[1:], "=")
+			name, ok := s.short[letter]
+			if !ok {
+				return nil, fmt.Errorf("unknown flag -%s", letter)
+			}
+			if err := setFlag(name, value, hasValue, next); err != nil {
+				return nil, err
+			}
+		default:
+			positionals = append(positionals, arg)
+		}
+	}
+
+	for idx, p := range s.positional {
+		name, optional, rest := positionalName(p)
+		if rest {
+			elems := []Value{}
+			for _, a := range positionals[min(idx, len(positionals)):] {
+				elems = append(elems, StringValue(a))
+			}
+			out[name] = ArrayValue(elems)
+			positionals = nil
+			break
+		}
+		if idx < len(positionals) {
+			out[name] = StringValue(positionals[idx])
+			continue
+		}
+		if !optional {
+			return nil, fmt.Errorf("missing required argument <%s>", name)
+		}
+		out[name] = NullValue()
+	}
+	if len(positionals) > len(s.positional) {
+		return nil, fmt.Errorf("unexpected argument %q", positionals[len(s.positional)])
+	}
+	return out, nil
+}
@@ -0,0 +1,94 @@
+package interpreter
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"bpl-plus/ast"
+)
+
+// ---------- Benchmarks ----------
+
+// BenchResult holds timings for repeated calls of one function.
+type BenchResult struct {
+	Iterations int
+	Total      time.Duration
+	Min        time.Duration
+	Max        time.Duration
+	Median     time.Duration
+}
+
+func (r BenchResult) Mean() time.Duration {
+	if r.Iterations == 0 {
+		return 0
+	}
+	return r.Total / time.Duration(r.Iterations)
+}
+
+// benchTarget is how long auto-calibrated benchmarks aim to run.
+const benchTarget = time.Second
+
+func (i *Interpreter) benchRun(fn *FunctionObject, n int, span ast.Span) (BenchResult, error) {
+	times := make([]time.Duration, n)
+	res := BenchResult{Iterations: n}
+	for k := 0; k < n; k++ {
+		t0 := time.Now()
+		if _, err := i.callClosure(fn.Decl, fn.Env, nil, span); err != nil {
+			return BenchResult{}, err
+		}
+		times[k] = time.Since(t0)
+		res.Total += times[k]
+	}
+	sort.Slice(times, func(a, b int) bool { return times[a] < times[b] })
+	if n > 0 {
+		res.Min, res.Max, res.Median = times[0], times[n-1], times[n/2]
+	}
+	return res, nil
+}
+
+// bench times fn over n calls. With n <= 0 the count is chosen automatically,
+// growing it until a run takes about benchTarget (like Go's testing package).
+func (i *Interpreter) bench(fn *FunctionObject, n int, span ast.Span) (BenchResult, error) {
+	if !acceptsArgs(fn.Decl, 0) {
+		return BenchResult{}, fmt.Errorf("function %q must take no args", funcName(fn.Decl))
+	}
+	if n > 0 {
+		return i.benchRun(fn, n, span)
+	}
+
+	n = 1
+	for {
+		res, err := i.benchRun(fn, n, span)
+		if err != nil || res.Total >= benchTarget || n >= 1_000_000_000 {
+			return res, err
+		}
+		// Aim for the target with some headroom, but never grow more than 100x at once.
+		next := n * 100
+		if per := res.Mean(); per > 0 {
+			next = min(next, int(float64(benchTarget)/float64(per)*1.2)+1)
+		}
+		n = max(next, n+1)
+	}
+}
+
+// Bench runs the named zero-arg function as a benchmark (n <= 0 = auto).
+func (i *Interpreter) Bench(name string, n int) (BenchResult, error) {
+	fn, ok := i.funcs[name]
+	if !ok {
+		return BenchResult{}, fmt.Errorf("undefined function %q", name)
+	}
+	return i.bench(&FunctionObject{Decl: fn}, n, fn.GetSpan())
+}
+
+func benchResultValue(r BenchResult) Value {
+	return MapValue(map[string]Value{
+		"iterations": NumberValue(float64(r.Iterations)),
+		"total":      NumberValue(r.Total.Seconds()),
+		"mean":       NumberValue(r.Mean().Seconds()),
+		"min":        NumberValue(r.Min.Seconds()),
+		"max":        NumberValue(r.Max.Seconds()),
+		"median":     NumberValue(r.Median.Seconds()),
+		"nsperop":    NumberValue(float64(r.Mean().Nanoseconds())),
+	})
+}
@@ -0,0 +1,126 @@
+package interpreter
+
+import (
+	"fmt"
+	"sort"
+
+	"bpl-plus/ast"
+	"bpl-plus/codes"
+)
+
+// ---------- Builtins ----------
+// Each builtins_*.go file registers its functions from init(). A builtin gets
+// its already-evaluated args; name is the name it was called by, so one
+// function can serve several aliases.
+
+type builtinFunc func(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error)
+
+var builtins = map[string]builtinFunc{}
+
+func registerBuiltins(fns map[string]builtinFunc) {
+	for name, fn := range fns {
+		if _, dup := builtins[name]; dup {
+			panic("builtin registered twice: " + name)
+		}
+		builtins[name] = fn
+	}
+}
+
+// A lazy builtin gets its args unevaluated and decides which ones to run
+// (iif() only evaluates the chosen branch). Each one is also registered as a
+// normal builtin, which is what callbyname() and mocks see.
+type lazyBuiltinFunc func(i *Interpreter, name string, args []ast.Expr, callSpan ast.Span) (Value, error)
+
+var lazyBuiltins = map[string]lazyBuiltinFunc{}
+
+func registerLazyBuiltins(fns map[string]lazyBuiltinFunc) {
+	for name, fn := range fns {
+		if _, dup := lazyBuiltins[name]; dup {
+			panic("lazy builtin registered twice: " + name)
+		}
+		lazyBuiltins[name] = fn
+	}
+}
+
+// Namespaces group builtins under a prefix (math.sqrt, str.lower). Members
+// either alias a flat legacy name, which keeps working, or are registered
+// directly under their dotted name when they have no flat form.
+var namespaceAliases = map[string]string{}
+
+// registerNamespace makes ns.member call the builtin flat for each entry.
+func registerNamespace(ns string, members map[string]string) {
+	for member, flat := range members {
+		dotted := ns + "." + member
+		if _, dup := namespaceAliases[dotted]; dup {
+			panic("builtin registered twice: " + dotted)
+		}
+		namespaceAliases[dotted] = flat
+	}
+}
+
+// builtinName resolves a namespaced alias to the builtin it stands for, so
+// mocks and error messages see one name. ok is false for unknown names.
+func builtinName(name string) (string, bool) {
+	if flat, ok := namespaceAliases[name]; ok {
+		name = flat
+	}
+	_, ok := builtins[name]
+	return name, ok
+}
+
+// IsBuiltin reports whether name is a builtin function.
+func IsBuiltin(name string) bool {
+	_, ok := builtinName(name)
+	return ok
+}
+
+// BuiltinNames returns the sorted names of every builtin function,
+// namespaced aliases included.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins)+len(namespaceAliases))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	for name := range namespaceAliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (i *Interpreter) evalBuiltin(name string, argExprs []ast.Expr, callSpan ast.Span) (Value, error) {
+	name, _ = builtinName(name)
+	if lazy, ok := lazyBuiltins[name]; ok && i.mocks[name] == nil {
+		return lazy(i, name, argExprs, callSpan)
+	}
+	args, err := i.evalArgs(argExprs)
+	if err != nil {
+		return Value{}, err
+	}
+	return i.callBuiltin(name, args, callSpan)
+}
+
+func (i *Interpreter) callBuiltin(name string, args []Value, callSpan ast.Span) (Value, error) {
+	name, _ = builtinName(name)
+	if mock, ok := i.mocks[name]; ok {
+		// Inside the mock, the name reaches the real builtin again.
+		delete(i.mocks, name)
+		defer func() {
+			if i.mocks != nil {
+				i.mocks[name] = mock
+			}
+		}()
+		return i.callClosure(mock.Decl, mock.Env, args, callSpan)
+	}
+	fn, ok := builtins[name]
+	if !ok {
+		return Value{}, i.codedErr(callSpan, codes.UndefinedFunction, fmt.Sprintf("Undefined function %q", name))
+	}
+	out, err := fn(i, name, args, callSpan)
+	// builtins like push() grow their args in place
+	for _, a := range args {
+		i.noteSize(a)
+	}
+	i.noteSize(out)
+	return out, err
+}
@@ -0,0 +1,78 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"bpl-plus/ast"
+)
+
+func init() {
+	registerBuiltins(map[string]builtinFunc{
+		"assert":         builtinAssert,
+		"assertgolden":   builtinAssertgolden,
+		"mockbuiltin":    builtinMockbuiltin,
+		"restorebuiltin": builtinRestorebuiltin,
+	})
+}
+
+func builtinAssert(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// assert(cond [, message]) fails with a runtime error when cond is false
+	if len(args) != 1 && len(args) != 2 {
+		return Value{}, i.runtimeErr(callSpan, "assert() expects 1 or 2 args: assert(cond, message)")
+	}
+	if args[0].Kind != ValBool {
+		return Value{}, i.runtimeErr(callSpan, "assert() condition must be a boolean")
+	}
+	if !args[0].Bool {
+		msg := "assertion failed"
+		if len(args) == 2 {
+			msg += ": " + args[1].ToString()
+		}
+		return Value{}, i.runtimeErr(callSpan, msg)
+	}
+	return NullValue(), nil
+}
+
+func builtinAssertgolden(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// assertgolden(name, actual) compares str(actual) with testdata/<name>.golden
+	if len(args) != 2 || args[0].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "assertgolden() expects 2 args: assertgolden(name, actual)")
+	}
+	if err := i.checkGolden(args[0].Str, args[1].ToString()); err != nil {
+		return Value{}, i.runtimeErr(callSpan, "assertgolden() "+err.Error())
+	}
+	return NullValue(), nil
+}
+
+func builtinMockbuiltin(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// mockbuiltin(builtinName, fnName or function) routes calls of a builtin to a user function
+	if len(args) != 2 || args[0].Kind != ValString || (args[1].Kind != ValString && args[1].Kind != ValFunction) {
+		return Value{}, i.runtimeErr(callSpan, "mockbuiltin() expects (builtinName, fnName or function)")
+	}
+	target, ok := builtinName(args[0].Str)
+	if !ok {
+		return Value{}, i.runtimeErr(callSpan, fmt.Sprintf("mockbuiltin() %q is not a builtin", target))
+	}
+	if target == "mockbuiltin" || target == "restorebuiltin" {
+		return Value{}, i.runtimeErr(callSpan, fmt.Sprintf("mockbuiltin() cannot mock %s()", target))
+	}
+	fn, err := i.userFunctionArg(args[1])
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "mockbuiltin() "+err.Error())
+	}
+	if i.mocks == nil {
+		i.mocks = map[string]*FunctionObject{}
+	}
+	i.mocks[target] = fn
+	return NullValue(), nil
+}
+
+func builtinRestorebuiltin(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// restorebuiltin(builtinName) undoes mockbuiltin()
+	if len(args) != 1 || args[0].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "restorebuiltin() expects 1 string arg: restorebuiltin(builtinName)")
+	}
+	target, _ := builtinName(args[0].Str)
+	delete(i.mocks, target)
+	return NullValue(), nil
+}
@@ -0,0 +1,104 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+
+	"bpl-plus/ast"
+)
+
+func init() {
+	registerBuiltins(map[string]builtinFunc{
+		"band": builtinBitwise,
+		"bor":  builtinBitwise,
+		"bxor": builtinBitwise,
+		"bnot": builtinBnot,
+		"shl":  builtinShift,
+		"shr":  builtinShift,
+	})
+	registerNamespace("math", map[string]string{
+		"band": "band",
+		"bor":  "bor",
+		"bxor": "bxor",
+		"bnot": "bnot",
+		"shl":  "shl",
+		"shr":  "shr",
+	})
+}
+
+// bitArg reads an argument of a bit builtin. Only whole numbers whose every
+// bit is exact (within 2^53 - 1 either way) have bits to work on; negative
+// numbers are two's complement, so bnot(0) is -1.
+func (i *Interpreter) bitArg(name string, v Value, callSpan ast.Span) (int64, error) {
+	if v.Kind != ValNumber || !isWhole(v.Number) || v.Number > maxSafeInt || v.Number < -maxSafeInt {
+		return 0, i.runtimeErr(callSpan, fmt.Sprintf("%s() expects whole numbers up to 2^53 - 1 in size, got %s", name, v.ToString()))
+	}
+	return int64(v.Number), nil
+}
+
+// band(a, b, ...), bor(a, b, ...), bxor(a, b, ...) -> the bits of all args
+// and-ed, or-ed or xor-ed together
+func builtinBitwise(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) < 2 {
+		return Value{}, i.runtimeErr(callSpan, name+"() expects at least 2 number args")
+	}
+	acc, err := i.bitArg(name, args[0], callSpan)
+	if err != nil {
+		return Value{}, err
+	}
+	for _, v := range args[1:] {
+		n, err := i.bitArg(name, v, callSpan)
+		if err != nil {
+			return Value{}, err
+		}
+		switch name {
+		case "band":
+			acc &= n
+		case "bor":
+			acc |= n
+		case "bxor":
+			acc ^= n
+		}
+	}
+	return NumberValue(float64(acc)), nil
+}
+
+// bnot(a) -> a with every bit flipped (-a - 1)
+func builtinBnot(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 {
+		return Value{}, i.runtimeErr(callSpan, "bnot() expects 1 number arg")
+	}
+	n, err := i.bitArg(name, args[0], callSpan)
+	if err != nil {
+		return Value{}, err
+	}
+	return NumberValue(float64(^n)), nil
+}
+
+// shl(a, n), shr(a, n) -> a shifted n bits left or right. shr keeps the
+// sign, so shr(-8, 1) is -4 and shr(-1, 5) stays -1.
+func builtinShift(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 2 {
+		return Value{}, i.runtimeErr(callSpan, fmt.Sprintf("%s() expects 2 number args: %s(a, bits)", name, name))
+	}
+	a, err := i.bitArg(name, args[0], callSpan)
+	if err != nil {
+		return Value{}, err
+	}
+	bits, err := i.bitArg(name, args[1], callSpan)
+	if err != nil {
+		return Value{}, err
+	}
+	if bits < 0 || bits > 63 {
+		return Value{}, i.runtimeErr(callSpan, fmt.Sprintf("%s() shift count must be between 0 and 63, got %d", name, bits))
+	}
+	if name == "shr" {
+		return NumberValue(float64(a >> bits)), nil
+	}
+	// shifting left is multiplying by 2^bits, which is exact in a float
+	res := float64(a) * math.Ldexp(1, int(bits))
+	if math.Abs(res) > maxSafeInt {
+		return Value{}, i.runtimeErr(callSpan, fmt.Sprintf("shl(%d, %d) is beyond 2^53 - 1 and would lose precision", a, bits))
+	}
+	return NumberValue(res), nil
+}
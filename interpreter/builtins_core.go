@@ -0,0 +1,339 @@
+package interpreter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"bpl-plus/ast"
+	"bpl-plus/codes"
+)
+
+func init() {
+	registerBuiltins(map[string]builtinFunc{
+		"str":           builtinStr,
+		"num":           builtinNum,
+		"trynum":        builtinTrynum,
+		"tryindex":      builtinTryindex,
+		"len":           builtinLen,
+		"freeze":        builtinFreeze,
+		"isfrozen":      builtinIsfrozen,
+		"delete":        builtinDelete,
+		"haskey":        builtinHaskey,
+		"removeat":      builtinRemoveat,
+		"isnull":        builtinIsnull,
+		"inspect":       builtinInspect,
+		"pprint":        builtinPprint,
+		"printall":      builtinPrintall,
+		"setprintlimit": builtinSetprintlimit,
+		"printtable":    builtinPrinttable,
+		"iif":           builtinIif,
+	})
+	registerLazyBuiltins(map[string]lazyBuiltinFunc{
+		"iif": lazyIif,
+	})
+	registerNamespace("arr", map[string]string{
+		"len":      "len",
+		"sort":     "sort",
+		"join":     "join",
+		"freeze":   "freeze",
+		"isfrozen": "isfrozen",
+		"removeat": "removeat",
+	})
+}
+
+// iif(cond, a, b) -> a if cond is true, else b. Only the chosen branch is
+// evaluated, so iif(n != 0, total / n, 0) is safe.
+func lazyIif(i *Interpreter, name string, args []ast.Expr, callSpan ast.Span) (Value, error) {
+	if len(args) != 3 {
+		return Value{}, i.runtimeErr(callSpan, "iif() expects 3 args: iif(cond, then, else)")
+	}
+	cond, err := i.evalExpr(args[0])
+	if err != nil {
+		return Value{}, err
+	}
+	if cond.Kind != ValBool {
+		return Value{}, i.runtimeErr(args[0].GetSpan(), "iif() condition must be boolean")
+	}
+	if cond.Bool {
+		return i.evalExpr(args[1])
+	}
+	return i.evalExpr(args[2])
+}
+
+// builtinIif is iif() with already-evaluated args (callbyname, mocks).
+func builtinIif(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 3 {
+		return Value{}, i.runtimeErr(callSpan, "iif() expects 3 args: iif(cond, then, else)")
+	}
+	if args[0].Kind != ValBool {
+		return Value{}, i.runtimeErr(callSpan, "iif() condition must be boolean")
+	}
+	if args[0].Bool {
+		return args[1], nil
+	}
+	return args[2], nil
+}
+
+func builtinStr(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 {
+		return Value{}, i.runtimeErr(callSpan, "str() expects 1 arg")
+	}
+	s, err := i.stringOf(args[0], callSpan)
+	if err != nil {
+		return Value{}, err
+	}
+	return StringValue(s), nil
+}
+
+func builtinNum(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 {
+		return Value{}, i.runtimeErr(callSpan, "num() expects 1 arg")
+	}
+	if args[0].Kind == ValNumber {
+		return args[0], nil
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(args[0].ToString()), 64)
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, fmt.Sprintf("num() could not parse %q", args[0].ToString()))
+	}
+	return NumberValue(n), nil
+}
+
+func builtinTrynum(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// trynum(s [, default]) -> num(s), or default (null) when s is not a number
+	if len(args) != 1 && len(args) != 2 {
+		return Value{}, i.runtimeErr(callSpan, "trynum() expects 1 or 2 args: trynum(text, default)")
+	}
+	if args[0].Kind == ValNumber {
+		return args[0], nil
+	}
+	if n, err := strconv.ParseFloat(strings.TrimSpace(args[0].ToString()), 64); err == nil {
+		return NumberValue(n), nil
+	}
+	if len(args) == 2 {
+		return args[1], nil
+	}
+	return NullValue(), nil
+}
+
+func builtinTryindex(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// tryindex(collection, key [, default]) -> collection[key], or default (null)
+	// when the key or index is missing
+	if len(args) != 2 && len(args) != 3 {
+		return Value{}, i.runtimeErr(callSpan, "tryindex() expects 2 or 3 args: tryindex(collection, key, default)")
+	}
+	coll, key := args[0], args[1]
+	switch coll.Kind {
+	case ValArray:
+		idx, err := i.toIndex(key, callSpan)
+		if elems := coll.arrayElems(); err == nil && idx >= 0 && idx < len(elems) {
+			return elems[idx], nil
+		}
+	case ValMap:
+		if k, err := i.toMapKey(key, callSpan); err == nil {
+			if v, ok := coll.mapElems()[k]; ok {
+				return v, nil
+			}
+		}
+	}
+	if len(args) == 3 {
+		return args[2], nil
+	}
+	return NullValue(), nil
+}
+
+func builtinIsnull(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 {
+		return Value{}, i.runtimeErr(callSpan, "isnull() expects 1 arg")
+	}
+	return BoolValue(args[0].Kind == ValNull), nil
+}
+
+func builtinLen(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 {
+		return Value{}, i.runtimeErr(callSpan, "len() expects 1 arg")
+	}
+	switch args[0].Kind {
+	case ValString:
+		return NumberValue(float64(runeLen(args[0].Str))), nil
+	case ValArray:
+		if args[0].Arr == nil {
+			return NumberValue(0), nil
+		}
+		return NumberValue(float64(len(args[0].Arr.Elems))), nil
+	case ValMap:
+		if args[0].Map == nil || args[0].Map.Elems == nil {
+			return NumberValue(0), nil
+		}
+		return NumberValue(float64(len(args[0].Map.Elems))), nil
+	case ValRange:
+		return NumberValue(float64(args[0].Rng.Len())), nil
+	default:
+		return Value{}, i.runtimeErr(callSpan, "len() expects a string, array, map or range")
+	}
+}
+
+func builtinFreeze(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// freeze(v) -> v (deep; arrays and maps become read-only)
+	if len(args) != 1 {
+		return Value{}, i.runtimeErr(callSpan, "freeze() expects 1 arg")
+	}
+	if args[0].Kind != ValArray && args[0].Kind != ValMap {
+		return Value{}, i.runtimeErr(callSpan, "freeze() expects an array or map")
+	}
+	freezeValue(args[0])
+	return args[0], nil
+}
+
+func builtinIsfrozen(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 {
+		return Value{}, i.runtimeErr(callSpan, "isfrozen() expects 1 arg")
+	}
+	return BoolValue(args[0].isFrozen()), nil
+}
+
+// haskey(m, key) -> true if map m has key (a number key matches as for m[k])
+func builtinHaskey(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 2 {
+		return Value{}, i.runtimeErr(callSpan, "haskey() expects 2 args: haskey(map, key)")
+	}
+	if args[0].Kind != ValMap {
+		return Value{}, i.runtimeErr(callSpan, "haskey() first arg must be a map")
+	}
+	found, err := i.contains(args[0], args[1], callSpan)
+	if err != nil {
+		return Value{}, err
+	}
+	return BoolValue(found), nil
+}
+
+// delete(m, key) -> true if m had key, which is now gone; false if it was
+// not there. The map is changed in place, so every variable holding it sees.
+func builtinDelete(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 2 {
+		return Value{}, i.runtimeErr(callSpan, "delete() expects 2 args: delete(map, key)")
+	}
+	m := args[0]
+	if m.Kind != ValMap || m.Map == nil {
+		return Value{}, i.runtimeErr(callSpan, "delete() first arg must be a map (use removeat() for arrays)")
+	}
+	if err := i.checkMutable(m, callSpan); err != nil {
+		return Value{}, err
+	}
+	key, err := i.toMapKey(args[1], callSpan)
+	if err != nil {
+		return Value{}, err
+	}
+	if _, ok := m.Map.Elems[key]; !ok {
+		return BoolValue(false), nil
+	}
+	delete(m.Map.Elems, key)
+	return BoolValue(true), nil
+}
+
+// removeat(a, i) -> the element that was at index i; later elements move
+// down one place. The array is changed in place.
+func builtinRemoveat(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 2 {
+		return Value{}, i.runtimeErr(callSpan, "removeat() expects 2 args: removeat(array, index)")
+	}
+	a := args[0]
+	if a.Kind != ValArray || a.Arr == nil {
+		return Value{}, i.runtimeErr(callSpan, "removeat() first arg must be an array (use delete() for maps)")
+	}
+	if err := i.checkMutable(a, callSpan); err != nil {
+		return Value{}, err
+	}
+	idx, err := i.toIndex(args[1], callSpan)
+	if err != nil {
+		return Value{}, err
+	}
+	elems := a.Arr.Elems
+	if idx < 0 || idx >= len(elems) {
+		return Value{}, i.codedErr(callSpan, codes.IndexOutOfBounds, fmt.Sprintf("Array index out of bounds (index %d, size %d)", idx, len(elems)))
+	}
+	removed := elems[idx]
+	a.Arr.Elems = append(elems[:idx], elems[idx+1:]...)
+	return removed, nil
+}
+
+func builtinInspect(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 {
+		return Value{}, i.runtimeErr(callSpan, "inspect() expects 1 arg")
+	}
+	return StringValue(prettyString(args[0])), nil
+}
+
+func builtinPprint(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 {
+		return Value{}, i.runtimeErr(callSpan, "pprint() expects 1 arg")
+	}
+	fmt.Println(prettyString(args[0]))
+	return NullValue(), nil
+}
+
+// printall(value) prints value in full, however long, ignoring the print limit.
+func builtinPrintall(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 {
+		return Value{}, i.runtimeErr(callSpan, "printall() expects 1 arg: printall(value)")
+	}
+	s, err := i.stringOf(args[0], callSpan)
+	if err != nil {
+		return Value{}, err
+	}
+	fmt.Println(s)
+	i.printCol = 0
+	return NullValue(), nil
+}
+
+// setprintlimit(n) -> previous limit; print shows at most n elements of each
+// array or map (0 means all of them).
+func builtinSetprintlimit(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 {
+		return Value{}, i.runtimeErr(callSpan, "setprintlimit() expects 1 arg: setprintlimit(n)")
+	}
+	n, err := i.toIndex(args[0], callSpan)
+	if err != nil || n < 0 {
+		return Value{}, i.runtimeErr(callSpan, "setprintlimit() n must be a non-negative integer")
+	}
+	prev := i.printLimit
+	i.printLimit = n
+	return NumberValue(float64(prev)), nil
+}
+
+func builtinPrinttable(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// printtable(rows [, headers [, "ascii" | "unicode"]]); rows is an array of
+	// arrays or of maps, and [] as headers means none (or all keys for maps)
+	if len(args) < 1 || len(args) > 3 {
+		return Value{}, i.runtimeErr(callSpan, "printtable() expects 1 to 3 args: printtable(rows, headers, style)")
+	}
+	if args[0].Kind != ValArray {
+		return Value{}, i.runtimeErr(callSpan, "printtable() rows must be an array")
+	}
+	var headers []string
+	if len(args) >= 2 {
+		if args[1].Kind != ValArray {
+			return Value{}, i.runtimeErr(callSpan, "printtable() headers must be an array")
+		}
+		for _, h := range args[1].arrayElems() {
+			headers = append(headers, h.ToString())
+		}
+	}
+	style := tableStyles["ascii"]
+	if len(args) == 3 {
+		s, ok := tableStyles[args[2].ToString()]
+		if args[2].Kind != ValString || !ok {
+			return Value{}, i.runtimeErr(callSpan, "printtable() style must be \"ascii\" or \"unicode\"")
+		}
+		style = s
+	}
+
+	headers, rows, err := tableRows(args[0].arrayElems(), headers)
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "printtable() "+err.Error())
+	}
+	fmt.Print(renderTable(headers, rows, style))
+	i.printCol = 0
+	return NullValue(), nil
+}
@@ -0,0 +1,122 @@
+package interpreter
+
+import (
+	"os"
+
+	"bpl-plus/ast"
+)
+
+func init() {
+	registerBuiltins(map[string]builtinFunc{
+		"parsejson": builtinParsejson,
+		"tojson":    builtinTojson,
+		"parsecsv":  builtinParsecsv,
+		"tocsv":     builtinTocsv,
+		"loaddata":  builtinLoaddata,
+		"savedata":  builtinSavedata,
+	})
+}
+
+func builtinParsejson(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// parsejson(text) -> value
+	if len(args) != 1 || args[0].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "parsejson() expects 1 string arg: parsejson(text)")
+	}
+	v, err := jsonToValue(args[0].Str)
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "parsejson() "+err.Error())
+	}
+	return v, nil
+}
+
+func builtinTojson(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// tojson(v [, pretty]) -> string
+	if len(args) != 1 && len(args) != 2 {
+		return Value{}, i.runtimeErr(callSpan, "tojson() expects 1 or 2 args: tojson(value, pretty)")
+	}
+	pretty := false
+	if len(args) == 2 {
+		if args[1].Kind != ValBool {
+			return Value{}, i.runtimeErr(callSpan, "tojson() pretty must be boolean")
+		}
+		pretty = args[1].Bool
+	}
+	s, err := valueToJSONText(args[0], pretty)
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "tojson() "+err.Error())
+	}
+	return StringValue(s), nil
+}
+
+func builtinParsecsv(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// parsecsv(text [, header]) -> array of maps (header row, the default) or arrays
+	if len(args) != 1 && len(args) != 2 || args[0].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "parsecsv() expects 1 or 2 args: parsecsv(text, header)")
+	}
+	header := true
+	if len(args) == 2 {
+		if args[1].Kind != ValBool {
+			return Value{}, i.runtimeErr(callSpan, "parsecsv() header must be boolean")
+		}
+		header = args[1].Bool
+	}
+	v, err := csvToValue(args[0].Str, ',', header)
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "parsecsv() "+err.Error())
+	}
+	return v, nil
+}
+
+func builtinTocsv(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// tocsv(rows) -> string; rows is an array of maps or of arrays
+	if len(args) != 1 {
+		return Value{}, i.runtimeErr(callSpan, "tocsv() expects 1 arg: tocsv(rows)")
+	}
+	s, err := valueToCSV(args[0], ',')
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "tocsv() "+err.Error())
+	}
+	return StringValue(s), nil
+}
+
+func builtinLoaddata(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// loaddata(path [, format]) -> value; format is detected when omitted
+	if len(args) != 1 && len(args) != 2 || args[0].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "loaddata() expects 1 or 2 args: loaddata(path, format)")
+	}
+	data, err := os.ReadFile(args[0].Str)
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "loaddata() failed: "+err.Error())
+	}
+	content := string(data)
+	format := sniffFormat(args[0].Str, content)
+	if len(args) == 2 {
+		format = args[1].ToString()
+	}
+	v, err := decodeData(content, format)
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "loaddata() "+err.Error())
+	}
+	return v, nil
+}
+
+func builtinSavedata(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// savedata(path, v [, format]); format comes from the extension, else JSON
+	if len(args) != 2 && len(args) != 3 || args[0].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "savedata() expects 2 or 3 args: savedata(path, value, format)")
+	}
+	format := extFormat(args[0].Str)
+	if len(args) == 3 {
+		format = args[2].ToString()
+	} else if format == "" {
+		format = "json"
+	}
+	text, err := encodeData(args[1], format)
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "savedata() "+err.Error())
+	}
+	if err := os.WriteFile(args[0].Str, []byte(text), 0644); err != nil {
+		return Value{}, i.runtimeErr(callSpan, "savedata() failed: "+err.Error())
+	}
+	return NullValue(), nil
+}
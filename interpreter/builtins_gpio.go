@@ -0,0 +1,131 @@
+//go:build gpio && linux
+
+package interpreter
+
+import (
+	"bpl-plus/ast"
+)
+
+func init() {
+	registerBuiltins(map[string]builtinFunc{
+		"gpiosetup":   builtinGpiosetup,
+		"gpiowrite":   builtinGpiowrite,
+		"gpioread":    builtinGpioread,
+		"gpiorelease": builtinGpiorelease,
+		"pwmstart":    builtinPwmstart,
+		"pwmstop":     builtinPwmstop,
+	})
+}
+
+// gpioInts converts the leading args of a GPIO builtin to non-negative integers.
+func (i *Interpreter) gpioInts(name string, args []Value, callSpan ast.Span) ([]int, error) {
+	out := make([]int, len(args))
+	for k, a := range args {
+		n, err := i.toIndex(a, callSpan)
+		if err != nil || n < 0 {
+			return nil, i.runtimeErr(callSpan, name+"() pin and channel numbers must be non-negative integers")
+		}
+		out[k] = n
+	}
+	return out, nil
+}
+
+func builtinGpiosetup(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// gpiosetup(pin, "in" | "out")
+	if len(args) != 2 || args[1].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "gpiosetup() expects 2 args: gpiosetup(pin, \"in\" or \"out\")")
+	}
+	pin, err := i.gpioInts(name, args[:1], callSpan)
+	if err != nil {
+		return Value{}, err
+	}
+	if err := gpioSetup(pin[0], args[1].Str); err != nil {
+		return Value{}, i.runtimeErr(callSpan, "gpiosetup() failed: "+err.Error())
+	}
+	return NullValue(), nil
+}
+
+func builtinGpiowrite(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// gpiowrite(pin, value) where value is true/false or 1/0
+	if len(args) != 2 {
+		return Value{}, i.runtimeErr(callSpan, "gpiowrite() expects 2 args: gpiowrite(pin, value)")
+	}
+	pin, err := i.gpioInts(name, args[:1], callSpan)
+	if err != nil {
+		return Value{}, err
+	}
+	var high bool
+	switch args[1].Kind {
+	case ValBool:
+		high = args[1].Bool
+	case ValNumber:
+		high = args[1].Number != 0
+	default:
+		return Value{}, i.runtimeErr(callSpan, "gpiowrite() value must be a boolean or 0/1")
+	}
+	if err := gpioWrite(pin[0], high); err != nil {
+		return Value{}, i.runtimeErr(callSpan, "gpiowrite() failed: "+err.Error())
+	}
+	return NullValue(), nil
+}
+
+func builtinGpioread(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// gpioread(pin) -> 0 or 1
+	if len(args) != 1 {
+		return Value{}, i.runtimeErr(callSpan, "gpioread() expects 1 arg: gpioread(pin)")
+	}
+	pin, err := i.gpioInts(name, args, callSpan)
+	if err != nil {
+		return Value{}, err
+	}
+	v, err := gpioRead(pin[0])
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "gpioread() failed: "+err.Error())
+	}
+	return NumberValue(float64(v)), nil
+}
+
+func builtinGpiorelease(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// gpiorelease(pin) hands the pin back to the kernel
+	if len(args) != 1 {
+		return Value{}, i.runtimeErr(callSpan, "gpiorelease() expects 1 arg: gpiorelease(pin)")
+	}
+	pin, err := i.gpioInts(name, args, callSpan)
+	if err != nil {
+		return Value{}, err
+	}
+	if err := gpioRelease(pin[0]); err != nil {
+		return Value{}, i.runtimeErr(callSpan, "gpiorelease() failed: "+err.Error())
+	}
+	return NullValue(), nil
+}
+
+func builtinPwmstart(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// pwmstart(chip, channel, frequencyHz, duty) with duty from 0 to 1
+	if len(args) != 4 || args[2].Kind != ValNumber || args[3].Kind != ValNumber {
+		return Value{}, i.runtimeErr(callSpan, "pwmstart() expects 4 args: pwmstart(chip, channel, frequencyHz, duty)")
+	}
+	ids, err := i.gpioInts(name, args[:2], callSpan)
+	if err != nil {
+		return Value{}, err
+	}
+	if err := pwmStart(ids[0], ids[1], args[2].Number, args[3].Number); err != nil {
+		return Value{}, i.runtimeErr(callSpan, "pwmstart() failed: "+err.Error())
+	}
+	return NullValue(), nil
+}
+
+func builtinPwmstop(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// pwmstop(chip, channel)
+	if len(args) != 2 {
+		return Value{}, i.runtimeErr(callSpan, "pwmstop() expects 2 args: pwmstop(chip, channel)")
+	}
+	ids, err := i.gpioInts(name, args, callSpan)
+	if err != nil {
+		return Value{}, err
+	}
+	if err := pwmStop(ids[0], ids[1]); err != nil {
+		return Value{}, i.runtimeErr(callSpan, "pwmstop() failed: "+err.Error())
+	}
+	return NullValue(), nil
+}
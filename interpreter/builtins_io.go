@@ -0,0 +1,234 @@
+package interpreter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"bpl-plus/ast"
+)
+
+func init() {
+	registerBuiltins(map[string]builtinFunc{
+		"lineinput":   builtinLineinput,
+		"eof":         builtinEof,
+		"input":       builtinInput,
+		"inputnum":    builtinInputnum,
+		"confirm":     builtinConfirm,
+		"choose":      builtinChoose,
+		"inputsecret": builtinInputsecret,
+		"tab":         builtinTab,
+		"tryopen":     builtinTryopen,
+
+		// only reachable through the fs namespace
+		"fs.exists": builtinFsExists,
+		"fs.isdir":  builtinFsExists,
+	})
+	registerNamespace("fs", map[string]string{
+		"load": "loaddata",
+		"save": "savedata",
+	})
+}
+
+// fs.exists(path) -> true if anything is there; fs.isdir(path) -> true for a directory
+func builtinFsExists(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 || args[0].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, name+"() expects 1 string arg: "+name+"(path)")
+	}
+	info, err := os.Stat(args[0].Str)
+	if err != nil {
+		return BoolValue(false), nil
+	}
+	return BoolValue(name == "fs.exists" || info.IsDir()), nil
+}
+
+func builtinLineinput(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// lineinput(handle) -> string | null
+	if len(args) != 1 || args[0].Kind != ValNumber {
+		return Value{}, i.runtimeErr(callSpan, "lineinput() expects 1 number arg: lineinput(handle)")
+	}
+	h := int(args[0].Number)
+	if args[0].Number != float64(h) || h <= 0 {
+		return Value{}, i.runtimeErr(callSpan, "lineinput() handle must be a positive integer")
+	}
+
+	r, _, herr := i.getHandleReader(h)
+	if herr != nil {
+		return Value{}, i.runtimeErr(callSpan, "lineinput() failed: "+herr.Error())
+	}
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		if err == io.EOF {
+			if line == "" {
+				return NullValue(), nil
+			}
+			// return last partial line
+			return StringValue(strings.TrimRight(line, "\r\n")), nil
+		}
+		return Value{}, i.runtimeErr(callSpan, fmt.Sprintf("lineinput() failed: %v", err))
+	}
+	return StringValue(strings.TrimRight(line, "\r\n")), nil
+}
+
+func builtinEof(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// eof(handle) -> bool
+	if len(args) != 1 || args[0].Kind != ValNumber {
+		return Value{}, i.runtimeErr(callSpan, "eof() expects 1 number arg: eof(handle)")
+	}
+	h := int(args[0].Number)
+	if args[0].Number != float64(h) || h <= 0 {
+		return Value{}, i.runtimeErr(callSpan, "eof() handle must be a positive integer")
+	}
+
+	// per your preference: if not open, treat as EOF=true
+	if _, ok := i.files[h]; !ok || i.files[h] == nil {
+		return BoolValue(true), nil
+	}
+
+	r, _, herr := i.getHandleReader(h)
+	if herr != nil {
+		return BoolValue(true), nil
+	}
+
+	_, err := r.Peek(1)
+	if err == io.EOF {
+		return BoolValue(true), nil
+	}
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, fmt.Sprintf("eof() failed: %v", err))
+	}
+	return BoolValue(false), nil
+
+	// Input (stdin)
+}
+
+func builtinInput(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// input([prompt [, default]]) -> string; default is returned for empty input
+	if len(args) > 2 {
+		return Value{}, i.runtimeErr(callSpan, "input() expects 0 to 2 args: input(prompt, default)")
+	}
+	prompt := ""
+	if len(args) >= 1 {
+		prompt = args[0].ToString()
+	}
+	line, _ := i.promptLine(prompt)
+	if len(args) == 2 && strings.TrimSpace(line) == "" {
+		return args[1], nil
+	}
+	return StringValue(line), nil
+}
+
+func builtinInputnum(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// inputnum(prompt [, min, max] [, default]) -> number, re-prompting until
+	// valid; default is returned for empty input
+	if len(args) < 1 || len(args) > 4 {
+		return Value{}, i.runtimeErr(callSpan, "inputnum() expects 1 to 4 args: inputnum(prompt [, min, max] [, default])")
+	}
+	lo, hi, bounded := 0.0, 0.0, len(args) >= 3
+	if bounded {
+		if args[1].Kind != ValNumber || args[2].Kind != ValNumber {
+			return Value{}, i.runtimeErr(callSpan, "inputnum() min and max must be numbers")
+		}
+		lo, hi = args[1].Number, args[2].Number
+	}
+	def, hasDef := 0.0, len(args) == 2 || len(args) == 4
+	if hasDef {
+		d := args[len(args)-1]
+		if d.Kind != ValNumber {
+			return Value{}, i.runtimeErr(callSpan, "inputnum() default must be a number")
+		}
+		if bounded && (d.Number < lo || d.Number > hi) {
+			return Value{}, i.runtimeErr(callSpan, "inputnum() default must be between min and max")
+		}
+		def = d.Number
+	}
+	n, err := i.promptNumber(args[0].ToString(), lo, hi, bounded, def, hasDef)
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "inputnum() "+err.Error())
+	}
+	return NumberValue(n), nil
+}
+
+func builtinConfirm(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// confirm(prompt [, default]) -> bool
+	if len(args) != 1 && len(args) != 2 {
+		return Value{}, i.runtimeErr(callSpan, "confirm() expects 1 or 2 args: confirm(prompt, default)")
+	}
+	def, hasDef := false, len(args) == 2
+	if hasDef {
+		if args[1].Kind != ValBool {
+			return Value{}, i.runtimeErr(callSpan, "confirm() default must be true or false")
+		}
+		def = args[1].Bool
+	}
+	ok, err := i.promptConfirm(args[0].ToString(), def, hasDef)
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "confirm() "+err.Error())
+	}
+	return BoolValue(ok), nil
+}
+
+func builtinChoose(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// choose(prompt, options) -> the chosen element
+	if len(args) != 2 || args[1].Kind != ValArray {
+		return Value{}, i.runtimeErr(callSpan, "choose() expects 2 args: choose(prompt, optionsArray)")
+	}
+	opts := args[1].arrayElems()
+	idx, err := i.promptChoose(args[0].ToString(), opts)
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "choose() "+err.Error())
+	}
+	return opts[idx], nil
+}
+
+func builtinInputsecret(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) > 1 {
+		return Value{}, i.runtimeErr(callSpan, "inputsecret() expects 0 or 1 args")
+	}
+	prompt := ""
+	if len(args) == 1 {
+		prompt = args[0].ToString()
+	}
+	s, err := i.promptSecret(prompt)
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "inputsecret() "+err.Error())
+	}
+	return StringValue(s), nil
+}
+
+func (i *Interpreter) getHandleReader(handle int) (*bufio.Reader, *os.File, error) {
+	f, ok := i.files[handle]
+	if !ok || f == nil {
+		return nil, nil, fmt.Errorf("handle #%d is not open", handle)
+	}
+	if r, ok := i.readers[handle]; ok && r != nil {
+		return r, f, nil
+	}
+	r := bufio.NewReader(f)
+	i.readers[handle] = r
+	return r, f, nil
+}
+
+// tab(n) is handled by print itself (see execPrint); anywhere else it is an error.
+func builtinTab(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	return Value{}, i.runtimeErr(callSpan, "tab() can only be used as a print item: print a; tab(20); b")
+}
+
+func builtinTryopen(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// tryopen(handle, path, mode) -> null when the file opened, else an error
+	// map {"message": ...}; like open #n, path, mode but never stops the program
+	if len(args) != 3 || args[0].Kind != ValNumber || args[1].Kind != ValString || args[2].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "tryopen() expects 3 args: tryopen(handle, path, mode)")
+	}
+	h := int(args[0].Number)
+	if args[0].Number != float64(h) || h <= 0 {
+		return Value{}, i.runtimeErr(callSpan, "tryopen() handle must be a positive integer")
+	}
+	if err := i.openHandle(h, args[1].Str, args[2].Str); err != nil {
+		return MapValue(map[string]Value{"message": StringValue(err.Error())}), nil
+	}
+	return NullValue(), nil
+}
@@ -0,0 +1,272 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"bpl-plus/ast"
+)
+
+func init() {
+	registerBuiltins(map[string]builtinFunc{
+		"matmul":    builtinMatmul,
+		"transpose": builtinTranspose,
+		"identity":  builtinIdentity,
+		"dot":       builtinDot,
+		"vadd":      builtinElementwise,
+		"vsub":      builtinElementwise,
+		"vmul":      builtinElementwise,
+		"vdiv":      builtinElementwise,
+		"decimal":   builtinDecimal,
+		"decround":  builtinDecround,
+		"isdecimal": builtinIsdecimal,
+
+		"setprecision": builtinSetprecision,
+		"formatfixed":  builtinFormatfixed,
+
+		// only reachable through the math namespace
+		"math.sqrt":  builtinMathUnary,
+		"math.abs":   builtinMathUnary,
+		"math.floor": builtinMathUnary,
+		"math.ceil":  builtinMathUnary,
+		"math.round": builtinMathUnary,
+		"math.pow":   builtinMathPow,
+		"math.min":   builtinMathMinMax,
+		"math.max":   builtinMathMinMax,
+	})
+	registerNamespace("math", map[string]string{
+		"matmul":       "matmul",
+		"transpose":    "transpose",
+		"identity":     "identity",
+		"dot":          "dot",
+		"vadd":         "vadd",
+		"vsub":         "vsub",
+		"vmul":         "vmul",
+		"vdiv":         "vdiv",
+		"decimal":      "decimal",
+		"decround":     "decround",
+		"isdecimal":    "isdecimal",
+		"setprecision": "setprecision",
+		"formatfixed":  "formatfixed",
+	})
+}
+
+var mathUnary = map[string]func(float64) float64{
+	"math.sqrt":  math.Sqrt,
+	"math.abs":   math.Abs,
+	"math.floor": math.Floor,
+	"math.ceil":  math.Ceil,
+	"math.round": math.Round,
+}
+
+// math.sqrt(x), math.abs(x), math.floor(x), math.ceil(x), math.round(x) -> number
+func builtinMathUnary(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 || args[0].Kind != ValNumber {
+		return Value{}, i.runtimeErr(callSpan, name+"() expects 1 number arg")
+	}
+	if name == "math.sqrt" && args[0].Number < 0 {
+		return Value{}, i.runtimeErr(callSpan, "math.sqrt() of a negative number")
+	}
+	return NumberValue(mathUnary[name](args[0].Number)), nil
+}
+
+// math.pow(x, y) -> x raised to y
+func builtinMathPow(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 2 || args[0].Kind != ValNumber || args[1].Kind != ValNumber {
+		return Value{}, i.runtimeErr(callSpan, "math.pow() expects 2 number args: math.pow(x, y)")
+	}
+	return NumberValue(math.Pow(args[0].Number, args[1].Number)), nil
+}
+
+// math.min(a, b, ...) / math.max(a, b, ...), or a single array of numbers
+func builtinMathMinMax(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	nums := args
+	if len(args) == 1 && args[0].Kind == ValArray && args[0].Arr != nil {
+		nums = args[0].Arr.Elems
+	}
+	if len(nums) == 0 {
+		return Value{}, i.runtimeErr(callSpan, name+"() needs at least one number")
+	}
+	best := 0.0
+	for n, v := range nums {
+		if v.Kind != ValNumber {
+			return Value{}, i.runtimeErr(callSpan, name+"() expects numbers")
+		}
+		if n == 0 || (name == "math.min" && v.Number < best) || (name == "math.max" && v.Number > best) {
+			best = v.Number
+		}
+	}
+	return NumberValue(best), nil
+}
+
+func builtinMatmul(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 2 {
+		return Value{}, i.runtimeErr(callSpan, "matmul() expects 2 args: matmul(a, b)")
+	}
+	a, err := toMatrix(args[0])
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "matmul() first arg: "+err.Error())
+	}
+	b, err := toMatrix(args[1])
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "matmul() second arg: "+err.Error())
+	}
+	out, err := matMul(a, b)
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "matmul() "+err.Error())
+	}
+	return matrixValue(out), nil
+}
+
+func builtinTranspose(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 {
+		return Value{}, i.runtimeErr(callSpan, "transpose() expects 1 arg")
+	}
+	m, err := toMatrix(args[0])
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "transpose() "+err.Error())
+	}
+	return matrixValue(transpose(m)), nil
+}
+
+func builtinIdentity(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 {
+		return Value{}, i.runtimeErr(callSpan, "identity() expects 1 arg: identity(n)")
+	}
+	n, err := i.toIndex(args[0], callSpan)
+	if err != nil {
+		return Value{}, err
+	}
+	if n < 0 {
+		return Value{}, i.runtimeErr(callSpan, "identity() n must be >= 0")
+	}
+	return matrixValue(identity(n)), nil
+}
+
+func builtinDot(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 2 {
+		return Value{}, i.runtimeErr(callSpan, "dot() expects 2 args: dot(a, b)")
+	}
+	a, err := toVector(args[0])
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "dot() first arg: "+err.Error())
+	}
+	b, err := toVector(args[1])
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "dot() second arg: "+err.Error())
+	}
+	d, err := dot(a, b)
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "dot() "+err.Error())
+	}
+	return NumberValue(d), nil
+}
+
+func builtinElementwise(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// elementwise ops over nested numeric arrays (numbers broadcast)
+	if len(args) != 2 {
+		return Value{}, i.runtimeErr(callSpan, fmt.Sprintf("%s() expects 2 args", name))
+	}
+	ops := map[string]func(x, y float64) float64{
+		"vadd": func(x, y float64) float64 { return x + y },
+		"vsub": func(x, y float64) float64 { return x - y },
+		"vmul": func(x, y float64) float64 { return x * y },
+		"vdiv": func(x, y float64) float64 { return x / y },
+	}
+	out, err := elementwise(args[0], args[1], ops[name])
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, fmt.Sprintf("%s() %s", name, err.Error()))
+	}
+	return out, nil
+}
+
+func builtinDecimal(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 {
+		return Value{}, i.runtimeErr(callSpan, "decimal() expects 1 arg: decimal(value)")
+	}
+	switch args[0].Kind {
+	case ValDecimal:
+		return args[0], nil
+	case ValNumber, ValString:
+		var d *Decimal
+		var err error
+		if args[0].Kind == ValNumber {
+			d, err = decimalFromFloat(args[0].Number)
+		} else {
+			d, err = parseDecimal(args[0].Str)
+		}
+		if err != nil {
+			return Value{}, i.runtimeErr(callSpan, "decimal() "+err.Error())
+		}
+		return DecimalValue(d), nil
+	default:
+		return Value{}, i.runtimeErr(callSpan, "decimal() expects a number or string")
+	}
+}
+
+func builtinDecround(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return Value{}, i.runtimeErr(callSpan, "decround() expects 2 or 3 args: decround(d, places, mode)")
+	}
+	d, ok := toDecimal(args[0])
+	if !ok {
+		return Value{}, i.runtimeErr(callSpan, "decround() expects a decimal or number")
+	}
+	places, err := i.toIndex(args[1], callSpan)
+	if err != nil || places < 0 {
+		return Value{}, i.runtimeErr(callSpan, "decround() places must be a non-negative integer")
+	}
+	mode := "half-up"
+	if len(args) == 3 {
+		if args[2].Kind != ValString || !decimalRoundModes[strings.ToLower(args[2].Str)] {
+			return Value{}, i.runtimeErr(callSpan, "decround() mode must be one of: half-up, half-even, half-down, up, down, ceiling, floor")
+		}
+		mode = strings.ToLower(args[2].Str)
+	}
+	return DecimalValue(decimalRound(d, places, mode)), nil
+}
+
+func builtinIsdecimal(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 {
+		return Value{}, i.runtimeErr(callSpan, "isdecimal() expects 1 arg")
+	}
+	return BoolValue(args[0].Kind == ValDecimal), nil
+}
+
+// setprecision(n) -> previous setting; n significant digits (1-17) for printing
+// numbers (15 by default), or 0 for exact round-trip output
+func builtinSetprecision(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 {
+		return Value{}, i.runtimeErr(callSpan, "setprecision() expects 1 arg: setprecision(digits)")
+	}
+	n, err := i.toIndex(args[0], callSpan)
+	if err != nil || n < 0 || n > 17 {
+		return Value{}, i.runtimeErr(callSpan, "setprecision() digits must be an integer from 0 to 17")
+	}
+	prev := i.printPrecision
+	i.printPrecision = n
+	return NumberValue(float64(prev)), nil
+}
+
+// formatfixed(x, decimals) -> string with exactly that many decimals
+func builtinFormatfixed(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 2 {
+		return Value{}, i.runtimeErr(callSpan, "formatfixed() expects 2 args: formatfixed(x, decimals)")
+	}
+	places, err := i.toIndex(args[1], callSpan)
+	if err != nil || places < 0 {
+		return Value{}, i.runtimeErr(callSpan, "formatfixed() decimals must be a non-negative integer")
+	}
+	switch args[0].Kind {
+	case ValDecimal:
+		return StringValue(decimalRound(args[0].Dec, places, "half-up").String()), nil
+	case ValNumber:
+		s, err := formatFixed(args[0].Number, places)
+		if err != nil {
+			return Value{}, i.runtimeErr(callSpan, "formatfixed() "+err.Error())
+		}
+		return StringValue(s), nil
+	}
+	return Value{}, i.runtimeErr(callSpan, "formatfixed() expects a number")
+}
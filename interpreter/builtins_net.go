@@ -0,0 +1,94 @@
+package interpreter
+
+import (
+	"fmt"
+	"net/url"
+
+	"bpl-plus/ast"
+)
+
+func init() {
+	registerBuiltins(map[string]builtinFunc{
+		"urlparse":     builtinUrlparse,
+		"urlbuild":     builtinUrlbuild,
+		"urlencode":    builtinUrlencode,
+		"urldecode":    builtinUrldecode,
+		"httpdownload": builtinHttpdownload,
+	})
+}
+
+func builtinUrlparse(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 || args[0].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "urlparse() expects 1 string arg: urlparse(url)")
+	}
+	m, err := urlToMap(args[0].Str)
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "urlparse() "+err.Error())
+	}
+	return m, nil
+}
+
+func builtinUrlbuild(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 || args[0].Kind != ValMap {
+		return Value{}, i.runtimeErr(callSpan, "urlbuild() expects 1 map arg: urlbuild(parts)")
+	}
+	s, err := mapToURL(args[0].mapElems())
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "urlbuild() "+err.Error())
+	}
+	return StringValue(s), nil
+}
+
+func builtinUrlencode(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// urlencode(string) escapes one component; urlencode(map) builds a query string
+	if len(args) != 1 {
+		return Value{}, i.runtimeErr(callSpan, "urlencode() expects 1 arg: urlencode(text) or urlencode(params)")
+	}
+	if args[0].Kind == ValMap {
+		s, err := encodeQuery(args[0].mapElems())
+		if err != nil {
+			return Value{}, i.runtimeErr(callSpan, "urlencode() "+err.Error())
+		}
+		return StringValue(s), nil
+	}
+	return StringValue(url.QueryEscape(args[0].ToString())), nil
+}
+
+func builtinUrldecode(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 || args[0].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "urldecode() expects 1 string arg")
+	}
+	s, err := url.QueryUnescape(args[0].Str)
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, fmt.Sprintf("urldecode() could not decode %q", args[0].Str))
+	}
+	return StringValue(s), nil
+}
+
+func builtinHttpdownload(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// httpdownload(url, path [, progressfn]) -> bytes written; progressfn(done, total)
+	// is called as the download runs (total is -1 when the size is unknown)
+	if len(args) != 2 && len(args) != 3 || args[0].Kind != ValString || args[1].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "httpdownload() expects 2 or 3 args: httpdownload(url, path, progressfn)")
+	}
+	var progress func(done, total int64) error
+	var cbErr error // an error from progressfn is passed through unchanged
+	if len(args) == 3 {
+		fn, err := i.callableArg(args[2])
+		if err != nil {
+			return Value{}, i.runtimeErr(callSpan, "httpdownload() progressfn "+err.Error())
+		}
+		progress = func(done, total int64) error {
+			_, cbErr = i.callValue(fn, []Value{NumberValue(float64(done)), NumberValue(float64(total))}, callSpan)
+			return cbErr
+		}
+	}
+	n, err := downloadFile(args[0].Str, args[1].Str, progress)
+	if cbErr != nil {
+		return Value{}, cbErr
+	}
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "httpdownload() failed: "+err.Error())
+	}
+	return NumberValue(float64(n)), nil
+}
@@ -0,0 +1,98 @@
+package interpreter
+
+import (
+	"bpl-plus/ast"
+)
+
+func init() {
+	registerBuiltins(map[string]builtinFunc{
+		"funcexists": builtinFuncexists,
+		"callbyname": builtinCallbyname,
+		"builtins":   builtinBuiltins,
+		"docof":      builtinDocof,
+		"apply":      builtinApply,
+	})
+}
+
+func builtinFuncexists(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// funcexists(name) -> true for user functions and builtins
+	if len(args) != 1 || args[0].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "funcexists() expects 1 string arg: funcexists(name)")
+	}
+	_, user := i.funcs[args[0].Str]
+	builtin := IsBuiltin(args[0].Str)
+	return BoolValue(user || builtin), nil
+}
+
+func builtinCallbyname(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// callbyname(name [, argsArray]) -> whatever the function returns
+	// name may also be a function value
+	if len(args) != 1 && len(args) != 2 {
+		return Value{}, i.runtimeErr(callSpan, "callbyname() expects 1 or 2 args: callbyname(name, argsArray)")
+	}
+	if args[0].Kind != ValString && args[0].Kind != ValFunction {
+		return Value{}, i.runtimeErr(callSpan, "callbyname() name must be a string")
+	}
+	var callArgs []Value
+	if len(args) == 2 {
+		if args[1].Kind != ValArray {
+			return Value{}, i.runtimeErr(callSpan, "callbyname() args must be an array")
+		}
+		callArgs = append(callArgs, args[1].arrayElems()...)
+	}
+
+	fv, err := i.callableArg(args[0])
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "callbyname() "+err.Error())
+	}
+	return i.callValue(fv, callArgs, callSpan)
+}
+
+func builtinApply(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// apply(fn, args...) -> fn(args...); fn is a function value or name
+	if len(args) < 1 {
+		return Value{}, i.runtimeErr(callSpan, "apply() expects at least 1 arg: apply(fn, args...)")
+	}
+	fv, err := i.callableArg(args[0])
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "apply() "+err.Error())
+	}
+	return i.callValue(fv, append([]Value(nil), args[1:]...), callSpan)
+}
+
+func builtinBuiltins(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// builtins() -> sorted array of builtin function names
+	if len(args) != 0 {
+		return Value{}, i.runtimeErr(callSpan, "builtins() expects 0 args")
+	}
+	names := BuiltinNames()
+	out := make([]Value, len(names))
+	for idx, n := range names {
+		out[idx] = StringValue(n)
+	}
+	return ArrayValue(out), nil
+}
+
+func builtinDocof(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// docof(name) -> {"name", "params", "doc"} for a user function
+	if len(args) != 1 || (args[0].Kind != ValString && args[0].Kind != ValFunction) {
+		return Value{}, i.runtimeErr(callSpan, "docof() expects 1 string arg: docof(name)")
+	}
+	fo, err := i.userFunctionArg(args[0])
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "docof() "+err.Error())
+	}
+	fn := fo.Decl
+	params := make([]Value, len(fn.Params))
+	for idx, p := range fn.Params {
+		if fn.Variadic && idx == len(fn.Params)-1 {
+			p = "..." + p
+		}
+		params[idx] = StringValue(p)
+	}
+	return MapValue(map[string]Value{
+		"name":   StringValue(fn.Name),
+		"params": ArrayValue(params),
+		"doc":    StringValue(fn.Doc),
+	}), nil
+}
@@ -0,0 +1,371 @@
+package interpreter
+
+import (
+	"strings"
+	"unicode"
+
+	"bpl-plus/ast"
+)
+
+func init() {
+	registerBuiltins(map[string]builtinFunc{
+		"lower":       builtinLower,
+		"upper":       builtinUpper,
+		"trim":        builtinTrim,
+		"ltrim":       builtinLtrim,
+		"rtrim":       builtinRtrim,
+		"contains":    builtinContains,
+		"startswith":  builtinStartswith,
+		"endswith":    builtinEndswith,
+		"replace":     builtinReplace,
+		"split":       builtinSplit,
+		"join":        builtinJoin,
+		"indexof":     builtinIndexof,
+		"lastindexof": builtinLastindexof,
+		"repeat":      builtinRepeat,
+		"substr":      builtinSubstr,
+		"levenshtein": builtinLevenshtein,
+		"similarity":  builtinSimilarity,
+		"soundex":     builtinSoundex,
+		"format":      builtinFormat,
+
+		"equalsfold":     builtinEqualsfold,
+		"naturalcompare": builtinNaturalcompare,
+		"sort":           builtinSort,
+
+		"foldcase":  builtinFoldcase,
+		"normalize": builtinNormalize,
+		"graphemes": builtinGraphemes,
+
+		"escapehtml":  builtinEscape,
+		"escapejson":  builtinEscape,
+		"escapeshell": builtinEscape,
+		"escaperegex": builtinEscape,
+	})
+	registerNamespace("str", map[string]string{
+		"lower":          "lower",
+		"upper":          "upper",
+		"trim":           "trim",
+		"ltrim":          "ltrim",
+		"rtrim":          "rtrim",
+		"contains":       "contains",
+		"startswith":     "startswith",
+		"endswith":       "endswith",
+		"replace":        "replace",
+		"split":          "split",
+		"join":           "join",
+		"indexof":        "indexof",
+		"lastindexof":    "lastindexof",
+		"repeat":         "repeat",
+		"substr":         "substr",
+		"levenshtein":    "levenshtein",
+		"similarity":     "similarity",
+		"soundex":        "soundex",
+		"format":         "format",
+		"equalsfold":     "equalsfold",
+		"naturalcompare": "naturalcompare",
+		"foldcase":       "foldcase",
+		"normalize":      "normalize",
+		"graphemes":      "graphemes",
+		"escapehtml":     "escapehtml",
+		"escapejson":     "escapejson",
+		"escapeshell":    "escapeshell",
+		"escaperegex":    "escaperegex",
+	})
+}
+
+func builtinLower(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 || args[0].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "lower() expects 1 string arg")
+	}
+	return StringValue(strings.ToLower(args[0].Str)), nil
+}
+
+func builtinUpper(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 || args[0].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "upper() expects 1 string arg")
+	}
+	return StringValue(strings.ToUpper(args[0].Str)), nil
+}
+
+func builtinTrim(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return Value{}, i.runtimeErr(callSpan, "trim() expects 1 or 2 args: trim(s [,cutset])")
+	}
+	if args[0].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "trim() first arg must be a string")
+	}
+	if len(args) == 1 {
+		return StringValue(strings.TrimSpace(args[0].Str)), nil
+	}
+	if args[1].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "trim() cutset must be a string")
+	}
+	return StringValue(strings.Trim(args[0].Str, args[1].Str)), nil
+}
+
+func builtinLtrim(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return Value{}, i.runtimeErr(callSpan, "ltrim() expects 1 or 2 args: ltrim(s [,cutset])")
+	}
+	if args[0].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "ltrim() first arg must be a string")
+	}
+	if len(args) == 1 {
+		return StringValue(strings.TrimLeftFunc(args[0].Str, unicode.IsSpace)), nil
+	}
+	if args[1].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "ltrim() cutset must be a string")
+	}
+	return StringValue(strings.TrimLeft(args[0].Str, args[1].Str)), nil
+}
+
+func builtinRtrim(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return Value{}, i.runtimeErr(callSpan, "rtrim() expects 1 or 2 args: rtrim(s [,cutset])")
+	}
+	if args[0].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "rtrim() first arg must be a string")
+	}
+	if len(args) == 1 {
+		return StringValue(strings.TrimRightFunc(args[0].Str, unicode.IsSpace)), nil
+	}
+	if args[1].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "rtrim() cutset must be a string")
+	}
+	return StringValue(strings.TrimRight(args[0].Str, args[1].Str)), nil
+}
+
+func builtinContains(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 2 || args[0].Kind != ValString || args[1].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "contains() expects 2 string args: contains(s, sub)")
+	}
+	return BoolValue(strings.Contains(args[0].Str, args[1].Str)), nil
+}
+
+func builtinStartswith(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 2 || args[0].Kind != ValString || args[1].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "startswith() expects 2 string args: startswith(s, prefix)")
+	}
+	return BoolValue(strings.HasPrefix(args[0].Str, args[1].Str)), nil
+}
+
+func builtinEndswith(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 2 || args[0].Kind != ValString || args[1].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "endswith() expects 2 string args: endswith(s, suffix)")
+	}
+	return BoolValue(strings.HasSuffix(args[0].Str, args[1].Str)), nil
+}
+
+func builtinReplace(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 3 && len(args) != 4 {
+		return Value{}, i.runtimeErr(callSpan, "replace() expects 3 or 4 args: replace(s, old, new [,n])")
+	}
+	if args[0].Kind != ValString || args[1].Kind != ValString || args[2].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "replace() expects string args for s/old/new")
+	}
+	n := -1
+	if len(args) == 4 {
+		if args[3].Kind != ValNumber {
+			return Value{}, i.runtimeErr(callSpan, "replace() n must be a number")
+		}
+		n = int(args[3].Number)
+	}
+	return StringValue(strings.Replace(args[0].Str, args[1].Str, args[2].Str, n)), nil
+}
+
+func builtinSplit(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 2 || args[0].Kind != ValString || args[1].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "split() expects 2 string args: split(s, sep)")
+	}
+	parts := strings.Split(args[0].Str, args[1].Str)
+	out := make([]Value, 0, len(parts))
+	for _, p := range parts {
+		out = append(out, StringValue(p))
+	}
+	return ArrayValue(out), nil
+}
+
+func builtinJoin(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 2 {
+		return Value{}, i.runtimeErr(callSpan, "join() expects 2 args: join(array, sep)")
+	}
+	if args[0].Kind != ValArray || args[0].Arr == nil {
+		return Value{}, i.runtimeErr(callSpan, "join() first arg must be an array")
+	}
+	if args[1].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "join() sep must be a string")
+	}
+	sep := args[1].Str
+	elems := args[0].Arr.Elems
+	ss := make([]string, 0, len(elems))
+	for _, v := range elems {
+		ss = append(ss, v.ToString())
+	}
+	return StringValue(strings.Join(ss, sep)), nil
+}
+
+func builtinIndexof(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 2 || args[0].Kind != ValString || args[1].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "indexof() expects 2 string args: indexof(s, sub)")
+	}
+	return NumberValue(float64(runeIndexOf(args[0].Str, args[1].Str))), nil
+}
+
+func builtinLastindexof(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 2 || args[0].Kind != ValString || args[1].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "lastindexof() expects 2 string args: lastindexof(s, sub)")
+	}
+	return NumberValue(float64(runeLastIndexOf(args[0].Str, args[1].Str))), nil
+}
+
+func builtinRepeat(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 2 || args[0].Kind != ValString || args[1].Kind != ValNumber {
+		return Value{}, i.runtimeErr(callSpan, "repeat() expects (string, number): repeat(s, n)")
+	}
+	n := int(args[1].Number)
+	if n < 0 {
+		return Value{}, i.runtimeErr(callSpan, "repeat() n must be >= 0")
+	}
+	return StringValue(strings.Repeat(args[0].Str, n)), nil
+}
+
+func builtinSubstr(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return Value{}, i.runtimeErr(callSpan, "substr() expects 2 or 3 args: substr(s, start [,len])")
+	}
+	if args[0].Kind != ValString || args[1].Kind != ValNumber {
+		return Value{}, i.runtimeErr(callSpan, "substr() expects (string, number [,number])")
+	}
+	start := int(args[1].Number)
+	if args[1].Number != float64(start) {
+		return Value{}, i.runtimeErr(callSpan, "substr() start must be an integer")
+	}
+	if len(args) == 2 {
+		out, ok := substrRunes(args[0].Str, start, nil)
+		if !ok {
+			return Value{}, i.runtimeErr(callSpan, "substr() out of range")
+		}
+		return StringValue(out), nil
+	}
+	if args[2].Kind != ValNumber {
+		return Value{}, i.runtimeErr(callSpan, "substr() len must be a number")
+	}
+	l := int(args[2].Number)
+	if args[2].Number != float64(l) {
+		return Value{}, i.runtimeErr(callSpan, "substr() len must be an integer")
+	}
+	out, ok := substrRunes(args[0].Str, start, &l)
+	if !ok {
+		return Value{}, i.runtimeErr(callSpan, "substr() out of range")
+	}
+	return StringValue(out), nil
+}
+
+func builtinLevenshtein(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 2 || args[0].Kind != ValString || args[1].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "levenshtein() expects 2 string args: levenshtein(a, b)")
+	}
+	return NumberValue(float64(levenshtein(args[0].Str, args[1].Str))), nil
+}
+
+func builtinSimilarity(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 2 || args[0].Kind != ValString || args[1].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "similarity() expects 2 string args: similarity(a, b)")
+	}
+	return NumberValue(similarity(args[0].Str, args[1].Str)), nil
+}
+
+func builtinSoundex(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 || args[0].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "soundex() expects 1 string arg")
+	}
+	return StringValue(soundex(args[0].Str)), nil
+}
+
+func builtinEqualsfold(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 2 || args[0].Kind != ValString || args[1].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "equalsfold() expects 2 string args: equalsfold(a, b)")
+	}
+	return BoolValue(strings.EqualFold(args[0].Str, args[1].Str)), nil
+}
+
+func builtinNaturalcompare(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 2 || args[0].Kind != ValString || args[1].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "naturalcompare() expects 2 string args: naturalcompare(a, b)")
+	}
+	return NumberValue(float64(naturalCompare(args[0].Str, args[1].Str))), nil
+}
+
+// sort(arr [, mode]) -> new sorted array; mode is "default", "fold" or "natural"
+func builtinSort(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return Value{}, i.runtimeErr(callSpan, "sort() expects 1 or 2 args: sort(arr [,mode])")
+	}
+	if args[0].Kind != ValArray {
+		return Value{}, i.runtimeErr(callSpan, "sort() first arg must be an array")
+	}
+	mode := "default"
+	if len(args) == 2 {
+		if args[1].Kind != ValString {
+			return Value{}, i.runtimeErr(callSpan, "sort() mode must be a string")
+		}
+		mode = args[1].Str
+	}
+	out, err := sortValues(args[0].arrayElems(), mode)
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "sort() "+err.Error())
+	}
+	return ArrayValue(out), nil
+}
+
+func builtinFoldcase(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 || args[0].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "foldcase() expects 1 string arg")
+	}
+	return StringValue(foldCase(args[0].Str)), nil
+}
+
+// normalize(s [, form]) -> s in Unicode normal form NFC (default), NFD, NFKC or NFKD
+func builtinNormalize(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return Value{}, i.runtimeErr(callSpan, "normalize() expects 1 or 2 args: normalize(s [,form])")
+	}
+	if args[0].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "normalize() first arg must be a string")
+	}
+	form := "NFC"
+	if len(args) == 2 {
+		if args[1].Kind != ValString {
+			return Value{}, i.runtimeErr(callSpan, "normalize() form must be a string")
+		}
+		form = args[1].Str
+	}
+	out, err := normalizeText(args[0].Str, form)
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "normalize() "+err.Error())
+	}
+	return StringValue(out), nil
+}
+
+// graphemes(s) -> array of user-perceived characters (len() of it is the visible length)
+func builtinGraphemes(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 || args[0].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "graphemes() expects 1 string arg")
+	}
+	parts := graphemes(args[0].Str)
+	out := make([]Value, len(parts))
+	for k, g := range parts {
+		out[k] = StringValue(g)
+	}
+	return ArrayValue(out), nil
+}
+
+// escapehtml(s), escapejson(s), escapeshell(s), escaperegex(s) -> s made safe
+// to paste into HTML, a JSON string, a shell command or a regular expression
+func builtinEscape(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 || args[0].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, name+"() expects 1 string arg")
+	}
+	return StringValue(escapers[name](args[0].Str)), nil
+}
@@ -0,0 +1,246 @@
+package interpreter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"bpl-plus/ast"
+)
+
+func init() {
+	registerBuiltins(map[string]builtinFunc{
+		"debugbreak": builtinDebugbreak,
+		"notify":     builtinNotify,
+		"args":       builtinArgs,
+		"parseargs":  builtinParseargs,
+		"usage":      builtinUsage,
+		"exit":       builtinExit,
+		"atexit":     builtinAtexit,
+		"stats":      builtinStats,
+		"bench":      builtinBench,
+		"retry":      builtinRetry,
+		"ratelimit":  builtinRatelimit,
+		"platform":   builtinPlatform,
+		"cpucount":   builtinCpucount,
+		"memfree":    builtinMemfree,
+	})
+}
+
+func builtinDebugbreak(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 0 {
+		return Value{}, i.runtimeErr(callSpan, "debugbreak() expects 0 args")
+	}
+	i.debugBreak(callSpan)
+	return NullValue(), nil
+}
+
+func builtinNotify(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// notify(title, message) -> bool (false when no notifier is available)
+	if len(args) != 2 {
+		return Value{}, i.runtimeErr(callSpan, "notify() expects 2 args: notify(title, message)")
+	}
+	return BoolValue(showNotification(args[0].ToString(), args[1].ToString())), nil
+}
+
+func builtinArgs(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// args() -> array of command-line arguments after the script name
+	if len(args) != 0 {
+		return Value{}, i.runtimeErr(callSpan, "args() expects 0 args")
+	}
+	out := make([]Value, len(i.scriptArgs))
+	for idx, a := range i.scriptArgs {
+		out[idx] = StringValue(a)
+	}
+	return ArrayValue(out), nil
+}
+
+// argSpecFrom validates the spec map shared by parseargs() and usage().
+func (i *Interpreter) argSpecFrom(fname string, args []Value, callSpan ast.Span) (*argSpec, error) {
+	if len(args) != 1 || args[0].Kind != ValMap {
+		return nil, i.runtimeErr(callSpan, fmt.Sprintf("%s() expects 1 map arg: %s(spec)", fname, fname))
+	}
+	prog := strings.TrimSuffix(filepath.Base(i.filename), ".bpl")
+	spec, err := parseArgSpec(args[0].mapElems(), prog)
+	if err != nil {
+		return nil, i.runtimeErr(callSpan, fname+"() "+err.Error())
+	}
+	return spec, nil
+}
+
+func builtinParseargs(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// parseargs(spec) -> map of flag/positional values; -h prints usage and exits
+	spec, err := i.argSpecFrom("parseargs", args, callSpan)
+	if err != nil {
+		return Value{}, err
+	}
+	out, perr := spec.parse(i.scriptArgs)
+	if perr == errHelpRequested {
+		fmt.Print(spec.usage())
+		return Value{}, ExitSignal{Code: 0}
+	}
+	if perr != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n\n%s", spec.name, perr.Error(), spec.usage())
+		return Value{}, ExitSignal{Code: 2}
+	}
+	return MapValue(out), nil
+}
+
+func builtinUsage(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// usage(spec) -> the help text parseargs() would print
+	spec, err := i.argSpecFrom("usage", args, callSpan)
+	if err != nil {
+		return Value{}, err
+	}
+	return StringValue(spec.usage()), nil
+}
+
+func builtinExit(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// exit([code]) stops the program
+	if len(args) > 1 {
+		return Value{}, i.runtimeErr(callSpan, "exit() expects 0 or 1 args: exit(code)")
+	}
+	code := 0
+	if len(args) == 1 {
+		c, err := i.toIndex(args[0], callSpan)
+		if err != nil {
+			return Value{}, i.runtimeErr(callSpan, "exit() code must be an integer")
+		}
+		code = c
+	}
+	return Value{}, ExitSignal{Code: code}
+}
+
+func builtinAtexit(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// atexit(fn) runs the function (a value or a name) when the program finishes
+	if len(args) != 1 || (args[0].Kind != ValString && args[0].Kind != ValFunction) {
+		return Value{}, i.runtimeErr(callSpan, "atexit() expects a function name or function value: atexit(fn)")
+	}
+	fn, err := i.userFunctionArg(args[0])
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "atexit() "+err.Error())
+	}
+	if !acceptsArgs(fn.Decl, 0) {
+		return Value{}, i.runtimeErr(callSpan, fmt.Sprintf("atexit() function %q must take no args", funcName(fn.Decl)))
+	}
+	i.exitHooks = append(i.exitHooks, exitHook{fn: fn, span: callSpan})
+	return NullValue(), nil
+}
+
+func builtinStats(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// stats() -> map of resource counters (same numbers as --stats)
+	if len(args) != 0 {
+		return Value{}, i.runtimeErr(callSpan, "stats() expects 0 args")
+	}
+	return i.StatsMap(), nil
+}
+
+func builtinBench(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// bench(fnName [, iterations]) -> timing map (seconds); no count = auto
+	if len(args) != 1 && len(args) != 2 {
+		return Value{}, i.runtimeErr(callSpan, "bench() expects 1 or 2 args: bench(fnName, iterations)")
+	}
+	if args[0].Kind != ValString && args[0].Kind != ValFunction {
+		return Value{}, i.runtimeErr(callSpan, "bench() fnName must be a string")
+	}
+	fn, err := i.userFunctionArg(args[0])
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "bench() "+err.Error())
+	}
+	n := 0
+	if len(args) == 2 {
+		c, err := i.toIndex(args[1], callSpan)
+		if err != nil || c <= 0 {
+			return Value{}, i.runtimeErr(callSpan, "bench() iterations must be a positive integer")
+		}
+		n = c
+	}
+	if !acceptsArgs(fn.Decl, 0) {
+		return Value{}, i.runtimeErr(callSpan, fmt.Sprintf("bench() function %q must take no args", funcName(fn.Decl)))
+	}
+	res, err := i.bench(fn, n, callSpan)
+	if err != nil {
+		return Value{}, err
+	}
+	return benchResultValue(res), nil
+}
+
+func builtinRetry(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// retry(fn, attempts [, backoffms]) -> fn()'s result; waits backoffms
+	// (default 0) before the second attempt, doubling each time
+	if len(args) != 2 && len(args) != 3 {
+		return Value{}, i.runtimeErr(callSpan, "retry() expects 2 or 3 args: retry(fn, attempts, backoffms)")
+	}
+	fn, err := i.callableArg(args[0])
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "retry() "+err.Error())
+	}
+	attempts, err := i.toIndex(args[1], callSpan)
+	if err != nil || attempts <= 0 {
+		return Value{}, i.runtimeErr(callSpan, "retry() attempts must be a positive integer")
+	}
+	backoff := 0
+	if len(args) == 3 {
+		backoff, err = i.toIndex(args[2], callSpan)
+		if err != nil || backoff < 0 {
+			return Value{}, i.runtimeErr(callSpan, "retry() backoffms must be a non-negative integer")
+		}
+	}
+	return i.retryCall(fn, attempts, time.Duration(backoff)*time.Millisecond, callSpan)
+}
+
+func builtinRatelimit(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// ratelimit(n, per_ms [, fn]) -> a function that allows n calls per per_ms
+	// milliseconds, waiting when over the limit; with fn, it calls fn after waiting
+	if len(args) != 2 && len(args) != 3 {
+		return Value{}, i.runtimeErr(callSpan, "ratelimit() expects 2 or 3 args: ratelimit(n, per_ms, fn)")
+	}
+	n, err := i.toIndex(args[0], callSpan)
+	if err != nil || n <= 0 {
+		return Value{}, i.runtimeErr(callSpan, "ratelimit() n must be a positive integer")
+	}
+	per, err := i.toIndex(args[1], callSpan)
+	if err != nil || per <= 0 {
+		return Value{}, i.runtimeErr(callSpan, "ratelimit() per_ms must be a positive integer")
+	}
+	var wrapped *Value
+	if len(args) == 3 {
+		fn, err := i.callableArg(args[2])
+		if err != nil {
+			return Value{}, i.runtimeErr(callSpan, "ratelimit() "+err.Error())
+		}
+		wrapped = &fn
+	}
+	r := &rateLimiter{n: n, per: time.Duration(per) * time.Millisecond}
+	return rateLimitedValue(r, wrapped), nil
+}
+
+func builtinPlatform(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// platform() -> {"os", "arch", "sep", "listsep"}, e.g. os "linux", arch "arm64", sep "/"
+	if len(args) != 0 {
+		return Value{}, i.runtimeErr(callSpan, "platform() expects 0 args")
+	}
+	return platformInfo(), nil
+}
+
+func builtinCpucount(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// cpucount() -> number of logical CPUs
+	if len(args) != 0 {
+		return Value{}, i.runtimeErr(callSpan, "cpucount() expects 0 args")
+	}
+	return NumberValue(float64(runtime.NumCPU())), nil
+}
+
+func builtinMemfree(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// memfree() -> bytes of memory available, or null if the OS will not say
+	if len(args) != 0 {
+		return Value{}, i.runtimeErr(callSpan, "memfree() expects 0 args")
+	}
+	if n, ok := freeMemory(); ok {
+		return NumberValue(float64(n)), nil
+	}
+	return NullValue(), nil
+}
@@ -0,0 +1,109 @@
+package interpreter
+
+import (
+	"time"
+
+	"bpl-plus/ast"
+)
+
+func init() {
+	registerBuiltins(map[string]builtinFunc{
+		"parseduration":  builtinParseduration,
+		"formatduration": builtinFormatduration,
+		"schedule":       builtinSchedule,
+		"unschedule":     builtinUnschedule,
+		"runscheduler":   builtinRunscheduler,
+		"cronnext":       builtinCronnext,
+	})
+}
+
+func builtinParseduration(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 || args[0].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "parseduration() expects 1 string arg: parseduration(\"1h30m\")")
+	}
+	sec, err := parseDuration(args[0].Str)
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "parseduration() "+err.Error())
+	}
+	return NumberValue(sec), nil
+}
+
+func builtinFormatduration(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) != 1 || args[0].Kind != ValNumber {
+		return Value{}, i.runtimeErr(callSpan, "formatduration() expects 1 number arg: formatduration(seconds)")
+	}
+	return StringValue(formatDuration(args[0].Number)), nil
+}
+
+func builtinSchedule(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// schedule(cron, fn) -> job id; fn() runs on the schedule once runscheduler() is called
+	if len(args) != 2 || args[0].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "schedule() expects 2 args: schedule(\"*/5 * * * *\", fn)")
+	}
+	spec, err := parseCron(args[0].Str)
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "schedule() "+err.Error())
+	}
+	fn, err := i.callableArg(args[1])
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "schedule() "+err.Error())
+	}
+	id, err := i.addJob(spec, fn, callSpan)
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "schedule() "+err.Error())
+	}
+	return NumberValue(float64(id)), nil
+}
+
+func builtinUnschedule(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// unschedule(id) -> true if the job existed
+	if len(args) != 1 {
+		return Value{}, i.runtimeErr(callSpan, "unschedule() expects 1 arg: unschedule(jobId)")
+	}
+	id, err := i.toIndex(args[0], callSpan)
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "unschedule() job id must be an integer")
+	}
+	return BoolValue(i.removeJob(id)), nil
+}
+
+func builtinRunscheduler(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// runscheduler([maxRuns]) -> jobs run; blocks until no jobs are left
+	// (or maxRuns jobs have run)
+	if len(args) > 1 {
+		return Value{}, i.runtimeErr(callSpan, "runscheduler() expects 0 or 1 args: runscheduler(maxRuns)")
+	}
+	maxRuns := 0
+	if len(args) == 1 {
+		n, err := i.toIndex(args[0], callSpan)
+		if err != nil || n <= 0 {
+			return Value{}, i.runtimeErr(callSpan, "runscheduler() maxRuns must be a positive integer")
+		}
+		maxRuns = n
+	}
+	runs, err := i.runScheduler(maxRuns)
+	if err != nil {
+		return Value{}, err
+	}
+	return NumberValue(float64(runs)), nil
+}
+
+func builtinCronnext(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	// cronnext(cron) -> "YYYY-MM-DD HH:MM" of the next run (local time)
+	if len(args) != 1 || args[0].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "cronnext() expects 1 string arg: cronnext(\"0 9 * * mon-fri\")")
+	}
+	spec, err := parseCron(args[0].Str)
+	if err != nil {
+		return Value{}, i.runtimeErr(callSpan, "cronnext() "+err.Error())
+	}
+	next := spec.next(time.Now())
+	if next.IsZero() {
+		return Value{}, i.runtimeErr(callSpan, "cronnext() schedule never fires")
+	}
+	layout := "2006-01-02 15:04"
+	if spec.every > 0 {
+		layout = "2006-01-02 15:04:05"
+	}
+	return StringValue(next.Format(layout)), nil
+}
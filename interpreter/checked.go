@@ -0,0 +1,39 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+
+	"bpl-plus/ast"
+	"bpl-plus/codes"
+)
+
+// Whole numbers below 2^53 in size are exact; from 2^53 on, neighbouring
+// integers round to the same number (2^53 + 1 == 2^53).
+const maxSafeInt = 1<<53 - 1
+
+// checkArith enforces option checked on the result of a op b: no infinities
+// or NaN (so x / 0 is an error), and whole-number arithmetic must not leave
+// the range where every integer is exact, since past it results are silently
+// rounded.
+func (i *Interpreter) checkArith(expr *ast.BinaryExpr, a, b, res float64) (Value, error) {
+	if !expr.Checked {
+		return NumberValue(res), nil
+	}
+	if expr.Op == "/" && b == 0 {
+		return Value{}, i.codedErr(expr.GetSpan(), codes.DivisionByZero, "Division by zero (option checked)")
+	}
+	if math.IsInf(res, 0) || math.IsNaN(res) {
+		return Value{}, i.runtimeErr(expr.GetSpan(), fmt.Sprintf("Arithmetic overflow: %s %s %s is not a finite number (option checked)",
+			formatNumber(a), expr.Op, formatNumber(b)))
+	}
+	if isWhole(a) && isWhole(b) && isWhole(res) && math.Abs(res) > maxSafeInt {
+		return Value{}, i.runtimeErr(expr.GetSpan(), fmt.Sprintf("Integer overflow: %s %s %s is beyond 2^53 - 1 and would lose precision (option checked)",
+			formatNumber(a), expr.Op, formatNumber(b)))
+	}
+	return NumberValue(res), nil
+}
+
+func isWhole(x float64) bool {
+	return x == math.Trunc(x) && !math.IsInf(x, 0)
+}
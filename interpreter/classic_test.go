@@ -0,0 +1,61 @@
+package interpreter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bpl-plus/lexer"
+	"bpl-plus/parser"
+)
+
+// An option classic file folds the names it declares, but still reaches
+// mixed-case names defined elsewhere: module exports, record fields and
+// namespaced builtins.
+func TestOptionClassicReachesMixedCaseNames(t *testing.T) {
+	dir := t.TempDir()
+	util := `type Person
+  Name = ""
+  function greet()
+    return "hi " + self.Name
+  end
+end
+function sayHi()
+  return "hi"
+end
+function makePerson(n)
+  return Person(n)
+end
+export sayHi, makePerson
+`
+	if err := os.WriteFile(filepath.Join(dir, "util.bpl"), []byte(util), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, src, want string
+	}{
+		{"named import", "import sayHi from \"util.bpl\"\nR = SAYHI()", "hi"},
+		{"plain import", "import \"util.bpl\"\nR = sayHi()", "hi"},
+		{"field as written", "import makePerson from \"util.bpl\"\nR = makePerson(\"Ann\").Name", "Ann"},
+		{"field in another case", "import makePerson from \"util.bpl\"\nR = MAKEPERSON(\"Ann\").NAME", "Ann"},
+		{"method in another case", "import makePerson from \"util.bpl\"\nR = makePerson(\"Ann\").GREET()", "hi Ann"},
+		{"field assignment", "import makePerson from \"util.bpl\"\nP = makePerson(\"Ann\")\nP.NAME = \"Bo\"\nR = p.Name", "Bo"},
+		{"namespaced builtin", "R = STR(MATH.FLOOR(2.5))", "2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := "option classic\n" + tt.src + "\n"
+			prog, err := parser.New(lexer.New(src)).ParseProgram()
+			if err != nil {
+				t.Fatal(err)
+			}
+			in := NewWithSource(filepath.Join(dir, "main.bpl"), src)
+			if err := in.Run(prog); err != nil {
+				t.Fatal(err)
+			}
+			if got := in.globals["r"]; got.Kind != ValString || got.Str != tt.want {
+				t.Errorf("r = %s, want %q", got.ToString(), tt.want)
+			}
+		})
+	}
+}
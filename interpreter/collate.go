@@ -0,0 +1,139 @@
+package interpreter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ---------- String ordering ----------
+
+// naturalCompare orders strings the way people expect file names to sort:
+// runs of digits compare by numeric value ("file2" < "file10") and other text
+// compares case-insensitively. Strings that only differ in case or leading
+// zeros fall back to a plain comparison so the order is still total.
+// Returns -1, 0 or 1.
+func naturalCompare(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	i, j := 0, 0
+	for i < len(ra) && j < len(rb) {
+		if unicode.IsDigit(ra[i]) && unicode.IsDigit(rb[j]) {
+			si, sj := i, j
+			for i < len(ra) && unicode.IsDigit(ra[i]) {
+				i++
+			}
+			for j < len(rb) && unicode.IsDigit(rb[j]) {
+				j++
+			}
+			if c := compareDigits(ra[si:i], rb[sj:j]); c != 0 {
+				return c
+			}
+			continue
+		}
+		ca, cb := unicode.ToLower(ra[i]), unicode.ToLower(rb[j])
+		if ca != cb {
+			return cmpRunes(ca, cb)
+		}
+		i++
+		j++
+	}
+	switch {
+	case i < len(ra):
+		return 1
+	case j < len(rb):
+		return -1
+	}
+	return strings.Compare(a, b)
+}
+
+// compareDigits compares two digit runs by value, ignoring leading zeros.
+func compareDigits(a, b []rune) int {
+	a = trimLeadingZeros(a)
+	b = trimLeadingZeros(b)
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	for k := range a {
+		if a[k] != b[k] {
+			return cmpRunes(a[k], b[k])
+		}
+	}
+	return 0
+}
+
+func trimLeadingZeros(d []rune) []rune {
+	for len(d) > 1 && d[0] == '0' {
+		d = d[1:]
+	}
+	return d
+}
+
+func cmpRunes(a, b rune) int {
+	if a < b {
+		return -1
+	}
+	return 1
+}
+
+// foldCompare compares case-insensitively, falling back to a plain
+// comparison for strings that only differ in case.
+func foldCompare(a, b string) int {
+	if c := strings.Compare(strings.ToLower(a), strings.ToLower(b)); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+// sortModes maps sort() modes to their string comparison.
+var sortModes = map[string]func(a, b string) int{
+	"default": strings.Compare,
+	"fold":    foldCompare,
+	"natural": naturalCompare,
+}
+
+// sortValues returns a sorted copy of elems. Numbers (and decimals) sort
+// numerically and strings use the given mode; numbers sort before strings.
+// Other kinds cannot be sorted.
+func sortValues(elems []Value, mode string) ([]Value, error) {
+	cmpStr, ok := sortModes[mode]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort mode %q (use \"default\", \"fold\" or \"natural\")", mode)
+	}
+	for _, v := range elems {
+		if v.Kind != ValNumber && v.Kind != ValDecimal && v.Kind != ValString {
+			return nil, fmt.Errorf("can only sort numbers and strings")
+		}
+	}
+
+	out := append([]Value(nil), elems...)
+	sort.SliceStable(out, func(a, b int) bool {
+		x, y := out[a], out[b]
+		xs, ys := x.Kind == ValString, y.Kind == ValString
+		switch {
+		case xs && ys:
+			return cmpStr(x.Str, y.Str) < 0
+		case xs != ys:
+			return ys
+		}
+		if x.Kind == ValDecimal || y.Kind == ValDecimal {
+			dx, okx := toDecimal(x)
+			dy, oky := toDecimal(y)
+			if okx && oky {
+				return decimalCmp(dx, dy) < 0
+			}
+		}
+		return sortFloat(x) < sortFloat(y)
+	})
+	return out, nil
+}
+
+func sortFloat(v Value) float64 {
+	if v.Kind == ValDecimal {
+		return v.Dec.Float64()
+	}
+	return v.Number
+}
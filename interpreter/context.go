@@ -1,9 +1,97 @@
 package interpreter
 
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"bpl-plus/ast"
+)
+
 // SetSource updates the interpreter's current "active" source context.
 // This is used by the REPL so runtime errors show correct filename + caret lines,
 // and imports resolve relative to the chunk filename (CWD-anchored).
+// The text is kept under filename, so functions defined by this chunk still
+// point at it when a later chunk calls them.
 func (i *Interpreter) SetSource(filename string, source string) {
-	i.filename = filename
-	i.lines = splitLinesPreserve(source)
+	chunk := i.addChunk(filename, source)
+	i.filename, i.lines = chunk.name, chunk.lines
+	i.mainSource.name, i.mainSource.lines = chunk.name, chunk.lines
+}
+
+// SetInteractive marks the interpreter as a REPL session, where redefining a
+// function is routine and does not warn.
+func (i *Interpreter) SetInteractive(on bool) {
+	i.interactive = on
+}
+
+// WarningHandler receives the warnings found while a program runs, such as a
+// function being redefined or an imported module's analyzer warnings. file
+// is the script or module the warning is about.
+type WarningHandler func(file string, span ast.Span, msg string)
+
+// SetWarningHandler replaces how warnings are reported; nil restores printing
+// them to stderr.
+func (i *Interpreter) SetWarningHandler(h WarningHandler) {
+	i.warnHandler = h
+}
+
+func (i *Interpreter) warn(file string, span ast.Span, msg string) {
+	if i.warnHandler != nil {
+		i.warnHandler(file, span, msg)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s: Warning: %s at %d:%d\n", file, msg, span.Line, span.Col)
+}
+
+// ModuleError is an error found while loading an imported module, before
+// any of it ran (a syntax error or an analyzer error).
+type ModuleError struct {
+	File string
+	Err  error
+}
+
+func (e ModuleError) Error() string { return e.File + ": " + e.Err.Error() }
+func (e ModuleError) Unwrap() error { return e.Err }
+
+// LineReader is how input() and the other prompt builtins talk to the user.
+// Both methods show prompt and return the next line without its trailing
+// newline, or io.EOF once input is exhausted; ReadSecret must not echo.
+type LineReader interface {
+	ReadLine(prompt string) (string, error)
+	ReadSecret(prompt string) (string, error)
+}
+
+// SetLineReader replaces how input() and the other prompt builtins read from
+// the user. The CLI uses this to plug in a line editor when stdin is a
+// terminal; nil restores plain buffered reads from stdin.
+func (i *Interpreter) SetLineReader(r LineReader) {
+	i.lineReader = r
+}
+
+type exitHook struct {
+	fn   *FunctionObject
+	span ast.Span // the atexit() call, used for error locations
+}
+
+// RunExitHooks runs the functions registered with atexit(), most recent
+// first, and clears the list. It is called once the program has finished,
+// whether it ended normally, via exit() or with a runtime error. A failing
+// hook does not stop the others; the errors are returned together.
+func (i *Interpreter) RunExitHooks() error {
+	hooks := i.exitHooks
+	i.exitHooks = nil
+
+	var errs []error
+	for idx := len(hooks) - 1; idx >= 0; idx-- {
+		h := hooks[idx]
+		_, err := i.callClosure(h.fn.Decl, h.fn.Env, nil, h.span)
+		if _, ok := err.(ExitSignal); ok {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
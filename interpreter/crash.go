@@ -0,0 +1,172 @@
+package interpreter
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+)
+
+// ---------- Crash reports ----------
+
+// CrashSnapshot is the program state captured when a RuntimeError is created.
+type CrashSnapshot struct {
+	Globals map[string]Value
+	Frames  []FrameSnapshot // innermost call first, like RuntimeError.Stack
+}
+
+type FrameSnapshot struct {
+	Func   string
+	Locals map[string]Value
+}
+
+// EnableCrashSnapshots makes every RuntimeError carry a copy of the globals and
+// each call frame's locals. It is off by default because it costs a copy per error.
+func (i *Interpreter) EnableCrashSnapshots() {
+	i.crashSnapshots = true
+}
+
+func (i *Interpreter) snapshot() *CrashSnapshot {
+	snap := &CrashSnapshot{Globals: copyEnv(i.globals)}
+	for idx := len(i.callStack) - 1; idx >= 0; idx-- {
+		frame := FrameSnapshot{Func: i.callStack[idx]}
+		if idx < len(i.locals) {
+			top := i.locals[idx]
+			base := top
+			for base.Block {
+				base = base.Parent
+			}
+			frame.Locals = visibleVars(base.Vars, top)
+		}
+		snap.Frames = append(snap.Frames, frame)
+	}
+	return snap
+}
+
+func copyEnv(env map[string]Value) map[string]Value {
+	out := make(map[string]Value, len(env))
+	for k, v := range env {
+		out[k] = v
+	}
+	return out
+}
+
+// CrashReportJSON renders err as the JSON document written by --crash-report.
+func CrashReportJSON(err error) ([]byte, error) {
+	report := map[string]any{
+		"time":  time.Now().Format(time.RFC3339),
+		"error": err.Error(),
+	}
+
+	var rerr RuntimeError
+	if errors.As(err, &rerr) {
+		report["error"] = rerr.Msg
+		if rerr.Code != "" {
+			report["code"] = rerr.Code
+		}
+		report["file"] = rerr.File
+		report["line"] = rerr.Span.Line
+		report["col"] = rerr.Span.Col
+		report["source"] = rerr.Line
+		report["stack"] = rerr.Stack
+		if snap := rerr.Snapshot; snap != nil {
+			report["globals"] = envToJSON(snap.Globals)
+			frames := []any{}
+			for _, f := range snap.Frames {
+				frames = append(frames, map[string]any{
+					"function": f.Func,
+					"locals":   envToJSON(f.Locals),
+				})
+			}
+			report["frames"] = frames
+		}
+	}
+	return json.MarshalIndent(report, "", "  ")
+}
+
+func envToJSON(env map[string]Value) map[string]any {
+	out := make(map[string]any, len(env))
+	for k, v := range env {
+		out[k] = valueToJSON(v)
+	}
+	return out
+}
+
+// valueToJSON converts v into plain Go data for encoding/json. Decimals become
+// strings so no precision is lost; containers already being converted (cycles)
+// become "<cycle>". A range stays short ("1..5") so a crash report cannot grow
+// with it; see dataToJSON.
+func valueToJSON(v Value) any {
+	return valueToJSONSeen(v, map[any]bool{}, false)
+}
+
+// dataToJSON is valueToJSON for tojson() and savedata(): a range becomes the
+// array of its numbers, as [r...] would.
+func dataToJSON(v Value) any {
+	return valueToJSONSeen(v, map[any]bool{}, true)
+}
+
+func valueToJSONSeen(v Value, seen map[any]bool, ranges bool) any {
+	switch v.Kind {
+	case ValNumber:
+		if math.IsNaN(v.Number) || math.IsInf(v.Number, 0) {
+			return v.ToString() // JSON has no NaN/Inf
+		}
+		return v.Number
+	case ValString:
+		return v.Str
+	case ValBool:
+		return v.Bool
+	case ValDecimal:
+		return v.Dec.String()
+	case ValFunction:
+		return v.Fn.String()
+	case ValRange:
+		if !ranges {
+			return v.Rng.String()
+		}
+		out := make([]any, v.Rng.Len())
+		for idx := range out {
+			out[idx] = v.Rng.At(idx)
+		}
+		return out
+	case ValGenerator:
+		return v.Gen.String()
+	case ValArray:
+		if seen[v.Arr] {
+			return "<cycle>"
+		}
+		seen[v.Arr] = true
+		defer delete(seen, v.Arr)
+		out := make([]any, 0, len(v.arrayElems()))
+		for _, el := range v.arrayElems() {
+			out = append(out, valueToJSONSeen(el, seen, ranges))
+		}
+		return out
+	case ValMap:
+		if seen[v.Map] {
+			return "<cycle>"
+		}
+		seen[v.Map] = true
+		defer delete(seen, v.Map)
+		out := make(map[string]any, len(v.mapElems()))
+		for k, el := range v.mapElems() {
+			out[k] = valueToJSONSeen(el, seen, ranges)
+		}
+		return out
+	case ValRecord:
+		// a record becomes an object of its fields
+		if seen[v.Rec] {
+			return "<cycle>"
+		}
+		seen[v.Rec] = true
+		defer delete(seen, v.Rec)
+		out := make(map[string]any, len(v.Rec.Fields))
+		for idx, f := range v.Rec.Type.Decl.Fields {
+			out[f] = valueToJSONSeen(v.Rec.Fields[idx], seen, ranges)
+		}
+		return out
+	default:
+		return nil
+	}
+}
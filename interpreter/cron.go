@@ -0,0 +1,242 @@
+package interpreter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"bpl-plus/ast"
+)
+
+// ---------- Cron schedules ----------
+
+// cronSpec is a parsed schedule: either five cron fields (minute hour
+// day-of-month month day-of-week) or a fixed interval from "@every 5m".
+type cronSpec struct {
+	minute, hour, dom, month, dow [64]bool
+	domAny, dowAny                bool // field was "*" (matters for the day rule)
+	every                         time.Duration
+}
+
+var cronAliases = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cronMonthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var cronDayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// parseCron accepts standard five-field cron syntax (*, */n, a-b, a-b/n,
+// lists, month and weekday names), the @hourly/@daily/... aliases and
+// "@every <duration>".
+func parseCron(expr string) (*cronSpec, error) {
+	s := strings.ToLower(strings.TrimSpace(expr))
+	if rest, ok := strings.CutPrefix(s, "@every "); ok {
+		sec, err := parseDuration(rest)
+		if err != nil || sec <= 0 {
+			return nil, fmt.Errorf("invalid interval in %q", expr)
+		}
+		return &cronSpec{every: time.Duration(sec * float64(time.Second))}, nil
+	}
+	if alias, ok := cronAliases[s]; ok {
+		s = alias
+	}
+
+	fields := strings.Fields(s)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q (want 5 fields: minute hour day month weekday)", expr)
+	}
+	spec := &cronSpec{domAny: fields[2] == "*", dowAny: fields[4] == "*"}
+	parts := []struct {
+		set       *[64]bool
+		lo, hi    int
+		names     map[string]int
+		what      string
+		sundayIs7 bool
+	}{
+		{&spec.minute, 0, 59, nil, "minute", false},
+		{&spec.hour, 0, 23, nil, "hour", false},
+		{&spec.dom, 1, 31, nil, "day", false},
+		{&spec.month, 1, 12, cronMonthNames, "month", false},
+		{&spec.dow, 0, 7, cronDayNames, "weekday", true},
+	}
+	for k, p := range parts {
+		if err := parseCronField(fields[k], p.set, p.lo, p.hi, p.names); err != nil {
+			return nil, fmt.Errorf("invalid %s field in %q: %v", p.what, expr, err)
+		}
+		if p.sundayIs7 && p.set[7] {
+			p.set[0] = true
+		}
+	}
+	return spec, nil
+}
+
+func parseCronField(field string, set *[64]bool, lo, hi int, names map[string]int) error {
+	for _, item := range strings.Split(field, ",") {
+		rng, stepText, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n <= 0 {
+				return fmt.Errorf("bad step %q", stepText)
+			}
+			step = n
+		}
+
+		from, to := lo, hi
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if from, err = cronValue(a, lo, hi, names); err != nil {
+				return err
+			}
+			to = from
+			if isRange {
+				if to, err = cronValue(b, lo, hi, names); err != nil {
+					return err
+				}
+			} else if hasStep {
+				to = hi // "5/15" means from 5 to the end, every 15
+			}
+			if to < from {
+				return fmt.Errorf("range %q runs backwards", rng)
+			}
+		}
+		for v := from; v <= to; v += step {
+			set[v] = true
+		}
+	}
+	return nil
+}
+
+func cronValue(s string, lo, hi int, names map[string]int) (int, error) {
+	if v, ok := names[s]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < lo || v > hi {
+		return 0, fmt.Errorf("%q is not in %d-%d", s, lo, hi)
+	}
+	return v, nil
+}
+
+// dayMatches applies cron's day rule: when both day-of-month and weekday are
+// restricted, a day matching either one counts.
+func (c *cronSpec) dayMatches(t time.Time) bool {
+	dom, dow := c.dom[t.Day()], c.dow[int(t.Weekday())]
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+// next returns the first matching time strictly after t (local time).
+// The zero time means the schedule never fires (e.g. "0 0 31 2 *").
+func (c *cronSpec) next(t time.Time) time.Time {
+	if c.every > 0 {
+		return t.Add(c.every)
+	}
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !c.month[int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !c.hour[t.Hour()]:
+			// not t.Truncate(time.Hour): that rounds to UTC hours, which are
+			// half past the local hour in zones such as India's
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !c.minute[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// ---------- Scheduler (schedule / runscheduler) ----------
+
+type cronJob struct {
+	id   int
+	spec *cronSpec
+	fn   Value
+	next time.Time
+	span ast.Span // the schedule() call, used for error locations
+}
+
+// addJob registers fn to run on spec and returns its id.
+func (i *Interpreter) addJob(spec *cronSpec, fn Value, span ast.Span) (int, error) {
+	next := spec.next(time.Now())
+	if next.IsZero() {
+		return 0, fmt.Errorf("schedule never fires")
+	}
+	i.lastJobID++
+	i.jobs = append(i.jobs, &cronJob{id: i.lastJobID, spec: spec, fn: fn, next: next, span: span})
+	return i.lastJobID, nil
+}
+
+func (i *Interpreter) removeJob(id int) bool {
+	for k, j := range i.jobs {
+		if j.id == id {
+			i.jobs = append(i.jobs[:k], i.jobs[k+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// runScheduler runs due jobs until none are left, or until maxRuns jobs have
+// run when maxRuns > 0. Jobs run one at a time; a job that is still running
+// when its next slot comes skips that slot. An error from a job stops the
+// scheduler. Returns the number of jobs run.
+func (i *Interpreter) runScheduler(maxRuns int) (int, error) {
+	runs := 0
+	for len(i.jobs) > 0 && (maxRuns <= 0 || runs < maxRuns) {
+		job := i.jobs[0]
+		for _, j := range i.jobs[1:] {
+			if j.next.Before(job.next) {
+				job = j
+			}
+		}
+		if d := time.Until(job.next); d > 0 {
+			time.Sleep(d)
+		}
+
+		runs++
+		if _, err := i.callValue(job.fn, nil, job.span); err != nil {
+			return runs, err
+		}
+		job.next = job.spec.next(maxTime(job.next, time.Now()))
+		if job.next.IsZero() {
+			i.removeJob(job.id)
+		}
+	}
+	return runs, nil
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
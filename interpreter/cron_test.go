@@ -0,0 +1,48 @@
+package interpreter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNextInOffsetZones(t *testing.T) {
+	zones := []*time.Location{
+		time.UTC,
+		time.FixedZone("IST", 5*3600+30*60),      // India, UTC+5:30
+		time.FixedZone("NPT", 5*3600+45*60),      // Nepal, UTC+5:45
+		time.FixedZone("NST", -(3*3600 + 30*60)), // Newfoundland, UTC-3:30
+	}
+	tests := []struct {
+		expr     string
+		from     string // local time in each zone
+		wantNext string
+	}{
+		{"0 11 * * *", "2024-03-10 09:15", "2024-03-10 11:00"},
+		{"0 11 * * *", "2024-03-10 11:00", "2024-03-11 11:00"},
+		{"30 8 * * *", "2024-03-10 07:59", "2024-03-10 08:30"},
+		{"*/15 * * * *", "2024-03-10 10:52", "2024-03-10 11:00"},
+		{"0 0 1 * *", "2024-03-10 12:00", "2024-04-01 00:00"},
+		{"45 23 * * 5", "2024-03-10 12:00", "2024-03-15 23:45"},
+	}
+	for _, loc := range zones {
+		for _, tt := range tests {
+			t.Run(loc.String()+" "+tt.expr+" from "+tt.from, func(t *testing.T) {
+				spec, err := parseCron(tt.expr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				from, err := time.ParseInLocation("2006-01-02 15:04", tt.from, loc)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := spec.next(from)
+				if got.IsZero() {
+					t.Fatalf("%q never fires", tt.expr)
+				}
+				if s := got.In(loc).Format("2006-01-02 15:04"); s != tt.wantNext {
+					t.Errorf("next = %s, want %s", s, tt.wantNext)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package interpreter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ---------- JSON / CSV conversion ----------
+
+// jsonToValue decodes JSON text. Objects become maps, arrays become arrays and
+// JSON null becomes null.
+func jsonToValue(text string) (Value, error) {
+	var data any
+	dec := json.NewDecoder(strings.NewReader(text))
+	if err := dec.Decode(&data); err != nil {
+		return Value{}, fmt.Errorf("invalid JSON: %v", err)
+	}
+	if dec.More() {
+		return Value{}, fmt.Errorf("invalid JSON: unexpected data after the value")
+	}
+	return fromJSONData(data), nil
+}
+
+func fromJSONData(data any) Value {
+	switch d := data.(type) {
+	case float64:
+		return NumberValue(d)
+	case string:
+		return StringValue(d)
+	case bool:
+		return BoolValue(d)
+	case []any:
+		out := make([]Value, len(d))
+		for idx, el := range d {
+			out[idx] = fromJSONData(el)
+		}
+		return ArrayValue(out)
+	case map[string]any:
+		out := make(map[string]Value, len(d))
+		for k, el := range d {
+			out[k] = fromJSONData(el)
+		}
+		return MapValue(out)
+	}
+	return NullValue()
+}
+
+// valueToJSONText encodes v; pretty output is indented by two spaces.
+func valueToJSONText(v Value, pretty bool) (string, error) {
+	var b []byte
+	var err error
+	if pretty {
+		b, err = json.MarshalIndent(dataToJSON(v), "", "  ")
+	} else {
+		b, err = json.Marshal(dataToJSON(v))
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// csvToValue parses CSV text. With a header row, each record becomes a map
+// keyed by the header; otherwise each record is an array. Fields that look
+// like numbers become numbers.
+func csvToValue(text string, comma rune, header bool) (Value, error) {
+	r := csv.NewReader(strings.NewReader(text))
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return Value{}, fmt.Errorf("invalid CSV: %v", err)
+	}
+
+	rows := make([]Value, 0, len(records))
+	if !header {
+		for _, rec := range records {
+			cells := make([]Value, len(rec))
+			for c, f := range rec {
+				cells[c] = csvField(f)
+			}
+			rows = append(rows, ArrayValue(cells))
+		}
+		return ArrayValue(rows), nil
+	}
+
+	if len(records) == 0 {
+		return ArrayValue(rows), nil
+	}
+	names := records[0]
+	for _, rec := range records[1:] {
+		m := make(map[string]Value, len(names))
+		for c, name := range names {
+			if c < len(rec) {
+				m[name] = csvField(rec[c])
+			} else {
+				m[name] = StringValue("")
+			}
+		}
+		rows = append(rows, MapValue(m))
+	}
+	return ArrayValue(rows), nil
+}
+
+// csvField turns a field into a number only when it is written as one: a
+// plain decimal such as 42, -3.5 or 0.25. Codes that merely parse as numbers
+// stay text, so a postcode like 02134, a phone number like +4420, 1e3, Inf
+// and NaN keep their exact spelling.
+func csvField(f string) Value {
+	t := strings.TrimSpace(f)
+	if t == "" || t[0] == '+' || leadingZero(strings.TrimPrefix(t, "-")) {
+		return StringValue(f)
+	}
+	if n, ok := parsePlainNumber(t); ok {
+		return NumberValue(n)
+	}
+	return StringValue(f)
+}
+
+// leadingZero reports whether digits start with a 0 that is not the whole
+// integer part (007, 0123; but not 0 or 0.5).
+func leadingZero(digits string) bool {
+	return len(digits) > 1 && digits[0] == '0' && digits[1] != '.'
+}
+
+// valueToCSV writes an array of maps (header row = sorted keys) or an array
+// of arrays (no header row).
+func valueToCSV(v Value, comma rune) (string, error) {
+	if v.Kind != ValArray {
+		return "", fmt.Errorf("CSV data must be an array of rows")
+	}
+	rows := v.arrayElems()
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = comma
+
+	if len(rows) > 0 && rows[0].Kind == ValMap {
+		var keys []string
+		seen := map[string]bool{}
+		for _, r := range rows {
+			if r.Kind != ValMap {
+				return "", fmt.Errorf("CSV rows must all be arrays or all be maps")
+			}
+			for k := range r.mapElems() {
+				if !seen[k] {
+					seen[k] = true
+					keys = append(keys, k)
+				}
+			}
+		}
+		sort.Strings(keys)
+		_ = w.Write(keys)
+		for _, r := range rows {
+			rec := make([]string, len(keys))
+			for c, k := range keys {
+				if el, ok := r.mapElems()[k]; ok {
+					rec[c] = el.ToString()
+				}
+			}
+			_ = w.Write(rec)
+		}
+	} else {
+		for _, r := range rows {
+			if r.Kind != ValArray {
+				return "", fmt.Errorf("CSV rows must all be arrays or all be maps")
+			}
+			rec := make([]string, len(r.arrayElems()))
+			for c, el := range r.arrayElems() {
+				rec[c] = el.ToString()
+			}
+			_ = w.Write(rec)
+		}
+	}
+	w.Flush()
+	return buf.String(), w.Error()
+}
+
+// extFormat maps a file extension to "json", "csv" or "tsv" ("" if unknown).
+func extFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json"
+	case ".csv":
+		return "csv"
+	case ".tsv", ".tab":
+		return "tsv"
+	}
+	return ""
+}
+
+// sniffFormat picks the format for loaddata(): the file extension wins, then
+// the first non-blank character of the content, and CSV otherwise.
+func sniffFormat(path, content string) string {
+	if f := extFormat(path); f != "" {
+		return f
+	}
+	if t := strings.TrimSpace(content); strings.HasPrefix(t, "{") || strings.HasPrefix(t, "[") {
+		return "json"
+	}
+	if first, _, _ := strings.Cut(content, "\n"); strings.Contains(first, "\t") && !strings.Contains(first, ",") {
+		return "tsv"
+	}
+	return "csv"
+}
+
+func decodeData(content, format string) (Value, error) {
+	switch format {
+	case "json":
+		return jsonToValue(content)
+	case "csv":
+		return csvToValue(content, ',', true)
+	case "tsv":
+		return csvToValue(content, '\t', true)
+	}
+	return Value{}, fmt.Errorf("unknown format %q (use \"json\", \"csv\" or \"tsv\")", format)
+}
+
+func encodeData(v Value, format string) (string, error) {
+	switch format {
+	case "json":
+		s, err := valueToJSONText(v, true)
+		return s + "\n", err
+	case "csv":
+		return valueToCSV(v, ',')
+	case "tsv":
+		return valueToCSV(v, '\t')
+	}
+	return "", fmt.Errorf("unknown format %q (use \"json\", \"csv\" or \"tsv\")", format)
+}
@@ -0,0 +1,27 @@
+package interpreter
+
+import "testing"
+
+func TestToJSONWritesRangesAsArrays(t *testing.T) {
+	tests := []struct {
+		v    Value
+		want string
+	}{
+		{RangeValue(1, 5), "[1,2,3,4,5]"},
+		{RangeValue(5, 1), "[]"},
+		{MapValue(map[string]Value{"r": RangeValue(0, 2)}), `{"r":[0,1,2]}`},
+	}
+	for _, tt := range tests {
+		got, err := valueToJSONText(tt.v, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("tojson(%s) = %s, want %s", tt.v.ToString(), got, tt.want)
+		}
+	}
+	// crash reports keep a range short, however long it is
+	if got := valueToJSON(RangeValue(1, 1e15)); got != "1..1000000000000000" {
+		t.Errorf("crash report range = %v", got)
+	}
+}
@@ -0,0 +1,110 @@
+package interpreter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"bpl-plus/ast"
+	"bpl-plus/lexer"
+	"bpl-plus/parser"
+)
+
+const debugHelp = `Debugger commands:
+  :continue, :c   resume the script
+  :vars           list variables in the current scope
+  :stack          show the call stack
+  :help           show this help
+Anything else is run as BPL in the current scope (expressions are printed).`
+
+// debugBreak pauses at a debugbreak() call and runs a small prompt in the
+// current scope. It does nothing unless a line reader is installed, which the
+// CLI only does when stdin is a terminal.
+func (i *Interpreter) debugBreak(span ast.Span) {
+	if i.lineReader == nil {
+		return
+	}
+
+	loc := fmt.Sprintf("line %d", span.Line)
+	if i.filename != "" {
+		loc = fmt.Sprintf("%s:%d", i.filename, span.Line)
+	}
+	fmt.Printf("debugbreak at %s — :help for commands, :continue to resume\n", loc)
+	if span.Line > 0 && span.Line-1 < len(i.lines) {
+		fmt.Printf("  %d | %s\n", span.Line, i.lines[span.Line-1])
+	}
+
+	// Errors in typed code should point at the typed line, not the script.
+	savedFile, savedLines := i.filename, i.lines
+	defer func() { i.filename, i.lines = savedFile, savedLines }()
+
+	for {
+		line, err := i.lineReader.ReadLine("(debug) ")
+		if err != nil {
+			fmt.Println()
+			return
+		}
+		cmd := strings.TrimSpace(line)
+		switch cmd {
+		case "":
+			continue
+		case ":continue", ":c":
+			return
+		case ":help":
+			fmt.Println(debugHelp)
+			continue
+		case ":vars":
+			i.debugPrintVars()
+			continue
+		case ":stack":
+			if len(i.callStack) == 0 {
+				fmt.Println("  <top level>")
+			}
+			for idx := len(i.callStack) - 1; idx >= 0; idx-- {
+				fmt.Printf("  at %s()\n", i.callStack[idx])
+			}
+			continue
+		}
+
+		i.filename, i.lines = "<debug>", []string{line}
+		if err := i.debugEval(line); err != nil {
+			fmt.Println(err.Error())
+		}
+		i.filename, i.lines = savedFile, savedLines
+	}
+}
+
+func (i *Interpreter) debugPrintVars() {
+	env := visibleVars(i.currentEnv(), i.scope())
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		fmt.Println("  (no variables)")
+	}
+	for _, name := range names {
+		fmt.Printf("  %s = %s\n", name, i.printString(env[name]))
+	}
+}
+
+// debugEval runs src in the current scope. Input that only parses as an
+// expression (e.g. "total * 2") is printed instead.
+func (i *Interpreter) debugEval(src string) error {
+	prog, err := parser.New(lexer.New(src)).ParseProgram()
+	if err != nil {
+		exprProg, exprErr := parser.New(lexer.New("print " + src)).ParseProgram()
+		if exprErr != nil {
+			return err
+		}
+		prog = exprProg
+		i.lines = []string{"print " + src}
+	}
+	for _, s := range prog {
+		if err := i.execStmt(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package interpreter
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an exact fixed-point number: Unscaled × 10^-Scale.
+// Decimals are immutable; operations always return a new value.
+type Decimal struct {
+	Unscaled *big.Int
+	Scale    int
+}
+
+// Extra fractional digits kept when dividing decimals.
+const decimalDivExtraScale = 16
+
+func DecimalValue(d *Decimal) Value { return Value{Kind: ValDecimal, Dec: d} }
+
+var bigTen = big.NewInt(10)
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+func parseDecimal(s string) (*Decimal, error) {
+	s = strings.TrimSpace(s)
+	orig := s
+	neg := false
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	intPart, fracPart, hasDot := strings.Cut(s, ".")
+	if intPart == "" && fracPart == "" {
+		return nil, fmt.Errorf("invalid decimal %q", orig)
+	}
+	digits := intPart + fracPart
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return nil, fmt.Errorf("invalid decimal %q", orig)
+		}
+	}
+	if hasDot && fracPart == "" && intPart == "" {
+		return nil, fmt.Errorf("invalid decimal %q", orig)
+	}
+	u, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid decimal %q", orig)
+	}
+	if neg {
+		u.Neg(u)
+	}
+	return &Decimal{Unscaled: u, Scale: len(fracPart)}, nil
+}
+
+func decimalFromFloat(f float64) (*Decimal, error) {
+	return parseDecimal(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+// toDecimal converts numbers and decimals; ok is false for other kinds.
+func toDecimal(v Value) (*Decimal, bool) {
+	switch v.Kind {
+	case ValDecimal:
+		return v.Dec, true
+	case ValNumber:
+		d, err := decimalFromFloat(v.Number)
+		if err != nil {
+			return nil, false
+		}
+		return d, true
+	default:
+		return nil, false
+	}
+}
+
+func (d *Decimal) String() string {
+	s := new(big.Int).Abs(d.Unscaled).String()
+	if d.Scale > 0 {
+		if len(s) <= d.Scale {
+			s = strings.Repeat("0", d.Scale-len(s)+1) + s
+		}
+		s = s[:len(s)-d.Scale] + "." + s[len(s)-d.Scale:]
+	}
+	if d.Unscaled.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+func (d *Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// withScale returns d expressed with a larger (or equal) scale.
+func (d *Decimal) withScale(scale int) *big.Int {
+	if scale <= d.Scale {
+		return new(big.Int).Set(d.Unscaled)
+	}
+	return new(big.Int).Mul(d.Unscaled, pow10(scale-d.Scale))
+}
+
+func decimalAdd(a, b *Decimal) *Decimal {
+	scale := max(a.Scale, b.Scale)
+	return &Decimal{Unscaled: new(big.Int).Add(a.withScale(scale), b.withScale(scale)), Scale: scale}
+}
+
+func decimalSub(a, b *Decimal) *Decimal {
+	scale := max(a.Scale, b.Scale)
+	return &Decimal{Unscaled: new(big.Int).Sub(a.withScale(scale), b.withScale(scale)), Scale: scale}
+}
+
+func decimalMul(a, b *Decimal) *Decimal {
+	return &Decimal{Unscaled: new(big.Int).Mul(a.Unscaled, b.Unscaled), Scale: a.Scale + b.Scale}
+}
+
+// decimalDiv divides with decimalDivExtraScale extra digits (half-even), then
+// drops trailing zeros back down to the larger operand scale.
+func decimalDiv(a, b *Decimal) (*Decimal, error) {
+	if b.Unscaled.Sign() == 0 {
+		return nil, fmt.Errorf("division by zero")
+	}
+	keep := max(a.Scale, b.Scale)
+	scale := keep + decimalDivExtraScale
+	// a/b at `scale` digits == a.U * 10^(scale - a.S + b.S) / b.U, computed
+	// with one more digit so rounding can look at it.
+	shift := scale - a.Scale + b.Scale + 1
+	num := new(big.Int).Set(a.Unscaled)
+	den := new(big.Int).Set(b.Unscaled)
+	if shift >= 0 {
+		num.Mul(num, pow10(shift))
+	} else {
+		den.Mul(den, pow10(-shift))
+	}
+	q := new(big.Int).Quo(num, den)
+	out := decimalRound(&Decimal{Unscaled: q, Scale: scale + 1}, scale, "half-even")
+	return out.trimZeros(keep), nil
+}
+
+func (d *Decimal) trimZeros(minScale int) *Decimal {
+	u := new(big.Int).Set(d.Unscaled)
+	scale := d.Scale
+	r := new(big.Int)
+	for scale > minScale {
+		q, rem := new(big.Int).QuoRem(u, bigTen, r)
+		if rem.Sign() != 0 {
+			break
+		}
+		u = q
+		scale--
+	}
+	return &Decimal{Unscaled: u, Scale: scale}
+}
+
+// decimalMod is a floored remainder: the result takes the sign of b.
+func decimalMod(a, b *Decimal) (*Decimal, error) {
+	if b.Unscaled.Sign() == 0 {
+		return nil, fmt.Errorf("modulo by zero")
+	}
+	scale := max(a.Scale, b.Scale)
+	bu := b.withScale(scale)
+	r := new(big.Int).Rem(a.withScale(scale), bu)
+	if r.Sign() != 0 && r.Sign() != bu.Sign() {
+		r.Add(r, bu)
+	}
+	return &Decimal{Unscaled: r, Scale: scale}, nil
+}
+
+func decimalCmp(a, b *Decimal) int {
+	scale := max(a.Scale, b.Scale)
+	return a.withScale(scale).Cmp(b.withScale(scale))
+}
+
+var decimalRoundModes = map[string]bool{
+	"half-up": true, "half-even": true, "half-down": true,
+	"up": true, "down": true, "ceiling": true, "floor": true,
+}
+
+// decimalRound rescales d to `places` fractional digits using mode.
+func decimalRound(d *Decimal, places int, mode string) *Decimal {
+	if places >= d.Scale {
+		return &Decimal{Unscaled: d.withScale(places), Scale: places}
+	}
+	div := pow10(d.Scale - places)
+	q, r := new(big.Int).QuoRem(d.Unscaled, div, new(big.Int))
+	if r.Sign() == 0 {
+		return &Decimal{Unscaled: q, Scale: places}
+	}
+
+	neg := d.Unscaled.Sign() < 0
+	// compare |2r| with div to find which side of the halfway point we are on
+	half := new(big.Int).Mul(new(big.Int).Abs(r), big.NewInt(2)).Cmp(div)
+
+	awayFromZero := false
+	switch mode {
+	case "up":
+		awayFromZero = true
+	case "down":
+		awayFromZero = false
+	case "ceiling":
+		awayFromZero = !neg
+	case "floor":
+		awayFromZero = neg
+	case "half-up":
+		awayFromZero = half >= 0
+	case "half-down":
+		awayFromZero = half > 0
+	default: // half-even
+		awayFromZero = half > 0 || (half == 0 && q.Bit(0) == 1)
+	}
+
+	if awayFromZero {
+		if neg {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return &Decimal{Unscaled: q, Scale: places}
+}
+
+// decimalArith applies a binary operator when either operand is a decimal.
+func decimalArith(op string, a, b *Decimal) (Value, error) {
+	switch op {
+	case "+":
+		return DecimalValue(decimalAdd(a, b)), nil
+	case "-":
+		return DecimalValue(decimalSub(a, b)), nil
+	case "*":
+		return DecimalValue(decimalMul(a, b)), nil
+	case "/":
+		d, err := decimalDiv(a, b)
+		if err != nil {
+			return Value{}, err
+		}
+		return DecimalValue(d), nil
+	case "%":
+		d, err := decimalMod(a, b)
+		if err != nil {
+			return Value{}, err
+		}
+		return DecimalValue(d), nil
+	case "<":
+		return BoolValue(decimalCmp(a, b) < 0), nil
+	case ">":
+		return BoolValue(decimalCmp(a, b) > 0), nil
+	case "<=":
+		return BoolValue(decimalCmp(a, b) <= 0), nil
+	case ">=":
+		return BoolValue(decimalCmp(a, b) >= 0), nil
+	}
+	return Value{}, fmt.Errorf("operator %q is not supported for decimals", op)
+}
@@ -0,0 +1,79 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+
+	"bpl-plus/ast"
+	"bpl-plus/codes"
+)
+
+// execDestructure unpacks the value of [a, b, ...rest] = v or {a, b} = v.
+// The whole value is checked against the pattern before any variable is
+// set, so a mismatch leaves every name as it was.
+func (i *Interpreter) execDestructure(stmt *ast.DestructureStmt) error {
+	v, err := i.evalExpr(stmt.Value)
+	if err != nil {
+		return err
+	}
+	var vals []Value
+	if stmt.Map {
+		vals, err = i.unpackMap(stmt, v)
+	} else {
+		vals, err = i.unpackArray(stmt, v)
+	}
+	if err != nil {
+		return err
+	}
+	for idx, name := range stmt.Names {
+		i.setVar(name, vals[idx])
+	}
+	if stmt.Rest != "" {
+		rest := ArrayValue(vals[len(stmt.Names):])
+		i.noteSize(rest)
+		i.setVar(stmt.Rest, rest)
+	}
+	return nil
+}
+
+func (i *Interpreter) unpackArray(stmt *ast.DestructureStmt, v Value) ([]Value, error) {
+	if v.Kind != ValArray || v.Arr == nil {
+		return nil, i.codedErr(stmt.Value.GetSpan(), codes.UnpackMismatch, fmt.Sprintf("Cannot unpack %s into [%s] (need an array)", kindName(v.Kind), strings.Join(stmt.Names, ", ")))
+	}
+	elems, n := v.Arr.Elems, len(stmt.Names)
+	pattern := "[" + strings.Join(stmt.Names, ", ") + "]"
+	switch {
+	case stmt.Rest == "" && len(elems) > n:
+		return nil, i.codedErr(stmt.Value.GetSpan(), codes.UnpackMismatch, fmt.Sprintf("Expected %d values to unpack into %s, got %d (collect the extras with ...rest)", n, pattern, len(elems)))
+	case stmt.Rest == "" && len(elems) < n:
+		return nil, i.codedErr(stmt.Value.GetSpan(), codes.UnpackMismatch, fmt.Sprintf("Expected %d values to unpack into %s, got %d", n, pattern, len(elems)))
+	case len(elems) < n:
+		return nil, i.codedErr(stmt.Value.GetSpan(), codes.UnpackMismatch, fmt.Sprintf("Expected at least %d values to unpack into %s, got %d", n, pattern, len(elems)))
+	}
+	return append([]Value(nil), elems...), nil
+}
+
+func (i *Interpreter) unpackMap(stmt *ast.DestructureStmt, v Value) ([]Value, error) {
+	vals := make([]Value, len(stmt.Names))
+	switch {
+	case v.Kind == ValMap && v.Map != nil:
+		for idx, name := range stmt.Names {
+			el, ok := v.Map.Elems[name]
+			if !ok {
+				return nil, i.codedErr(stmt.Value.GetSpan(), codes.UnpackMismatch, fmt.Sprintf("Cannot unpack {%s}: the map has no key %q", strings.Join(stmt.Names, ", "), name))
+			}
+			vals[idx] = el
+		}
+	case v.Kind == ValRecord:
+		for idx, name := range stmt.Names {
+			f := v.Rec.Type.fieldIndex(name)
+			if f < 0 {
+				return nil, i.codedErr(stmt.Value.GetSpan(), codes.UnpackMismatch, fmt.Sprintf("Cannot unpack {%s}: %s has no field %s", strings.Join(stmt.Names, ", "), v.Rec.Type.Decl.Name, name))
+			}
+			vals[idx] = v.Rec.Fields[f]
+		}
+	default:
+		return nil, i.codedErr(stmt.Value.GetSpan(), codes.UnpackMismatch, fmt.Sprintf("Cannot unpack %s into {%s} (need a map or record)", kindName(v.Kind), strings.Join(stmt.Names, ", ")))
+	}
+	return vals, nil
+}
@@ -0,0 +1,87 @@
+package interpreter
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// ---------- Streaming downloads (httpdownload) ----------
+
+const (
+	downloadChunk         = 64 * 1024
+	downloadProgressEvery = 100 * time.Millisecond
+
+	// downloadTimeout bounds a whole download, body included, so a server
+	// that stops sending cannot hang the program. It is far longer than
+	// remoteImportTimeout because downloads may be large.
+	downloadTimeout = 30 * time.Minute
+)
+
+// downloadFile streams url to path without holding the body in memory. The
+// data goes to path + ".part" first and is renamed once complete, so a failed
+// download never leaves a truncated file under the real name. progress, if
+// set, is called with the bytes written so far and the total size (-1 when
+// the server does not say), at most every downloadProgressEvery and once at
+// the end.
+func downloadFile(rawURL, path string, progress func(done, total int64) error) (int64, error) {
+	client := &http.Client{Timeout: downloadTimeout}
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, fmt.Errorf("server returned %s", resp.Status)
+	}
+
+	tmp := path + ".part"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return 0, err
+	}
+	done, err := copyWithProgress(f, resp.Body, resp.ContentLength, progress)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return done, err
+	}
+	return done, os.Rename(tmp, path)
+}
+
+func copyWithProgress(w io.Writer, r io.Reader, total int64, progress func(done, total int64) error) (int64, error) {
+	buf := make([]byte, downloadChunk)
+	var done int64
+	last := time.Now()
+	for {
+		n, rerr := r.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return done, err
+			}
+			done += int64(n)
+			if progress != nil && time.Since(last) >= downloadProgressEvery {
+				last = time.Now()
+				if err := progress(done, total); err != nil {
+					return done, err
+				}
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return done, rerr
+		}
+	}
+	if progress != nil {
+		if err := progress(done, total); err != nil {
+			return done, err
+		}
+	}
+	return done, nil
+}
@@ -0,0 +1,109 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ---------- Duration helpers ----------
+// Durations are plain numbers of seconds in BPL.
+
+var durationUnits = map[string]float64{
+	"w":  7 * 86400,
+	"d":  86400,
+	"h":  3600,
+	"m":  60,
+	"s":  1,
+	"ms": 0.001,
+	"us": 0.000001,
+	"ns": 0.000000001,
+}
+
+// parseDuration accepts strings like "1h30m", "2d 4h", "1.5h", "-90s" or "45"
+// (bare numbers are seconds) and returns the total in seconds.
+func parseDuration(s string) (float64, error) {
+	orig := s
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return 0, fmt.Errorf("invalid duration %q", orig)
+	}
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return n, nil
+	}
+
+	sign := 1.0
+	if s[0] == '-' || s[0] == '+' {
+		if s[0] == '-' {
+			sign = -1
+		}
+		s = s[1:]
+	}
+
+	total := 0.0
+	pos := 0
+	for pos < len(s) {
+		if s[pos] == ' ' {
+			pos++
+			continue
+		}
+		start := pos
+		for pos < len(s) && (s[pos] >= '0' && s[pos] <= '9' || s[pos] == '.') {
+			pos++
+		}
+		if start == pos {
+			return 0, fmt.Errorf("invalid duration %q", orig)
+		}
+		n, err := strconv.ParseFloat(s[start:pos], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", orig)
+		}
+		ustart := pos
+		for pos < len(s) && s[pos] >= 'a' && s[pos] <= 'z' {
+			pos++
+		}
+		mult, ok := durationUnits[s[ustart:pos]]
+		if !ok {
+			if ustart == pos {
+				return 0, fmt.Errorf("missing unit in duration %q", orig)
+			}
+			return 0, fmt.Errorf("unknown unit %q in duration %q", s[ustart:pos], orig)
+		}
+		total += n * mult
+	}
+	return sign * total, nil
+}
+
+// formatDuration renders seconds as e.g. "1d 2h 3m 4s", "1m 2.5s" or "250ms".
+func formatDuration(sec float64) string {
+	if sec == 0 {
+		return "0s"
+	}
+	sign := ""
+	if sec < 0 {
+		sign = "-"
+		sec = -sec
+	}
+	if sec < 1 {
+		return sign + strconv.FormatFloat(math.Round(sec*1e6)/1e3, 'f', -1, 64) + "ms"
+	}
+
+	parts := []string{}
+	whole := int64(sec)
+	frac := sec - float64(whole)
+	for _, u := range []struct {
+		name string
+		size int64
+	}{{"d", 86400}, {"h", 3600}, {"m", 60}} {
+		if whole >= u.size {
+			parts = append(parts, fmt.Sprintf("%d%s", whole/u.size, u.name))
+			whole %= u.size
+		}
+	}
+	if whole > 0 || frac > 0 {
+		secs := math.Round((float64(whole)+frac)*1000) / 1000
+		parts = append(parts, strconv.FormatFloat(secs, 'f', -1, 64)+"s")
+	}
+	return sign + strings.Join(parts, " ")
+}
@@ -0,0 +1,147 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"bpl-plus/ast"
+)
+
+// Env is one function call's variables. Parent is the scope of the function
+// the callee was defined in, so closures keep seeing (and updating) the
+// variables they captured after that call has returned. Top-level functions
+// have no parent; globals are looked up separately. Globals holds the names
+// the function declared with `global`, which skip its locals entirely.
+//
+// A Block env holds the let declarations of one if/loop/try body. It sits in
+// front of the function's env (or alone, at top level) only while the body
+// runs; plain assignments still go to the function's locals.
+type Env struct {
+	Vars    map[string]Value
+	Parent  *Env
+	Globals map[string]bool
+	Block   bool
+}
+
+func newEnv(parent *Env) *Env {
+	return &Env{Vars: map[string]Value{}, Parent: parent}
+}
+
+// find returns the nearest scope in the chain that defines name, or nil
+// (also when a scope on the way declared name global).
+func (e *Env) find(name string) *Env {
+	for ; e != nil; e = e.Parent {
+		if e.Globals[name] {
+			return nil
+		}
+		if _, ok := e.Vars[name]; ok {
+			return e
+		}
+	}
+	return nil
+}
+
+// isGlobal reports whether name was declared global by this scope or, before
+// any local of that name, by a function it is nested in.
+func (e *Env) isGlobal(name string) bool {
+	for ; e != nil; e = e.Parent {
+		if e.Globals[name] {
+			return true
+		}
+		if _, ok := e.Vars[name]; ok {
+			return false
+		}
+	}
+	return false
+}
+
+// runBlock runs an if/loop/try body. Bodies that declare something with let
+// get a fresh scope for each run, so those names (and closures over them)
+// do not outlive it; other bodies run in the enclosing scope as before.
+func (i *Interpreter) runBlock(body []ast.Stmt) error {
+	if !i.blockHasLet(body) {
+		return i.Run(body)
+	}
+	i.pushBlock()
+	defer i.popBlock()
+	return i.Run(body)
+}
+
+func (i *Interpreter) blockHasLet(body []ast.Stmt) bool {
+	if len(body) == 0 {
+		return false
+	}
+	key := &body[0]
+	has, ok := i.blockLets[key]
+	if ok {
+		return has
+	}
+	for _, s := range body {
+		if _, isLet := s.(*ast.LetStmt); isLet {
+			has = true
+			break
+		}
+	}
+	if i.blockLets == nil {
+		i.blockLets = map[*ast.Stmt]bool{}
+	}
+	i.blockLets[key] = has
+	return has
+}
+
+// pushBlock puts a block scope in front of the current one. Inside a call it
+// temporarily replaces the call's entry in i.locals, so the stack still has
+// one entry per call for crash snapshots.
+func (i *Interpreter) pushBlock() {
+	b := &Env{Vars: map[string]Value{}, Parent: i.scope(), Block: true}
+	if n := len(i.locals); n > 0 {
+		i.locals[n-1] = b
+		return
+	}
+	i.topBlock = b
+}
+
+func (i *Interpreter) popBlock() {
+	if n := len(i.locals); n > 0 {
+		i.locals[n-1] = i.locals[n-1].Parent
+		return
+	}
+	i.topBlock = i.topBlock.Parent
+}
+
+// execLet declares a variable in the innermost block. Outside any block it is
+// an ordinary local (or global at top level), like dim.
+func (i *Interpreter) execLet(stmt *ast.LetStmt) error {
+	val := NullValue()
+	if stmt.Value != nil {
+		v, err := i.evalExpr(stmt.Value)
+		if err != nil {
+			return err
+		}
+		val = v
+	}
+	if s := i.scope(); s != nil && s.Block {
+		if _, ok := s.Vars[stmt.Name]; ok {
+			return i.runtimeErr(stmt.GetSpan(), fmt.Sprintf("%q is already declared with let in this block", stmt.Name))
+		}
+		s.Vars[stmt.Name] = val
+		return nil
+	}
+	i.currentEnv()[stmt.Name] = val
+	return nil
+}
+
+// visibleVars copies base and overlays the let declarations of the blocks
+// open in front of it (innermost wins), for snapshots and the debugger.
+func visibleVars(base map[string]Value, top *Env) map[string]Value {
+	out := copyEnv(base)
+	var blocks []*Env
+	for e := top; e != nil && e.Block; e = e.Parent {
+		blocks = append(blocks, e)
+	}
+	for k := len(blocks) - 1; k >= 0; k-- {
+		for name, v := range blocks[k].Vars {
+			out[name] = v
+		}
+	}
+	return out
+}
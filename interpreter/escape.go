@@ -0,0 +1,34 @@
+package interpreter
+
+import (
+	"bytes"
+	"encoding/json"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// escapers back the escape*() builtins, one per target language.
+var escapers = map[string]func(string) string{
+	"escapehtml":  html.EscapeString,
+	"escapejson":  escapeJSON,
+	"escapeshell": escapeShell,
+	"escaperegex": regexp.QuoteMeta,
+}
+
+// escapeJSON returns s encoded for use inside a JSON string literal, without
+// the surrounding quotes. <, > and & are left alone.
+func escapeJSON(s string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s) // a string always encodes
+	out := strings.TrimSuffix(b.String(), "\n")
+	return out[1 : len(out)-1]
+}
+
+// escapeShell quotes s as one word for a POSIX shell: it is wrapped in single
+// quotes, inside which nothing is special except the single quote itself.
+func escapeShell(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,218 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"bpl-plus/ast"
+)
+
+// ---------- format() ----------
+
+// format(template, args...) fills printf-style placeholders:
+//
+//	%[flags][width][.precision]verb
+//
+// verbs: s (any value as print shows it), d (whole number), f (fixed
+// decimals, 6 by default), e (exponent), x / X (hex), %% (a percent sign).
+// flags: - (left-align), 0 (pad numbers with zeros), + (always show the
+// sign), , (group thousands). Values are right-aligned in the width, which
+// counts graphemes (see padFormatted). %d, %x and %X take whole numbers up to
+// 2^53 - 1 in size, the ones a number holds exactly.
+func builtinFormat(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+	if len(args) == 0 || args[0].Kind != ValString {
+		return Value{}, i.runtimeErr(callSpan, "format() expects a format string, then the values: format(\"%5.2f\", x)")
+	}
+	tmpl, vals := args[0].Str, args[1:]
+
+	var b strings.Builder
+	next := 0
+	for pos := 0; pos < len(tmpl); pos++ {
+		c := tmpl[pos]
+		if c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		spec, end, err := parseFormatSpec(tmpl, pos+1)
+		if err != nil {
+			return Value{}, i.runtimeErr(callSpan, "format() "+err.Error())
+		}
+		pos = end
+		if spec.verb == '%' {
+			b.WriteByte('%')
+			continue
+		}
+		if next >= len(vals) {
+			return Value{}, i.runtimeErr(callSpan, fmt.Sprintf("format() has more placeholders than values (%d given)", len(vals)))
+		}
+		s, err := i.formatOne(spec, vals[next], callSpan)
+		if err != nil {
+			return Value{}, err
+		}
+		next++
+		b.WriteString(s)
+	}
+	if next < len(vals) {
+		return Value{}, i.runtimeErr(callSpan, fmt.Sprintf("format() got %d values but the format uses %d", len(vals), next))
+	}
+	return StringValue(b.String()), nil
+}
+
+type formatSpec struct {
+	left, zero, plus, group bool
+	width                   int
+	precision               int // -1 when not given
+	verb                    byte
+}
+
+// parseFormatSpec reads the placeholder after a '%' starting at pos and
+// returns it with the index of its verb.
+func parseFormatSpec(tmpl string, pos int) (formatSpec, int, error) {
+	spec := formatSpec{precision: -1}
+flags:
+	for ; pos < len(tmpl); pos++ {
+		switch tmpl[pos] {
+		case '-':
+			spec.left = true
+		case '0':
+			spec.zero = true
+		case '+':
+			spec.plus = true
+		case ',':
+			spec.group = true
+		default:
+			break flags
+		}
+	}
+	for pos < len(tmpl) && tmpl[pos] >= '0' && tmpl[pos] <= '9' {
+		spec.width = spec.width*10 + int(tmpl[pos]-'0')
+		pos++
+	}
+	if pos < len(tmpl) && tmpl[pos] == '.' {
+		pos++
+		spec.precision = 0
+		for pos < len(tmpl) && tmpl[pos] >= '0' && tmpl[pos] <= '9' {
+			spec.precision = spec.precision*10 + int(tmpl[pos]-'0')
+			pos++
+		}
+	}
+	if pos >= len(tmpl) {
+		return spec, pos, fmt.Errorf("format string ends inside a placeholder")
+	}
+	spec.verb = tmpl[pos]
+	if !strings.ContainsRune("sdfexX%", rune(spec.verb)) {
+		return spec, pos, fmt.Errorf("unknown placeholder %%%c (use %%s, %%d, %%f, %%e, %%x or %%%%)", spec.verb)
+	}
+	return spec, pos, nil
+}
+
+func (i *Interpreter) formatOne(spec formatSpec, v Value, callSpan ast.Span) (string, error) {
+	if spec.verb == 's' {
+		s, err := i.stringOf(v, callSpan)
+		if err != nil {
+			return "", err
+		}
+		if spec.precision >= 0 {
+			if g := graphemes(s); len(g) > spec.precision {
+				s = strings.Join(g[:spec.precision], "")
+			}
+		}
+		return padFormatted(s, spec), nil
+	}
+
+	if v.Kind != ValNumber && v.Kind != ValDecimal {
+		return "", i.runtimeErr(callSpan, fmt.Sprintf("format() %%%c needs a number, got %s", spec.verb, kindName(v.Kind)))
+	}
+	var digits string
+	switch spec.verb {
+	case 'd', 'x', 'X':
+		n := v.Number
+		if v.Kind == ValDecimal {
+			n = v.Dec.Float64()
+		}
+		if n != math.Trunc(n) || math.IsInf(n, 0) {
+			return "", i.runtimeErr(callSpan, fmt.Sprintf("format() %%%c needs a whole number, got %s", spec.verb, v.ToString()))
+		}
+		if math.Abs(n) > maxSafeInt {
+			return "", i.runtimeErr(callSpan, fmt.Sprintf("format() %%%c needs a whole number up to 2^53 - 1 in size, got %s", spec.verb, v.ToString()))
+		}
+		switch spec.verb {
+		case 'd':
+			digits = strconv.FormatInt(int64(n), 10)
+		case 'x':
+			digits = strconv.FormatInt(int64(n), 16)
+		default:
+			digits = strings.ToUpper(strconv.FormatInt(int64(n), 16))
+		}
+	case 'f':
+		places := spec.precision
+		if places < 0 {
+			places = 6
+		}
+		if v.Kind == ValDecimal {
+			digits = decimalRound(v.Dec, places, "half-up").String()
+		} else {
+			s, err := formatFixed(v.Number, places)
+			if err != nil {
+				return "", i.runtimeErr(callSpan, "format() "+err.Error())
+			}
+			digits = s
+		}
+	case 'e':
+		n := v.Number
+		if v.Kind == ValDecimal {
+			n = v.Dec.Float64()
+		}
+		digits = strconv.FormatFloat(n, 'e', spec.precision, 64)
+	}
+
+	sign := ""
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	} else if spec.plus {
+		sign = "+"
+	}
+	if spec.group && spec.verb != 'x' && spec.verb != 'X' {
+		digits = groupThousands(digits)
+	}
+	if spec.zero && !spec.left {
+		for len(sign)+len(digits) < spec.width {
+			digits = "0" + digits
+		}
+	}
+	return padFormatted(sign+digits, spec), nil
+}
+
+// padFormatted pads s with spaces to the spec's width, on the left unless
+// the - flag asks for left alignment. The width is in graphemes (textWidth):
+// é or a flag emoji counts as one, but so does a CJK character that a
+// terminal shows two columns wide.
+func padFormatted(s string, spec formatSpec) string {
+	gap := spec.width - textWidth(s)
+	if gap <= 0 {
+		return s
+	}
+	if spec.left {
+		return s + strings.Repeat(" ", gap)
+	}
+	return strings.Repeat(" ", gap) + s
+}
+
+// groupThousands puts commas between groups of three digits before the
+// decimal point: 1234567.5 -> 1,234,567.5.
+func groupThousands(digits string) string {
+	whole, frac, hasFrac := strings.Cut(digits, ".")
+	var b strings.Builder
+	for k, r := range whole {
+		if k > 0 && (len(whole)-k)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	if hasFrac {
+		b.WriteString("." + frac)
+	}
+	return b.String()
+}
@@ -0,0 +1,192 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"bpl-plus/ast"
+	"bpl-plus/codes"
+)
+
+// ---------- Function values ----------
+
+// FunctionObject is a callable value: a user function or lambda (Decl) with
+// the scope it closes over (Env, nil for top-level functions), or a builtin
+// referred to by name (Builtin). Native is set for functions made by builtins
+// such as ratelimit(); Builtin then only names them.
+type FunctionObject struct {
+	Decl    *ast.FunctionDecl
+	Env     *Env
+	Builtin string
+	Native  builtinFunc
+}
+
+func FunctionValue(fn *ast.FunctionDecl) Value {
+	return closureValue(fn, nil)
+}
+
+func closureValue(fn *ast.FunctionDecl, env *Env) Value {
+	return Value{Kind: ValFunction, Fn: &FunctionObject{Decl: fn, Env: env}}
+}
+
+func builtinFunctionValue(name string) Value {
+	return Value{Kind: ValFunction, Fn: &FunctionObject{Builtin: name}}
+}
+
+func (f *FunctionObject) String() string {
+	switch {
+	case f.Native != nil:
+		return "<" + f.Builtin + " function>"
+	case f.Builtin != "":
+		return "<builtin " + f.Builtin + ">"
+	case f.Decl.Name == "":
+		return "<function>"
+	default:
+		return "<function " + f.Decl.Name + ">"
+	}
+}
+
+// funcName names fn in call stacks and errors; lambdas have no name.
+func funcName(fn *ast.FunctionDecl) string {
+	if fn.Name == "" {
+		return "<lambda>"
+	}
+	return fn.Name
+}
+
+// acceptsArgs reports whether fn can be called with n args.
+func acceptsArgs(fn *ast.FunctionDecl, n int) bool {
+	if fn.Variadic {
+		return n >= len(fn.Params)-1
+	}
+	return n == len(fn.Params)
+}
+
+// arityText describes how many args fn takes, for error messages.
+func arityText(fn *ast.FunctionDecl) string {
+	if fn.Variadic {
+		return fmt.Sprintf("at least %d", len(fn.Params)-1)
+	}
+	return fmt.Sprint(len(fn.Params))
+}
+
+// sameFunction reports whether two function values call the same code.
+func sameFunction(a, b *FunctionObject) bool {
+	if a == nil || b == nil || a.Native != nil || b.Native != nil {
+		return a == b
+	}
+	return a.Decl == b.Decl && a.Env == b.Env && a.Builtin == b.Builtin
+}
+
+// lookupFunction finds a function by name for use as a value: user functions
+// first, then builtins.
+func (i *Interpreter) lookupFunction(name string) (Value, bool) {
+	if fn, ok := i.funcs[name]; ok {
+		return FunctionValue(fn), true
+	}
+	if IsBuiltin(name) {
+		return builtinFunctionValue(name), true
+	}
+	return Value{}, false
+}
+
+// callableArg accepts either a function value or the name of a function, as
+// taken by callbyname(), apply() and friends.
+func (i *Interpreter) callableArg(v Value) (Value, error) {
+	switch v.Kind {
+	case ValFunction:
+		return v, nil
+	case ValString:
+		if fv, ok := i.lookupFunction(v.Str); ok {
+			return fv, nil
+		}
+		return Value{}, fmt.Errorf("undefined function %q", v.Str)
+	}
+	return Value{}, fmt.Errorf("expects a function or function name")
+}
+
+// userFunctionArg is callableArg for builtins that need a user function
+// (atexit, bench, mockbuiltin).
+func (i *Interpreter) userFunctionArg(v Value) (*FunctionObject, error) {
+	fv, err := i.callableArg(v)
+	if err != nil {
+		return nil, err
+	}
+	if fv.Fn.Decl == nil {
+		return nil, fmt.Errorf("needs a user function, not %s", fv.Fn)
+	}
+	return fv.Fn, nil
+}
+
+// callValue calls a function value with already-evaluated args.
+func (i *Interpreter) callValue(fv Value, args []Value, callSpan ast.Span) (Value, error) {
+	if fv.Kind != ValFunction || fv.Fn == nil {
+		return Value{}, i.codedErr(callSpan, codes.NotCallable, fmt.Sprintf("Cannot call a %s value", kindName(fv.Kind)))
+	}
+	if fv.Fn.Native != nil {
+		return fv.Fn.Native(i, fv.Fn.Builtin, args, callSpan)
+	}
+	if fv.Fn.Builtin != "" {
+		return i.callBuiltin(fv.Fn.Builtin, args, callSpan)
+	}
+	return i.callClosure(fv.Fn.Decl, fv.Fn.Env, args, callSpan)
+}
+
+func (i *Interpreter) evalCallValue(call *ast.CallValueExpr) (Value, error) {
+	fv, err := i.evalExpr(call.Callee)
+	if err != nil {
+		return Value{}, err
+	}
+	args, err := i.evalArgs(call.Args)
+	if err != nil {
+		return Value{}, err
+	}
+	return i.callValue(fv, args, call.GetSpan())
+}
+
+func (i *Interpreter) evalArgs(exprs []ast.Expr) ([]Value, error) {
+	args := make([]Value, 0, len(exprs))
+	for _, a := range exprs {
+		if s, ok := a.(*ast.SpreadExpr); ok {
+			var err error
+			if args, err = i.appendSpread(args, s); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		v, err := i.evalExpr(a)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	return args, nil
+}
+
+// kindName is the user-facing name of a value kind, for error messages.
+func kindName(k ValueKind) string {
+	switch k {
+	case ValNull:
+		return "null"
+	case ValNumber:
+		return "number"
+	case ValString:
+		return "string"
+	case ValBool:
+		return "boolean"
+	case ValArray:
+		return "array"
+	case ValMap:
+		return "map"
+	case ValDecimal:
+		return "decimal"
+	case ValFunction:
+		return "function"
+	case ValRecord:
+		return "record"
+	case ValRange:
+		return "range"
+	case ValGenerator:
+		return "generator"
+	}
+	return "unknown"
+}
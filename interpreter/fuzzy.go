@@ -0,0 +1,90 @@
+package interpreter
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ---------- Fuzzy string matching ----------
+
+// levenshtein returns the edit distance between a and b, counted in runes.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 {
+		return len(rb)
+	}
+	if len(rb) == 0 {
+		return len(ra)
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// similarity is 1 - distance/longer length: 1 for equal strings, 0 for
+// completely different ones.
+func similarity(a, b string) float64 {
+	longest := max(runeLen(a), runeLen(b))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(a, b))/float64(longest)
+}
+
+var soundexCodes = map[rune]byte{
+	'b': '1', 'f': '1', 'p': '1', 'v': '1',
+	'c': '2', 'g': '2', 'j': '2', 'k': '2', 'q': '2', 's': '2', 'x': '2', 'z': '2',
+	'd': '3', 't': '3',
+	'l': '4',
+	'm': '5', 'n': '5',
+	'r': '6',
+}
+
+// soundex returns the American Soundex code (e.g. "Robert" -> "R163"), or ""
+// when s has no ASCII letters.
+func soundex(s string) string {
+	letters := []rune{}
+	for _, r := range strings.ToLower(s) {
+		if r < unicode.MaxASCII && unicode.IsLetter(r) {
+			letters = append(letters, r)
+		}
+	}
+	if len(letters) == 0 {
+		return ""
+	}
+
+	out := []byte{byte(unicode.ToUpper(letters[0]))}
+	last := soundexCodes[letters[0]]
+	for _, r := range letters[1:] {
+		code, ok := soundexCodes[r]
+		switch {
+		case ok && code != last:
+			out = append(out, code)
+			if len(out) == 4 {
+				return string(out)
+			}
+			last = code
+		case !ok && r != 'h' && r != 'w':
+			// vowels separate repeated codes; h and w do not
+			last = 0
+		}
+	}
+	for len(out) < 4 {
+		out = append(out, '0')
+	}
+	return string(out)
+}
@@ -0,0 +1,232 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"bpl-plus/ast"
+)
+
+// GeneratorObject is a call of a function that yields. The body runs on its
+// own goroutine, but only one side runs at a time: the consumer hands control
+// over on resume and waits until the body yields a value or finishes, so the
+// interpreter's state is never touched by both.
+type GeneratorObject struct {
+	Name string
+	fn   *ast.FunctionDecl
+	env  *Env
+	args []Value
+
+	resume chan bool   // true: produce the next value; false: stop
+	yield  chan genMsg // a yielded value, or the end of the body
+	paused execState   // the body's state while it waits for resume
+
+	started  bool
+	running  bool // a value is being produced (the body is not paused)
+	stopping bool // closed early: yields no longer wait for the consumer
+	done     bool
+}
+
+type genMsg struct {
+	val  Value
+	done bool
+	err  error // why the body stopped, if not by finishing or return
+}
+
+// genStop unwinds a generator that was closed early, so the finally blocks
+// around the paused yield still run. It is not a RuntimeError: catch does not
+// see it.
+type genStop struct{}
+
+func (genStop) Error() string { return "generator closed" }
+
+// execState is the part of the interpreter that belongs to whichever code is
+// running: the generator body or the code consuming it.
+type execState struct {
+	locals    []*Env
+	callStack []string
+	running   [][]ast.Stmt
+	gosubs    []string
+	source    sourceFile
+	gen       *GeneratorObject
+}
+
+func (i *Interpreter) saveExec() execState {
+	return execState{
+		locals:    i.locals,
+		callStack: i.callStack,
+		running:   i.running,
+		gosubs:    i.gosubs,
+		source:    sourceFile{name: i.filename, lines: i.lines, globals: i.globals, funcs: i.funcs, data: i.data},
+		gen:       i.gen,
+	}
+}
+
+func (i *Interpreter) loadExec(s execState) {
+	i.locals, i.callStack = s.locals, s.callStack
+	i.running, i.gosubs = s.running, s.gosubs
+	i.restoreSource(s.source)
+	i.gen = s.gen
+}
+
+func GeneratorValue(g *GeneratorObject) Value {
+	return Value{Kind: ValGenerator, Gen: g}
+}
+
+func (g *GeneratorObject) String() string { return "<generator " + g.Name + ">" }
+
+// newGenerator is what calling a generator function gives: nothing of the
+// body runs until the first value is wanted.
+func newGenerator(fn *ast.FunctionDecl, env *Env, argVals []Value) Value {
+	return GeneratorValue(&GeneratorObject{
+		Name:   funcName(fn),
+		fn:     fn,
+		env:    env,
+		args:   argVals,
+		resume: make(chan bool),
+		yield:  make(chan genMsg),
+	})
+}
+
+// genNext runs g up to its next yield. ok is false once the body has
+// finished.
+func (i *Interpreter) genNext(g *GeneratorObject, span ast.Span) (v Value, ok bool, err error) {
+	if g.done {
+		return NullValue(), false, nil
+	}
+	if g.running {
+		return Value{}, false, i.runtimeErr(span, fmt.Sprintf("Generator %q is already running (it cannot consume itself)", g.Name))
+	}
+	msg := i.switchToGenerator(g, true)
+	if msg.done {
+		return NullValue(), false, msg.err
+	}
+	return msg.val, true, nil
+}
+
+// closeGenerator stops g where it is paused, running the finally blocks it
+// is inside. A generator that never started is just marked done.
+func (i *Interpreter) closeGenerator(g *GeneratorObject) error {
+	if g.done || g.running {
+		return nil
+	}
+	if !g.started {
+		g.done = true
+		return nil
+	}
+	return i.switchToGenerator(g, false).err
+}
+
+// switchToGenerator hands control to g and waits for it to yield or end.
+// The body sees the consumer's call stack with its own frames on top; those
+// frames are copied out again when it pauses, so neither side's slices are
+// shared with the other.
+func (i *Interpreter) switchToGenerator(g *GeneratorObject, more bool) genMsg {
+	consumer := i.saveExec()
+	state := g.paused
+	state.locals = append(append([]*Env{}, consumer.locals...), g.paused.locals...)
+	state.callStack = append(append([]string{}, consumer.callStack...), g.paused.callStack...)
+	i.loadExec(state)
+
+	g.running = true
+	if !g.started {
+		g.started = true
+		go i.runGenerator(g)
+	} else {
+		g.resume <- more
+	}
+	msg := <-g.yield
+	g.running = false
+
+	if msg.done {
+		g.done = true
+	} else {
+		g.paused = i.saveExec()
+		g.paused.locals = append([]*Env{}, i.locals[len(consumer.locals):]...)
+		g.paused.callStack = append([]string{}, i.callStack[len(consumer.callStack):]...)
+	}
+	i.loadExec(consumer)
+	return msg
+}
+
+// runGenerator is the generator's goroutine: it sets up the call as
+// callClosure would, then runs the body to its end.
+func (i *Interpreter) runGenerator(g *GeneratorObject) {
+	i.callStack = append(i.callStack, g.Name)
+	i.pushLocals(g.env)
+	i.enterSource(g.fn)
+	i.running, i.gosubs, i.gen = nil, nil, g
+	i.bindParams(g.fn, g.args)
+
+	err := i.Run(g.fn.Body)
+	switch e := err.(type) {
+	case ReturnSignal, genStop:
+		err = nil
+	case GotoSignal:
+		err = i.runtimeErr(e.Span, fmt.Sprintf("goto %s: no label %q in function %q", e.Label, e.Label, g.Name))
+	}
+	g.yield <- genMsg{done: true, err: err}
+}
+
+// execYield hands a value to the consumer and waits to be resumed.
+func (i *Interpreter) execYield(stmt *ast.YieldStmt) error {
+	g := i.gen
+	if g == nil {
+		return i.runtimeErr(stmt.GetSpan(), "yield can only be used inside a function")
+	}
+	val, err := i.evalExpr(stmt.Value)
+	if err != nil {
+		return err
+	}
+	if g.stopping {
+		// a yield in a finally block while closing: nobody is listening
+		return genStop{}
+	}
+	g.yield <- genMsg{val: val}
+	if more := <-g.resume; !more {
+		g.stopping = true
+		return genStop{}
+	}
+	return nil
+}
+
+// forEachGenerated runs body for each value g yields. Leaving the loop early
+// (break, return, an error) closes g, so a generator is consumed once.
+func (i *Interpreter) forEachGenerated(stmt *ast.ForEachStmt, g *GeneratorObject) error {
+	for idx := 0; ; idx++ {
+		v, ok, err := i.genNext(g, stmt.GetSpan())
+		if err != nil || !ok {
+			return err
+		}
+		i.declEnv(stmt.Var)[stmt.Var] = v
+		if stmt.IndexVar != "" {
+			i.declEnv(stmt.IndexVar)[stmt.IndexVar] = NumberValue(float64(idx))
+		}
+		err = i.runBlock(stmt.Body)
+		if err != nil {
+			switch err.(type) {
+			case ContinueSignal:
+				continue
+			case BreakSignal:
+				err = nil
+			}
+			if cerr := i.closeGenerator(g); cerr != nil {
+				return cerr
+			}
+			return err
+		}
+	}
+}
+
+// drainGenerator appends every value g still has to yield to out.
+func (i *Interpreter) drainGenerator(out []Value, g *GeneratorObject, span ast.Span) ([]Value, error) {
+	for {
+		v, ok, err := i.genNext(g, span)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return out, nil
+		}
+		out = append(out, v)
+	}
+}
@@ -0,0 +1,53 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"bpl-plus/ast"
+)
+
+// ---------- gosub / return ----------
+
+// GosubReturnSignal is a bare return ending the innermost gosub.
+type GosubReturnSignal struct{}
+
+func (g GosubReturnSignal) Error() string { return "return" }
+
+// execGosub runs the statements after the label (found in the innermost list
+// of the current call that has it) until a bare return, then carries on
+// after the gosub. i.gosubs is the return stack; each call has its own.
+func (i *Interpreter) execGosub(stmt *ast.GosubStmt) error {
+	for k := len(i.running) - 1; k >= 0; k-- {
+		list := i.running[k]
+		at := labelIndex(list, stmt.Label)
+		if at < 0 {
+			continue
+		}
+
+		i.gosubs = append(i.gosubs, stmt.Label)
+		err := i.runFrom(list, at+1)
+		i.gosubs = i.gosubs[:len(i.gosubs)-1]
+
+		switch err.(type) {
+		case GosubReturnSignal:
+			return nil
+		case nil:
+			return i.runtimeErr(stmt.GetSpan(), fmt.Sprintf("gosub %s reached the end of its block without return", stmt.Label))
+		}
+		return err
+	}
+	return i.runtimeErr(stmt.GetSpan(), fmt.Sprintf("gosub %s: no label %q here", stmt.Label, stmt.Label))
+}
+
+// execBareReturn handles return without a value: it ends the innermost
+// gosub if one is running in this call, and otherwise returns null from the
+// function.
+func (i *Interpreter) execBareReturn(stmt *ast.ReturnStmt) error {
+	if len(i.gosubs) > 0 {
+		return GosubReturnSignal{}
+	}
+	if i.inFunction() {
+		return ReturnSignal{Val: NullValue()}
+	}
+	return i.runtimeErr(stmt.GetSpan(), "return without gosub")
+}
@@ -0,0 +1,128 @@
+//go:build gpio && linux
+
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------- GPIO and PWM through Linux sysfs ----------
+// Built only with `-tags gpio`. Pins are the kernel's GPIO numbers (on newer
+// kernels these can carry a chip offset, e.g. 512+n on a Raspberry Pi).
+
+// sysfsRoot is where the gpio and pwm classes live.
+var sysfsRoot = "/sys/class"
+
+func sysfsWrite(path, value string) error {
+	return os.WriteFile(path, []byte(value), 0)
+}
+
+func sysfsRead(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	return strings.TrimSpace(string(b)), err
+}
+
+// sysfsExport exports n through dir/export and waits for dir/<prefix><n> to
+// become writable (udev fixes the permissions a moment after it appears).
+func sysfsExport(dir, prefix string, n int) (string, error) {
+	node := filepath.Join(dir, prefix+strconv.Itoa(n))
+	if _, err := os.Stat(node); err == nil {
+		return node, nil
+	}
+	if err := sysfsWrite(filepath.Join(dir, "export"), strconv.Itoa(n)); err != nil && !errors.Is(err, os.ErrExist) {
+		return "", err
+	}
+	for tries := 0; tries < 50; tries++ {
+		if f, err := os.OpenFile(node, os.O_RDONLY, 0); err == nil {
+			f.Close()
+			return node, nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return "", fmt.Errorf("%s did not appear after export", node)
+}
+
+func gpioSetup(pin int, direction string) error {
+	if direction != "in" && direction != "out" {
+		return fmt.Errorf("direction must be \"in\" or \"out\"")
+	}
+	node, err := sysfsExport(filepath.Join(sysfsRoot, "gpio"), "gpio", pin)
+	if err != nil {
+		return err
+	}
+	for tries := 0; ; tries++ {
+		err = sysfsWrite(filepath.Join(node, "direction"), direction)
+		if err == nil || !errors.Is(err, os.ErrPermission) || tries == 50 {
+			return err
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func gpioValuePath(pin int) string {
+	return filepath.Join(sysfsRoot, "gpio", "gpio"+strconv.Itoa(pin), "value")
+}
+
+func gpioWrite(pin int, high bool) error {
+	v := "0"
+	if high {
+		v = "1"
+	}
+	return sysfsWrite(gpioValuePath(pin), v)
+}
+
+func gpioRead(pin int) (int, error) {
+	s, err := sysfsRead(gpioValuePath(pin))
+	if err != nil {
+		return 0, err
+	}
+	if s == "0" {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+func gpioRelease(pin int) error {
+	return sysfsWrite(filepath.Join(sysfsRoot, "gpio", "unexport"), strconv.Itoa(pin))
+}
+
+// pwmStart runs channel of pwmchip<chip> at freq Hz with duty in 0..1.
+func pwmStart(chip, channel int, freq, duty float64) error {
+	if freq <= 0 {
+		return fmt.Errorf("frequency must be positive")
+	}
+	if duty < 0 || duty > 1 {
+		return fmt.Errorf("duty must be between 0 and 1")
+	}
+	node, err := sysfsExport(filepath.Join(sysfsRoot, "pwm", "pwmchip"+strconv.Itoa(chip)), "pwm", channel)
+	if err != nil {
+		return err
+	}
+	period := int64(1e9 / freq)
+	// The kernel rejects a period shorter than the current duty cycle, so
+	// clear the duty cycle before changing the period.
+	if err := sysfsWrite(filepath.Join(node, "duty_cycle"), "0"); err != nil {
+		return err
+	}
+	if err := sysfsWrite(filepath.Join(node, "period"), strconv.FormatInt(period, 10)); err != nil {
+		return err
+	}
+	if err := sysfsWrite(filepath.Join(node, "duty_cycle"), strconv.FormatInt(int64(float64(period)*duty), 10)); err != nil {
+		return err
+	}
+	return sysfsWrite(filepath.Join(node, "enable"), "1")
+}
+
+func pwmStop(chip, channel int) error {
+	dir := filepath.Join(sysfsRoot, "pwm", "pwmchip"+strconv.Itoa(chip))
+	if err := sysfsWrite(filepath.Join(dir, "pwm"+strconv.Itoa(channel), "enable"), "0"); err != nil {
+		return err
+	}
+	return sysfsWrite(filepath.Join(dir, "unexport"), strconv.Itoa(channel))
+}
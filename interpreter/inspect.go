@@ -21,6 +21,15 @@ func (i *Interpreter) FuncNames() []string {
 	return names
 }
 
+// Undefine removes the user function name, reporting whether it existed.
+func (i *Interpreter) Undefine(name string) bool {
+	if _, ok := i.funcs[name]; !ok {
+		return false
+	}
+	delete(i.funcs, name)
+	return true
+}
+
 // ModulesSnapshot returns module paths grouped by state.
 // This is REPL-friendly and avoids exposing internal enums.
 func (i *Interpreter) ModulesSnapshot() (loading []string, loaded []string) {
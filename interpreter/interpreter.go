@@ -4,18 +4,18 @@ package interpreter
 import (
 	"bufio"
 	"fmt"
-	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
-	"unicode"
 	"unicode/utf8"
 
+	"bpl-plus/analyzer"
 	"bpl-plus/ast"
-	"bpl-plus/lexer"
-	"bpl-plus/parser"
+	"bpl-plus/codes"
+	"bpl-plus/modcache"
 )
 
 type ValueKind int
@@ -27,16 +27,23 @@ const (
 	ValBool
 	ValArray
 	ValMap
+	ValDecimal
+	ValFunction
+	ValRecord
+	ValRange
+	ValGenerator
 )
 
 // ArrayObject gives arrays reference semantics.
 type ArrayObject struct {
-	Elems []Value
+	Elems  []Value
+	Frozen bool
 }
 
 // MapObject gives maps reference semantics.
 type MapObject struct {
-	Elems map[string]Value
+	Elems  map[string]Value
+	Frozen bool
 }
 
 type Value struct {
@@ -46,6 +53,11 @@ type Value struct {
 	Bool   bool
 	Arr    *ArrayObject
 	Map    *MapObject
+	Dec    *Decimal
+	Fn     *FunctionObject
+	Rec    *RecordObject
+	Rng    *RangeObject
+	Gen    *GeneratorObject
 }
 
 func NullValue() Value            { return Value{Kind: ValNull} }
@@ -76,17 +88,73 @@ func (v Value) mapElems() map[string]Value {
 	return v.Map.Elems
 }
 
-func (v Value) ToString() string {
+func (v Value) isFrozen() bool {
 	switch v.Kind {
-	case ValNumber:
-		if v.Number == float64(int64(v.Number)) {
-			return fmt.Sprintf("%d", int64(v.Number))
+	case ValArray:
+		return v.Arr != nil && v.Arr.Frozen
+	case ValMap:
+		return v.Map != nil && v.Map.Frozen
+	default:
+		return false
+	}
+}
+
+// freezeValue marks v and every container reachable from it as frozen.
+func freezeValue(v Value) {
+	switch v.Kind {
+	case ValArray:
+		if v.Arr == nil || v.Arr.Frozen {
+			return
+		}
+		v.Arr.Frozen = true
+		for _, el := range v.Arr.Elems {
+			freezeValue(el)
 		}
-		return fmt.Sprintf("%g", v.Number)
+	case ValMap:
+		if v.Map == nil || v.Map.Frozen {
+			return
+		}
+		v.Map.Frozen = true
+		for _, el := range v.Map.Elems {
+			freezeValue(el)
+		}
+	}
+}
+
+func (v Value) ToString() string { return v.toString(nil, textStyle{}) }
+
+// toString renders v; seen holds the containers currently being rendered so
+// self-referencing arrays/maps print as [...] / {...} instead of looping forever.
+// st sets the digits of numbers and how many elements containers show (see
+// printString).
+func (v Value) toString(seen map[any]bool, st textStyle) string {
+	switch v.Kind {
+	case ValNumber:
+		return formatDigits(v.Number, st.precision)
 
 	case ValString:
 		return v.Str
 
+	case ValDecimal:
+		return v.Dec.String()
+
+	case ValFunction:
+		return v.Fn.String()
+
+	case ValRecord:
+		if st.record != nil {
+			if s, ok := st.record(v); ok {
+				return s
+			}
+		}
+		return v.Rec.toString(seen, st)
+
+	case ValRange:
+		return v.Rng.String()
+
+	case ValGenerator:
+		return v.Gen.String()
+
 	case ValBool:
 		if v.Bool {
 			return "true"
@@ -94,6 +162,15 @@ func (v Value) ToString() string {
 		return "false"
 
 	case ValArray:
+		if seen[v.Arr] {
+			return "[...]"
+		}
+		if seen == nil {
+			seen = map[any]bool{}
+		}
+		seen[v.Arr] = true
+		defer delete(seen, v.Arr)
+
 		elems := v.arrayElems()
 		var b strings.Builder
 		b.WriteString("[")
@@ -101,7 +178,11 @@ func (v Value) ToString() string {
 			if idx > 0 {
 				b.WriteString(", ")
 			}
-			b.WriteString(el.ToString())
+			if st.limit > 0 && idx == st.limit {
+				b.WriteString(moreText(len(elems) - st.limit))
+				break
+			}
+			b.WriteString(el.toString(seen, st))
 		}
 		b.WriteString("]")
 		return b.String()
@@ -111,6 +192,15 @@ func (v Value) ToString() string {
 		if m == nil {
 			return "{}"
 		}
+		if seen[v.Map] {
+			return "{...}"
+		}
+		if seen == nil {
+			seen = map[any]bool{}
+		}
+		seen[v.Map] = true
+		defer delete(seen, v.Map)
+
 		keys := make([]string, 0, len(m))
 		for k := range m {
 			keys = append(keys, k)
@@ -123,7 +213,11 @@ func (v Value) ToString() string {
 			if idx > 0 {
 				b.WriteString(", ")
 			}
-			b.WriteString(fmt.Sprintf("%q: %s", k, m[k].ToString()))
+			if st.limit > 0 && idx == st.limit {
+				b.WriteString(moreText(len(keys) - st.limit))
+				break
+			}
+			b.WriteString(fmt.Sprintf("%q: %s", k, m[k].toString(seen, st)))
 		}
 		b.WriteString("}")
 		return b.String()
@@ -145,12 +239,28 @@ type ContinueSignal struct{}
 
 func (c ContinueSignal) Error() string { return "continue" }
 
+// GotoSignal travels up from a goto until it reaches the statement list
+// holding its label (the analyzer makes sure there is one).
+type GotoSignal struct {
+	Label string
+	Span  ast.Span
+}
+
+func (g GotoSignal) Error() string { return "goto " + g.Label }
+
 type RuntimeError struct {
 	File  string
 	Span  ast.Span
 	Msg   string
+	Code  string // stable code from package codes, if the error has one
 	Line  string
 	Stack []string
+
+	// The value given to raise, if the error came from a raise statement.
+	Raised *Value
+
+	// Filled in only when crash snapshots are enabled (see EnableCrashSnapshots).
+	Snapshot *CrashSnapshot
 }
 
 func (e RuntimeError) Error() string {
@@ -160,7 +270,11 @@ func (e RuntimeError) Error() string {
 	}
 
 	var b strings.Builder
-	b.WriteString(fmt.Sprintf("Runtime error at %s\n", loc))
+	if e.Code != "" {
+		b.WriteString(fmt.Sprintf("Runtime error %s at %s\n", e.Code, loc))
+	} else {
+		b.WriteString(fmt.Sprintf("Runtime error at %s\n", loc))
+	}
 	b.WriteString(fmt.Sprintf("  %s\n", e.Msg))
 
 	if e.Line != "" && e.Span.Line > 0 {
@@ -174,6 +288,9 @@ func (e RuntimeError) Error() string {
 		b.WriteString(strings.Repeat(" ", caretSpaces))
 		b.WriteString("^\n")
 	}
+	if e.Code != "" {
+		b.WriteString(fmt.Sprintf("  (run 'bplplus explain %s' for help)\n", e.Code))
+	}
 
 	if len(e.Stack) > 0 {
 		b.WriteString("Stack:\n")
@@ -195,18 +312,51 @@ const (
 
 type Interpreter struct {
 	globals map[string]Value
-	locals  []map[string]Value
+	locals  []*Env // one per active call; each links to the scope it closes over
 	funcs   map[string]*ast.FunctionDecl
+	types   map[string]*RecordType
+
+	topBlock  *Env               // innermost block with let declarations at top level
+	blockLets map[*ast.Stmt]bool // whether a block (keyed by its first statement) has a let
+
+	running [][]ast.Stmt // statement lists being run by the current call, innermost last
+	gosubs  []string     // labels of the current call's gosubs awaiting a bare return
+	data    *dataList    // the current file's data statements and read position
 
-	in *bufio.Reader
+	in          *bufio.Reader
+	interactive bool // REPL session: redefining functions does not warn
+	lineReader  LineReader
+	warnHandler WarningHandler
+	scriptArgs  []string
+	exitHooks   []exitHook
+	jobs        []*cronJob // schedule() jobs, run by runscheduler()
+	lastJobID   int
+
+	crashSnapshots bool
+	stats          runStats
+	goldenUpdate   bool
+	mocks          map[string]*FunctionObject // builtin name -> replacement (mockbuiltin)
 
 	filename string
 	lines    []string
 
+	mainSource sourceFile                        // the script being run: its file, globals and functions
+	fnSources  map[*ast.FunctionDecl]*sourceFile // module (or REPL chunk) each function came from
+	chunks     map[string]*sourceFile            // sources that are not files, by synthetic name ("<repl:7>")
+
 	callStack []string
+	gen       *GeneratorObject // the generator whose body is running, if any
 
-	modules     map[string]moduleState
-	moduleStack []string
+	printCol       int // characters printed on the current console line (for tab())
+	printPrecision int // significant digits for print and str(), from setprecision(); 0 is exact round-trip
+	printLimit     int // elements print shows of each container, from setprintlimit(); 0 is all
+
+	modules       map[string]moduleState
+	moduleSources map[string]*sourceFile // loaded modules, for importing their names again
+	moduleStack   []string
+	importRoots   []string    // extra import directories (a project's bpl.mod path lines)
+	offline       bool        // URL imports must come from bpl.lock and the download cache
+	lock          *importLock // bpl.lock, read on the first URL import
 
 	// File handles: #n -> *os.File
 	files map[int]*os.File
@@ -215,18 +365,34 @@ type Interpreter struct {
 }
 
 func NewWithSource(filename string, source string) *Interpreter {
+	main := sourceFile{
+		name:    filename,
+		lines:   splitLinesPreserve(source),
+		globals: map[string]Value{},
+		funcs:   map[string]*ast.FunctionDecl{},
+		data:    &dataList{},
+	}
 	return &Interpreter{
-		globals:     map[string]Value{},
-		locals:      []map[string]Value{},
-		funcs:       map[string]*ast.FunctionDecl{},
-		in:          bufio.NewReader(os.Stdin),
-		filename:    filename,
-		lines:       splitLinesPreserve(source),
-		callStack:   []string{},
-		modules:     map[string]moduleState{},
-		moduleStack: []string{},
-		files:       map[int]*os.File{},
-		readers:     map[int]*bufio.Reader{},
+		globals:        main.globals,
+		data:           main.data,
+		locals:         []*Env{},
+		funcs:          main.funcs,
+		types:          map[string]*RecordType{},
+		in:             bufio.NewReader(os.Stdin),
+		filename:       filename,
+		lines:          main.lines,
+		mainSource:     main,
+		fnSources:      map[*ast.FunctionDecl]*sourceFile{},
+		chunks:         map[string]*sourceFile{},
+		callStack:      []string{},
+		modules:        map[string]moduleState{},
+		moduleSources:  map[string]*sourceFile{},
+		moduleStack:    []string{},
+		files:          map[int]*os.File{},
+		readers:        map[int]*bufio.Reader{},
+		stats:          newRunStats(),
+		printLimit:     defaultPrintLimit,
+		printPrecision: defaultPrintPrecision,
 	}
 }
 
@@ -243,30 +409,115 @@ func splitLinesPreserve(src string) []string {
 
 func (i *Interpreter) inFunction() bool { return len(i.locals) > 0 }
 
+// currentEnv is where new variables go: the running function's own scope, or
+// the globals at top level. Block scopes only hold let declarations.
 func (i *Interpreter) currentEnv() map[string]Value {
+	if f := i.frame(); f != nil {
+		return f.Vars
+	}
+	return i.globals
+}
+
+// scope returns the innermost scope: an open block with let declarations,
+// else the running function's scope, or nil at top level.
+func (i *Interpreter) scope() *Env {
 	if i.inFunction() {
 		return i.locals[len(i.locals)-1]
 	}
+	return i.topBlock
+}
+
+// frame returns the running function's own scope, skipping open blocks, or
+// nil at top level.
+func (i *Interpreter) frame() *Env {
+	s := i.scope()
+	for s != nil && s.Block {
+		s = s.Parent
+	}
+	return s
+}
+
+// lookupVar finds a variable in the current function's scope chain (its
+// locals, then the functions it is nested in), then globals.
+func (i *Interpreter) lookupVar(name string) (Value, bool) {
+	if env := i.scope().find(name); env != nil {
+		return env.Vars[name], true
+	}
+	v, ok := i.globals[name]
+	return v, ok
+}
+
+// setVar assigns name. Inside a function, a variable captured from an
+// enclosing function is updated in place, a name declared global writes the
+// global, and anything else becomes a local.
+func (i *Interpreter) setVar(name string, val Value) {
+	if env := i.scope().find(name); env != nil {
+		env.Vars[name] = val
+		return
+	}
+	i.declEnv(name)[name] = val
+}
+
+// declEnv is where a new variable called name goes: the globals at top level
+// or when the running function declared it global, otherwise its locals.
+func (i *Interpreter) declEnv(name string) map[string]Value {
+	for s := i.scope(); s != nil && s.Block; s = s.Parent {
+		if _, ok := s.Vars[name]; ok {
+			return s.Vars
+		}
+	}
+	if f := i.frame(); f != nil && !f.isGlobal(name) {
+		return f.Vars
+	}
 	return i.globals
 }
 
-func (i *Interpreter) pushLocals() { i.locals = append(i.locals, map[string]Value{}) }
-func (i *Interpreter) popLocals()  { i.locals = i.locals[:len(i.locals)-1] }
+func (i *Interpreter) pushLocals(parent *Env) { i.locals = append(i.locals, newEnv(parent)) }
+func (i *Interpreter) popLocals()             { i.locals = i.locals[:len(i.locals)-1] }
 
-func (i *Interpreter) Run(stmts []ast.Stmt) error {
-	for _, s := range stmts {
-		if err := i.execStmt(s); err != nil {
-			switch err.(type) {
-			case ReturnSignal, BreakSignal, ContinueSignal:
-				return err
-			default:
-				return err
+// Run executes stmts by index, so a goto whose label is in this list (and not
+// in a nested block) continues after the label; other signals and errors
+// return to the caller.
+func (i *Interpreter) Run(stmts []ast.Stmt) error { return i.runFrom(stmts, 0) }
+
+// runFrom is Run starting at stmts[start] (gosub enters a list after its label).
+func (i *Interpreter) runFrom(stmts []ast.Stmt, start int) error {
+	if len(i.running) == 0 && !i.inFunction() {
+		// a program, module or REPL chunk is starting
+		i.loadData(stmts)
+		if chunk, ok := i.chunks[i.filename]; ok {
+			i.trackModuleFuncs(stmts, chunk)
+		}
+	}
+	i.running = append(i.running, stmts)
+	defer func() { i.running = i.running[:len(i.running)-1] }()
+
+	for pc := start; pc < len(stmts); pc++ {
+		err := i.execStmt(stmts[pc])
+		if err == nil {
+			continue
+		}
+		if g, ok := err.(GotoSignal); ok {
+			if at := labelIndex(stmts, g.Label); at >= 0 {
+				pc = at
+				continue
 			}
 		}
+		return err
 	}
 	return nil
 }
 
+// labelIndex returns the position of label in stmts, or -1.
+func labelIndex(stmts []ast.Stmt, label string) int {
+	for idx, s := range stmts {
+		if l, ok := s.(*ast.LabelStmt); ok && l.Name == label {
+			return idx
+		}
+	}
+	return -1
+}
+
 func (i *Interpreter) runtimeErr(span ast.Span, msg string) error {
 	lineText := ""
 	if span.Line > 0 && span.Line-1 < len(i.lines) {
@@ -278,22 +529,31 @@ func (i *Interpreter) runtimeErr(span ast.Span, msg string) error {
 		stack = append(stack, i.callStack[idx])
 	}
 
-	return RuntimeError{
+	rerr := RuntimeError{
 		File:  i.filename,
 		Span:  span,
 		Msg:   msg,
 		Line:  lineText,
 		Stack: stack,
 	}
+	if i.crashSnapshots {
+		rerr.Snapshot = i.snapshot()
+	}
+	return rerr
+}
+
+// codedErr is runtimeErr for the diagnostics that have a code in package
+// codes.
+func (i *Interpreter) codedErr(span ast.Span, code, msg string) error {
+	rerr := i.runtimeErr(span, msg).(RuntimeError)
+	rerr.Code = code
+	return rerr
 }
 
 // Find the environment a variable lives in (locals first, then globals).
 func (i *Interpreter) findVarEnv(name string) (map[string]Value, Value, bool) {
-	if i.inFunction() {
-		env := i.currentEnv()
-		if v, ok := env[name]; ok {
-			return env, v, true
-		}
+	if env := i.scope().find(name); env != nil {
+		return env.Vars, env.Vars[name], true
 	}
 	if v, ok := i.globals[name]; ok {
 		return i.globals, v, true
@@ -302,15 +562,34 @@ func (i *Interpreter) findVarEnv(name string) (map[string]Value, Value, bool) {
 }
 
 func (i *Interpreter) execStmt(s ast.Stmt) error {
+	i.stats.statements++
 	switch stmt := s.(type) {
 	case *ast.ImportStmt:
 		return i.execImport(stmt)
 
+	case *ast.ExportStmt:
+		// read when the module is imported; nothing to do when it runs
+		if i.inFunction() {
+			return i.runtimeErr(stmt.GetSpan(), "export must be at the top level of a module")
+		}
+		return nil
+
 	case *ast.FunctionDecl:
+		if i.inFunction() {
+			// nested functions are local closures over the enclosing call
+			i.currentEnv()[stmt.Name] = closureValue(stmt, i.scope())
+			return nil
+		}
+		if prev, ok := i.funcs[stmt.Name]; ok && prev != stmt && !stmt.Override && !i.interactive {
+			i.warn(i.filename, stmt.S, fmt.Sprintf("function %q replaces an earlier definition (write \"override function\" if this is intended)", stmt.Name))
+		}
 		i.funcs[stmt.Name] = stmt
 		return nil
 
 	case *ast.ReturnStmt:
+		if stmt.Value == nil {
+			return i.execBareReturn(stmt)
+		}
 		if !i.inFunction() {
 			return i.runtimeErr(stmt.GetSpan(), "Return is only valid inside a function")
 		}
@@ -323,6 +602,25 @@ func (i *Interpreter) execStmt(s ast.Stmt) error {
 	case *ast.BreakStmt:
 		return BreakSignal{}
 
+	case *ast.LabelStmt:
+		return nil
+
+	case *ast.GotoStmt:
+		return GotoSignal{Label: stmt.Label, Span: stmt.GetSpan()}
+
+	case *ast.GosubStmt:
+		return i.execGosub(stmt)
+
+	case *ast.DataStmt:
+		// collected before the program starts (see loadData)
+		return nil
+
+	case *ast.ReadStmt:
+		return i.execRead(stmt)
+
+	case *ast.RestoreStmt:
+		return i.execRestore(stmt)
+
 	case *ast.ContinueStmt:
 		return ContinueSignal{}
 
@@ -331,23 +629,43 @@ func (i *Interpreter) execStmt(s ast.Stmt) error {
 		if err != nil {
 			return err
 		}
-		i.currentEnv()[stmt.Name] = val
+		i.setVar(stmt.Name, val)
 		return nil
 
 	case *ast.IndexAssignStmt:
 		return i.execIndexAssign(stmt)
 
+	case *ast.MemberAssignStmt:
+		return i.execMemberAssign(stmt)
+
+	case *ast.SwapStmt:
+		return i.execSwap(stmt)
+
+	case *ast.DestructureStmt:
+		return i.execDestructure(stmt)
+
+	case *ast.TypeDecl:
+		return i.execTypeDecl(stmt)
+
+	case *ast.DimStmt:
+		return i.execDim(stmt)
+
+	case *ast.GlobalStmt:
+		return i.execGlobal(stmt)
+
+	case *ast.LetStmt:
+		return i.execLet(stmt)
+
+	case *ast.OptionStmt:
+		// Options are enforced statically by the analyzer.
+		return nil
+
 	case *ast.ExprStmt:
 		_, err := i.evalExpr(stmt.Expr)
 		return err
 
 	case *ast.PrintStmt:
-		val, err := i.evalExpr(stmt.Value)
-		if err != nil {
-			return err
-		}
-		fmt.Println(val.ToString())
-		return nil
+		return i.execPrint(stmt)
 
 	case *ast.OpenStmt:
 		return i.execOpen(stmt)
@@ -358,6 +676,15 @@ func (i *Interpreter) execStmt(s ast.Stmt) error {
 	case *ast.PrintHandleStmt:
 		return i.execPrintHandle(stmt)
 
+	case *ast.TryStmt:
+		return i.execTry(stmt)
+
+	case *ast.RaiseStmt:
+		return i.execRaise(stmt)
+
+	case *ast.YieldStmt:
+		return i.execYield(stmt)
+
 	case *ast.IfStmt:
 		cond, err := i.evalExpr(stmt.Condition)
 		if err != nil {
@@ -367,9 +694,21 @@ func (i *Interpreter) execStmt(s ast.Stmt) error {
 			return i.runtimeErr(stmt.Condition.GetSpan(), "If condition must be boolean")
 		}
 		if cond.Bool {
-			return i.Run(stmt.Then)
+			return i.runBlock(stmt.Then)
+		}
+		for _, clause := range stmt.ElseIfs {
+			cond, err := i.evalExpr(clause.Condition)
+			if err != nil {
+				return err
+			}
+			if cond.Kind != ValBool {
+				return i.runtimeErr(clause.Condition.GetSpan(), "Elseif condition must be boolean")
+			}
+			if cond.Bool {
+				return i.runBlock(clause.Body)
+			}
 		}
-		return i.Run(stmt.Else)
+		return i.runBlock(stmt.Else)
 
 	case *ast.WhileStmt:
 		for {
@@ -383,7 +722,7 @@ func (i *Interpreter) execStmt(s ast.Stmt) error {
 			if !cond.Bool {
 				break
 			}
-			err = i.Run(stmt.Body)
+			err = i.runBlock(stmt.Body)
 			if err != nil {
 				switch err.(type) {
 				case BreakSignal:
@@ -397,6 +736,31 @@ func (i *Interpreter) execStmt(s ast.Stmt) error {
 		}
 		return nil
 
+	case *ast.RepeatStmt:
+		for {
+			err := i.runBlock(stmt.Body)
+			if err != nil {
+				switch err.(type) {
+				case BreakSignal:
+					return nil
+				case ContinueSignal:
+					// fall through to the condition, like the end of the body
+				default:
+					return err
+				}
+			}
+			cond, err := i.evalExpr(stmt.Condition)
+			if err != nil {
+				return err
+			}
+			if cond.Kind != ValBool {
+				return i.runtimeErr(stmt.Condition.GetSpan(), "Until condition must be boolean")
+			}
+			if cond.Bool {
+				return nil
+			}
+		}
+
 	case *ast.ForStmt:
 		return i.execFor(stmt)
 
@@ -434,15 +798,26 @@ func (i *Interpreter) execOpen(stmt *ast.OpenStmt) error {
 		return i.runtimeErr(stmt.Mode.GetSpan(), "open mode must be a string (\"r\", \"w\", or \"a\")")
 	}
 
-	mode := strings.ToLower(strings.TrimSpace(modeV.Str))
-	path := pathV.Str
+	if err := i.openHandle(stmt.Handle, pathV.Str, modeV.Str); err != nil {
+		return i.runtimeErr(stmt.GetSpan(), err.Error())
+	}
+	return nil
+}
+
+// openHandle opens path on handle #n (closing whatever was open there).
+// Shared by the open statement and tryopen().
+func (i *Interpreter) openHandle(handle int, path, mode string) error {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	if mode != "r" && mode != "w" && mode != "a" {
+		return fmt.Errorf("open mode must be \"r\", \"w\", or \"a\"")
+	}
 
 	// if already open, close first
-	if f, ok := i.files[stmt.Handle]; ok && f != nil {
+	if f, ok := i.files[handle]; ok && f != nil {
 		_ = f.Close()
 	}
-	delete(i.files, stmt.Handle)
-	delete(i.readers, stmt.Handle)
+	delete(i.files, handle)
+	delete(i.readers, handle)
 
 	var f *os.File
 
@@ -455,7 +830,7 @@ func (i *Interpreter) execOpen(stmt *ast.OpenStmt) error {
 		}
 		ff, e := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
 		if e != nil {
-			return i.runtimeErr(stmt.GetSpan(), fmt.Sprintf("open failed: %v", e))
+			return fmt.Errorf("open failed: %v", e)
 		}
 		f = ff
 
@@ -467,22 +842,20 @@ func (i *Interpreter) execOpen(stmt *ast.OpenStmt) error {
 		}
 		ff, e := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 		if e != nil {
-			return i.runtimeErr(stmt.GetSpan(), fmt.Sprintf("open failed: %v", e))
+			return fmt.Errorf("open failed: %v", e)
 		}
 		f = ff
 
 	case "r":
 		ff, e := os.Open(path)
 		if e != nil {
-			return i.runtimeErr(stmt.GetSpan(), fmt.Sprintf("open failed: %v", e))
+			return fmt.Errorf("open failed: %v", e)
 		}
 		f = ff
-
-	default:
-		return i.runtimeErr(stmt.GetSpan(), "open mode must be \"r\", \"w\", or \"a\"")
 	}
 
-	i.files[stmt.Handle] = f
+	i.files[handle] = f
+	i.stats.filesOpened++
 	// Reader will be created lazily (or immediately for read mode if you prefer).
 	return nil
 }
@@ -507,7 +880,11 @@ func (i *Interpreter) execPrintHandle(stmt *ast.PrintHandleStmt) error {
 	if err != nil {
 		return err
 	}
-	_, werr := f.WriteString(v.ToString() + "\n")
+	s, err := i.stringOf(v, stmt.GetSpan())
+	if err != nil {
+		return err
+	}
+	_, werr := f.WriteString(s + "\n")
 	if werr != nil {
 		return i.runtimeErr(stmt.GetSpan(), fmt.Sprintf("print failed: %v", werr))
 	}
@@ -521,14 +898,32 @@ func (i *Interpreter) fileExists(p string) bool {
 	return err == nil
 }
 
+// SetImportRoots adds directories imports are looked up in after the
+// importing file's own directory and before the current directory. The CLI
+// passes a project's bpl.mod path lines here.
+func (i *Interpreter) SetImportRoots(roots []string) {
+	i.importRoots = roots
+}
+
 func (i *Interpreter) projectRootCandidates() []string {
 	return []string{"."}
 }
 
-func (i *Interpreter) importCandidates(raw string, importerFilename string) []string {
+// importCandidate is one path an import may resolve to, with where it came
+// from for the "Tried:" trace.
+type importCandidate struct {
+	Path  string
+	Where string
+}
+
+// importCandidates lists the paths an import is looked up at, in order. The
+// importing file's own directory (and its lib/) always comes first, then any
+// project import roots; the current directory is only a fallback, so a module
+// imports its neighbours the same way no matter where the program is run from.
+func (i *Interpreter) importCandidates(raw string, importerFilename string) []importCandidate {
 	raw = strings.TrimSpace(raw)
 	if raw == "" {
-		return []string{}
+		return []importCandidate{}
 	}
 
 	withExt := raw
@@ -537,69 +932,64 @@ func (i *Interpreter) importCandidates(raw string, importerFilename string) []st
 		withExt = raw + ".bpl"
 	}
 
-	if filepath.IsAbs(raw) {
-		cands := []string{filepath.Clean(raw)}
-		if needsExt {
-			cands = append(cands, filepath.Clean(withExt))
-		}
-		return cands
+	if isURLImport(raw) {
+		return []importCandidate{{Path: raw, Where: "URL"}}
 	}
-
-	baseDir := ""
-	if importerFilename != "" {
-		baseDir = filepath.Dir(importerFilename)
+	if isURLImport(importerFilename) && !filepath.IsAbs(raw) {
+		if c, ok := urlImportCandidate(raw, importerFilename); ok {
+			return []importCandidate{c}
+		}
 	}
 
-	cands := []string{}
-
-	if baseDir != "" {
-		cands = append(cands, filepath.Clean(filepath.Join(baseDir, raw)))
+	cands := []importCandidate{}
+	add := func(dir, where string) {
+		cands = append(cands, importCandidate{filepath.Clean(filepath.Join(dir, raw)), where})
 		if needsExt {
-			cands = append(cands, filepath.Clean(filepath.Join(baseDir, withExt)))
+			cands = append(cands, importCandidate{filepath.Clean(filepath.Join(dir, withExt)), where})
 		}
+	}
 
-		cands = append(cands, filepath.Clean(filepath.Join(baseDir, "lib", raw)))
-		if needsExt {
-			cands = append(cands, filepath.Clean(filepath.Join(baseDir, "lib", withExt)))
-		}
+	if filepath.IsAbs(raw) {
+		add("", "absolute path")
+		return cands
 	}
 
-	for _, root := range i.projectRootCandidates() {
-		cands = append(cands, filepath.Clean(filepath.Join(root, raw)))
-		if needsExt {
-			cands = append(cands, filepath.Clean(filepath.Join(root, withExt)))
-		}
+	if importerFilename != "" && !strings.HasPrefix(importerFilename, "<") {
+		baseDir := filepath.Dir(importerFilename)
+		name := filepath.Base(importerFilename)
+		add(baseDir, "next to "+name)
+		add(filepath.Join(baseDir, "lib"), "lib/ next to "+name)
+	}
 
-		cands = append(cands, filepath.Clean(filepath.Join(root, "lib", raw)))
-		if needsExt {
-			cands = append(cands, filepath.Clean(filepath.Join(root, "lib", withExt)))
-		}
+	for _, root := range i.importRoots {
+		add(root, "project path "+root)
+	}
+	for _, root := range i.projectRootCandidates() {
+		add(root, "current directory")
+		add(filepath.Join(root, "lib"), "lib/ in current directory")
 	}
 
 	seen := map[string]bool{}
-	out := []string{}
+	out := []importCandidate{}
 	for _, c := range cands {
-		if c == "" {
-			continue
-		}
-		if seen[c] {
+		if c.Path == "" || seen[c.Path] {
 			continue
 		}
-		seen[c] = true
+		seen[c.Path] = true
 		out = append(out, c)
 	}
 	return out
 }
 
-func (i *Interpreter) resolveImportPath(raw string, importerFilename string) (string, []string) {
+func (i *Interpreter) resolveImportPath(raw string, importerFilename string) (string, []importCandidate) {
 	cands := i.importCandidates(raw, importerFilename)
 	for _, c := range cands {
-		if i.fileExists(c) {
-			return c, cands
+		if i.fileExists(c.Path) {
+			return c.Path, cands
 		}
 	}
 	if len(cands) > 0 {
-		return cands[0], cands
+		return cands[0].Path, cands
 	}
 	return raw, cands
 }
@@ -617,15 +1007,15 @@ func (i *Interpreter) circularImportMessage(target string) string {
 	return strings.TrimRight(b.String(), "\n")
 }
 
-func (i *Interpreter) execImport(stmt *ast.ImportStmt) error {
-	importerFile := i.filename
-	resolved, tried := i.resolveImportPath(stmt.Path, importerFile)
-
-	switch i.modules[resolved] {
-	case modLoaded:
-		return nil
-	case modLoading:
-		return i.runtimeErr(stmt.GetSpan(), i.circularImportMessage(resolved))
+// readImport loads the source of a resolved import: a local file, or a URL
+// pinned in bpl.lock.
+func (i *Interpreter) readImport(stmt *ast.ImportStmt, resolved string, tried []importCandidate) ([]byte, error) {
+	if isURLImport(resolved) {
+		data, err := i.fetchImport(resolved)
+		if err != nil {
+			return nil, i.runtimeErr(stmt.GetSpan(), fmt.Sprintf("import failed for %q: %v", resolved, err))
+		}
+		return data, nil
 	}
 
 	if !i.fileExists(resolved) {
@@ -633,38 +1023,70 @@ func (i *Interpreter) execImport(stmt *ast.ImportStmt) error {
 		if len(tried) > 0 {
 			msg += "\nTried:\n"
 			for _, c := range tried {
-				msg += "  " + c + "\n"
+				msg += fmt.Sprintf("  %s  (%s)\n", c.Path, c.Where)
 			}
 			msg = strings.TrimRight(msg, "\n")
 		}
-		return i.runtimeErr(stmt.GetSpan(), msg)
+		return nil, i.runtimeErr(stmt.GetSpan(), msg)
 	}
 
 	data, err := os.ReadFile(resolved)
 	if err != nil {
-		return i.runtimeErr(stmt.GetSpan(), fmt.Sprintf("import failed for %q: %v", resolved, err))
+		return nil, i.runtimeErr(stmt.GetSpan(), fmt.Sprintf("import failed for %q: %v", resolved, err))
+	}
+	return data, nil
+}
+
+func (i *Interpreter) execImport(stmt *ast.ImportStmt) error {
+	importerFile := i.filename
+	resolved, tried := i.resolveImportPath(stmt.Path, importerFile)
+
+	switch i.modules[resolved] {
+	case modLoaded:
+		return i.importNames(stmt, i.moduleSources[resolved])
+	case modLoading:
+		return i.runtimeErr(stmt.GetSpan(), i.circularImportMessage(resolved))
 	}
 
-	lx := lexer.New(string(data))
-	p := parser.New(lx)
-	prog, err := p.ParseProgram()
+	data, err := i.readImport(stmt, resolved, tried)
 	if err != nil {
 		return err
 	}
 
+	prog, err := modcache.Parse(string(data))
+	if err != nil {
+		return ModuleError{File: resolved, Err: err}
+	}
+	diags := analyzer.Check(prog, IsBuiltin)
+	if d, ok := analyzer.FirstError(diags); ok {
+		return ModuleError{File: resolved, Err: d}
+	}
+	for _, d := range diags {
+		i.warn(resolved, d.Span, d.Msg)
+	}
+
 	i.modules[resolved] = modLoading
 	i.moduleStack = append(i.moduleStack, resolved)
 
-	prevFile := i.filename
-	prevLines := i.lines
-
-	i.filename = resolved
-	i.lines = splitLinesPreserve(string(data))
-
+	// the module runs with its own globals and functions
+	src := &sourceFile{
+		name:    resolved,
+		lines:   splitLinesPreserve(string(data)),
+		globals: map[string]Value{},
+		funcs:   map[string]*ast.FunctionDecl{},
+		data:    &dataList{},
+	}
+	collectExports(prog, src)
+	i.trackModuleFuncs(prog, src)
+	prev := i.switchSource(src)
+
+	// the module's top level never sees the importer's block scopes or gosubs
+	prevBlock, prevRunning, prevGosubs := i.topBlock, i.running, i.gosubs
+	i.topBlock, i.running, i.gosubs = nil, nil, nil
 	runErr := i.Run(prog)
+	i.topBlock, i.running, i.gosubs = prevBlock, prevRunning, prevGosubs
 
-	i.filename = prevFile
-	i.lines = prevLines
+	i.restoreSource(prev)
 
 	i.moduleStack = i.moduleStack[:len(i.moduleStack)-1]
 
@@ -674,15 +1096,18 @@ func (i *Interpreter) execImport(stmt *ast.ImportStmt) error {
 	}
 
 	i.modules[resolved] = modLoaded
-	return nil
+	i.moduleSources[resolved] = src
+	return i.importNames(stmt, src)
 }
 
 // ---------- Arrays / Maps / Loops ----------
 
 func (i *Interpreter) execIndexAssign(stmt *ast.IndexAssignStmt) error {
-	env, containerVal, ok := i.findVarEnv(stmt.Name)
-	if !ok {
-		return i.runtimeErr(stmt.GetSpan(), fmt.Sprintf("Undefined variable %q", stmt.Name))
+	// Arrays and maps are references, so writing into the evaluated
+	// container updates the variable (or outer container) that holds it.
+	containerVal, err := i.evalExpr(stmt.Target)
+	if err != nil {
+		return err
 	}
 
 	iv, err := i.evalExpr(stmt.Index)
@@ -695,68 +1120,221 @@ func (i *Interpreter) execIndexAssign(stmt *ast.IndexAssignStmt) error {
 		return err
 	}
 
+	return i.setIndex(containerVal, iv, newVal, stmt.GetSpan(), stmt.Index.GetSpan())
+}
+
+// setIndex stores newVal at container[iv]; span is the whole target and
+// indexSpan the index, for errors.
+func (i *Interpreter) setIndex(containerVal, iv, newVal Value, span, indexSpan ast.Span) error {
+	if err := i.checkMutable(containerVal, span); err != nil {
+		return err
+	}
+
 	if containerVal.Kind == ValArray && containerVal.Arr != nil {
-		idx, err := i.toIndex(iv, stmt.Index.GetSpan())
+		idx, err := i.toIndex(iv, indexSpan)
 		if err != nil {
 			return err
 		}
 
 		elems := containerVal.Arr.Elems
 		if idx < 0 || idx >= len(elems) {
-			return i.runtimeErr(stmt.GetSpan(), fmt.Sprintf("Array index out of bounds (index %d, size %d)", idx, len(elems)))
+			return i.codedErr(span, codes.IndexOutOfBounds, fmt.Sprintf("Array index out of bounds (index %d, size %d)", idx, len(elems)))
 		}
 
 		containerVal.Arr.Elems[idx] = newVal
-		env[stmt.Name] = containerVal
 		return nil
 	}
 
 	if containerVal.Kind == ValMap && containerVal.Map != nil {
-		if iv.Kind != ValString {
-			return i.runtimeErr(stmt.Index.GetSpan(), "Map key must be a string")
+		key, err := i.toMapKey(iv, indexSpan)
+		if err != nil {
+			return err
 		}
 		if containerVal.Map.Elems == nil {
 			containerVal.Map.Elems = map[string]Value{}
 		}
-		containerVal.Map.Elems[iv.Str] = newVal
-		env[stmt.Name] = containerVal
+		containerVal.Map.Elems[key] = newVal
+		i.noteSize(containerVal)
 		return nil
 	}
 
-	return i.runtimeErr(stmt.GetSpan(), "Index assignment requires an array or map")
+	return i.runtimeErr(span, "Index assignment requires an array or map")
 }
 
-func (i *Interpreter) execFor(stmt *ast.ForStmt) error {
-	startV, err := i.evalExpr(stmt.Start)
-	if err != nil {
-		return err
+// execGlobal binds the listed names to globals for the rest of the function.
+func (i *Interpreter) execGlobal(stmt *ast.GlobalStmt) error {
+	s := i.frame()
+	if s == nil {
+		return i.runtimeErr(stmt.GetSpan(), "global can only be used inside a function")
 	}
-	endV, err := i.evalExpr(stmt.End)
-	if err != nil {
-		return err
+	for _, name := range stmt.Names {
+		if _, ok := s.Vars[name]; ok {
+			return i.runtimeErr(stmt.GetSpan(), fmt.Sprintf("%q is already a local variable here; declare it global before assigning it", name))
+		}
+		if s.Globals == nil {
+			s.Globals = map[string]bool{}
+		}
+		s.Globals[name] = true
 	}
-	if startV.Kind != ValNumber || endV.Kind != ValNumber {
-		return i.runtimeErr(stmt.GetSpan(), "For loop start/end must be numbers")
+	if stmt.Value != nil {
+		v, err := i.evalExpr(stmt.Value)
+		if err != nil {
+			return err
+		}
+		i.globals[stmt.Names[0]] = v
 	}
+	return nil
+}
 
-	step := 1.0
-	if stmt.Step != nil {
-		stepV, err := i.evalExpr(stmt.Step)
+// execDim declares a variable, optionally as a pre-sized N-D array:
+// dim grid(rows, cols) creates rows arrays of cols elements each.
+func (i *Interpreter) execDim(stmt *ast.DimStmt) error {
+	val := NullValue()
+	if len(stmt.Dims) > 0 {
+		val = NumberValue(0)
+	}
+	if stmt.Value != nil {
+		v, err := i.evalExpr(stmt.Value)
 		if err != nil {
 			return err
 		}
-		if stepV.Kind != ValNumber || stepV.Number == 0 {
-			return i.runtimeErr(stmt.Step.GetSpan(), "For loop step must be a non-zero number")
-		}
-		step = stepV.Number
-	} else if startV.Number > endV.Number {
-		step = -1
+		val = v
 	}
 
-	i.currentEnv()[stmt.Var] = NumberValue(startV.Number)
+	if len(stmt.Dims) == 0 {
+		i.declEnv(stmt.Name)[stmt.Name] = val
+		return nil
+	}
+
+	sizes := make([]int, 0, len(stmt.Dims))
+	for _, d := range stmt.Dims {
+		dv, err := i.evalExpr(d)
+		if err != nil {
+			return err
+		}
+		n, err := i.toIndex(dv, d.GetSpan())
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return i.runtimeErr(d.GetSpan(), "dim size must be >= 0")
+		}
+		sizes = append(sizes, n)
+	}
+
+	i.declEnv(stmt.Name)[stmt.Name] = makeGrid(sizes, val)
+	return nil
+}
+
+// makeGrid builds the nested arrays of a dim. Each cell gets its own copy of
+// an array, map or record fill value, so writing into one cell leaves the
+// others alone.
+func makeGrid(sizes []int, fill Value) Value {
+	elems := make([]Value, sizes[0])
+	for idx := range elems {
+		if len(sizes) > 1 {
+			elems[idx] = makeGrid(sizes[1:], fill)
+		} else {
+			elems[idx] = deepCopy(fill)
+		}
+	}
+	return ArrayValue(elems)
+}
+
+// deepCopy copies arrays, maps and records all the way down; other values
+// are returned as they are. A container reached twice (or through a cycle)
+// is copied once, so the copy has the same shape.
+func deepCopy(v Value) Value {
+	return copyValue(v, map[any]Value{})
+}
+
+func copyValue(v Value, seen map[any]Value) Value {
+	switch v.Kind {
+	case ValArray:
+		if v.Arr == nil {
+			return v
+		}
+		if c, ok := seen[v.Arr]; ok {
+			return c
+		}
+		arr := &ArrayObject{Elems: make([]Value, len(v.Arr.Elems)), Frozen: v.Arr.Frozen}
+		c := Value{Kind: ValArray, Arr: arr}
+		seen[v.Arr] = c
+		for idx, el := range v.Arr.Elems {
+			arr.Elems[idx] = copyValue(el, seen)
+		}
+		return c
+	case ValMap:
+		if v.Map == nil {
+			return v
+		}
+		if c, ok := seen[v.Map]; ok {
+			return c
+		}
+		m := &MapObject{Elems: make(map[string]Value, len(v.Map.Elems)), Frozen: v.Map.Frozen}
+		c := Value{Kind: ValMap, Map: m}
+		seen[v.Map] = c
+		for k, el := range v.Map.Elems {
+			m.Elems[k] = copyValue(el, seen)
+		}
+		return c
+	case ValRecord:
+		if c, ok := seen[v.Rec]; ok {
+			return c
+		}
+		rec := &RecordObject{Type: v.Rec.Type, Fields: make([]Value, len(v.Rec.Fields))}
+		c := Value{Kind: ValRecord, Rec: rec}
+		seen[v.Rec] = c
+		for idx, f := range v.Rec.Fields {
+			rec.Fields[idx] = copyValue(f, seen)
+		}
+		return c
+	}
+	return v
+}
+
+// checkMutable rejects writes to containers that have been frozen.
+func (i *Interpreter) checkMutable(v Value, span ast.Span) error {
+	if !v.isFrozen() {
+		return nil
+	}
+	if v.Kind == ValMap {
+		return i.codedErr(span, codes.FrozenValue, "Cannot modify a frozen map")
+	}
+	return i.codedErr(span, codes.FrozenValue, "Cannot modify a frozen array")
+}
+
+func (i *Interpreter) execFor(stmt *ast.ForStmt) error {
+	startV, err := i.evalExpr(stmt.Start)
+	if err != nil {
+		return err
+	}
+	endV, err := i.evalExpr(stmt.End)
+	if err != nil {
+		return err
+	}
+	if startV.Kind != ValNumber || endV.Kind != ValNumber {
+		return i.runtimeErr(stmt.GetSpan(), "For loop start/end must be numbers")
+	}
+
+	step := 1.0
+	if stmt.Step != nil {
+		stepV, err := i.evalExpr(stmt.Step)
+		if err != nil {
+			return err
+		}
+		if stepV.Kind != ValNumber || stepV.Number == 0 {
+			return i.runtimeErr(stmt.Step.GetSpan(), "For loop step must be a non-zero number")
+		}
+		step = stepV.Number
+	} else if startV.Number > endV.Number {
+		step = -1
+	}
+
+	i.declEnv(stmt.Var)[stmt.Var] = NumberValue(startV.Number)
 
 	for {
-		curV := i.currentEnv()[stmt.Var]
+		curV := i.declEnv(stmt.Var)[stmt.Var]
 		if curV.Kind != ValNumber {
 			return i.runtimeErr(stmt.GetSpan(), "For loop variable must remain numeric")
 		}
@@ -766,20 +1344,20 @@ func (i *Interpreter) execFor(stmt *ast.ForStmt) error {
 			break
 		}
 
-		err := i.Run(stmt.Body)
+		err := i.runBlock(stmt.Body)
 		if err != nil {
 			switch err.(type) {
 			case BreakSignal:
 				return nil
 			case ContinueSignal:
-				i.currentEnv()[stmt.Var] = NumberValue(cur + step)
+				i.declEnv(stmt.Var)[stmt.Var] = NumberValue(cur + step)
 				continue
 			default:
 				return err
 			}
 		}
 
-		i.currentEnv()[stmt.Var] = NumberValue(cur + step)
+		i.declEnv(stmt.Var)[stmt.Var] = NumberValue(cur + step)
 	}
 
 	return nil
@@ -793,11 +1371,11 @@ func (i *Interpreter) execForEach(stmt *ast.ForEachStmt) error {
 
 	if iterV.Kind == ValArray && iterV.Arr != nil {
 		for idx, el := range iterV.Arr.Elems {
-			i.currentEnv()[stmt.Var] = el
+			i.declEnv(stmt.Var)[stmt.Var] = el
 			if stmt.IndexVar != "" {
-				i.currentEnv()[stmt.IndexVar] = NumberValue(float64(idx))
+				i.declEnv(stmt.IndexVar)[stmt.IndexVar] = NumberValue(float64(idx))
 			}
-			err := i.Run(stmt.Body)
+			err := i.runBlock(stmt.Body)
 			if err != nil {
 				switch err.(type) {
 				case BreakSignal:
@@ -821,11 +1399,11 @@ func (i *Interpreter) execForEach(stmt *ast.ForEachStmt) error {
 		sort.Strings(keys)
 
 		for idx, k := range keys {
-			i.currentEnv()[stmt.Var] = StringValue(k)
+			i.declEnv(stmt.Var)[stmt.Var] = StringValue(k)
 			if stmt.IndexVar != "" {
-				i.currentEnv()[stmt.IndexVar] = NumberValue(float64(idx))
+				i.declEnv(stmt.IndexVar)[stmt.IndexVar] = NumberValue(float64(idx))
 			}
-			err := i.Run(stmt.Body)
+			err := i.runBlock(stmt.Body)
 			if err != nil {
 				switch err.(type) {
 				case BreakSignal:
@@ -840,56 +1418,114 @@ func (i *Interpreter) execForEach(stmt *ast.ForEachStmt) error {
 		return nil
 	}
 
-	return i.runtimeErr(stmt.GetSpan(), "foreach expects an array or map")
+	if iterV.Kind == ValRange {
+		r := iterV.Rng
+		for idx, n := 0, r.Len(); idx < n; idx++ {
+			i.declEnv(stmt.Var)[stmt.Var] = NumberValue(r.At(idx))
+			if stmt.IndexVar != "" {
+				i.declEnv(stmt.IndexVar)[stmt.IndexVar] = NumberValue(float64(idx))
+			}
+			err := i.runBlock(stmt.Body)
+			if err != nil {
+				switch err.(type) {
+				case BreakSignal:
+					return nil
+				case ContinueSignal:
+					continue
+				default:
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if iterV.Kind == ValGenerator {
+		return i.forEachGenerated(stmt, iterV.Gen)
+	}
+
+	return i.runtimeErr(stmt.GetSpan(), "foreach expects an array, map, range or generator")
 }
 
-func (i *Interpreter) valuesEqual(a, b Value) bool {
+// valuesEqual is ==: containers compare element by element, and a record
+// whose type has __eq is compared by it, at any depth (so x in arr and match
+// cases agree with ==).
+func (i *Interpreter) valuesEqual(a, b Value, span ast.Span) (bool, error) {
+	if a.Kind == ValRecord || b.Kind == ValRecord {
+		if v, handled, err := i.applyOperatorHook("==", a, b, span); handled || err != nil {
+			return v.Bool, err
+		}
+	}
+	if a.Kind == ValDecimal || b.Kind == ValDecimal {
+		ad, aok := toDecimal(a)
+		bd, bok := toDecimal(b)
+		return aok && bok && decimalCmp(ad, bd) == 0, nil
+	}
 	if a.Kind != b.Kind {
-		return false
+		return false, nil
 	}
 	switch a.Kind {
 	case ValNull:
-		return true
+		return true, nil
 	case ValNumber:
-		return a.Number == b.Number
+		return a.Number == b.Number, nil
 	case ValString:
-		return a.Str == b.Str
+		return a.Str == b.Str, nil
 	case ValBool:
-		return a.Bool == b.Bool
+		return a.Bool == b.Bool, nil
 	case ValArray:
 		if a.Arr == nil || b.Arr == nil {
-			return a.Arr == b.Arr
+			return a.Arr == b.Arr, nil
 		}
 		if len(a.Arr.Elems) != len(b.Arr.Elems) {
-			return false
+			return false, nil
 		}
 		for idx := range a.Arr.Elems {
-			if !i.valuesEqual(a.Arr.Elems[idx], b.Arr.Elems[idx]) {
-				return false
+			if eq, err := i.valuesEqual(a.Arr.Elems[idx], b.Arr.Elems[idx], span); !eq || err != nil {
+				return false, err
 			}
 		}
-		return true
+		return true, nil
 	case ValMap:
 		if a.Map == nil || b.Map == nil {
-			return a.Map == b.Map
+			return a.Map == b.Map, nil
 		}
 		am := a.Map.Elems
 		bm := b.Map.Elems
 		if len(am) != len(bm) {
-			return false
+			return false, nil
 		}
 		for k, av := range am {
 			bv, ok := bm[k]
 			if !ok {
-				return false
+				return false, nil
+			}
+			if eq, err := i.valuesEqual(av, bv, span); !eq || err != nil {
+				return false, err
 			}
-			if !i.valuesEqual(av, bv) {
-				return false
+		}
+		return true, nil
+	case ValFunction:
+		return sameFunction(a.Fn, b.Fn), nil
+	case ValRange:
+		return a.Rng.Start == b.Rng.Start && a.Rng.End == b.Rng.End, nil
+	case ValGenerator:
+		return a.Gen == b.Gen, nil
+	case ValRecord:
+		if a.Rec == b.Rec {
+			return true, nil
+		}
+		if a.Rec.Type != b.Rec.Type {
+			return false, nil
+		}
+		for idx := range a.Rec.Fields {
+			if eq, err := i.valuesEqual(a.Rec.Fields[idx], b.Rec.Fields[idx], span); !eq || err != nil {
+				return false, err
 			}
 		}
-		return true
+		return true, nil
 	default:
-		return false
+		return false, nil
 	}
 }
 
@@ -919,6 +1555,36 @@ func (i *Interpreter) toIndex(v Value, span ast.Span) (int, error) {
 	return idx, nil
 }
 
+// toMapKey turns an index value into a map key. Map keys are strings; a
+// number is stored under its canonical text, so m[5] and m["5"] are the same
+// entry and foreach k in m gives "5" back. The text does not follow
+// setprecision().
+func (i *Interpreter) toMapKey(v Value, span ast.Span) (string, error) {
+	switch v.Kind {
+	case ValString:
+		return v.Str, nil
+	case ValNumber:
+		if math.IsNaN(v.Number) || math.IsInf(v.Number, 0) {
+			return "", i.runtimeErr(span, "Map key must be a finite number")
+		}
+		if v.Number == float64(int64(v.Number)) {
+			return strconv.FormatInt(int64(v.Number), 10), nil
+		}
+		return strconv.FormatFloat(v.Number, 'g', -1, 64), nil
+	}
+	return "", i.runtimeErr(span, "Map key must be a string or a number")
+}
+
+// floorMod is a mod b with the sign of b (so -7 mod 3 == 2), which is what
+// wrap-around uses like clock arithmetic and cycling through indexes expect.
+func floorMod(a, b float64) float64 {
+	r := math.Mod(a, b)
+	if r != 0 && (r < 0) != (b < 0) {
+		r += b
+	}
+	return r
+}
+
 // ---------- String helpers (runes) ----------
 
 func runeLen(s string) int { return utf8.RuneCountInString(s) }
@@ -1007,75 +1673,86 @@ func (i *Interpreter) evalExpr(e ast.Expr) (Value, error) {
 		return BoolValue(expr.Value), nil
 
 	case *ast.ArrayLiteralExpr:
-		els := make([]Value, 0, len(expr.Elements))
-		for _, el := range expr.Elements {
-			v, err := i.evalExpr(el)
-			if err != nil {
-				return Value{}, err
-			}
-			els = append(els, v)
+		els, err := i.evalArgs(expr.Elements)
+		if err != nil {
+			return Value{}, err
 		}
-		return ArrayValue(els), nil
+		arr := ArrayValue(els)
+		i.noteSize(arr)
+		return arr, nil
 
 	case *ast.MapLiteralExpr:
 		m := map[string]Value{}
 		for _, ent := range expr.Entries {
+			if ent.Spread {
+				if err := i.spreadMap(m, ent.Value); err != nil {
+					return Value{}, err
+				}
+				continue
+			}
+			key := ent.Key
+			if ent.KeyExpr != nil {
+				kv, err := i.evalExpr(ent.KeyExpr)
+				if err != nil {
+					return Value{}, err
+				}
+				if key, err = i.toMapKey(kv, ent.KeyExpr.GetSpan()); err != nil {
+					return Value{}, err
+				}
+			}
 			v, err := i.evalExpr(ent.Value)
 			if err != nil {
 				return Value{}, err
 			}
-			m[ent.Key] = v
+			m[key] = v
 		}
-		return MapValue(m), nil
+		mv := MapValue(m)
+		i.noteSize(mv)
+		return mv, nil
 
 	case *ast.IndexExpr:
-		left, err := i.evalExpr(expr.Left)
-		if err != nil {
-			return Value{}, err
-		}
-		iv, err := i.evalExpr(expr.Index)
-		if err != nil {
-			return Value{}, err
-		}
+		return i.evalIndex(expr, expr.Optional)
 
-		if left.Kind == ValArray && left.Arr != nil {
-			idx, err := i.toIndex(iv, expr.Index.GetSpan())
-			if err != nil {
-				return Value{}, err
-			}
-			if idx < 0 || idx >= len(left.Arr.Elems) {
-				return Value{}, i.runtimeErr(expr.GetSpan(), fmt.Sprintf("Array index out of bounds (index %d, size %d)", idx, len(left.Arr.Elems)))
-			}
-			return left.Arr.Elems[idx], nil
-		}
+	case *ast.SliceExpr:
+		return i.evalSlice(expr)
 
-		if left.Kind == ValMap && left.Map != nil {
-			if iv.Kind != ValString {
-				return Value{}, i.runtimeErr(expr.Index.GetSpan(), "Map key must be a string")
-			}
-			val, ok := left.Map.Elems[iv.Str]
-			if !ok {
-				return Value{}, i.runtimeErr(expr.GetSpan(), fmt.Sprintf("Map key %q not found", iv.Str))
-			}
-			return val, nil
-		}
+	case *ast.MemberExpr:
+		return i.evalMember(expr)
+
+	case *ast.MethodCallExpr:
+		return i.evalMethodCall(expr)
 
-		return Value{}, i.runtimeErr(expr.GetSpan(), "Indexing requires an array or map")
+	case *ast.NullLiteral:
+		return NullValue(), nil
 
 	case *ast.Identifier:
-		if i.inFunction() {
-			if v, ok := i.currentEnv()[expr.Name]; ok {
-				return v, nil
-			}
-		}
-		if v, ok := i.globals[expr.Name]; ok {
+		if v, ok := i.lookupVar(expr.Name); ok {
 			return v, nil
 		}
-		return Value{}, i.runtimeErr(expr.GetSpan(), fmt.Sprintf("Undefined variable %q", expr.Name))
+		// a function name on its own is a function value: apply(double, 3)
+		if fv, ok := i.lookupFunction(expr.Name); ok {
+			return fv, nil
+		}
+		return Value{}, i.codedErr(expr.GetSpan(), codes.UndefinedVariable, fmt.Sprintf("Undefined variable %q", expr.Name))
 
 	case *ast.CallExpr:
 		return i.evalCall(expr)
 
+	case *ast.MatchExpr:
+		return i.evalMatch(expr)
+
+	case *ast.RangeExpr:
+		return i.evalRange(expr)
+
+	case *ast.SpreadExpr:
+		return Value{}, i.runtimeErr(expr.GetSpan(), "... can only spread values into [ ], { } or call arguments")
+
+	case *ast.CallValueExpr:
+		return i.evalCallValue(expr)
+
+	case *ast.FunctionLit:
+		return closureValue(expr.Fn, i.scope()), nil
+
 	case *ast.UnaryExpr:
 		right, err := i.evalExpr(expr.Right)
 		if err != nil {
@@ -1092,6 +1769,9 @@ func (i *Interpreter) evalExpr(e ast.Expr) (Value, error) {
 		}
 
 	case *ast.BinaryExpr:
+		if expr.Op == "??" {
+			return i.evalCoalesce(expr)
+		}
 		if expr.Op == "and" || expr.Op == "or" {
 			left, err := i.evalExpr(expr.Left)
 			if err != nil {
@@ -1137,9 +1817,35 @@ func (i *Interpreter) evalExpr(e ast.Expr) (Value, error) {
 			return Value{}, err
 		}
 
+		if left.Kind == ValRecord || right.Kind == ValRecord {
+			if v, handled, err := i.applyOperatorHook(expr.Op, left, right, expr.GetSpan()); handled || err != nil {
+				return v, err
+			}
+		}
+
+		if expr.Op == "in" || expr.Op == "not in" {
+			found, err := i.contains(right, left, expr.GetSpan())
+			if err != nil {
+				return Value{}, err
+			}
+			return BoolValue(found == (expr.Op == "in")), nil
+		}
+
+		if (left.Kind == ValDecimal || right.Kind == ValDecimal) && expr.Op != "==" && expr.Op != "!=" {
+			ld, lok := toDecimal(left)
+			rd, rok := toDecimal(right)
+			if lok && rok {
+				v, err := decimalArith(expr.Op, ld, rd)
+				if err != nil {
+					return Value{}, i.runtimeErr(expr.GetSpan(), err.Error())
+				}
+				return v, nil
+			}
+		}
+
 		if expr.Op == "+" {
 			if left.Kind == ValNumber && right.Kind == ValNumber {
-				return NumberValue(left.Number + right.Number), nil
+				return i.checkArith(expr, left.Number, right.Number, left.Number+right.Number)
 			}
 			if left.Kind == ValArray && right.Kind == ValArray {
 				if left.Arr == nil || right.Arr == nil {
@@ -1148,13 +1854,26 @@ func (i *Interpreter) evalExpr(e ast.Expr) (Value, error) {
 				out := make([]Value, 0, len(left.Arr.Elems)+len(right.Arr.Elems))
 				out = append(out, left.Arr.Elems...)
 				out = append(out, right.Arr.Elems...)
-				return ArrayValue(out), nil
+				arr := ArrayValue(out)
+				i.noteSize(arr)
+				return arr, nil
+			}
+			ls, err := i.stringOf(left, expr.GetSpan())
+			if err != nil {
+				return Value{}, err
 			}
-			return StringValue(left.ToString() + right.ToString()), nil
+			rs, err := i.stringOf(right, expr.GetSpan())
+			if err != nil {
+				return Value{}, err
+			}
+			return StringValue(ls + rs), nil
 		}
 
 		if expr.Op == "==" || expr.Op == "!=" {
-			eq := i.valuesEqual(left, right)
+			eq, err := i.valuesEqual(left, right, expr.GetSpan())
+			if err != nil {
+				return Value{}, err
+			}
 			if expr.Op == "!=" {
 				eq = !eq
 			}
@@ -1190,11 +1909,16 @@ func (i *Interpreter) evalExpr(e ast.Expr) (Value, error) {
 
 		switch expr.Op {
 		case "-":
-			return NumberValue(left.Number - right.Number), nil
+			return i.checkArith(expr, left.Number, right.Number, left.Number-right.Number)
 		case "*":
-			return NumberValue(left.Number * right.Number), nil
+			return i.checkArith(expr, left.Number, right.Number, left.Number*right.Number)
 		case "/":
-			return NumberValue(left.Number / right.Number), nil
+			return i.checkArith(expr, left.Number, right.Number, left.Number/right.Number)
+		case "%":
+			if right.Number == 0 {
+				return Value{}, i.codedErr(expr.GetSpan(), codes.DivisionByZero, "Modulo by zero")
+			}
+			return NumberValue(floorMod(left.Number, right.Number)), nil
 		}
 
 		return Value{}, i.runtimeErr(expr.GetSpan(), fmt.Sprintf("Unknown operator %q", expr.Op))
@@ -1208,358 +1932,205 @@ func (i *Interpreter) evalExpr(e ast.Expr) (Value, error) {
 	}
 }
 
-func (i *Interpreter) evalCall(call *ast.CallExpr) (Value, error) {
-	if fn, ok := i.funcs[call.Callee]; ok {
-		return i.evalUserCall(fn, call.Args, call.GetSpan())
-	}
-	return i.evalBuiltin(call.Callee, call.Args, call.GetSpan())
-}
-
-func (i *Interpreter) evalUserCall(fn *ast.FunctionDecl, args []ast.Expr, callSpan ast.Span) (Value, error) {
-	if len(args) != len(fn.Params) {
-		return Value{}, i.runtimeErr(callSpan, fmt.Sprintf("Function %q expects %d args, got %d", fn.Name, len(fn.Params), len(args)))
-	}
-
-	argVals := []Value{}
-	for _, a := range args {
-		v, err := i.evalExpr(a)
-		if err != nil {
-			return Value{}, err
-		}
-		argVals = append(argVals, v)
-	}
-
-	i.callStack = append(i.callStack, fn.Name)
-	i.pushLocals()
-	defer func() {
-		i.popLocals()
-		i.callStack = i.callStack[:len(i.callStack)-1]
-	}()
-
-	for idx, name := range fn.Params {
-		i.currentEnv()[name] = argVals[idx]
-	}
-
-	err := i.Run(fn.Body)
-	if rs, ok := err.(ReturnSignal); ok {
-		return rs.Val, nil
+// evalIndex evaluates a[i]. With soft set, a missing map key or an array
+// index out of range gives null instead of an error, as does indexing null.
+func (i *Interpreter) evalIndex(expr *ast.IndexExpr, soft bool) (Value, error) {
+	left, err := i.evalExpr(expr.Left)
+	if err != nil {
+		return Value{}, err
 	}
+	iv, err := i.evalExpr(expr.Index)
 	if err != nil {
 		return Value{}, err
 	}
-	return Value{}, i.runtimeErr(fn.GetSpan(), fmt.Sprintf("Function %q ended without return", fn.Name))
+	return i.indexValue(left, iv, expr, soft)
 }
 
-// ---------- Builtins ----------
-
-func (i *Interpreter) getHandleReader(handle int) (*bufio.Reader, *os.File, error) {
-	f, ok := i.files[handle]
-	if !ok || f == nil {
-		return nil, nil, fmt.Errorf("handle #%d is not open", handle)
+// indexValue reads left[iv] for the already evaluated parts of expr.
+func (i *Interpreter) indexValue(left, iv Value, expr *ast.IndexExpr, soft bool) (Value, error) {
+	if soft && left.Kind == ValNull {
+		return NullValue(), nil
 	}
-	if r, ok := i.readers[handle]; ok && r != nil {
-		return r, f, nil
-	}
-	r := bufio.NewReader(f)
-	i.readers[handle] = r
-	return r, f, nil
-}
 
-func (i *Interpreter) evalBuiltin(name string, argExprs []ast.Expr, callSpan ast.Span) (Value, error) {
-	args := []Value{}
-	for _, a := range argExprs {
-		v, err := i.evalExpr(a)
+	if left.Kind == ValArray && left.Arr != nil {
+		idx, err := i.toIndex(iv, expr.Index.GetSpan())
 		if err != nil {
 			return Value{}, err
 		}
-		args = append(args, v)
-	}
-
-	switch name {
-	// --- core ---
-	case "str":
-		if len(args) != 1 {
-			return Value{}, i.runtimeErr(callSpan, "str() expects 1 arg")
+		if idx < 0 || idx >= len(left.Arr.Elems) {
+			if soft {
+				return NullValue(), nil
+			}
+			return Value{}, i.codedErr(expr.GetSpan(), codes.IndexOutOfBounds, fmt.Sprintf("Array index out of bounds (index %d, size %d)", idx, len(left.Arr.Elems)))
 		}
-		return StringValue(args[0].ToString()), nil
+		return left.Arr.Elems[idx], nil
+	}
 
-	case "num":
-		if len(args) != 1 {
-			return Value{}, i.runtimeErr(callSpan, "num() expects 1 arg")
-		}
-		if args[0].Kind == ValNumber {
-			return args[0], nil
-		}
-		n, err := strconv.ParseFloat(strings.TrimSpace(args[0].ToString()), 64)
+	if left.Kind == ValMap && left.Map != nil {
+		key, err := i.toMapKey(iv, expr.Index.GetSpan())
 		if err != nil {
-			return Value{}, i.runtimeErr(callSpan, fmt.Sprintf("num() could not parse %q", args[0].ToString()))
-		}
-		return NumberValue(n), nil
-
-	case "len":
-		if len(args) != 1 {
-			return Value{}, i.runtimeErr(callSpan, "len() expects 1 arg")
+			return Value{}, err
 		}
-		switch args[0].Kind {
-		case ValString:
-			return NumberValue(float64(runeLen(args[0].Str))), nil
-		case ValArray:
-			if args[0].Arr == nil {
-				return NumberValue(0), nil
-			}
-			return NumberValue(float64(len(args[0].Arr.Elems))), nil
-		case ValMap:
-			if args[0].Map == nil || args[0].Map.Elems == nil {
-				return NumberValue(0), nil
+		val, ok := left.Map.Elems[key]
+		if !ok {
+			if soft {
+				return NullValue(), nil
 			}
-			return NumberValue(float64(len(args[0].Map.Elems))), nil
-		default:
-			return Value{}, i.runtimeErr(callSpan, "len() expects a string, array, or map")
-		}
-
-	// --- string funcs ---
-	case "lower":
-		if len(args) != 1 || args[0].Kind != ValString {
-			return Value{}, i.runtimeErr(callSpan, "lower() expects 1 string arg")
-		}
-		return StringValue(strings.ToLower(args[0].Str)), nil
-
-	case "upper":
-		if len(args) != 1 || args[0].Kind != ValString {
-			return Value{}, i.runtimeErr(callSpan, "upper() expects 1 string arg")
-		}
-		return StringValue(strings.ToUpper(args[0].Str)), nil
-
-	case "trim":
-		if len(args) != 1 && len(args) != 2 {
-			return Value{}, i.runtimeErr(callSpan, "trim() expects 1 or 2 args: trim(s [,cutset])")
-		}
-		if args[0].Kind != ValString {
-			return Value{}, i.runtimeErr(callSpan, "trim() first arg must be a string")
-		}
-		if len(args) == 1 {
-			return StringValue(strings.TrimSpace(args[0].Str)), nil
-		}
-		if args[1].Kind != ValString {
-			return Value{}, i.runtimeErr(callSpan, "trim() cutset must be a string")
-		}
-		return StringValue(strings.Trim(args[0].Str, args[1].Str)), nil
-
-	case "ltrim":
-		if len(args) != 1 && len(args) != 2 {
-			return Value{}, i.runtimeErr(callSpan, "ltrim() expects 1 or 2 args: ltrim(s [,cutset])")
+			return Value{}, i.runtimeErr(expr.GetSpan(), fmt.Sprintf("Map key %q not found", key))
 		}
-		if args[0].Kind != ValString {
-			return Value{}, i.runtimeErr(callSpan, "ltrim() first arg must be a string")
-		}
-		if len(args) == 1 {
-			return StringValue(strings.TrimLeftFunc(args[0].Str, unicode.IsSpace)), nil
-		}
-		if args[1].Kind != ValString {
-			return Value{}, i.runtimeErr(callSpan, "ltrim() cutset must be a string")
-		}
-		return StringValue(strings.TrimLeft(args[0].Str, args[1].Str)), nil
-
-	case "rtrim":
-		if len(args) != 1 && len(args) != 2 {
-			return Value{}, i.runtimeErr(callSpan, "rtrim() expects 1 or 2 args: rtrim(s [,cutset])")
-		}
-		if args[0].Kind != ValString {
-			return Value{}, i.runtimeErr(callSpan, "rtrim() first arg must be a string")
-		}
-		if len(args) == 1 {
-			return StringValue(strings.TrimRightFunc(args[0].Str, unicode.IsSpace)), nil
-		}
-		if args[1].Kind != ValString {
-			return Value{}, i.runtimeErr(callSpan, "rtrim() cutset must be a string")
-		}
-		return StringValue(strings.TrimRight(args[0].Str, args[1].Str)), nil
-
-	case "contains":
-		if len(args) != 2 || args[0].Kind != ValString || args[1].Kind != ValString {
-			return Value{}, i.runtimeErr(callSpan, "contains() expects 2 string args: contains(s, sub)")
-		}
-		return BoolValue(strings.Contains(args[0].Str, args[1].Str)), nil
+		return val, nil
+	}
 
-	case "startswith":
-		if len(args) != 2 || args[0].Kind != ValString || args[1].Kind != ValString {
-			return Value{}, i.runtimeErr(callSpan, "startswith() expects 2 string args: startswith(s, prefix)")
-		}
-		return BoolValue(strings.HasPrefix(args[0].Str, args[1].Str)), nil
+	return Value{}, i.runtimeErr(expr.GetSpan(), "Indexing requires an array or map")
+}
 
-	case "endswith":
-		if len(args) != 2 || args[0].Kind != ValString || args[1].Kind != ValString {
-			return Value{}, i.runtimeErr(callSpan, "endswith() expects 2 string args: endswith(s, suffix)")
-		}
-		return BoolValue(strings.HasSuffix(args[0].Str, args[1].Str)), nil
+// evalCoalesce evaluates a ?? b: a unless it is null, in which case b (only
+// then evaluated). A missing key or index on the left counts as null, so
+// m["name"] ?? "unknown" works for absent keys.
+func (i *Interpreter) evalCoalesce(expr *ast.BinaryExpr) (Value, error) {
+	var left Value
+	var err error
+	if ix, ok := expr.Left.(*ast.IndexExpr); ok {
+		left, err = i.evalIndex(ix, true)
+	} else {
+		left, err = i.evalExpr(expr.Left)
+	}
+	if err != nil {
+		return Value{}, err
+	}
+	if left.Kind != ValNull {
+		return left, nil
+	}
+	return i.evalExpr(expr.Right)
+}
 
-	case "replace":
-		if len(args) != 3 && len(args) != 4 {
-			return Value{}, i.runtimeErr(callSpan, "replace() expects 3 or 4 args: replace(s, old, new [,n])")
-		}
-		if args[0].Kind != ValString || args[1].Kind != ValString || args[2].Kind != ValString {
-			return Value{}, i.runtimeErr(callSpan, "replace() expects string args for s/old/new")
-		}
-		n := -1
-		if len(args) == 4 {
-			if args[3].Kind != ValNumber {
-				return Value{}, i.runtimeErr(callSpan, "replace() n must be a number")
+// contains is the `in` operator: x in arr compares elements with ==, k in m
+// looks for the key (a number key matches as for m[k]) and s in text looks
+// for a substring.
+func (i *Interpreter) contains(coll, x Value, span ast.Span) (bool, error) {
+	switch coll.Kind {
+	case ValArray:
+		for _, el := range coll.arrayElems() {
+			if eq, err := i.valuesEqual(el, x, span); eq || err != nil {
+				return eq, err
 			}
-			n = int(args[3].Number)
-		}
-		return StringValue(strings.Replace(args[0].Str, args[1].Str, args[2].Str, n)), nil
-
-	case "split":
-		if len(args) != 2 || args[0].Kind != ValString || args[1].Kind != ValString {
-			return Value{}, i.runtimeErr(callSpan, "split() expects 2 string args: split(s, sep)")
-		}
-		parts := strings.Split(args[0].Str, args[1].Str)
-		out := make([]Value, 0, len(parts))
-		for _, p := range parts {
-			out = append(out, StringValue(p))
-		}
-		return ArrayValue(out), nil
-
-	case "join":
-		if len(args) != 2 {
-			return Value{}, i.runtimeErr(callSpan, "join() expects 2 args: join(array, sep)")
-		}
-		if args[0].Kind != ValArray || args[0].Arr == nil {
-			return Value{}, i.runtimeErr(callSpan, "join() first arg must be an array")
-		}
-		if args[1].Kind != ValString {
-			return Value{}, i.runtimeErr(callSpan, "join() sep must be a string")
-		}
-		sep := args[1].Str
-		elems := args[0].Arr.Elems
-		ss := make([]string, 0, len(elems))
-		for _, v := range elems {
-			ss = append(ss, v.ToString())
 		}
-		return StringValue(strings.Join(ss, sep)), nil
-
-	case "indexof":
-		if len(args) != 2 || args[0].Kind != ValString || args[1].Kind != ValString {
-			return Value{}, i.runtimeErr(callSpan, "indexof() expects 2 string args: indexof(s, sub)")
-		}
-		return NumberValue(float64(runeIndexOf(args[0].Str, args[1].Str))), nil
-
-	case "lastindexof":
-		if len(args) != 2 || args[0].Kind != ValString || args[1].Kind != ValString {
-			return Value{}, i.runtimeErr(callSpan, "lastindexof() expects 2 string args: lastindexof(s, sub)")
+		return false, nil
+	case ValMap:
+		if x.Kind != ValString && x.Kind != ValNumber {
+			return false, nil
 		}
-		return NumberValue(float64(runeLastIndexOf(args[0].Str, args[1].Str))), nil
-
-	case "repeat":
-		if len(args) != 2 || args[0].Kind != ValString || args[1].Kind != ValNumber {
-			return Value{}, i.runtimeErr(callSpan, "repeat() expects (string, number): repeat(s, n)")
+		key, err := i.toMapKey(x, span)
+		if err != nil {
+			return false, err
 		}
-		n := int(args[1].Number)
-		if n < 0 {
-			return Value{}, i.runtimeErr(callSpan, "repeat() n must be >= 0")
+		_, ok := coll.mapElems()[key]
+		return ok, nil
+	case ValString:
+		if x.Kind != ValString {
+			return false, i.runtimeErr(span, "Operator 'in' on a string needs a string on the left, got "+kindName(x.Kind))
 		}
-		return StringValue(strings.Repeat(args[0].Str, n)), nil
+		return strings.Contains(coll.Str, x.Str), nil
+	case ValRange:
+		return x.Kind == ValNumber && coll.Rng.Contains(x.Number), nil
+	}
+	return false, i.runtimeErr(span, "Operator 'in' needs an array, map, string or range on the right, got "+kindName(coll.Kind))
+}
 
-	case "substr":
-		if len(args) != 2 && len(args) != 3 {
-			return Value{}, i.runtimeErr(callSpan, "substr() expects 2 or 3 args: substr(s, start [,len])")
-		}
-		if args[0].Kind != ValString || args[1].Kind != ValNumber {
-			return Value{}, i.runtimeErr(callSpan, "substr() expects (string, number [,number])")
-		}
-		start := int(args[1].Number)
-		if args[1].Number != float64(start) {
-			return Value{}, i.runtimeErr(callSpan, "substr() start must be an integer")
-		}
-		if len(args) == 2 {
-			out, ok := substrRunes(args[0].Str, start, nil)
-			if !ok {
-				return Value{}, i.runtimeErr(callSpan, "substr() out of range")
-			}
-			return StringValue(out), nil
-		}
-		if args[2].Kind != ValNumber {
-			return Value{}, i.runtimeErr(callSpan, "substr() len must be a number")
-		}
-		l := int(args[2].Number)
-		if args[2].Number != float64(l) {
-			return Value{}, i.runtimeErr(callSpan, "substr() len must be an integer")
+func (i *Interpreter) evalCall(call *ast.CallExpr) (Value, error) {
+	// A variable holding a function shadows functions of the same name.
+	v, isVar := i.lookupVar(call.Callee)
+	if isVar && v.Kind == ValFunction {
+		args, err := i.evalArgs(call.Args)
+		if err != nil {
+			return Value{}, err
 		}
-		out, ok := substrRunes(args[0].Str, start, &l)
-		if !ok {
-			return Value{}, i.runtimeErr(callSpan, "substr() out of range")
+		return i.callValue(v, args, call.GetSpan())
+	}
+	if fn, ok := i.funcs[call.Callee]; ok {
+		return i.evalUserCall(fn, call.Args, call.GetSpan())
+	}
+	if t, ok := i.types[call.Callee]; ok {
+		args, err := i.evalArgs(call.Args)
+		if err != nil {
+			return Value{}, err
 		}
-		return StringValue(out), nil
+		return i.construct(t, args, call.GetSpan())
+	}
+	if !IsBuiltin(call.Callee) && isVar {
+		return i.callValue(v, nil, call.GetSpan())
+	}
+	return i.evalBuiltin(call.Callee, call.Args, call.GetSpan())
+}
 
-	// --- file handle read helpers ---
-	case "lineinput":
-		// lineinput(handle) -> string | null
-		if len(args) != 1 || args[0].Kind != ValNumber {
-			return Value{}, i.runtimeErr(callSpan, "lineinput() expects 1 number arg: lineinput(handle)")
-		}
-		h := int(args[0].Number)
-		if args[0].Number != float64(h) || h <= 0 {
-			return Value{}, i.runtimeErr(callSpan, "lineinput() handle must be a positive integer")
-		}
+func (i *Interpreter) evalUserCall(fn *ast.FunctionDecl, args []ast.Expr, callSpan ast.Span) (Value, error) {
+	// with f(xs...) the count is known only once xs is evaluated
+	if !hasSpread(args) && !acceptsArgs(fn, len(args)) {
+		return Value{}, i.codedErr(callSpan, codes.WrongArgCount, fmt.Sprintf("Function %q expects %s args, got %d", fn.Name, arityText(fn), len(args)))
+	}
 
-		r, _, herr := i.getHandleReader(h)
-		if herr != nil {
-			return Value{}, i.runtimeErr(callSpan, "lineinput() failed: "+herr.Error())
-		}
+	argVals, err := i.evalArgs(args)
+	if err != nil {
+		return Value{}, err
+	}
+	return i.callFunction(fn, argVals, callSpan)
+}
 
-		line, err := r.ReadString('\n')
-		if err != nil {
-			if err == io.EOF {
-				if line == "" {
-					return NullValue(), nil
-				}
-				// return last partial line
-				return StringValue(strings.TrimRight(line, "\r\n")), nil
-			}
-			return Value{}, i.runtimeErr(callSpan, fmt.Sprintf("lineinput() failed: %v", err))
-		}
-		return StringValue(strings.TrimRight(line, "\r\n")), nil
+// callFunction runs a top-level user function with already-evaluated args.
+func (i *Interpreter) callFunction(fn *ast.FunctionDecl, argVals []Value, callSpan ast.Span) (Value, error) {
+	return i.callClosure(fn, nil, argVals, callSpan)
+}
 
-	case "eof":
-		// eof(handle) -> bool
-		if len(args) != 1 || args[0].Kind != ValNumber {
-			return Value{}, i.runtimeErr(callSpan, "eof() expects 1 number arg: eof(handle)")
-		}
-		h := int(args[0].Number)
-		if args[0].Number != float64(h) || h <= 0 {
-			return Value{}, i.runtimeErr(callSpan, "eof() handle must be a positive integer")
-		}
+// callClosure runs fn in a new scope whose parent is env, the scope the
+// function was created in (nil for top-level functions).
+func (i *Interpreter) callClosure(fn *ast.FunctionDecl, env *Env, argVals []Value, callSpan ast.Span) (Value, error) {
+	name := funcName(fn)
+	if !acceptsArgs(fn, len(argVals)) {
+		return Value{}, i.codedErr(callSpan, codes.WrongArgCount, fmt.Sprintf("Function %q expects %s args, got %d", name, arityText(fn), len(argVals)))
+	}
 
-		// per your preference: if not open, treat as EOF=true
-		if _, ok := i.files[h]; !ok || i.files[h] == nil {
-			return BoolValue(true), nil
-		}
+	if fn.Generator {
+		return newGenerator(fn, env, argVals), nil
+	}
 
-		r, _, herr := i.getHandleReader(h)
-		if herr != nil {
-			return BoolValue(true), nil
-		}
+	i.callStack = append(i.callStack, name)
+	i.pushLocals(env)
+	prevSource := i.enterSource(fn)
+	prevRunning, prevGosubs := i.running, i.gosubs
+	i.running, i.gosubs = nil, nil
+	defer func() {
+		i.popLocals()
+		i.callStack = i.callStack[:len(i.callStack)-1]
+		i.restoreSource(prevSource)
+		i.running, i.gosubs = prevRunning, prevGosubs
+	}()
 
-		_, err := r.Peek(1)
-		if err == io.EOF {
-			return BoolValue(true), nil
-		}
-		if err != nil {
-			return Value{}, i.runtimeErr(callSpan, fmt.Sprintf("eof() failed: %v", err))
-		}
-		return BoolValue(false), nil
+	i.bindParams(fn, argVals)
 
-	// Input (stdin)
-	case "input":
-		if len(args) > 1 {
-			return Value{}, i.runtimeErr(callSpan, "input() expects 0 or 1 args")
-		}
-		if len(args) == 1 {
-			fmt.Print(args[0].ToString())
-		}
-		line, _ := i.in.ReadString('\n')
-		return StringValue(strings.TrimRight(line, "\r\n")), nil
+	err := i.Run(fn.Body)
+	if rs, ok := err.(ReturnSignal); ok {
+		return rs.Val, nil
+	}
+	if g, ok := err.(GotoSignal); ok {
+		return Value{}, i.runtimeErr(g.Span, fmt.Sprintf("goto %s: no label %q in function %q", g.Label, g.Label, name))
+	}
+	if err != nil {
+		return Value{}, err
 	}
+	// falling off the end (a procedure) returns null
+	return NullValue(), nil
+}
 
-	return Value{}, i.runtimeErr(callSpan, fmt.Sprintf("Undefined function %q", name))
+// bindParams declares fn's params in the new call's scope; a variadic last
+// param collects the extra args in an array.
+func (i *Interpreter) bindParams(fn *ast.FunctionDecl, argVals []Value) {
+	fixed := fn.Params
+	if fn.Variadic {
+		fixed = fn.Params[:len(fn.Params)-1]
+		rest := append([]Value{}, argVals[len(fixed):]...)
+		i.currentEnv()[fn.Params[len(fixed)]] = ArrayValue(rest)
+	}
+	for idx, name := range fixed {
+		i.currentEnv()[name] = argVals[idx]
+	}
 }
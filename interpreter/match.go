@@ -0,0 +1,100 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+
+	"bpl-plus/ast"
+)
+
+// valueKinds are the names `case is kind` accepts besides record type names.
+var valueKinds = []string{"null", "number", "string", "boolean", "array", "map", "decimal", "function", "record", "range", "generator"}
+
+// evalMatch evaluates the subject once and gives the body of the first arm
+// that fits it. A match with no fitting arm (and no case else) is an error,
+// so a forgotten value does not turn into a silent null.
+func (i *Interpreter) evalMatch(expr *ast.MatchExpr) (Value, error) {
+	v, err := i.evalExpr(expr.Subject)
+	if err != nil {
+		return Value{}, err
+	}
+	for _, arm := range expr.Arms {
+		ok := arm.Patterns == nil
+		for _, pat := range arm.Patterns {
+			if ok, err = i.patternFits(pat, v, arm.S); ok || err != nil {
+				break
+			}
+		}
+		if err != nil {
+			return Value{}, err
+		}
+		if !ok {
+			continue
+		}
+		if arm.Bind != "" {
+			// the as name lives in a block around the arm, like a let
+			i.pushBlock()
+			defer i.popBlock()
+			i.scope().Vars[arm.Bind] = v
+		}
+		return i.evalExpr(arm.Body)
+	}
+	return Value{}, i.runtimeErr(expr.GetSpan(), fmt.Sprintf("No case of the match fits %s (add a case else)", v.ToString()))
+}
+
+func (i *Interpreter) patternFits(pat ast.MatchPattern, v Value, span ast.Span) (bool, error) {
+	if pat.Kind != "" {
+		return i.isKind(v, pat.Kind, span)
+	}
+	val, err := i.evalExpr(pat.Value)
+	if err != nil {
+		return false, err
+	}
+	if pat.High == nil {
+		// case 1..5 matches the values of the range: 1, 2, 3, 4 or 5
+		if val.Kind == ValRange && v.Kind != ValRange {
+			return v.Kind == ValNumber && val.Rng.Contains(v.Number), nil
+		}
+		return i.valuesEqual(v, val, span)
+	}
+	high, err := i.evalExpr(pat.High)
+	if err != nil {
+		return false, err
+	}
+	return inRange(v, val, high), nil
+}
+
+// isKind reports whether v is of the named kind (case is number) or is a
+// record of the named type (case is Point).
+func (i *Interpreter) isKind(v Value, kind string, span ast.Span) (bool, error) {
+	if v.Kind == ValRecord && v.Rec.Type.Decl.Name == kind {
+		return true, nil
+	}
+	for _, k := range valueKinds {
+		if strings.EqualFold(k, kind) {
+			return strings.EqualFold(kindName(v.Kind), kind), nil
+		}
+	}
+	if _, ok := i.types[kind]; ok {
+		return false, nil
+	}
+	return false, i.runtimeErr(span, fmt.Sprintf("Unknown kind %q in case is (expected %s, or a record type)", kind, strings.Join(valueKinds, ", ")))
+}
+
+// inRange reports whether low <= v <= high, comparing numbers (and decimals)
+// by value and strings by text. Values of other kinds are never in range.
+func inRange(v, low, high Value) bool {
+	if v.Kind == ValString && low.Kind == ValString && high.Kind == ValString {
+		return low.Str <= v.Str && v.Str <= high.Str
+	}
+	if v.Kind == ValNumber && low.Kind == ValNumber && high.Kind == ValNumber {
+		return low.Number <= v.Number && v.Number <= high.Number
+	}
+	vd, ok1 := toDecimal(v)
+	ld, ok2 := toDecimal(low)
+	hd, ok3 := toDecimal(high)
+	if !ok1 || !ok2 || !ok3 {
+		return false
+	}
+	return decimalCmp(ld, vd) <= 0 && decimalCmp(vd, hd) <= 0
+}
@@ -0,0 +1,155 @@
+package interpreter
+
+import "fmt"
+
+// ---------- Matrix / vector helpers ----------
+// Vectors are arrays of numbers; matrices are arrays of equal-length rows.
+
+func toVector(v Value) ([]float64, error) {
+	if v.Kind != ValArray || v.Arr == nil {
+		return nil, fmt.Errorf("expected an array of numbers")
+	}
+	out := make([]float64, len(v.Arr.Elems))
+	for idx, el := range v.Arr.Elems {
+		if el.Kind != ValNumber {
+			return nil, fmt.Errorf("element %d is not a number", idx)
+		}
+		out[idx] = el.Number
+	}
+	return out, nil
+}
+
+func toMatrix(v Value) ([][]float64, error) {
+	if v.Kind != ValArray || v.Arr == nil {
+		return nil, fmt.Errorf("expected a matrix (array of rows)")
+	}
+	rows := make([][]float64, len(v.Arr.Elems))
+	for r, rowV := range v.Arr.Elems {
+		row, err := toVector(rowV)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %v", r, err)
+		}
+		if r > 0 && len(row) != len(rows[0]) {
+			return nil, fmt.Errorf("row %d has %d columns, expected %d", r, len(row), len(rows[0]))
+		}
+		rows[r] = row
+	}
+	return rows, nil
+}
+
+func vectorValue(xs []float64) Value {
+	out := make([]Value, len(xs))
+	for idx, x := range xs {
+		out[idx] = NumberValue(x)
+	}
+	return ArrayValue(out)
+}
+
+func matrixValue(m [][]float64) Value {
+	out := make([]Value, len(m))
+	for r, row := range m {
+		out[r] = vectorValue(row)
+	}
+	return ArrayValue(out)
+}
+
+func matMul(a, b [][]float64) ([][]float64, error) {
+	if len(a) == 0 || len(b) == 0 {
+		return nil, fmt.Errorf("matrices must not be empty")
+	}
+	if len(a[0]) != len(b) {
+		return nil, fmt.Errorf("shape mismatch: %dx%d times %dx%d", len(a), len(a[0]), len(b), len(b[0]))
+	}
+	out := make([][]float64, len(a))
+	for r := range a {
+		out[r] = make([]float64, len(b[0]))
+		for c := range b[0] {
+			sum := 0.0
+			for k := range b {
+				sum += a[r][k] * b[k][c]
+			}
+			out[r][c] = sum
+		}
+	}
+	return out, nil
+}
+
+func transpose(m [][]float64) [][]float64 {
+	if len(m) == 0 {
+		return [][]float64{}
+	}
+	out := make([][]float64, len(m[0]))
+	for c := range out {
+		out[c] = make([]float64, len(m))
+		for r := range m {
+			out[c][r] = m[r][c]
+		}
+	}
+	return out
+}
+
+func identity(n int) [][]float64 {
+	out := make([][]float64, n)
+	for r := range out {
+		out[r] = make([]float64, n)
+		out[r][r] = 1
+	}
+	return out
+}
+
+func dot(a, b []float64) (float64, error) {
+	if len(a) != len(b) {
+		return 0, fmt.Errorf("vectors have different lengths (%d and %d)", len(a), len(b))
+	}
+	sum := 0.0
+	for idx := range a {
+		sum += a[idx] * b[idx]
+	}
+	return sum, nil
+}
+
+// elementwise applies op to matching numbers in two values of the same shape.
+// Either side may be a plain number, which is broadcast over the other.
+func elementwise(a, b Value, op func(x, y float64) float64) (Value, error) {
+	if a.Kind == ValNumber && b.Kind == ValNumber {
+		return NumberValue(op(a.Number, b.Number)), nil
+	}
+	if a.Kind == ValArray && b.Kind == ValNumber {
+		out := make([]Value, len(a.arrayElems()))
+		for idx, el := range a.arrayElems() {
+			v, err := elementwise(el, b, op)
+			if err != nil {
+				return Value{}, err
+			}
+			out[idx] = v
+		}
+		return ArrayValue(out), nil
+	}
+	if a.Kind == ValNumber && b.Kind == ValArray {
+		out := make([]Value, len(b.arrayElems()))
+		for idx, el := range b.arrayElems() {
+			v, err := elementwise(a, el, op)
+			if err != nil {
+				return Value{}, err
+			}
+			out[idx] = v
+		}
+		return ArrayValue(out), nil
+	}
+	if a.Kind == ValArray && b.Kind == ValArray {
+		ae, be := a.arrayElems(), b.arrayElems()
+		if len(ae) != len(be) {
+			return Value{}, fmt.Errorf("shape mismatch (%d and %d elements)", len(ae), len(be))
+		}
+		out := make([]Value, len(ae))
+		for idx := range ae {
+			v, err := elementwise(ae[idx], be[idx], op)
+			if err != nil {
+				return Value{}, err
+			}
+			out[idx] = v
+		}
+		return ArrayValue(out), nil
+	}
+	return Value{}, fmt.Errorf("expected numbers or arrays of numbers")
+}
@@ -0,0 +1,50 @@
+package interpreter
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// showNotification tries to pop up a desktop notification using whatever the
+// OS ships with. It reports whether a notifier ran successfully; scripts
+// should treat a false result as "no desktop available", not as an error.
+func showNotification(title, message string) bool {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := "display notification " + appleScriptQuote(message) + " with title " + appleScriptQuote(title)
+		cmd = exec.Command("osascript", "-e", script)
+	case "windows":
+		script := `Add-Type -AssemblyName System.Windows.Forms;` +
+			`$n = New-Object System.Windows.Forms.NotifyIcon;` +
+			`$n.Icon = [System.Drawing.SystemIcons]::Information;` +
+			`$n.Visible = $true;` +
+			`$n.ShowBalloonTip(5000, ` + psQuote(title) + `, ` + psQuote(message) + `, 'Info');` +
+			`Start-Sleep -Seconds 5; $n.Dispose()`
+		// The balloon has to stay alive while shown, so don't wait for it
+		// here; reap the process in the background once it exits.
+		cmd = exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script)
+		if err := cmd.Start(); err != nil {
+			return false
+		}
+		go cmd.Wait()
+		return true
+	default:
+		if _, err := exec.LookPath("notify-send"); err != nil {
+			return false
+		}
+		// -- so a title or message starting with "-" is not read as an option
+		cmd = exec.Command("notify-send", "--", title, message)
+	}
+	return cmd.Run() == nil
+}
+
+func appleScriptQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,66 @@
+package interpreter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// ---------- Number parsing ----------
+
+// plainNumber matches decimal numbers as people type them: 42, -3.5, .5.
+// strconv.ParseFloat also takes nan, inf, 1e3 and 0x1p4, which are not what
+// someone answering a prompt means.
+var plainNumber = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)$`)
+
+// parsePlainNumber reads s if it is a plain decimal number.
+func parsePlainNumber(s string) (float64, bool) {
+	if !plainNumber.MatchString(s) {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	return n, err == nil
+}
+
+// ---------- Number formatting ----------
+
+// print and str() round numbers to this many significant digits unless
+// setprecision() says otherwise, so 0.1 + 0.2 shows 0.3. Fifteen digits is
+// what a float64 always holds exactly.
+const defaultPrintPrecision = 15
+
+// textStyle is how values are turned into text. The zero style shows every
+// element and the shortest digits that read back as the exact same float.
+type textStyle struct {
+	limit     int // containers show at most this many elements (0: all)
+	precision int // significant digits, as set by setprecision() (0: shortest)
+
+	// record, when set, renders records in place of the built-in
+	// Name(field: value, ...) form; ok false falls back to it.
+	record func(v Value) (s string, ok bool)
+}
+
+// formatNumber is f in the shortest text that reads back as the same float.
+func formatNumber(f float64) string { return formatDigits(f, 0) }
+
+// formatDigits is f with precision significant digits, or the shortest
+// round-trip text when precision is 0. Whole numbers always print in full.
+func formatDigits(f float64, precision int) string {
+	if f == float64(int64(f)) {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	if precision == 0 {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return strconv.FormatFloat(f, 'g', precision, 64)
+}
+
+// formatFixed renders f with exactly places decimals, rounding half away from
+// zero on the printed digits (2.675 -> "2.68", not the binary "2.67").
+func formatFixed(f float64, places int) (string, error) {
+	d, err := decimalFromFloat(f)
+	if err != nil {
+		return "", fmt.Errorf("cannot format %v", f)
+	}
+	return decimalRound(d, places, "half-up").String(), nil
+}
@@ -0,0 +1,130 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"bpl-plus/ast"
+)
+
+// ---------- Operator hooks ----------
+// A record type takes part in operators by declaring hook methods in its type
+// block: __add(a, b) runs for a + b when either operand is of that type, and
+// __tostring(v) decides how it prints. Hooks get both operands as args, in
+// the order they were written, so 2 * v and v * 2 reach the same __mul.
+// "text" + v stays string concatenation (using __tostring) rather than
+// calling __add.
+
+var operatorHooks = map[string]string{
+	"+":  "__add",
+	"-":  "__sub",
+	"*":  "__mul",
+	"/":  "__div",
+	"%":  "__mod",
+	"==": "__eq",
+	"!=": "__eq",
+	"<":  "__cmp",
+	">":  "__cmp",
+	"<=": "__cmp",
+	">=": "__cmp",
+}
+
+// recordHook finds the hook method name on v's type, if v is a record.
+func recordHook(v Value, name string) (*ast.FunctionDecl, bool) {
+	if v.Kind != ValRecord || v.Rec == nil {
+		return nil, false
+	}
+	fn, ok := v.Rec.Type.Methods[name]
+	return fn, ok
+}
+
+// callMethod runs method fn with self bound to the record obj.
+func (i *Interpreter) callMethod(obj Value, fn *ast.FunctionDecl, args []Value, callSpan ast.Span) (Value, error) {
+	self := newEnv(nil)
+	self.Vars["self"] = obj
+	return i.callClosure(fn, self, args, callSpan)
+}
+
+// applyOperatorHook runs the hook for op if the left operand's type, or else
+// the right's, declares one. handled is false when there is no hook and the
+// operator should behave as usual. __eq must return a boolean and __cmp a
+// number that is negative, zero or positive as a is less than, equal to or
+// greater than b.
+func (i *Interpreter) applyOperatorHook(op string, left, right Value, span ast.Span) (out Value, handled bool, err error) {
+	name, ok := operatorHooks[op]
+	if !ok || (op == "+" && (left.Kind == ValString || right.Kind == ValString)) {
+		return Value{}, false, nil
+	}
+	owner := left
+	fn, ok := recordHook(left, name)
+	if !ok {
+		owner = right
+		if fn, ok = recordHook(right, name); !ok {
+			return Value{}, false, nil
+		}
+	}
+	res, err := i.callMethod(owner, fn, []Value{left, right}, span)
+	if err != nil {
+		return Value{}, true, err
+	}
+
+	switch name {
+	case "__eq":
+		if res.Kind != ValBool {
+			return Value{}, true, i.runtimeErr(span, fmt.Sprintf("%s.__eq must return a boolean, got %s", owner.Rec.Type.Decl.Name, kindName(res.Kind)))
+		}
+		return BoolValue(res.Bool == (op == "==")), true, nil
+	case "__cmp":
+		if res.Kind != ValNumber {
+			return Value{}, true, i.runtimeErr(span, fmt.Sprintf("%s.__cmp must return a number, got %s", owner.Rec.Type.Decl.Name, kindName(res.Kind)))
+		}
+		c := res.Number
+		switch op {
+		case "<":
+			return BoolValue(c < 0), true, nil
+		case ">":
+			return BoolValue(c > 0), true, nil
+		case "<=":
+			return BoolValue(c <= 0), true, nil
+		default:
+			return BoolValue(c >= 0), true, nil
+		}
+	}
+	return res, true, nil
+}
+
+// stringOf is v.ToString() with the __tostring hook applied to records, at
+// any depth, and numbers at the setprecision() digits. It is what str() and
+// string concatenation use.
+func (i *Interpreter) stringOf(v Value, span ast.Span) (string, error) {
+	return i.textOf(v, textStyle{precision: i.printPrecision}, span)
+}
+
+// textOf renders v in style st, calling __tostring for every record that has
+// one: print [p] shows p the way print p does. The first hook error stops the
+// remaining hooks and is returned.
+func (i *Interpreter) textOf(v Value, st textStyle, span ast.Span) (string, error) {
+	var hookErr error
+	st.record = func(v Value) (string, bool) {
+		fn, ok := recordHook(v, "__tostring")
+		if !ok {
+			return "", false
+		}
+		if hookErr != nil {
+			return "", true
+		}
+		res, err := i.callMethod(v, fn, []Value{v}, span)
+		if err == nil && res.Kind != ValString {
+			err = i.runtimeErr(span, fmt.Sprintf("%s.__tostring must return a string, got %s", v.Rec.Type.Decl.Name, kindName(res.Kind)))
+		}
+		if err != nil {
+			hookErr = err
+			return "", true
+		}
+		return res.Str, true
+	}
+	s := v.toString(nil, st)
+	if hookErr != nil {
+		return "", hookErr
+	}
+	return s, nil
+}
@@ -0,0 +1,80 @@
+package interpreter
+
+import (
+	"strings"
+	"testing"
+
+	"bpl-plus/lexer"
+	"bpl-plus/parser"
+)
+
+// __tostring and __eq apply to records inside arrays, maps and other records
+// just as they do to a record on its own.
+func TestHooksApplyToNestedRecords(t *testing.T) {
+	const types = `type Money
+  cents = 0
+  note = ""
+  function __tostring(m)
+    return "$" + str(m.cents / 100)
+  end
+  function __eq(a, b)
+    return a.cents == b.cents
+  end
+end
+type Pair
+  a = null
+  b = null
+end
+dim m = Money(150)
+`
+	tests := []struct {
+		name, expr, want string
+	}{
+		{"str of a record", "str(m)", "$1.5"},
+		{"str of an array", "str([m, 2])", "[$1.5, 2]"},
+		{"str of a map", `str({"price": m})`, `{"price": $1.5}`},
+		{"str of a record field", "str(Pair(m, 1))", "Pair(a: $1.5, b: 1)"},
+		{"concatenation", `"total " + [m]`, "total [$1.5]"},
+		{"in", `str(Money(150, "cash") in [Money(1), Money(150, "card")])`, "true"},
+		{"not in", `str(Money(200) in [Money(150)])`, "false"},
+		{"arrays of records", `str([Money(150, "cash")] == [Money(150, "card")])`, "true"},
+		{"match case", `match Money(150, "card")
+  case m: "matched"
+  case else: "missed"
+end`, "matched"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := types + "r = " + tt.expr + "\n"
+			prog, err := parser.New(lexer.New(src)).ParseProgram()
+			if err != nil {
+				t.Fatal(err)
+			}
+			in := NewWithSource("main.bpl", src)
+			if err := in.Run(prog); err != nil {
+				t.Fatal(err)
+			}
+			if got := in.globals["r"]; got.Kind != ValString || got.Str != tt.want {
+				t.Errorf("r = %s, want %q", got.ToString(), tt.want)
+			}
+		})
+	}
+}
+
+func TestNestedHookErrorsAreReported(t *testing.T) {
+	src := `type Bad
+  function __tostring(b)
+    return 1
+  end
+end
+r = str([Bad()])
+`
+	prog, err := parser.New(lexer.New(src)).ParseProgram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = NewWithSource("main.bpl", src).Run(prog)
+	if err == nil || !strings.Contains(err.Error(), "Bad.__tostring must return a string, got number") {
+		t.Errorf("err = %v", err)
+	}
+}
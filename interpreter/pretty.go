@@ -0,0 +1,156 @@
+package interpreter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Limits applied by pprint()/inspect() so huge values stay readable.
+const (
+	prettyMaxItems  = 100 // elements shown per array/map
+	prettyMaxDepth  = 12  // nesting levels before collapsing to [...] / {...}
+	prettyMaxString = 200 // runes shown per string
+	prettyInlineMax = 8   // scalar-only arrays up to this size print on one line
+)
+
+type prettyPrinter struct {
+	b    strings.Builder
+	seen map[any]bool
+}
+
+// prettyString renders v with indentation, truncation and cycle detection.
+func prettyString(v Value) string {
+	pp := &prettyPrinter{seen: map[any]bool{}}
+	pp.write(v, 0)
+	return pp.b.String()
+}
+
+func (pp *prettyPrinter) indent(depth int) {
+	pp.b.WriteString(strings.Repeat("  ", depth))
+}
+
+func (pp *prettyPrinter) write(v Value, depth int) {
+	switch v.Kind {
+	case ValString:
+		s := v.Str
+		rs := []rune(s)
+		if len(rs) > prettyMaxString {
+			pp.b.WriteString(strconv.Quote(string(rs[:prettyMaxString])))
+			pp.b.WriteString(fmt.Sprintf("... (%d more chars)", len(rs)-prettyMaxString))
+			return
+		}
+		pp.b.WriteString(strconv.Quote(s))
+
+	case ValArray:
+		pp.writeArray(v, depth)
+
+	case ValMap:
+		pp.writeMap(v, depth)
+
+	default:
+		pp.b.WriteString(v.ToString())
+	}
+}
+
+func isScalar(v Value) bool {
+	return v.Kind != ValArray && v.Kind != ValMap
+}
+
+func (pp *prettyPrinter) writeArray(v Value, depth int) {
+	elems := v.arrayElems()
+	if len(elems) == 0 {
+		pp.b.WriteString("[]")
+		return
+	}
+	if pp.seen[v.Arr] {
+		pp.b.WriteString("[<cycle>]")
+		return
+	}
+	if depth >= prettyMaxDepth {
+		pp.b.WriteString("[...]")
+		return
+	}
+	pp.seen[v.Arr] = true
+	defer delete(pp.seen, v.Arr)
+
+	inline := len(elems) <= prettyInlineMax
+	for _, el := range elems {
+		if !isScalar(el) {
+			inline = false
+			break
+		}
+	}
+	if inline {
+		pp.b.WriteString("[")
+		for idx, el := range elems {
+			if idx > 0 {
+				pp.b.WriteString(", ")
+			}
+			pp.write(el, depth+1)
+		}
+		pp.b.WriteString("]")
+		return
+	}
+
+	pp.b.WriteString("[\n")
+	for idx, el := range elems {
+		if idx == prettyMaxItems {
+			pp.indent(depth + 1)
+			pp.b.WriteString(fmt.Sprintf("... (%d more)\n", len(elems)-prettyMaxItems))
+			break
+		}
+		pp.indent(depth + 1)
+		pp.write(el, depth+1)
+		if idx < len(elems)-1 {
+			pp.b.WriteString(",")
+		}
+		pp.b.WriteString("\n")
+	}
+	pp.indent(depth)
+	pp.b.WriteString("]")
+}
+
+func (pp *prettyPrinter) writeMap(v Value, depth int) {
+	m := v.mapElems()
+	if len(m) == 0 {
+		pp.b.WriteString("{}")
+		return
+	}
+	if pp.seen[v.Map] {
+		pp.b.WriteString("{<cycle>}")
+		return
+	}
+	if depth >= prettyMaxDepth {
+		pp.b.WriteString("{...}")
+		return
+	}
+	pp.seen[v.Map] = true
+	defer delete(pp.seen, v.Map)
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pp.b.WriteString("{\n")
+	for idx, k := range keys {
+		if idx == prettyMaxItems {
+			pp.indent(depth + 1)
+			pp.b.WriteString(fmt.Sprintf("... (%d more)\n", len(keys)-prettyMaxItems))
+			break
+		}
+		pp.indent(depth + 1)
+		pp.b.WriteString(strconv.Quote(k))
+		pp.b.WriteString(": ")
+		pp.write(m[k], depth+1)
+		if idx < len(keys)-1 {
+			pp.b.WriteString(",")
+		}
+		pp.b.WriteString("\n")
+	}
+	pp.indent(depth)
+	pp.b.WriteString("}")
+}
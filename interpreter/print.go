@@ -0,0 +1,83 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"bpl-plus/ast"
+)
+
+// execPrint writes a print statement's items back to back. tab(n) inside a
+// print pads with spaces up to column n, or starts a new line first if the
+// cursor is already past it.
+func (i *Interpreter) execPrint(stmt *ast.PrintStmt) error {
+	var b strings.Builder
+	for k, item := range stmt.Values {
+		if k > 0 && stmt.Seps[k-1] == "," {
+			b.WriteString(" ")
+		}
+		if call, ok := item.(*ast.CallExpr); ok && call.Callee == "tab" && i.funcs["tab"] == nil {
+			col, err := i.evalTab(call)
+			if err != nil {
+				return err
+			}
+			if cur := i.columnAfter(b.String()); col < cur {
+				b.WriteString("\n")
+				b.WriteString(strings.Repeat(" ", col))
+			} else {
+				b.WriteString(strings.Repeat(" ", col-cur))
+			}
+			continue
+		}
+
+		val, err := i.evalExpr(item)
+		if err != nil {
+			return err
+		}
+		s, err := i.printOf(val, item.GetSpan())
+		if err != nil {
+			return err
+		}
+		b.WriteString(s)
+	}
+	if !stmt.NoNewline {
+		b.WriteString("\n")
+	} else if len(stmt.Seps) == len(stmt.Values) && stmt.Seps[len(stmt.Seps)-1] == "," {
+		b.WriteString(" ")
+	}
+
+	out := b.String()
+	i.printCol = i.columnAfter(out)
+	fmt.Print(out)
+	return nil
+}
+
+// printOf is stringOf as print uses it: big containers are cut off at the
+// print limit (see setprintlimit).
+func (i *Interpreter) printOf(v Value, span ast.Span) (string, error) {
+	return i.textOf(v, textStyle{limit: i.printLimit, precision: i.printPrecision}, span)
+}
+
+func (i *Interpreter) evalTab(call *ast.CallExpr) (int, error) {
+	if len(call.Args) != 1 {
+		return 0, i.runtimeErr(call.GetSpan(), "tab() expects 1 arg: tab(column)")
+	}
+	v, err := i.evalExpr(call.Args[0])
+	if err != nil {
+		return 0, err
+	}
+	col, err := i.toIndex(v, call.GetSpan())
+	if err != nil || col < 0 {
+		return 0, i.runtimeErr(call.GetSpan(), "tab() column must be a non-negative integer")
+	}
+	return col, nil
+}
+
+// columnAfter returns the console column once s has been printed.
+func (i *Interpreter) columnAfter(s string) int {
+	if nl := strings.LastIndexByte(s, '\n'); nl >= 0 {
+		return utf8.RuneCountInString(s[nl+1:])
+	}
+	return i.printCol + utf8.RuneCountInString(s)
+}
@@ -0,0 +1,29 @@
+package interpreter
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ---------- Print limit ----------
+
+// Arrays and maps with more elements than this print only the first ones,
+// followed by "… (N more)", so print big_array cannot flood the terminal.
+const defaultPrintLimit = 1000
+
+// printString is v as print shows it: containers are cut off at the print
+// limit and numbers have the digits set by setprecision(). str(), file output
+// and JSON always get every element.
+func (i *Interpreter) printString(v Value) string {
+	return v.toString(nil, textStyle{limit: i.printLimit, precision: i.printPrecision})
+}
+
+// moreText is the marker for n elements left out, with thousands separators:
+// "… (999,000 more)".
+func moreText(n int) string {
+	digits := strconv.Itoa(n)
+	for at := len(digits) - 3; at > 0; at -= 3 {
+		digits = digits[:at] + "," + digits[at:]
+	}
+	return fmt.Sprintf("… (%s more)", digits)
+}
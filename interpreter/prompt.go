@@ -0,0 +1,140 @@
+package interpreter
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/chzyer/readline"
+)
+
+// ---------- Interactive prompts ----------
+
+// promptLine prints prompt and reads one line from stdin. It returns io.EOF
+// when input has run out and nothing was typed.
+func (i *Interpreter) promptLine(prompt string) (string, error) {
+	i.printCol = 0 // the user's Enter ends the line
+	if i.lineReader != nil {
+		line, err := i.lineReader.ReadLine(prompt)
+		if err != nil {
+			return "", io.EOF
+		}
+		return line, nil
+	}
+	fmt.Print(prompt)
+	line, err := i.in.ReadString('\n')
+	if err != nil && line == "" {
+		return "", io.EOF
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// promptConfirm asks a yes/no question until it gets an answer. def is used
+// for empty input (and at end of input) when hasDef is set.
+func (i *Interpreter) promptConfirm(prompt string, def, hasDef bool) (bool, error) {
+	for {
+		line, err := i.promptLine(prompt)
+		if err != nil {
+			if hasDef {
+				return def, nil
+			}
+			return false, fmt.Errorf("reached end of input")
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		case "":
+			if hasDef {
+				return def, nil
+			}
+		}
+		fmt.Println("Please answer yes or no.")
+	}
+}
+
+// promptNumber reads until the user types a number (within [lo, hi] when
+// bounded). def is used for empty input (and at end of input) when hasDef
+// is set.
+func (i *Interpreter) promptNumber(prompt string, lo, hi float64, bounded bool, def float64, hasDef bool) (float64, error) {
+	for {
+		line, err := i.promptLine(prompt)
+		if err != nil {
+			if hasDef {
+				return def, nil
+			}
+			return 0, fmt.Errorf("reached end of input")
+		}
+		line = strings.TrimSpace(line)
+		if line == "" && hasDef {
+			return def, nil
+		}
+		n, ok := parsePlainNumber(line)
+		switch {
+		case !ok:
+			fmt.Println("Please enter a number.")
+		case bounded && (n < lo || n > hi):
+			fmt.Printf("Please enter a number from %s to %s.\n", NumberValue(lo).ToString(), NumberValue(hi).ToString())
+		default:
+			return n, nil
+		}
+	}
+}
+
+// promptChoose lists options numbered from 1 and returns the index picked,
+// either by number or by typing the option text exactly.
+func (i *Interpreter) promptChoose(prompt string, options []Value) (int, error) {
+	if len(options) == 0 {
+		return 0, fmt.Errorf("needs at least one option")
+	}
+	fmt.Println(prompt)
+	for idx, opt := range options {
+		fmt.Printf("  %d) %s\n", idx+1, opt.ToString())
+	}
+	for {
+		line, err := i.promptLine("> ")
+		if err != nil {
+			return 0, fmt.Errorf("reached end of input")
+		}
+		line = strings.TrimSpace(line)
+		if n, err := strconv.Atoi(line); err == nil && n >= 1 && n <= len(options) {
+			return n - 1, nil
+		}
+		for idx, opt := range options {
+			if strings.EqualFold(opt.ToString(), line) {
+				return idx, nil
+			}
+		}
+		fmt.Printf("Please enter a number from 1 to %d.\n", len(options))
+	}
+}
+
+// promptSecret reads a line without echoing it when stdin is a terminal.
+// Piped input falls back to a normal read so scripts stay testable.
+func (i *Interpreter) promptSecret(prompt string) (string, error) {
+	if i.lineReader != nil {
+		s, err := i.lineReader.ReadSecret(prompt)
+		if err != nil {
+			return "", fmt.Errorf("reached end of input")
+		}
+		return s, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !readline.IsTerminal(fd) {
+		line, err := i.promptLine(prompt)
+		if err != nil {
+			return "", fmt.Errorf("reached end of input")
+		}
+		return line, nil
+	}
+	fmt.Print(prompt)
+	b, err := readline.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
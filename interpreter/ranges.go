@@ -0,0 +1,75 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+
+	"bpl-plus/ast"
+)
+
+// ---------- Ranges ----------
+
+// maxRangeLen keeps every value of a range exact: past 2^53 adding 1 to a
+// number no longer changes it.
+const maxRangeLen = 1 << 53
+
+// RangeObject is the value of start..end: start, start + 1, ... up to end.
+// A range never changes, and its values are worked out as they are needed
+// rather than stored.
+type RangeObject struct {
+	Start, End float64
+}
+
+func RangeValue(start, end float64) Value {
+	return Value{Kind: ValRange, Rng: &RangeObject{Start: start, End: end}}
+}
+
+// Len is the number of values in the range; 5..1 is empty, as a for loop
+// from 5 to 1 runs no times.
+func (r *RangeObject) Len() int {
+	if r.End < r.Start {
+		return 0
+	}
+	return int(math.Floor(r.End-r.Start)) + 1
+}
+
+// At is the idx-th value of the range, counting from 0.
+func (r *RangeObject) At(idx int) float64 { return r.Start + float64(idx) }
+
+// Contains reports whether x is one of the range's values.
+func (r *RangeObject) Contains(x float64) bool {
+	return x >= r.Start && x <= r.End && x-r.Start == math.Trunc(x-r.Start)
+}
+
+func (r *RangeObject) String() string {
+	return formatNumber(r.Start) + ".." + formatNumber(r.End)
+}
+
+func (i *Interpreter) evalRange(expr *ast.RangeExpr) (Value, error) {
+	start, err := i.rangeEnd(expr.Start)
+	if err != nil {
+		return Value{}, err
+	}
+	end, err := i.rangeEnd(expr.End)
+	if err != nil {
+		return Value{}, err
+	}
+	if end-start >= maxRangeLen {
+		return Value{}, i.runtimeErr(expr.GetSpan(), fmt.Sprintf("Range %s..%s is too long", formatNumber(start), formatNumber(end)))
+	}
+	return RangeValue(start, end), nil
+}
+
+func (i *Interpreter) rangeEnd(e ast.Expr) (float64, error) {
+	v, err := i.evalExpr(e)
+	if err != nil {
+		return 0, err
+	}
+	if v.Kind == ValDecimal {
+		return v.Dec.Float64(), nil
+	}
+	if v.Kind != ValNumber || math.IsNaN(v.Number) || math.IsInf(v.Number, 0) {
+		return 0, i.runtimeErr(e.GetSpan(), "A range needs a finite number at each end, got "+v.ToString())
+	}
+	return v.Number, nil
+}
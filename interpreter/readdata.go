@@ -0,0 +1,82 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"bpl-plus/ast"
+	"bpl-plus/codes"
+)
+
+// ---------- data / read / restore ----------
+
+// dataList is every value from one file's data statements, in source order
+// (wherever the statements are, including inside functions), and where the
+// next read takes from. labels maps a label to the index of the first value
+// after it, for restore label.
+type dataList struct {
+	values []Value
+	labels map[string]int
+	next   int
+}
+
+// loadData appends the data statements of a program (or REPL chunk) that is
+// about to run, so read works even when the data comes after it.
+func (i *Interpreter) loadData(stmts []ast.Stmt) {
+	d := i.data
+	for _, s := range stmts {
+		ast.Inspect(s, func(n ast.Node) bool {
+			switch st := n.(type) {
+			case *ast.LabelStmt:
+				if d.labels == nil {
+					d.labels = map[string]int{}
+				}
+				if _, ok := d.labels[st.Name]; !ok {
+					d.labels[st.Name] = len(d.values)
+				}
+			case *ast.DataStmt:
+				for _, e := range st.Values {
+					// literals only, so evaluation cannot fail or have effects
+					v, _ := i.evalExpr(e)
+					d.values = append(d.values, v)
+				}
+			}
+			return true
+		})
+	}
+}
+
+// execRead assigns the next data values to the listed variables in turn.
+func (i *Interpreter) execRead(stmt *ast.ReadStmt) error {
+	d := i.data
+	for _, name := range stmt.Names {
+		if d.next >= len(d.values) {
+			return i.codedErr(stmt.GetSpan(), codes.OutOfData, outOfDataMessage(name, d))
+		}
+		i.setVar(name, d.values[d.next])
+		d.next++
+	}
+	return nil
+}
+
+func outOfDataMessage(name string, d *dataList) string {
+	if len(d.values) == 0 {
+		return fmt.Sprintf("read %s: out of data (the program has no data statements)", name)
+	}
+	return fmt.Sprintf("read %s: out of data (all %d data values have been read; use restore to read them again)", name, len(d.values))
+}
+
+// execRestore moves the read position back to the first data value, or to
+// the first one after the label.
+func (i *Interpreter) execRestore(stmt *ast.RestoreStmt) error {
+	d := i.data
+	if stmt.Label == "" {
+		d.next = 0
+		return nil
+	}
+	at, ok := d.labels[stmt.Label]
+	if !ok {
+		return i.runtimeErr(stmt.GetSpan(), fmt.Sprintf("restore %s: no label %q in this file", stmt.Label, stmt.Label))
+	}
+	d.next = at
+	return nil
+}
@@ -0,0 +1,209 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+
+	"bpl-plus/ast"
+)
+
+// ---------- Records ----------
+
+// RecordType is a type declared with a `type ... end` block.
+type RecordType struct {
+	Decl    *ast.TypeDecl
+	Methods map[string]*ast.FunctionDecl
+}
+
+func (t *RecordType) fieldIndex(name string) int {
+	for idx, f := range t.Decl.Fields {
+		if f == name {
+			return idx
+		}
+	}
+	return -1
+}
+
+// findField is fieldIndex, except that with fold (a p.X written in an option
+// classic file) a field whose name differs only in case also matches.
+func (t *RecordType) findField(name string, fold bool) int {
+	idx := t.fieldIndex(name)
+	if idx >= 0 || !fold {
+		return idx
+	}
+	for idx, f := range t.Decl.Fields {
+		if strings.EqualFold(f, name) {
+			return idx
+		}
+	}
+	return -1
+}
+
+// findMethod looks up a method like findField looks up a field.
+func (t *RecordType) findMethod(name string, fold bool) (*ast.FunctionDecl, bool) {
+	if m, ok := t.Methods[name]; ok || !fold {
+		return m, ok
+	}
+	for mname, m := range t.Methods {
+		if strings.EqualFold(mname, name) {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+// dottedName is the namespaced builtin name written as ns.name; an option
+// classic file may write it in any case (MATH.FLOOR).
+func dottedName(ns, name string, fold bool) string {
+	if fold {
+		return strings.ToLower(ns + "." + name)
+	}
+	return ns + "." + name
+}
+
+// RecordObject gives records reference semantics, like arrays and maps.
+type RecordObject struct {
+	Type   *RecordType
+	Fields []Value // in declaration order
+}
+
+func (i *Interpreter) execTypeDecl(decl *ast.TypeDecl) error {
+	if i.inFunction() {
+		return i.runtimeErr(decl.GetSpan(), "Types must be declared at the top level")
+	}
+	if _, ok := i.funcs[decl.Name]; ok {
+		return i.runtimeErr(decl.GetSpan(), fmt.Sprintf("Type %s has the same name as a function", decl.Name))
+	}
+	t := &RecordType{Decl: decl, Methods: map[string]*ast.FunctionDecl{}}
+	for _, m := range decl.Methods {
+		t.Methods[m.Name] = m
+	}
+	i.types[decl.Name] = t
+	return nil
+}
+
+// construct builds a record from constructor args: Point(1, 2) fills fields
+// in order, and fields left out take their default (or null).
+func (i *Interpreter) construct(t *RecordType, args []Value, callSpan ast.Span) (Value, error) {
+	decl := t.Decl
+	if len(args) > len(decl.Fields) {
+		return Value{}, i.runtimeErr(callSpan, fmt.Sprintf("%s() expects at most %d args, got %d", decl.Name, len(decl.Fields), len(args)))
+	}
+	fields := make([]Value, len(decl.Fields))
+	copy(fields, args)
+	for idx := len(args); idx < len(fields); idx++ {
+		if decl.Defaults[idx] == nil {
+			continue
+		}
+		v, err := i.evalExpr(decl.Defaults[idx])
+		if err != nil {
+			return Value{}, err
+		}
+		fields[idx] = v
+	}
+	return Value{Kind: ValRecord, Rec: &RecordObject{Type: t, Fields: fields}}, nil
+}
+
+// evalMember evaluates p.x. A name.member that is not a variable may be a
+// namespaced builtin used as a value (f = str.upper).
+func (i *Interpreter) evalMember(expr *ast.MemberExpr) (Value, error) {
+	if id, ok := expr.Left.(*ast.Identifier); ok {
+		if _, isVar := i.lookupVar(id.Name); !isVar {
+			if dotted := dottedName(id.Name, expr.Name, expr.Classic); IsBuiltin(dotted) {
+				return builtinFunctionValue(dotted), nil
+			}
+		}
+	}
+	left, err := i.evalExpr(expr.Left)
+	if err != nil {
+		return Value{}, err
+	}
+	idx, err := i.fieldOf(left, expr.Name, expr.Classic, expr.GetSpan())
+	if err != nil {
+		return Value{}, err
+	}
+	return left.Rec.Fields[idx], nil
+}
+
+// evalMethodCall evaluates obj.name(args). On a record it runs the type's
+// method with self bound to the record, or calls a function stored in a field;
+// on a name that is not a variable it calls the namespaced builtin.
+func (i *Interpreter) evalMethodCall(call *ast.MethodCallExpr) (Value, error) {
+	if id, ok := call.Object.(*ast.Identifier); ok {
+		if _, isVar := i.lookupVar(id.Name); !isVar {
+			return i.evalBuiltin(dottedName(id.Name, call.Name, call.Classic), call.Args, call.GetSpan())
+		}
+	}
+	obj, err := i.evalExpr(call.Object)
+	if err != nil {
+		return Value{}, err
+	}
+	if obj.Kind != ValRecord || obj.Rec == nil {
+		return Value{}, i.runtimeErr(call.GetSpan(), fmt.Sprintf("Cannot call method %q on a %s value", call.Name, kindName(obj.Kind)))
+	}
+	args, err := i.evalArgs(call.Args)
+	if err != nil {
+		return Value{}, err
+	}
+	t := obj.Rec.Type
+	if m, ok := t.findMethod(call.Name, call.Classic); ok {
+		return i.callMethod(obj, m, args, call.GetSpan())
+	}
+	if idx := t.findField(call.Name, call.Classic); idx >= 0 {
+		return i.callValue(obj.Rec.Fields[idx], args, call.GetSpan())
+	}
+	return Value{}, i.runtimeErr(call.GetSpan(), fmt.Sprintf("%s has no method %q", t.Decl.Name, call.Name))
+}
+
+func (i *Interpreter) execMemberAssign(stmt *ast.MemberAssignStmt) error {
+	target, err := i.evalExpr(stmt.Target)
+	if err != nil {
+		return err
+	}
+	idx, err := i.fieldOf(target, stmt.Name, stmt.Classic, stmt.GetSpan())
+	if err != nil {
+		return err
+	}
+	val, err := i.evalExpr(stmt.Value)
+	if err != nil {
+		return err
+	}
+	target.Rec.Fields[idx] = val
+	i.noteSize(val)
+	return nil
+}
+
+// fieldOf finds field name in record v, in any case when fold is set.
+func (i *Interpreter) fieldOf(v Value, name string, fold bool, span ast.Span) (int, error) {
+	if v.Kind != ValRecord || v.Rec == nil {
+		return 0, i.runtimeErr(span, fmt.Sprintf("Cannot read field %q of a %s value", name, kindName(v.Kind)))
+	}
+	idx := v.Rec.Type.findField(name, fold)
+	if idx < 0 {
+		return 0, i.runtimeErr(span, fmt.Sprintf("%s has no field %q", v.Rec.Type.Decl.Name, name))
+	}
+	return idx, nil
+}
+
+// toString renders Point(x: 1, y: 2).
+func (r *RecordObject) toString(seen map[any]bool, st textStyle) string {
+	if seen[r] {
+		return r.Type.Decl.Name + "(...)"
+	}
+	if seen == nil {
+		seen = map[any]bool{}
+	}
+	seen[r] = true
+	defer delete(seen, r)
+
+	var b strings.Builder
+	b.WriteString(r.Type.Decl.Name + "(")
+	for idx, f := range r.Type.Decl.Fields {
+		if idx > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(f + ": " + r.Fields[idx].toString(seen, st))
+	}
+	b.WriteString(")")
+	return b.String()
+}
@@ -0,0 +1,224 @@
+package interpreter
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"bpl-plus/modcache"
+)
+
+// ---------- URL imports (import "https://...") ----------
+
+// lockFileName is the file, next to the main script, that pins every URL
+// import to the sha256 of the content first downloaded for it.
+const lockFileName = "bpl.lock"
+
+const remoteImportTimeout = 30 * time.Second
+
+// maxRemoteModuleBytes caps a downloaded module; no source file is this big,
+// so anything larger is a wrong URL or a misbehaving server.
+const maxRemoteModuleBytes = 16 << 20
+
+// SetOffline stops URL imports from touching the network: they must already
+// be pinned in bpl.lock and present in the download cache.
+func (i *Interpreter) SetOffline(on bool) {
+	i.offline = on
+}
+
+func isURLImport(p string) bool {
+	lower := strings.ToLower(p)
+	return strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "http://")
+}
+
+// urlImportCandidate resolves raw against the URL of the importing module, so
+// a downloaded module's own relative imports come from the same server.
+func urlImportCandidate(raw, importer string) (importCandidate, bool) {
+	base, err := url.Parse(importer)
+	if err != nil {
+		return importCandidate{}, false
+	}
+	ref, err := url.Parse(raw)
+	if err != nil {
+		return importCandidate{}, false
+	}
+	u := base.ResolveReference(ref)
+	if filepath.Ext(u.Path) == "" {
+		u.Path += ".bpl"
+	}
+	return importCandidate{Path: u.String(), Where: "next to " + importer}, true
+}
+
+// importLock is bpl.lock: URL -> "sha256:<hex>".
+type importLock struct {
+	path string
+	pins map[string]string
+}
+
+func (i *Interpreter) lockFile() (*importLock, error) {
+	if i.lock != nil {
+		return i.lock, nil
+	}
+	dir := "."
+	if name := i.mainSource.name; name != "" && !strings.HasPrefix(name, "<") {
+		dir = filepath.Dir(name)
+	}
+	lock := &importLock{path: filepath.Join(dir, lockFileName), pins: map[string]string{}}
+
+	f, err := os.Open(lock.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		defer f.Close()
+		sc := bufio.NewScanner(f)
+		for n := 1; sc.Scan(); n++ {
+			line := strings.TrimSpace(sc.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			fields := strings.Fields(line)
+			if len(fields) != 2 || !strings.HasPrefix(fields[1], "sha256:") {
+				return nil, fmt.Errorf("%s:%d: expected \"<url> sha256:<hash>\"", lock.path, n)
+			}
+			lock.pins[fields[0]] = fields[1]
+		}
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+	}
+	i.lock = lock
+	return lock, nil
+}
+
+func (l *importLock) save() error {
+	urls := make([]string, 0, len(l.pins))
+	for u := range l.pins {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+
+	var b strings.Builder
+	b.WriteString("# Content hashes of URL imports, written by bplplus. Commit this file;\n")
+	b.WriteString("# delete a line to accept a new version of that module.\n")
+	for _, u := range urls {
+		fmt.Fprintf(&b, "%s %s\n", u, l.pins[u])
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
+
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// remoteCachePath is where content with the given hash is kept, or "" when
+// the cache is off (BPLPLUS_NOCACHE).
+func remoteCachePath(hash string) string {
+	dir := modcache.Dir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "remote", strings.TrimPrefix(hash, "sha256:")+".bpl")
+}
+
+func readRemoteCache(hash string) ([]byte, bool) {
+	path := remoteCachePath(hash)
+	if path == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || contentHash(data) != hash {
+		return nil, false
+	}
+	return data, true
+}
+
+func writeRemoteCache(hash string, data []byte) {
+	path := remoteCachePath(hash)
+	if path == "" {
+		return
+	}
+	// Like the parse cache, a failed write only costs a download next time.
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	tmp := path + ".part"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return
+	}
+	_ = os.Rename(tmp, path)
+}
+
+// fetchImport returns the source of a URL import. Pinned content comes from
+// the download cache when possible; otherwise it is downloaded and must match
+// its pin. A URL seen for the first time is pinned to what was downloaded.
+func (i *Interpreter) fetchImport(rawURL string) ([]byte, error) {
+	lock, err := i.lockFile()
+	if err != nil {
+		return nil, err
+	}
+	want := lock.pins[rawURL]
+	if want != "" {
+		if data, ok := readRemoteCache(want); ok {
+			return data, nil
+		}
+	}
+	if i.offline {
+		if want == "" {
+			return nil, fmt.Errorf("not pinned in %s and --offline is set (run once online to download and pin it)", lockFileName)
+		}
+		return nil, fmt.Errorf("not in the download cache and --offline is set")
+	}
+
+	data, err := httpGetBytes(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	got := contentHash(data)
+	if want != "" && got != want {
+		return nil, fmt.Errorf("content does not match %s (pinned %s, downloaded %s); delete its line from %s to accept the new version",
+			lockFileName, want, got, lock.path)
+	}
+	writeRemoteCache(got, data)
+	if want == "" {
+		lock.pins[rawURL] = got
+		if err := lock.save(); err != nil {
+			return nil, fmt.Errorf("could not update %s: %v", lock.path, err)
+		}
+	}
+	return data, nil
+}
+
+func httpGetBytes(rawURL string) ([]byte, error) {
+	client := &http.Client{Timeout: remoteImportTimeout}
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("server returned %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteModuleBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxRemoteModuleBytes {
+		return nil, fmt.Errorf("module is larger than %d MB", maxRemoteModuleBytes>>20)
+	}
+	return data, nil
+}
@@ -0,0 +1,69 @@
+package interpreter
+
+import (
+	"time"
+
+	"bpl-plus/ast"
+)
+
+// ---------- retry() and ratelimit() ----------
+
+// retryMaxBackoff caps the doubling delay between retry() attempts.
+const retryMaxBackoff = 30 * time.Second
+
+// retryCall calls fn with no args until it succeeds, up to attempts times,
+// sleeping backoff before the second attempt and doubling it after each
+// failure. Only runtime errors are retried; exit() and the like pass through.
+// The last error is returned when every attempt fails.
+func (i *Interpreter) retryCall(fn Value, attempts int, backoff time.Duration, span ast.Span) (Value, error) {
+	var lastErr error
+	for n := 1; n <= attempts; n++ {
+		v, err := i.callValue(fn, nil, span)
+		if err == nil {
+			return v, nil
+		}
+		if _, ok := err.(RuntimeError); !ok {
+			return Value{}, err
+		}
+		lastErr = err
+		if n < attempts {
+			time.Sleep(backoff)
+			backoff = min(backoff*2, retryMaxBackoff)
+		}
+	}
+	return Value{}, lastErr
+}
+
+// rateLimiter allows at most n calls in any window of length per, blocking
+// callers until a slot frees up.
+type rateLimiter struct {
+	n     int
+	per   time.Duration
+	calls []time.Time // start times of the most recent calls, oldest first
+}
+
+func (r *rateLimiter) wait() {
+	if len(r.calls) >= r.n {
+		if d := time.Until(r.calls[0].Add(r.per)); d > 0 {
+			time.Sleep(d)
+		}
+		r.calls = r.calls[1:]
+	}
+	r.calls = append(r.calls, time.Now())
+}
+
+// rateLimitedValue returns a function value that waits for the limiter and
+// then calls wrapped with its args, or just returns null when wrapped is nil.
+func rateLimitedValue(r *rateLimiter, wrapped *Value) Value {
+	native := func(i *Interpreter, name string, args []Value, callSpan ast.Span) (Value, error) {
+		r.wait()
+		if wrapped == nil {
+			if len(args) != 0 {
+				return Value{}, i.runtimeErr(callSpan, "rate limiter expects 0 args")
+			}
+			return NullValue(), nil
+		}
+		return i.callValue(*wrapped, args, callSpan)
+	}
+	return Value{Kind: ValFunction, Fn: &FunctionObject{Builtin: "ratelimit", Native: native}}
+}
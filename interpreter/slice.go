@@ -0,0 +1,67 @@
+package interpreter
+
+import (
+	"bpl-plus/ast"
+)
+
+// evalSlice evaluates a[start:end] on an array or string, giving a new array
+// or string. A missing start is 0 and a missing end is the length; negative
+// bounds count from the end (a[len(a) - 2:] and a[0 - 2:] are the same), and
+// bounds past either end are clamped, so a slice never fails on a short value.
+func (i *Interpreter) evalSlice(expr *ast.SliceExpr) (Value, error) {
+	left, err := i.evalExpr(expr.Left)
+	if err != nil {
+		return Value{}, err
+	}
+
+	var n int
+	switch left.Kind {
+	case ValArray:
+		n = len(left.arrayElems())
+	case ValString:
+		n = len([]rune(left.Str))
+	default:
+		return Value{}, i.runtimeErr(expr.GetSpan(), "Slicing requires an array or string, got "+kindName(left.Kind))
+	}
+
+	start, err := i.sliceBound(expr.Start, 0, n)
+	if err != nil {
+		return Value{}, err
+	}
+	end, err := i.sliceBound(expr.End, n, n)
+	if err != nil {
+		return Value{}, err
+	}
+	if end < start {
+		end = start
+	}
+
+	if left.Kind == ValString {
+		return StringValue(string([]rune(left.Str)[start:end])), nil
+	}
+	elems := make([]Value, end-start)
+	copy(elems, left.arrayElems()[start:end])
+	arr := ArrayValue(elems)
+	i.noteSize(arr)
+	return arr, nil
+}
+
+// sliceBound evaluates one bound of a slice over n items, defaulting to def
+// when the bound is omitted.
+func (i *Interpreter) sliceBound(e ast.Expr, def, n int) (int, error) {
+	if e == nil {
+		return def, nil
+	}
+	v, err := i.evalExpr(e)
+	if err != nil {
+		return 0, err
+	}
+	if v.Kind != ValNumber || v.Number != float64(int(v.Number)) {
+		return 0, i.runtimeErr(e.GetSpan(), "Slice bounds must be integers")
+	}
+	b := int(v.Number)
+	if b < 0 {
+		b += n
+	}
+	return max(0, min(b, n)), nil
+}
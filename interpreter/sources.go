@@ -0,0 +1,176 @@
+package interpreter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"bpl-plus/ast"
+)
+
+// sourceFile is a script or module: its name and lines, used for error carets
+// and as the anchor for relative imports, and its own top-level variables and
+// functions, so two modules defining the same name do not clobber each other.
+type sourceFile struct {
+	name    string
+	lines   []string
+	globals map[string]Value
+	funcs   map[string]*ast.FunctionDecl
+
+	// exports lists the names an importer may take, from the module's export
+	// statements. A module without any export statement exports everything.
+	exports   map[string]bool
+	exportAll bool
+
+	// data holds the values of the file's data statements for read.
+	data *dataList
+}
+
+// trackModuleFuncs records which module every function in prog (including
+// methods, lambdas and nested functions) was defined in, so a call made from
+// another file still reports errors, resolves imports and sees globals
+// against its own file.
+func (i *Interpreter) trackModuleFuncs(prog []ast.Stmt, src *sourceFile) {
+	if i.fnSources == nil {
+		i.fnSources = map[*ast.FunctionDecl]*sourceFile{}
+	}
+	for _, s := range prog {
+		ast.Inspect(s, func(n ast.Node) bool {
+			if fn, ok := n.(*ast.FunctionDecl); ok {
+				i.fnSources[fn] = src
+			}
+			return true
+		})
+	}
+}
+
+// enterSource switches the active file to the one fn was defined in and
+// returns the previous one for the caller to restore. Functions that were not
+// loaded from a module belong to the main script.
+func (i *Interpreter) enterSource(fn *ast.FunctionDecl) sourceFile {
+	src, ok := i.fnSources[fn]
+	if !ok {
+		src = &i.mainSource
+	}
+	return i.switchSource(src)
+}
+
+// addChunk records the text of a source that is not a file, such as a REPL
+// chunk, under its synthetic name. A chunk shares the main script's globals,
+// functions and data; only its name and lines are its own.
+func (i *Interpreter) addChunk(name, source string) *sourceFile {
+	if i.chunks == nil {
+		i.chunks = map[string]*sourceFile{}
+	}
+	chunk := i.mainSource
+	chunk.name, chunk.lines = name, splitLinesPreserve(source)
+	i.chunks[name] = &chunk
+	return &chunk
+}
+
+func (i *Interpreter) switchSource(src *sourceFile) sourceFile {
+	prev := sourceFile{name: i.filename, lines: i.lines, globals: i.globals, funcs: i.funcs, data: i.data}
+	i.restoreSource(*src)
+	return prev
+}
+
+func (i *Interpreter) restoreSource(src sourceFile) {
+	i.filename, i.lines = src.name, src.lines
+	i.globals, i.funcs = src.globals, src.funcs
+	i.data = src.data
+}
+
+// collectExports reads a module's top-level export statements.
+func collectExports(prog []ast.Stmt, src *sourceFile) {
+	src.exports = map[string]bool{}
+	for _, s := range prog {
+		if ex, ok := s.(*ast.ExportStmt); ok {
+			for _, name := range ex.Names {
+				src.exports[name] = true
+			}
+		}
+	}
+	src.exportAll = len(src.exports) == 0
+}
+
+// exportedNames lists everything src exports, sorted so imports are
+// deterministic.
+func (src *sourceFile) exportedNames() []string {
+	var names []string
+	if src.exportAll {
+		for name := range src.funcs {
+			names = append(names, name)
+		}
+		for name := range src.globals {
+			if _, ok := src.funcs[name]; !ok {
+				names = append(names, name)
+			}
+		}
+	} else {
+		for name := range src.exports {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// exportNamed finds the export an option classic file means by name, which
+// it reads in lower case: sayhi is the module's sayHi. An exact match wins.
+func (src *sourceFile) exportNamed(name string) (string, bool) {
+	var found []string
+	for _, ex := range src.exportedNames() {
+		if ex == name {
+			return ex, true
+		}
+		if strings.EqualFold(ex, name) {
+			found = append(found, ex)
+		}
+	}
+	return strings.Join(found, ", "), len(found) == 1
+}
+
+// importNames copies a loaded module's exports (or just the names listed in
+// `import a, b from "..."`) into the importing file. Functions are shared;
+// variables are copied, so arrays and maps stay shared with the module while
+// numbers and strings are a snapshot taken at import time. A plain import
+// never replaces a variable the importer already has; naming it does. An
+// option classic file gets every name in lower case.
+func (i *Interpreter) importNames(stmt *ast.ImportStmt, src *sourceFile) error {
+	names := stmt.Names
+	if len(names) == 0 {
+		names = src.exportedNames()
+	}
+	for _, local := range names {
+		name := local
+		if stmt.Classic {
+			local = strings.ToLower(local)
+			found, ok := src.exportNamed(local)
+			if !ok && found != "" {
+				return i.runtimeErr(stmt.GetSpan(), fmt.Sprintf("module %q exports more than one name that reads as %q in an option classic file: %s", stmt.Path, local, found))
+			}
+			if ok {
+				name = found
+			}
+		}
+		if !src.exportAll && !src.exports[name] {
+			return i.runtimeErr(stmt.GetSpan(), fmt.Sprintf("module %q does not export %q", stmt.Path, name))
+		}
+		if fn, ok := src.funcs[name]; ok {
+			if prev, ok := i.funcs[local]; ok && prev != fn && !fn.Override && !i.interactive {
+				i.warn(i.filename, stmt.S, fmt.Sprintf("import of %q replaces the function %q defined earlier", stmt.Path, local))
+			}
+			i.funcs[local] = fn
+			continue
+		}
+		v, ok := src.globals[name]
+		if !ok {
+			return i.runtimeErr(stmt.GetSpan(), fmt.Sprintf("module %q has no %q to import", stmt.Path, name))
+		}
+		if _, have := i.globals[local]; have && len(stmt.Names) == 0 {
+			continue
+		}
+		i.globals[local] = v
+	}
+	return nil
+}
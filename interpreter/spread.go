@@ -0,0 +1,50 @@
+package interpreter
+
+import (
+	"bpl-plus/ast"
+)
+
+// appendSpread appends the values of xs... to out: the elements of an array
+// the numbers of a range, or everything a generator yields.
+func (i *Interpreter) appendSpread(out []Value, e *ast.SpreadExpr) ([]Value, error) {
+	v, err := i.evalExpr(e.Value)
+	if err != nil {
+		return nil, err
+	}
+	switch v.Kind {
+	case ValArray:
+		return append(out, v.arrayElems()...), nil
+	case ValRange:
+		for idx, n := 0, v.Rng.Len(); idx < n; idx++ {
+			out = append(out, NumberValue(v.Rng.At(idx)))
+		}
+		return out, nil
+	case ValGenerator:
+		return i.drainGenerator(out, v.Gen, e.GetSpan())
+	}
+	return nil, i.runtimeErr(e.GetSpan(), "Only an array, range or generator can be spread with ..., got "+kindName(v.Kind))
+}
+
+// spreadMap copies the entries of the map in {base...} into m.
+func (i *Interpreter) spreadMap(m map[string]Value, e ast.Expr) error {
+	v, err := i.evalExpr(e)
+	if err != nil {
+		return err
+	}
+	if v.Kind != ValMap {
+		return i.runtimeErr(e.GetSpan(), "Only a map can be spread into a map literal, got "+kindName(v.Kind))
+	}
+	for k, el := range v.mapElems() {
+		m[k] = el
+	}
+	return nil
+}
+
+func hasSpread(exprs []ast.Expr) bool {
+	for _, e := range exprs {
+		if _, ok := e.(*ast.SpreadExpr); ok {
+			return true
+		}
+	}
+	return false
+}
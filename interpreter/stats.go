@@ -0,0 +1,81 @@
+package interpreter
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// ---------- Resource usage ----------
+// Counters are always kept (they are cheap); --stats and stats() just read them.
+
+type runStats struct {
+	start        time.Time
+	startMallocs uint64
+	startBytes   uint64
+
+	statements  int64
+	peakArray   int
+	peakMap     int
+	filesOpened int
+}
+
+func newRunStats() runStats {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return runStats{start: time.Now(), startMallocs: ms.Mallocs, startBytes: ms.TotalAlloc}
+}
+
+// noteSize records v's length if it is the biggest array/map seen so far.
+func (i *Interpreter) noteSize(v Value) {
+	switch v.Kind {
+	case ValArray:
+		i.stats.peakArray = max(i.stats.peakArray, len(v.arrayElems()))
+	case ValMap:
+		i.stats.peakMap = max(i.stats.peakMap, len(v.mapElems()))
+	}
+}
+
+type statEntry struct {
+	key   string
+	label string
+	value float64
+}
+
+func (i *Interpreter) statEntries() []statEntry {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return []statEntry{
+		{"elapsed", "execution time (s)", time.Since(i.stats.start).Seconds()},
+		{"statements", "statements executed", float64(i.stats.statements)},
+		{"peakarray", "peak array size", float64(i.stats.peakArray)},
+		{"peakmap", "peak map size", float64(i.stats.peakMap)},
+		{"filesopened", "files opened", float64(i.stats.filesOpened)},
+		{"allocations", "allocations", float64(ms.Mallocs - i.stats.startMallocs)},
+		{"allocbytes", "bytes allocated", float64(ms.TotalAlloc - i.stats.startBytes)},
+	}
+}
+
+// StatsMap returns the current counters (the value of stats()).
+func (i *Interpreter) StatsMap() Value {
+	m := map[string]Value{}
+	for _, e := range i.statEntries() {
+		m[e.key] = NumberValue(e.value)
+	}
+	return MapValue(m)
+}
+
+// StatsReport renders the counters as the table printed by --stats.
+func (i *Interpreter) StatsReport() string {
+	var b strings.Builder
+	b.WriteString("--- stats ---\n")
+	for _, e := range i.statEntries() {
+		val := NumberValue(e.value).ToString()
+		if e.key == "elapsed" {
+			val = fmt.Sprintf("%.3f", e.value)
+		}
+		b.WriteString(fmt.Sprintf("%-22s %s\n", e.label, val))
+	}
+	return b.String()
+}
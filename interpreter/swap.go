@@ -0,0 +1,91 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"bpl-plus/ast"
+	"bpl-plus/codes"
+)
+
+// place is one side of a swap with its container and index already
+// evaluated, so a[f()] calls f only once.
+type place struct {
+	target    ast.Expr // *ast.Identifier, *ast.IndexExpr or *ast.MemberExpr
+	container Value
+	index     Value
+}
+
+// execSwap exchanges the values of two variables, elements or fields. Both
+// sides are evaluated (left first) and read before either is written.
+func (i *Interpreter) execSwap(stmt *ast.SwapStmt) error {
+	a, err := i.evalPlace(stmt.Left)
+	if err != nil {
+		return err
+	}
+	b, err := i.evalPlace(stmt.Right)
+	if err != nil {
+		return err
+	}
+	av, err := i.readPlace(a)
+	if err != nil {
+		return err
+	}
+	bv, err := i.readPlace(b)
+	if err != nil {
+		return err
+	}
+	if err := i.writePlace(a, bv); err != nil {
+		return err
+	}
+	return i.writePlace(b, av)
+}
+
+func (i *Interpreter) evalPlace(target ast.Expr) (place, error) {
+	p := place{target: target}
+	var err error
+	switch t := target.(type) {
+	case *ast.IndexExpr:
+		if p.container, err = i.evalExpr(t.Left); err != nil {
+			return p, err
+		}
+		p.index, err = i.evalExpr(t.Index)
+	case *ast.MemberExpr:
+		p.container, err = i.evalExpr(t.Left)
+	}
+	return p, err
+}
+
+func (i *Interpreter) readPlace(p place) (Value, error) {
+	switch t := p.target.(type) {
+	case *ast.IndexExpr:
+		return i.indexValue(p.container, p.index, t, false)
+	case *ast.MemberExpr:
+		idx, err := i.fieldOf(p.container, t.Name, t.Classic, t.GetSpan())
+		if err != nil {
+			return Value{}, err
+		}
+		return p.container.Rec.Fields[idx], nil
+	}
+	id := p.target.(*ast.Identifier)
+	if v, ok := i.lookupVar(id.Name); ok {
+		return v, nil
+	}
+	return Value{}, i.codedErr(id.GetSpan(), codes.UndefinedVariable, fmt.Sprintf("Undefined variable %q", id.Name))
+}
+
+func (i *Interpreter) writePlace(p place, v Value) error {
+	switch t := p.target.(type) {
+	case *ast.IndexExpr:
+		return i.setIndex(p.container, p.index, v, t.GetSpan(), t.Index.GetSpan())
+	case *ast.MemberExpr:
+		idx, err := i.fieldOf(p.container, t.Name, t.Classic, t.GetSpan())
+		if err != nil {
+			return err
+		}
+		p.container.Rec.Fields[idx] = v
+		return nil
+	case *ast.Identifier:
+		i.setVar(t.Name, v)
+	}
+	return nil
+}
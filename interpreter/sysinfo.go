@@ -0,0 +1,104 @@
+package interpreter
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// platformInfo describes the machine for platform().
+func platformInfo() Value {
+	return MapValue(map[string]Value{
+		"os":      StringValue(runtime.GOOS),
+		"arch":    StringValue(runtime.GOARCH),
+		"sep":     StringValue(string(filepath.Separator)),
+		"listsep": StringValue(string(filepath.ListSeparator)),
+	})
+}
+
+// freeMemory returns the memory available to new programs in bytes, using
+// whatever the OS offers. ok is false when it cannot be found out.
+func freeMemory() (bytes int64, ok bool) {
+	switch runtime.GOOS {
+	case "linux":
+		return meminfoAvailable("/proc/meminfo")
+	case "darwin":
+		out, err := exec.Command("vm_stat").Output()
+		if err != nil {
+			return 0, false
+		}
+		return vmStatFree(string(out))
+	case "windows":
+		out, err := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command",
+			"(Get-CimInstance Win32_OperatingSystem).FreePhysicalMemory").Output()
+		if err != nil {
+			return 0, false
+		}
+		kb, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		return kb * 1024, true
+	}
+	return 0, false
+}
+
+// meminfoAvailable reads MemAvailable (or MemFree on old kernels) in bytes.
+func meminfoAvailable(path string) (int64, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+
+	fields := map[string]int64{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		name, rest, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		kb, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimSpace(rest), " kB"), 10, 64)
+		if err == nil {
+			fields[name] = kb * 1024
+		}
+	}
+	if v, ok := fields["MemAvailable"]; ok {
+		return v, true
+	}
+	v, ok := fields["MemFree"]
+	return v, ok
+}
+
+var vmStatPageSize = regexp.MustCompile(`page size of (\d+) bytes`)
+
+// vmStatFree counts free, inactive and speculative pages from macOS vm_stat.
+func vmStatFree(out string) (int64, bool) {
+	m := vmStatPageSize.FindStringSubmatch(out)
+	if m == nil {
+		return 0, false
+	}
+	pageSize, _ := strconv.ParseInt(m[1], 10, 64)
+	var pages int64
+	found := false
+	for _, line := range strings.Split(out, "\n") {
+		name, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch name {
+		case "Pages free", "Pages inactive", "Pages speculative":
+			n, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimSpace(rest), "."), 10, 64)
+			if err == nil {
+				pages += n
+				found = true
+			}
+		}
+	}
+	return pages * pageSize, found
+}
@@ -0,0 +1,156 @@
+package interpreter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ---------- Table rendering (printtable) ----------
+
+// tableBorders holds the characters of one table style. Corners and joins are
+// listed top, middle (under the header) and bottom, each as left/mid/right.
+type tableBorders struct {
+	h, v                string
+	top, middle, bottom [3]string
+}
+
+var tableStyles = map[string]tableBorders{
+	"ascii": {
+		h: "-", v: "|",
+		top:    [3]string{"+", "+", "+"},
+		middle: [3]string{"+", "+", "+"},
+		bottom: [3]string{"+", "+", "+"},
+	},
+	"unicode": {
+		h: "─", v: "│",
+		top:    [3]string{"┌", "┬", "┐"},
+		middle: [3]string{"├", "┼", "┤"},
+		bottom: [3]string{"└", "┴", "┘"},
+	},
+}
+
+type tableCell struct {
+	text    string
+	numeric bool
+}
+
+// tableRows turns printtable() input into header texts and cell rows. Rows
+// are arrays (cells by position) or maps (cells by header; without headers the
+// sorted union of keys is used).
+func tableRows(rows []Value, headers []string) ([]string, [][]tableCell, error) {
+	byMap := len(rows) > 0 && rows[0].Kind == ValMap
+	for _, r := range rows {
+		if byMap && r.Kind != ValMap || !byMap && r.Kind != ValArray {
+			return nil, nil, fmt.Errorf("rows must all be arrays or all be maps")
+		}
+	}
+
+	if byMap && len(headers) == 0 {
+		seen := map[string]bool{}
+		for _, r := range rows {
+			for k := range r.mapElems() {
+				if !seen[k] {
+					seen[k] = true
+					headers = append(headers, k)
+				}
+			}
+		}
+		sort.Strings(headers)
+	}
+
+	out := make([][]tableCell, len(rows))
+	for idx, r := range rows {
+		var vals []Value
+		if byMap {
+			m := r.mapElems()
+			for _, h := range headers {
+				v, ok := m[h]
+				if !ok {
+					v = StringValue("")
+				}
+				vals = append(vals, v)
+			}
+		} else {
+			vals = r.arrayElems()
+		}
+		cells := make([]tableCell, len(vals))
+		for c, v := range vals {
+			text := strings.ReplaceAll(v.ToString(), "\n", " ")
+			cells[c] = tableCell{text: text, numeric: v.Kind == ValNumber || v.Kind == ValDecimal}
+		}
+		out[idx] = cells
+	}
+	return headers, out, nil
+}
+
+// renderTable draws rows as an aligned table. Numbers are right-aligned and
+// everything else left-aligned; short rows are padded with empty cells.
+func renderTable(headers []string, rows [][]tableCell, style tableBorders) string {
+	cols := len(headers)
+	for _, r := range rows {
+		cols = max(cols, len(r))
+	}
+	if cols == 0 {
+		return ""
+	}
+
+	widths := make([]int, cols)
+	for c, h := range headers {
+		widths[c] = textWidth(h)
+	}
+	for _, r := range rows {
+		for c, cell := range r {
+			widths[c] = max(widths[c], textWidth(cell.text))
+		}
+	}
+
+	var b strings.Builder
+	rule := func(j [3]string) {
+		b.WriteString(j[0])
+		for c, w := range widths {
+			if c > 0 {
+				b.WriteString(j[1])
+			}
+			b.WriteString(strings.Repeat(style.h, w+2))
+		}
+		b.WriteString(j[2] + "\n")
+	}
+	line := func(cells []tableCell) {
+		for c, w := range widths {
+			var cell tableCell
+			if c < len(cells) {
+				cell = cells[c]
+			}
+			pad := strings.Repeat(" ", w-textWidth(cell.text))
+			b.WriteString(style.v + " ")
+			if cell.numeric {
+				b.WriteString(pad + cell.text)
+			} else {
+				b.WriteString(cell.text + pad)
+			}
+			b.WriteString(" ")
+		}
+		b.WriteString(style.v + "\n")
+	}
+
+	rule(style.top)
+	if len(headers) > 0 {
+		hs := make([]tableCell, len(headers))
+		for c, h := range headers {
+			hs[c] = tableCell{text: h}
+		}
+		line(hs)
+		rule(style.middle)
+	}
+	for _, r := range rows {
+		line(r)
+	}
+	rule(style.bottom)
+	return b.String()
+}
+
+// textWidth counts user-perceived characters, so accents and emoji line up.
+func textWidth(s string) int {
+	return len(graphemes(s))
+}
@@ -0,0 +1,84 @@
+package interpreter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ---------- Test support (bplplus test) ----------
+
+// Call runs the named user function with the given args.
+func (i *Interpreter) Call(name string, args ...Value) (Value, error) {
+	fn, ok := i.funcs[name]
+	if !ok {
+		return Value{}, fmt.Errorf("undefined function %q", name)
+	}
+	return i.callFunction(fn, args, fn.GetSpan())
+}
+
+// SetGoldenUpdate makes assertgolden() rewrite golden files instead of
+// comparing against them (bplplus test -update).
+func (i *Interpreter) SetGoldenUpdate(update bool) {
+	i.goldenUpdate = update
+}
+
+// goldenPath maps a golden name to testdata/<name>.golden next to the script.
+func (i *Interpreter) goldenPath(name string) (string, error) {
+	if name == "" || strings.Contains(name, "..") || filepath.IsAbs(name) {
+		return "", fmt.Errorf("invalid golden name %q", name)
+	}
+	base := "."
+	if i.filename != "" {
+		base = filepath.Dir(i.filename)
+	}
+	return filepath.Join(base, "testdata", name+".golden"), nil
+}
+
+// checkGolden compares actual with the golden file (or rewrites it when
+// updating). The error describes the first differing line.
+func (i *Interpreter) checkGolden(name, actual string) error {
+	path, err := i.goldenPath(name)
+	if err != nil {
+		return err
+	}
+	if i.goldenUpdate {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(path, []byte(actual), 0644)
+	}
+
+	want, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("golden file %s does not exist (run with -update to create it)", path)
+	}
+	if err != nil {
+		return err
+	}
+	if string(want) == actual {
+		return nil
+	}
+
+	wantLines := strings.Split(string(want), "\n")
+	gotLines := strings.Split(actual, "\n")
+	for n := 0; n < max(len(wantLines), len(gotLines)); n++ {
+		w, g := "<missing>", "<missing>"
+		if n < len(wantLines) {
+			w = wantLines[n]
+		}
+		if n < len(gotLines) {
+			g = gotLines[n]
+		}
+		if w != g {
+			return fmt.Errorf("output differs from %s at line %d:\n  want: %q\n  got:  %q", path, n+1, w, g)
+		}
+	}
+	return fmt.Errorf("output differs from %s", path)
+}
+
+// ResetMocks undoes every mockbuiltin() so each test starts with the real builtins.
+func (i *Interpreter) ResetMocks() {
+	i.mocks = nil
+}
@@ -0,0 +1,65 @@
+package interpreter
+
+import (
+	"bpl-plus/ast"
+)
+
+// ---------- try / catch / finally and raise ----------
+
+// execTry runs the body; a runtime error (including one from raise) runs the
+// catch block with the error value bound to the catch variable. The finally
+// block always runs, even on return, break or exit, and an error or signal
+// from finally replaces whatever was in flight.
+func (i *Interpreter) execTry(stmt *ast.TryStmt) error {
+	err := i.runBlock(stmt.Body)
+	if rerr, ok := err.(RuntimeError); ok && stmt.HasCatch {
+		if stmt.CatchVar != "" {
+			i.setVar(stmt.CatchVar, errorValue(rerr))
+		}
+		err = i.runBlock(stmt.Catch)
+	}
+	if stmt.Finally != nil {
+		if ferr := i.runBlock(stmt.Finally); ferr != nil {
+			return ferr
+		}
+	}
+	return err
+}
+
+// execRaise raises a user error. A string becomes the message; a map with a
+// "message" key (such as a caught error) uses that key, so `raise e` rethrows.
+func (i *Interpreter) execRaise(stmt *ast.RaiseStmt) error {
+	val, err := i.evalExpr(stmt.Value)
+	if err != nil {
+		return err
+	}
+	msg := i.printString(val)
+	if val.Kind == ValMap {
+		if m, ok := val.mapElems()["message"]; ok {
+			msg = i.printString(m)
+		}
+	}
+	rerr := i.runtimeErr(stmt.GetSpan(), msg).(RuntimeError)
+	rerr.Raised = &val
+	return rerr
+}
+
+// errorValue is what a catch block sees: a map with the message, where the
+// error happened, the call stack, and for raise the raised value.
+func errorValue(e RuntimeError) Value {
+	stack := make([]Value, len(e.Stack))
+	for idx, fn := range e.Stack {
+		stack[idx] = StringValue(fn)
+	}
+	m := map[string]Value{
+		"message": StringValue(e.Msg),
+		"file":    StringValue(e.File),
+		"line":    NumberValue(float64(e.Span.Line)),
+		"col":     NumberValue(float64(e.Span.Col)),
+		"stack":   ArrayValue(stack),
+	}
+	if e.Raised != nil {
+		m["value"] = *e.Raised
+	}
+	return MapValue(m)
+}
@@ -0,0 +1,82 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// ---------- Unicode-aware text helpers ----------
+
+// foldCase returns the Unicode case folding of s, for caseless matching
+// ("Straße" and "STRASSE" both fold to "strasse").
+func foldCase(s string) string {
+	return cases.Fold().String(s)
+}
+
+var normForms = map[string]norm.Form{
+	"NFC":  norm.NFC,
+	"NFD":  norm.NFD,
+	"NFKC": norm.NFKC,
+	"NFKD": norm.NFKD,
+}
+
+func normalizeText(s, form string) (string, error) {
+	f, ok := normForms[strings.ToUpper(form)]
+	if !ok {
+		return "", fmt.Errorf("unknown form %q (use NFC, NFD, NFKC or NFKD)", form)
+	}
+	return f.String(s), nil
+}
+
+// graphemes splits s into user-perceived characters. It follows the main
+// rules of UAX #29: combining marks, variation selectors and emoji modifiers
+// stay with their base, zero-width-joiner sequences are kept together,
+// regional-indicator flags pair up, and CR LF is a single cluster.
+func graphemes(s string) []string {
+	rs := []rune(s)
+	var out []string
+	for start := 0; start < len(rs); {
+		end := clusterEnd(rs, start)
+		out = append(out, string(rs[start:end]))
+		start = end
+	}
+	return out
+}
+
+// clusterEnd returns the index just past the cluster starting at rs[start].
+func clusterEnd(rs []rune, start int) int {
+	end := start + 1
+	if rs[start] == '\r' && end < len(rs) && rs[end] == '\n' {
+		return end + 1
+	}
+	if isRegionalIndicator(rs[start]) && end < len(rs) && isRegionalIndicator(rs[end]) {
+		end++
+	}
+	for end < len(rs) {
+		switch {
+		case isGraphemeExtend(rs[end]):
+			end++
+		case rs[end] == '\u200D': // zero width joiner glues the next rune on
+			end = min(end+2, len(rs))
+		default:
+			return end
+		}
+	}
+	return end
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// isGraphemeExtend reports runes that never start a cluster of their own.
+func isGraphemeExtend(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		(r >= 0xFE00 && r <= 0xFE0F) || // variation selectors
+		(r >= 0x1F3FB && r <= 0x1F3FF) || // emoji skin tones
+		(r >= 0xE0020 && r <= 0xE007F) // emoji tag sequences
+}
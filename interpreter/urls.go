@@ -0,0 +1,128 @@
+package interpreter
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+)
+
+// ---------- URL helpers ----------
+
+// urlToMap splits u into a map with scheme, user, host, port, path, query
+// (a map of params; repeated params become arrays) and fragment.
+func urlToMap(raw string) (Value, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return Value{}, fmt.Errorf("invalid URL %q", raw)
+	}
+
+	query := map[string]Value{}
+	for k, vs := range u.Query() {
+		if len(vs) == 1 {
+			query[k] = StringValue(vs[0])
+			continue
+		}
+		elems := make([]Value, len(vs))
+		for idx, s := range vs {
+			elems[idx] = StringValue(s)
+		}
+		query[k] = ArrayValue(elems)
+	}
+
+	user := ""
+	if u.User != nil {
+		user = u.User.Username()
+	}
+
+	return MapValue(map[string]Value{
+		"scheme":   StringValue(u.Scheme),
+		"user":     StringValue(user),
+		"host":     StringValue(u.Hostname()),
+		"port":     StringValue(u.Port()),
+		"path":     StringValue(u.Path),
+		"query":    MapValue(query),
+		"fragment": StringValue(u.Fragment),
+	}), nil
+}
+
+// mapToURL is the inverse of urlToMap; missing keys are treated as empty.
+func mapToURL(m map[string]Value) (string, error) {
+	str := func(key string) (string, error) {
+		v, ok := m[key]
+		if !ok || v.Kind == ValNull {
+			return "", nil
+		}
+		if v.Kind != ValString && v.Kind != ValNumber {
+			return "", fmt.Errorf("%q must be a string", key)
+		}
+		return v.ToString(), nil
+	}
+
+	u := &url.URL{}
+	var err error
+	if u.Scheme, err = str("scheme"); err != nil {
+		return "", err
+	}
+	host, err := str("host")
+	if err != nil {
+		return "", err
+	}
+	port, err := str("port")
+	if err != nil {
+		return "", err
+	}
+	if port != "" {
+		host = net.JoinHostPort(host, port)
+	}
+	u.Host = host
+	user, err := str("user")
+	if err != nil {
+		return "", err
+	}
+	if user != "" {
+		u.User = url.User(user)
+	}
+	if u.Path, err = str("path"); err != nil {
+		return "", err
+	}
+	if u.Fragment, err = str("fragment"); err != nil {
+		return "", err
+	}
+
+	if q, ok := m["query"]; ok && q.Kind != ValNull {
+		if q.Kind != ValMap {
+			return "", fmt.Errorf(`"query" must be a map`)
+		}
+		enc, err := encodeQuery(q.mapElems())
+		if err != nil {
+			return "", err
+		}
+		u.RawQuery = enc
+	}
+	return u.String(), nil
+}
+
+// encodeQuery builds a sorted query string; array values repeat the key.
+func encodeQuery(m map[string]Value) (string, error) {
+	vals := url.Values{}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := m[k]
+		switch v.Kind {
+		case ValArray:
+			for _, el := range v.arrayElems() {
+				vals.Add(k, el.ToString())
+			}
+		case ValMap:
+			return "", fmt.Errorf("query param %q cannot be a map", k)
+		default:
+			vals.Add(k, v.ToString())
+		}
+	}
+	return vals.Encode(), nil
+}
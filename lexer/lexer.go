@@ -3,6 +3,7 @@ package lexer
 import (
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 type Lexer struct {
@@ -12,20 +13,63 @@ type Lexer struct {
 
 	line int
 	col  int
+
+	// Text of comments that sit on a line by themselves, keyed by line number.
+	comments map[int]string
+
+	// Raw bytes that were not valid UTF-8, keyed by their position in input.
+	invalid map[int]byte
+
+	// Classic BASIC compatibility: REM starts a comment.
+	classic bool
 }
 
 func New(input string) *Lexer {
+	// Windows editors often save files with a UTF-8 byte order mark.
+	input = strings.TrimPrefix(input, "\uFEFF")
+	runes, invalid := decodeUTF8(input)
 	l := &Lexer{
-		input: []rune(input),
-		pos:   -1,
-		ch:    0,
-		line:  1,
-		col:   0,
+		input:    runes,
+		pos:      -1,
+		ch:       0,
+		line:     1,
+		col:      0,
+		comments: map[int]string{},
+		invalid:  invalid,
 	}
 	l.readChar()
 	return l
 }
 
+// decodeUTF8 splits input into runes, remembering each byte that is not valid
+// UTF-8 so it can be reported as an ILLEGAL token at its own position.
+func decodeUTF8(input string) ([]rune, map[int]byte) {
+	runes := make([]rune, 0, len(input))
+	invalid := map[int]byte{}
+	for i := 0; i < len(input); {
+		r, size := utf8.DecodeRuneInString(input[i:])
+		if r == utf8.RuneError && size == 1 {
+			invalid[len(runes)] = input[i]
+		}
+		runes = append(runes, r)
+		i += size
+	}
+	return runes, invalid
+}
+
+// SetClassic turns on classic BASIC compatibility: REM (any case) starts a
+// comment that runs to the end of the line. It applies from the next token
+// read, so option classic can turn it on part way through a file.
+func (l *Lexer) SetClassic(on bool) {
+	l.classic = on
+}
+
+// atInvalid reports whether the current character came from invalid UTF-8.
+func (l *Lexer) atInvalid() bool {
+	_, bad := l.invalid[l.pos]
+	return bad
+}
+
 func (l *Lexer) readChar() {
 	l.pos++
 	if l.pos >= len(l.input) {
@@ -42,10 +86,49 @@ func (l *Lexer) readChar() {
 }
 
 func (l *Lexer) peekChar() rune {
-	if l.pos+1 >= len(l.input) {
+	return l.peekCharAt(1)
+}
+
+// peekCharAt returns the character n positions ahead without consuming it.
+func (l *Lexer) peekCharAt(n int) rune {
+	if l.pos+n >= len(l.input) {
 		return 0
 	}
-	return l.input[l.pos+1]
+	return l.input[l.pos+n]
+}
+
+// onlyWhitespaceBefore reports whether pos is the first non-blank character on its line.
+func (l *Lexer) onlyWhitespaceBefore(pos int) bool {
+	for k := pos - 1; k >= 0 && l.input[k] != '\n'; k-- {
+		if l.input[k] != ' ' && l.input[k] != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+// CommentBlockAbove returns the run of full-line comments directly above line
+// (without the leading '#' and one space), or "" if there is none.
+func (l *Lexer) CommentBlockAbove(line int) string {
+	var block []string
+	for k := line - 1; ; k-- {
+		text, ok := l.comments[k]
+		if !ok {
+			break
+		}
+		block = append([]string{strings.TrimPrefix(text, " ")}, block...)
+	}
+	return strings.Join(block, "\n")
+}
+
+// SourceLine returns the text of the given 1-based line, or "" if the input
+// has no such line.
+func (l *Lexer) SourceLine(line int) string {
+	lines := strings.Split(string(l.input), "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return strings.TrimRight(lines[line-1], "\r")
 }
 
 func (l *Lexer) NextToken() Token {
@@ -56,6 +139,14 @@ func (l *Lexer) NextToken() Token {
 
 	tok := Token{Line: l.line, Col: l.col}
 
+	if l.atInvalid() {
+		// Lexeme keeps the raw byte so the parser can name it in its error.
+		tok.Type = ILLEGAL
+		tok.Lexeme = string([]byte{l.invalid[l.pos]})
+		l.readChar()
+		return tok
+	}
+
 	switch l.ch {
 	case 0:
 		tok.Type = EOF
@@ -80,9 +171,13 @@ func (l *Lexer) NextToken() Token {
 		}
 
 		// comment: consume until newline or EOF
-		for l.ch != 0 && l.ch != '\n' {
+		start := l.pos
+		for l.ch != 0 && l.ch != '\n' && !l.atInvalid() {
 			l.readChar()
 		}
+		if l.onlyWhitespaceBefore(start) {
+			l.comments[tok.Line] = string(l.input[start+1 : l.pos])
+		}
 		// do not consume newline here; let it be tokenized next call
 		return l.NextToken()
 
@@ -120,6 +215,14 @@ func (l *Lexer) NextToken() Token {
 			l.readChar()
 			return tok
 		}
+		if l.peekChar() == '>' {
+			// classic BASIC's not-equal
+			tok.Type = NEQ
+			tok.Lexeme = "<>"
+			l.readChar()
+			l.readChar()
+			return tok
+		}
 		tok.Type = LT
 		tok.Lexeme = "<"
 		l.readChar()
@@ -162,6 +265,12 @@ func (l *Lexer) NextToken() Token {
 		l.readChar()
 		return tok
 
+	case '%':
+		tok.Type = PERCENT
+		tok.Lexeme = "%"
+		l.readChar()
+		return tok
+
 	case '(':
 		tok.Type = LPAREN
 		tok.Lexeme = "("
@@ -210,7 +319,58 @@ func (l *Lexer) NextToken() Token {
 		l.readChar()
 		return tok
 
+	case ';':
+		tok.Type = SEMICOLON
+		tok.Lexeme = ";"
+		l.readChar()
+		return tok
+
+	case '?':
+		if l.peekChar() == '?' {
+			tok.Type = COALESCE
+			tok.Lexeme = "??"
+			l.readChar()
+			l.readChar()
+			return tok
+		}
+		tok.Type = QUESTION
+		tok.Lexeme = "?"
+		l.readChar()
+		return tok
+
+	case '.':
+		if l.peekChar() == '.' && l.peekCharAt(2) == '.' {
+			tok.Type = ELLIPSIS
+			tok.Lexeme = "..."
+			l.readChar()
+			l.readChar()
+			l.readChar()
+			return tok
+		}
+		if l.peekChar() == '.' {
+			tok.Type = RANGE
+			tok.Lexeme = ".."
+			l.readChar()
+			l.readChar()
+			return tok
+		}
+		tok.Type = DOT
+		tok.Lexeme = "."
+		l.readChar()
+		return tok
+
 	case '"':
+		if l.peekChar() == '"' && l.peekCharAt(2) == '"' {
+			text, ok := l.readHeredoc()
+			if !ok {
+				tok.Type = ILLEGAL
+				tok.Lexeme = `"""`
+				return tok
+			}
+			tok.Type = STRING
+			tok.Lexeme = text
+			return tok
+		}
 		tok.Type = STRING
 		tok.Lexeme = l.readString()
 		return tok
@@ -218,6 +378,12 @@ func (l *Lexer) NextToken() Token {
 	default:
 		if isLetter(l.ch) {
 			lit := l.readIdent()
+			if l.classic && strings.EqualFold(lit, "rem") {
+				for l.ch != 0 && l.ch != '\n' && !l.atInvalid() {
+					l.readChar()
+				}
+				return l.NextToken()
+			}
 			tok.Type = LookupIdent(lit)
 			tok.Lexeme = lit
 			return tok
@@ -255,7 +421,8 @@ func (l *Lexer) readIdent() string {
 func (l *Lexer) readNumber() string {
 	start := l.pos
 	dotSeen := false
-	for isDigit(l.ch) || (!dotSeen && l.ch == '.') {
+	// the '.' of 1..5 starts a range, not a fraction
+	for isDigit(l.ch) || (!dotSeen && l.ch == '.' && l.peekChar() != '.') {
 		if l.ch == '.' {
 			dotSeen = true
 		}
@@ -269,35 +436,11 @@ func (l *Lexer) readString() string {
 	l.readChar()
 
 	var b strings.Builder
-	for l.ch != 0 && l.ch != '"' {
+	// Stop at invalid UTF-8; the next token is then ILLEGAL at that byte.
+	for l.ch != 0 && l.ch != '"' && !l.atInvalid() {
 		if l.ch == '\\' {
-			switch l.peekChar() {
-			case 'n':
-				b.WriteRune('\n')
-				l.readChar()
-				l.readChar()
-				continue
-			case 't':
-				b.WriteRune('\t')
-				l.readChar()
-				l.readChar()
-				continue
-			case '"':
-				b.WriteRune('"')
-				l.readChar()
-				l.readChar()
-				continue
-			case '\\':
-				b.WriteRune('\\')
-				l.readChar()
-				l.readChar()
-				continue
-			default:
-				// unknown escape; keep the slash literally
-				b.WriteRune('\\')
-				l.readChar()
-				continue
-			}
+			l.readEscape(&b)
+			continue
 		}
 		b.WriteRune(l.ch)
 		l.readChar()
@@ -310,3 +453,114 @@ func (l *Lexer) readString() string {
 
 	return b.String()
 }
+
+// readEscape handles a backslash sequence (current char is '\\').
+func (l *Lexer) readEscape(b *strings.Builder) {
+	r, ok := escapeChar(l.peekChar())
+	if !ok {
+		// unknown escape; keep the slash literally
+		b.WriteRune('\\')
+		l.readChar()
+		return
+	}
+	b.WriteRune(r)
+	l.readChar()
+	l.readChar()
+}
+
+// escapeChar maps the character after a backslash to what it stands for.
+func escapeChar(c rune) (rune, bool) {
+	switch c {
+	case 'n':
+		return '\n', true
+	case 't':
+		return '\t', true
+	case '"':
+		return '"', true
+	case '\\':
+		return '\\', true
+	}
+	return 0, false
+}
+
+// readHeredoc reads a """ ... """ string (current char is the first '"').
+// Newlines are kept and escapes work as in normal strings. A newline right
+// after the opening quotes is dropped, and when the closing quotes sit on
+// their own line, their indentation is removed from every line:
+//
+//	html = """
+//	    <p>hi</p>
+//	    """          -> "<p>hi</p>\n"
+//
+// ok is false if the closing """ is missing.
+func (l *Lexer) readHeredoc() (text string, ok bool) {
+	l.readChar()
+	l.readChar()
+	l.readChar()
+
+	start := l.pos
+	for {
+		if l.ch == 0 || l.atInvalid() {
+			return "", false
+		}
+		if l.ch == '"' && l.peekChar() == '"' && l.peekCharAt(2) == '"' {
+			break
+		}
+		if l.ch == '\\' && l.peekChar() != 0 {
+			l.readChar() // so \" does not start the closing quotes
+		}
+		l.readChar()
+	}
+	raw := string(l.input[start:l.pos])
+	l.readChar()
+	l.readChar()
+	l.readChar()
+
+	// Dedent before unescaping so an escaped \n or \t is never taken as layout.
+	return unescape(dedentHeredoc(raw)), true
+}
+
+func unescape(s string) string {
+	var b strings.Builder
+	rs := []rune(s)
+	for k := 0; k < len(rs); k++ {
+		if rs[k] == '\\' && k+1 < len(rs) {
+			if r, ok := escapeChar(rs[k+1]); ok {
+				b.WriteRune(r)
+				k++
+				continue
+			}
+		}
+		b.WriteRune(rs[k])
+	}
+	return b.String()
+}
+
+func dedentHeredoc(s string) string {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "\r"), "\n")
+
+	nl := strings.LastIndex(s, "\n")
+	if nl < 0 {
+		return s
+	}
+	indent := s[nl+1:]
+	if strings.Trim(indent, " \t") != "" {
+		// closing quotes follow text on the same line: keep everything as is
+		return s
+	}
+
+	lines := strings.Split(s[:nl+1], "\n")
+	for k, line := range lines {
+		lines[k] = trimIndent(line, indent)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// trimIndent removes as much of indent as line starts with.
+func trimIndent(line, indent string) string {
+	n := 0
+	for n < len(line) && n < len(indent) && line[n] == indent[n] {
+		n++
+	}
+	return line[n:]
+}
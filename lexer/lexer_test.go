@@ -0,0 +1,189 @@
+package lexer
+
+import (
+	"strings"
+	"testing"
+)
+
+// tokens lexes src up to and including EOF.
+func tokens(src string) []Token {
+	l := New(src)
+	var out []Token
+	for {
+		tok := l.NextToken()
+		out = append(out, tok)
+		if tok.Type == EOF {
+			return out
+		}
+	}
+}
+
+func TestBOMIsSkipped(t *testing.T) {
+	tests := []struct {
+		name      string
+		src       string
+		want      []TokenType
+		line, col int // where the first word is; the BOM takes no column
+	}{
+		{"no BOM", "print 1", []TokenType{PRINT, NUMBER, EOF}, 1, 1},
+		{"BOM", "\uFEFFprint 1", []TokenType{PRINT, NUMBER, EOF}, 1, 1},
+		{"BOM then newline", "\uFEFF\nx = 1", []TokenType{NEWLINE, IDENT, ASSIGN, NUMBER, EOF}, 2, 1},
+		{"BOM only", "\uFEFF", []TokenType{EOF}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tokens(tt.src)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d tokens %v, want %v", len(got), got, tt.want)
+			}
+			for idx, tok := range got {
+				if tok.Type != tt.want[idx] {
+					t.Errorf("token %d is %s, want %s", idx, tok.Type, tt.want[idx])
+				}
+			}
+			for _, tok := range got {
+				if tok.Type == NEWLINE || tok.Type == EOF {
+					continue
+				}
+				if tok.Line != tt.line || tok.Col != tt.col {
+					t.Errorf("first word at %d:%d, want %d:%d", tok.Line, tok.Col, tt.line, tt.col)
+				}
+				break
+			}
+		})
+	}
+}
+
+func TestInvalidUTF8Position(t *testing.T) {
+	tests := []struct {
+		name      string
+		src       string
+		line, col int
+		lexeme    string
+	}{
+		{"after an assignment", "x = \xff", 1, 5, "\xff"},
+		{"start of a later line", "print 1\n\xfe", 2, 1, "\xfe"},
+		{"indented", "print 1\n  \xc0", 2, 3, "\xc0"},
+		{"after a multi-byte character", "s = \"é\"\xff", 1, 8, "\xff"},
+		{"inside a name", "ab\xffcd = 1", 1, 3, "\xff"},
+		{"after a BOM", "\uFEFF\xff", 1, 1, "\xff"},
+		{"truncated sequence", "x = \xe2\x82", 1, 5, "\xe2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, tok := range tokens(tt.src) {
+				if tok.Type != ILLEGAL {
+					continue
+				}
+				if tok.Line != tt.line || tok.Col != tt.col {
+					t.Errorf("ILLEGAL at %d:%d, want %d:%d", tok.Line, tok.Col, tt.line, tt.col)
+				}
+				if tok.Lexeme != tt.lexeme {
+					t.Errorf("ILLEGAL lexeme %q, want %q", tok.Lexeme, tt.lexeme)
+				}
+				return
+			}
+			t.Fatalf("no ILLEGAL token in %q", tt.src)
+		})
+	}
+}
+
+// Contextual keywords lex as their keyword in any case, and IsContextual
+// tells the parser it may take them as a name (`step = step + 1`) wherever
+// the keyword does not fit.
+func TestContextualKeywords(t *testing.T) {
+	tests := []struct {
+		word string
+		want TokenType
+	}{
+		{"to", TO},
+		{"step", STEP},
+		{"each", EACH},
+		{"in", IN},
+		{"mod", PERCENT},
+		{"repeat", REPEAT},
+		{"do", DO},
+		{"until", UNTIL},
+		{"try", TRY},
+		{"catch", CATCH},
+		{"finally", FINALLY},
+		{"raise", RAISE},
+		{"override", OVERRIDE},
+		{"type", TYPE},
+		{"global", GLOBAL},
+		{"let", LET},
+		{"export", EXPORT},
+		{"goto", GOTO},
+		{"gosub", GOSUB},
+		{"data", DATA},
+		{"read", READ},
+		{"restore", RESTORE},
+		{"swap", SWAP},
+		{"match", MATCH},
+		{"case", CASE},
+		{"yield", YIELD},
+	}
+	covered := map[TokenType]bool{}
+	for _, tt := range tests {
+		covered[tt.want] = true
+		t.Run(tt.word, func(t *testing.T) {
+			if got := LookupIdent(strings.ToUpper(tt.word)); got != tt.want {
+				t.Errorf("LookupIdent(%q) = %s, want %s", strings.ToUpper(tt.word), got, tt.want)
+			}
+			// as a keyword, then as a name on both sides of an assignment
+			for _, src := range []string{tt.word, tt.word + " = " + tt.word + " + 1"} {
+				toks := tokens(src)
+				for _, idx := range []int{0, 2} {
+					if idx >= len(toks)-1 {
+						continue
+					}
+					if tok := toks[idx]; tok.Type != tt.want || !IsContextual(tok) {
+						t.Errorf("%q: token %d is %s (contextual %v), want contextual %s", src, idx, tok.Type, IsContextual(tok), tt.want)
+					}
+				}
+			}
+		})
+	}
+	for typ := range contextualKeywords {
+		if !covered[typ] {
+			t.Errorf("contextual keyword %s has no test", typ)
+		}
+	}
+
+	// reserved words and the % operator are never names
+	for _, src := range []string{"print", "if", "end", "function", "%"} {
+		if tok := tokens(src)[0]; IsContextual(tok) {
+			t.Errorf("%q (%s) is contextual", src, tok.Type)
+		}
+	}
+}
+
+func TestComparisonOperators(t *testing.T) {
+	tests := []struct {
+		src    string
+		want   TokenType
+		lexeme string
+		next   TokenType // the token after the operator
+	}{
+		{"a < b", LT, "<", IDENT},
+		{"a <= b", LTE, "<=", IDENT},
+		{"a <> b", NEQ, "<>", IDENT},
+		{"a<>b", NEQ, "<>", IDENT},
+		{"a != b", NEQ, "!=", IDENT},
+		{"a > b", GT, ">", IDENT},
+		{"a >= b", GTE, ">=", IDENT},
+		{"a < > b", LT, "<", GT}, // <> only when the two are together
+		{"a <>= b", NEQ, "<>", ASSIGN},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			toks := tokens(tt.src)
+			if op := toks[1]; op.Type != tt.want || op.Lexeme != tt.lexeme {
+				t.Errorf("operator is %s %q, want %s %q", op.Type, op.Lexeme, tt.want, tt.lexeme)
+			}
+			if next := toks[2]; next.Type != tt.next {
+				t.Errorf("next token is %s, want %s", next.Type, tt.next)
+			}
+		})
+	}
+}
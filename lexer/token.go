@@ -1,6 +1,10 @@
 package lexer
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
 
 type TokenType string
 
@@ -16,13 +20,38 @@ const (
 	PRINT    TokenType = "PRINT"
 	IF       TokenType = "IF"
 	ELSE     TokenType = "ELSE"
+	ELSEIF   TokenType = "ELSEIF"
 	END      TokenType = "END"
 	WHILE    TokenType = "WHILE"
+	REPEAT   TokenType = "REPEAT"
+	DO       TokenType = "DO"
+	UNTIL    TokenType = "UNTIL"
 	FOR      TokenType = "FOR"
 	TO       TokenType = "TO"
 	STEP     TokenType = "STEP"
 	FUNCTION TokenType = "FUNCTION"
 	RETURN   TokenType = "RETURN"
+	TRY      TokenType = "TRY"
+	CATCH    TokenType = "CATCH"
+	FINALLY  TokenType = "FINALLY"
+	RAISE    TokenType = "RAISE"
+	OVERRIDE TokenType = "OVERRIDE"
+	TYPE     TokenType = "TYPE"
+	GOTO     TokenType = "GOTO"
+	GOSUB    TokenType = "GOSUB"
+
+	// DATA / READ / RESTORE
+	DATA    TokenType = "DATA"
+	READ    TokenType = "READ"
+	RESTORE TokenType = "RESTORE"
+
+	SWAP TokenType = "SWAP"
+
+	// match expression
+	MATCH TokenType = "MATCH"
+	CASE  TokenType = "CASE"
+
+	YIELD TokenType = "YIELD"
 
 	// foreach sugar
 	FOREACH TokenType = "FOREACH"
@@ -40,19 +69,28 @@ const (
 
 	TRUE  TokenType = "TRUE"
 	FALSE TokenType = "FALSE"
+	NULL  TokenType = "NULL"
 
 	// Modules
 	IMPORT TokenType = "IMPORT"
 
+	// Declarations / directives
+	DIM    TokenType = "DIM"
+	OPTION TokenType = "OPTION"
+	GLOBAL TokenType = "GLOBAL"
+	LET    TokenType = "LET"
+	EXPORT TokenType = "EXPORT"
+
 	AND TokenType = "AND"
 	OR  TokenType = "OR"
 	NOT TokenType = "NOT"
 
-	ASSIGN TokenType = "ASSIGN"
-	PLUS   TokenType = "PLUS"
-	MINUS  TokenType = "MINUS"
-	STAR   TokenType = "STAR"
-	SLASH  TokenType = "SLASH"
+	ASSIGN  TokenType = "ASSIGN"
+	PLUS    TokenType = "PLUS"
+	MINUS   TokenType = "MINUS"
+	STAR    TokenType = "STAR"
+	SLASH   TokenType = "SLASH"
+	PERCENT TokenType = "PERCENT" // % (also written as the keyword mod)
 
 	LPAREN   TokenType = "LPAREN"
 	RPAREN   TokenType = "RPAREN"
@@ -64,10 +102,16 @@ const (
 	RBRACE TokenType = "RBRACE"
 	COLON  TokenType = "COLON"
 
-	COMMA TokenType = "COMMA"
+	COALESCE  TokenType = "COALESCE" // ??
+	QUESTION  TokenType = "QUESTION" // ? after an index
+	COMMA     TokenType = "COMMA"
+	SEMICOLON TokenType = "SEMICOLON"
+	ELLIPSIS  TokenType = "ELLIPSIS" // ... before a variadic parameter
+	RANGE     TokenType = "RANGE"    // .. between the ends of a range
+	DOT       TokenType = "DOT"      // namespace separator: math.sqrt
 
 	EQ  TokenType = "EQ"  // ==
-	NEQ TokenType = "NEQ" // !=
+	NEQ TokenType = "NEQ" // != (or <>)
 	LT  TokenType = "LT"
 	GT  TokenType = "GT"
 	LTE TokenType = "LTE"
@@ -95,66 +139,131 @@ func (t Token) String() string {
 	}
 }
 
-func LookupIdent(ident string) TokenType {
-	switch ident {
-	case "print", "PRINT", "Print":
-		return PRINT
-	case "if", "IF", "If":
-		return IF
-	case "else", "ELSE", "Else":
-		return ELSE
-	case "end", "END", "End":
-		return END
-	case "while", "WHILE", "While":
-		return WHILE
-	case "for", "FOR", "For":
-		return FOR
-	case "to", "TO", "To":
-		return TO
-	case "step", "STEP", "Step":
-		return STEP
-	case "function", "FUNCTION", "Function":
-		return FUNCTION
-	case "return", "RETURN", "Return":
-		return RETURN
+// keywords maps the lower-case spelling of each keyword to its token.
+// Keywords are case-insensitive: print, PRINT and pRint are all PRINT.
+var keywords = map[string]TokenType{
+	"print":    PRINT,
+	"if":       IF,
+	"else":     ELSE,
+	"elseif":   ELSEIF,
+	"elif":     ELSEIF,
+	"end":      END,
+	"while":    WHILE,
+	"repeat":   REPEAT,
+	"do":       DO,
+	"until":    UNTIL,
+	"for":      FOR,
+	"to":       TO,
+	"step":     STEP,
+	"function": FUNCTION,
+	"return":   RETURN,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"raise":    RAISE,
+	"override": OVERRIDE,
+	"type":     TYPE,
+	"goto":     GOTO,
+	"gosub":    GOSUB,
+
+	// DATA / READ / RESTORE
+	"data":    DATA,
+	"read":    READ,
+	"restore": RESTORE,
+
+	"swap": SWAP,
+
+	// match expression
+	"match": MATCH,
+	"case":  CASE,
+
+	"yield": YIELD,
 
 	// foreach sugar
-	case "foreach", "FOREACH", "Foreach", "ForEach":
-		return FOREACH
-	case "each", "EACH", "Each":
-		return EACH
-	case "in", "IN", "In":
-		return IN
+	"foreach": FOREACH,
+	"each":    EACH,
+	"in":      IN,
 
 	// loop control
-	case "break", "BREAK", "Break":
-		return BREAK
-	case "continue", "CONTINUE", "Continue":
-		return CONTINUE
+	"break":    BREAK,
+	"continue": CONTINUE,
 
 	// file handles
-	case "open", "OPEN", "Open":
-		return OPEN
-	case "close", "CLOSE", "Close":
-		return CLOSE
+	"open":  OPEN,
+	"close": CLOSE,
 
-	case "true", "TRUE", "True":
-		return TRUE
-	case "false", "FALSE", "False":
-		return FALSE
+	"true":  TRUE,
+	"false": FALSE,
+	"null":  NULL,
 
 	// Modules
-	case "import", "IMPORT", "Import":
-		return IMPORT
+	"import": IMPORT,
 
-	case "and", "AND", "And":
-		return AND
-	case "or", "OR", "Or":
-		return OR
-	case "not", "NOT", "Not":
-		return NOT
+	// declarations / directives
+	"dim":    DIM,
+	"mod":    PERCENT,
+	"option": OPTION,
+	"global": GLOBAL,
+	"let":    LET,
+	"export": EXPORT,
 
-	default:
-		return IDENT
+	"and": AND,
+	"or":  OR,
+	"not": NOT,
+}
+
+// Keywords returns the lower-case spelling of every keyword, sorted, for
+// tools such as the editor syntax generator (see LookupIdent for each type).
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for w := range keywords {
+		words = append(words, w)
 	}
+	sort.Strings(words)
+	return words
+}
+
+func LookupIdent(ident string) TokenType {
+	if t, ok := keywords[strings.ToLower(ident)]; ok {
+		return t
+	}
+	return IDENT
+}
+
+// Contextual keywords only mean something in one position (for ... to ...
+// step, foreach ... in, infix mod, repeat/do ... until), so the parser also
+// accepts them as plain names anywhere a name is expected.
+var contextualKeywords = map[TokenType]bool{
+	TO:       true,
+	STEP:     true,
+	EACH:     true,
+	IN:       true,
+	PERCENT:  true,
+	REPEAT:   true,
+	DO:       true,
+	UNTIL:    true,
+	TRY:      true,
+	CATCH:    true,
+	FINALLY:  true,
+	RAISE:    true,
+	OVERRIDE: true,
+	TYPE:     true,
+	GLOBAL:   true,
+	LET:      true,
+	EXPORT:   true,
+	GOTO:     true,
+	GOSUB:    true,
+	DATA:     true,
+	READ:     true,
+	RESTORE:  true,
+	SWAP:     true,
+	MATCH:    true,
+	CASE:     true,
+	YIELD:    true,
+}
+
+// IsContextual reports whether tok is a contextual keyword written as a word
+// (so `mod` counts but the `%` operator does not).
+func IsContextual(tok Token) bool {
+	return contextualKeywords[tok.Type] && tok.Lexeme != "%"
 }
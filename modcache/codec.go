@@ -0,0 +1,304 @@
+package modcache
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+
+	"bpl-plus/ast"
+)
+
+// An entry is the program written field by field in declaration order, with
+// no field names or type names (gob's were most of its size and time):
+//
+//	node (interface)  uvarint tag from nodeTypes, 0 for nil, then its fields
+//	pointer           0 for nil, or 1 and the struct it points to
+//	slice             0 for nil, or len+1 and the elements
+//	string            uvarint index+1 of an earlier string, or 0, len, bytes
+//	int               varint
+//	bool              one byte
+//	float64           8 bytes, little-endian
+//
+// Repeated strings (names, mostly) are stored once.
+
+var errCorrupt = errors.New("modcache: corrupt entry")
+
+// fields holds the exported field indexes of every struct reachable from the
+// node types. It is filled once at init and only read afterwards.
+var fields = map[reflect.Type][]int{}
+
+func addFields(t reflect.Type) {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice:
+		addFields(t.Elem())
+	case reflect.Struct:
+		if _, ok := fields[t]; ok {
+			return
+		}
+		idx := []int{}
+		fields[t] = idx
+		for k := 0; k < t.NumField(); k++ {
+			if f := t.Field(k); f.IsExported() {
+				idx = append(idx, k)
+				addFields(f.Type)
+			}
+		}
+		fields[t] = idx
+	}
+}
+
+type encoder struct {
+	buf  []byte
+	strs map[string]uint64
+}
+
+func encode(prog []ast.Stmt) ([]byte, error) {
+	e := &encoder{strs: map[string]uint64{}}
+	if err := e.value(reflect.ValueOf(prog)); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+func (e *encoder) uint(x uint64) { e.buf = binary.AppendUvarint(e.buf, x) }
+
+func (e *encoder) value(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		idx, ok := fields[v.Type()]
+		if !ok {
+			return fmt.Errorf("modcache: cannot encode %s", v.Type())
+		}
+		for _, k := range idx {
+			if err := e.value(v.Field(k)); err != nil {
+				return err
+			}
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			e.uint(0)
+			return nil
+		}
+		p := v.Elem()
+		tag, ok := tags[p.Type().Elem()]
+		if p.Kind() != reflect.Pointer || !ok || p.IsNil() {
+			return fmt.Errorf("modcache: cannot encode %s", p.Type())
+		}
+		e.uint(tag)
+		return e.value(p.Elem())
+	case reflect.Pointer:
+		if v.IsNil() {
+			e.uint(0)
+			return nil
+		}
+		e.uint(1)
+		return e.value(v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			e.uint(0)
+			return nil
+		}
+		e.uint(uint64(v.Len()) + 1)
+		for k := 0; k < v.Len(); k++ {
+			if err := e.value(v.Index(k)); err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		s := v.String()
+		if n, ok := e.strs[s]; ok {
+			e.uint(n + 1)
+			return nil
+		}
+		e.strs[s] = uint64(len(e.strs))
+		e.uint(0)
+		e.uint(uint64(len(s)))
+		e.buf = append(e.buf, s...)
+	case reflect.Int:
+		e.buf = binary.AppendVarint(e.buf, v.Int())
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, 1)
+		} else {
+			e.buf = append(e.buf, 0)
+		}
+	case reflect.Float64:
+		e.buf = binary.LittleEndian.AppendUint64(e.buf, math.Float64bits(v.Float()))
+	default:
+		return fmt.Errorf("modcache: cannot encode %s", v.Type())
+	}
+	return nil
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+	strs []string
+}
+
+// decode reads an entry written by encode. Damaged data is an error, never
+// a panic, so a bad cache file only costs a re-parse.
+func decode(data []byte) (prog []ast.Stmt, err error) {
+	d := &decoder{data: data}
+	defer func() {
+		if r := recover(); r != nil {
+			if r != errCorrupt {
+				panic(r)
+			}
+			prog, err = nil, errCorrupt
+		}
+	}()
+	(*decoderFor(reflect.TypeOf(prog)))(d, reflect.ValueOf(&prog).Elem())
+	if d.pos != len(d.data) {
+		return nil, errCorrupt
+	}
+	return prog, nil
+}
+
+func (d *decoder) uint() uint64 {
+	x, n := binary.Uvarint(d.data[d.pos:])
+	if n <= 0 {
+		panic(errCorrupt)
+	}
+	d.pos += n
+	return x
+}
+
+func (d *decoder) bytes(n uint64) []byte {
+	if n > uint64(len(d.data)-d.pos) {
+		panic(errCorrupt)
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b
+}
+
+// decodeFn fills v, a settable value of the type it was made for.
+type decodeFn func(d *decoder, v reflect.Value)
+
+// decoders holds a decodeFn for every type reachable from the node types,
+// made once at init so decoding does no type inspection. tagDecoders is
+// indexed by tag.
+var (
+	decoders    = map[reflect.Type]*decodeFn{}
+	tagDecoders []*decodeFn
+)
+
+// decoderFor returns the (possibly not yet filled in) decodeFn cell for t;
+// recursive types refer to their own cell.
+func decoderFor(t reflect.Type) *decodeFn {
+	if fn, ok := decoders[t]; ok {
+		return fn
+	}
+	fn := new(decodeFn)
+	decoders[t] = fn
+	*fn = makeDecoder(t)
+	return fn
+}
+
+func makeDecoder(t reflect.Type) decodeFn {
+	switch t.Kind() {
+	case reflect.Struct:
+		idx := fields[t]
+		fns := make([]*decodeFn, len(idx))
+		for n, k := range idx {
+			fns[n] = decoderFor(t.Field(k).Type)
+		}
+		return func(d *decoder, v reflect.Value) {
+			for n, k := range idx {
+				(*fns[n])(d, v.Field(k))
+			}
+		}
+	case reflect.Interface:
+		return func(d *decoder, v reflect.Value) {
+			tag := d.uint()
+			if tag == 0 {
+				return
+			}
+			if tag >= uint64(len(tagType)) {
+				panic(errCorrupt)
+			}
+			p := reflect.New(tagType[tag])
+			(*tagDecoders[tag])(d, p.Elem())
+			setNode(v, p.Interface())
+		}
+	case reflect.Pointer:
+		elem := decoderFor(t.Elem())
+		return func(d *decoder, v reflect.Value) {
+			if d.uint() == 0 {
+				return
+			}
+			p := reflect.New(t.Elem())
+			(*elem)(d, p.Elem())
+			v.Set(p)
+		}
+	case reflect.Slice:
+		elem := decoderFor(t.Elem())
+		return func(d *decoder, v reflect.Value) {
+			n := d.uint()
+			if n == 0 {
+				return
+			}
+			n--
+			if n > uint64(len(d.data)-d.pos) {
+				panic(errCorrupt) // every element takes at least one byte
+			}
+			s := reflect.MakeSlice(t, int(n), int(n))
+			for k := 0; k < int(n); k++ {
+				(*elem)(d, s.Index(k))
+			}
+			v.Set(s)
+		}
+	case reflect.String:
+		return func(d *decoder, v reflect.Value) {
+			n := d.uint()
+			if n > 0 {
+				if n > uint64(len(d.strs)) {
+					panic(errCorrupt)
+				}
+				v.SetString(d.strs[n-1])
+				return
+			}
+			s := string(d.bytes(d.uint()))
+			d.strs = append(d.strs, s)
+			v.SetString(s)
+		}
+	case reflect.Int:
+		return func(d *decoder, v reflect.Value) {
+			x, n := binary.Varint(d.data[d.pos:])
+			if n <= 0 {
+				panic(errCorrupt)
+			}
+			d.pos += n
+			v.SetInt(x)
+		}
+	case reflect.Bool:
+		return func(d *decoder, v reflect.Value) { v.SetBool(d.bytes(1)[0] != 0) }
+	case reflect.Float64:
+		return func(d *decoder, v reflect.Value) {
+			v.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64(d.bytes(8))))
+		}
+	}
+	return func(d *decoder, v reflect.Value) { panic(errCorrupt) }
+}
+
+// setNode stores node in the interface field v. A plain type assertion is
+// much cheaper than reflect's Set, which checks the method sets every time;
+// it also rejects a tag of the wrong kind (a statement where an expression
+// belongs).
+func setNode(v reflect.Value, node any) {
+	var ok bool
+	switch dst := v.Addr().Interface().(type) {
+	case *ast.Expr:
+		*dst, ok = node.(ast.Expr)
+	case *ast.Stmt:
+		*dst, ok = node.(ast.Stmt)
+	case *ast.Node:
+		*dst, ok = node.(ast.Node)
+	}
+	if !ok {
+		panic(errCorrupt)
+	}
+}
@@ -0,0 +1,223 @@
+// Package modcache keeps parsed programs on disk, keyed by a hash of their
+// source, so scripts that import big libraries do not re-parse them on every
+// run. The cache lives in the user cache directory (~/.cache/bplplus on
+// Linux) and is shared by every bplplus process; entries are written to a
+// temporary file and renamed into place, so a reader never sees half of one.
+//
+// Entries use a compact binary encoding (codec.go) that loads faster than
+// the parser runs; the parse cache is kept under maxCacheBytes by removing
+// the least recently used entries. Set BPLPLUS_NOCACHE=1 to bypass the
+// cache. Deleting the directory is always safe; it only costs a re-parse.
+package modcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"bpl-plus/ast"
+	"bpl-plus/lexer"
+	"bpl-plus/parser"
+)
+
+// formatVersion changes whenever the encoding of cached entries does.
+const formatVersion = "2"
+
+// maxCacheBytes bounds the parse entries on disk; writing one that takes the
+// total past it removes the least recently used ones down to three quarters
+// of it. Downloaded modules in remote/ are pinned by bpl.lock and never
+// pruned.
+const maxCacheBytes = 64 << 20
+
+// Parse parses src like parser.New(lexer.New(src)).ParseProgram(), using the
+// on-disk cache when it has an entry for src. Programs with syntax errors are
+// never cached, so the error is always reported fresh.
+func Parse(src string) ([]ast.Stmt, error) {
+	return parse(src, false)
+}
+
+// ParseClassic is Parse with parser.NewClassic, for line-numbered listings.
+func ParseClassic(src string) ([]ast.Stmt, error) {
+	return parse(src, true)
+}
+
+func parse(src string, classic bool) ([]ast.Stmt, error) {
+	path, ok := entryPath(src, classic)
+	if ok {
+		if prog, err := load(path); err == nil {
+			return prog, nil
+		}
+	}
+	var ps *parser.Parser
+	if classic {
+		ps = parser.NewClassic(lexer.New(src))
+	} else {
+		ps = parser.New(lexer.New(src))
+	}
+	prog, err := ps.ParseProgram()
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		// A full disk or read-only home must not stop the program.
+		_ = store(path, prog)
+	}
+	return prog, nil
+}
+
+// Dir is the cache directory, or "" when caching is off or there is no
+// usable cache location.
+func Dir() string {
+	if os.Getenv("BPLPLUS_NOCACHE") != "" {
+		return ""
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(base, "bplplus")
+}
+
+func entryPath(src string, classic bool) (string, bool) {
+	dir := Dir()
+	if dir == "" {
+		return "", false
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%t\x00", formatVersion, buildStamp(), classic)
+	h.Write([]byte(src))
+	return filepath.Join(dir, hex.EncodeToString(h.Sum(nil))+".ast"), true
+}
+
+var (
+	stampOnce sync.Once
+	stamp     string
+)
+
+// buildStamp identifies the parser in the running binary, so a build that may
+// parse differently never reads entries written by another one. A binary
+// built from a clean checkout is named by its commit (or module version), so
+// rebuilding the same source keeps the cache; other builds fall back to the
+// executable's path, size and modification time.
+func buildStamp() string {
+	stampOnce.Do(func() {
+		if info, ok := debug.ReadBuildInfo(); ok {
+			var rev, modified string
+			for _, s := range info.Settings {
+				switch s.Key {
+				case "vcs.revision":
+					rev = s.Value
+				case "vcs.modified":
+					modified = s.Value
+				}
+			}
+			if rev != "" && modified == "false" {
+				stamp = "vcs:" + rev
+				return
+			}
+			if v := info.Main.Version; v != "" && v != "(devel)" {
+				stamp = "mod:" + v
+				return
+			}
+		}
+		exe, err := os.Executable()
+		if err != nil {
+			return
+		}
+		if fi, err := os.Stat(exe); err == nil {
+			stamp = fmt.Sprintf("%s:%d:%d", exe, fi.Size(), fi.ModTime().UnixNano())
+		}
+	})
+	return stamp
+}
+
+func load(path string) ([]ast.Stmt, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	prog, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	// The modification time records the last use, for pruning.
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return prog, nil
+}
+
+func store(path string, prog []ast.Stmt) error {
+	data, err := encode(prog)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "entry-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	prune(dir, maxCacheBytes)
+	return nil
+}
+
+// prune removes the least recently used entries in dir once they take more
+// than limit bytes, down to three quarters of limit. Another process may be
+// pruning at the same time; whichever removes a file first wins.
+func prune(dir string, limit int64) {
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	type entry struct {
+		path string
+		size int64
+		used time.Time
+	}
+	var list []entry
+	var total int64
+	for _, de := range ents {
+		if de.IsDir() || !strings.HasSuffix(de.Name(), ".ast") {
+			continue
+		}
+		fi, err := de.Info()
+		if err != nil {
+			continue
+		}
+		list = append(list, entry{filepath.Join(dir, de.Name()), fi.Size(), fi.ModTime()})
+		total += fi.Size()
+	}
+	if total <= limit {
+		return
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].used.Before(list[b].used) })
+	for _, e := range list {
+		if total <= limit/4*3 {
+			return
+		}
+		if os.Remove(e.path) == nil {
+			total -= e.size
+		}
+	}
+}
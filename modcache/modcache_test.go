@@ -0,0 +1,174 @@
+package modcache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"bpl-plus/lexer"
+	"bpl-plus/parser"
+)
+
+// bigModule is a library of n functions in the style of the examples, for
+// benchmarking a cache hit against a fresh parse.
+func bigModule(n int) string {
+	var b strings.Builder
+	b.WriteString("type Point\n  x = 0\n  y = 0\nend\n\n")
+	for k := 0; k < n; k++ {
+		fmt.Fprintf(&b, `function helper%d(xs, limit)
+  total = 0
+  seen = {"count": 0, "name": "helper%d", [str(limit)]: true}
+  for each x in xs
+    if x > limit and not (x mod 2 == 0)
+      total = total + x * 2.5
+    elseif x == limit
+      seen["count"] = seen["count"] + 1
+    else
+      continue
+    end
+  end
+  label = match total
+    case 0: "none"
+    case 1 to 10: "few"
+    case is number as t: "many: " + str(t)
+  end
+  p = Point(total, len(xs))
+  f = function(a, b) return a + b end
+  for k = 1 to 10 step 2
+    print k, label; p.x
+  end
+  try
+    raise "boom"
+  catch err
+    print "caught " + err
+  end
+  return f(total, seen["count"]) ?? 0
+end
+
+`, k, k)
+	}
+	return b.String()
+}
+
+func TestRoundTrip(t *testing.T) {
+	srcs := map[string]string{"big module": bigModule(3)}
+	files, _ := filepath.Glob("../examples/*.bpl")
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srcs[filepath.Base(f)] = string(data)
+	}
+	for name, src := range srcs {
+		prog, err := parser.New(lexer.New(src)).ParseProgram()
+		if err != nil {
+			continue // examples of syntax errors are never cached
+		}
+		data, err := encode(prog)
+		if err != nil {
+			t.Errorf("%s: encode: %v", name, err)
+			continue
+		}
+		back, err := decode(data)
+		if err != nil {
+			t.Errorf("%s: decode: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(prog, back) {
+			t.Errorf("%s: decoded program differs from the parsed one", name)
+		}
+	}
+}
+
+func TestPruneRemovesLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Now().Add(-time.Hour)
+	for k, name := range []string{"a.ast", "b.ast", "c.ast", "d.ast"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, make([]byte, 100), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		used := base.Add(time.Duration(k) * time.Minute) // a is the oldest
+		if err := os.Chtimes(path, used, used); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// downloads and other files are not parse entries
+	if err := os.MkdirAll(filepath.Join(dir, "remote"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "remote", "x.bpl"), make([]byte, 1000), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	prune(dir, 400) // at the limit: nothing to do
+	prune(dir, 300) // over it: down to 225 bytes, so two entries go
+
+	for name, want := range map[string]bool{"a.ast": false, "b.ast": false, "c.ast": true, "d.ast": true, "remote/x.bpl": true} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if got := err == nil; got != want {
+			t.Errorf("%s exists = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestDecodeRejectsDamagedEntries(t *testing.T) {
+	prog, err := parser.New(lexer.New(bigModule(1))).ParseProgram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := encode(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{0, 1, len(data) / 2, len(data) - 1} {
+		if _, err := decode(data[:n]); err == nil {
+			t.Errorf("decoding the first %d of %d bytes succeeded", n, len(data))
+		}
+	}
+	if _, err := decode(append(data, 0)); err == nil {
+		t.Error("decoding with a trailing byte succeeded")
+	}
+}
+
+// BenchmarkParse and BenchmarkCacheHit compare a fresh parse with loading
+// the same program from its cache entry; a hit has to be the faster one for
+// the cache to be worth having.
+func BenchmarkParse(b *testing.B) {
+	src := bigModule(2000)
+	b.SetBytes(int64(len(src)))
+	for n := 0; n < b.N; n++ {
+		if _, err := parser.New(lexer.New(src)).ParseProgram(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCacheHit(b *testing.B) {
+	src := bigModule(2000)
+	prog, err := parser.New(lexer.New(src)).ParseProgram()
+	if err != nil {
+		b.Fatal(err)
+	}
+	data, err := encode(prog)
+	if err != nil {
+		b.Fatal(err)
+	}
+	path := filepath.Join(b.TempDir(), "entry.ast")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(src)))
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := load(path); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(len(data))/float64(len(src)), "entry/src")
+}
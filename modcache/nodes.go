@@ -0,0 +1,95 @@
+package modcache
+
+import (
+	"reflect"
+
+	"bpl-plus/ast"
+)
+
+// nodeTypes lists every node type that can appear behind an ast.Expr or
+// ast.Stmt. An entry stores a node's position in this list as its tag, so
+// new types go at the end (and formatVersion changes when any move). A
+// program using a type missing here fails to encode and is simply not cached.
+var nodeTypes = []ast.Node{
+	// expressions
+	&ast.StringLiteral{},
+	&ast.NumberLiteral{},
+	&ast.NullLiteral{},
+	&ast.BoolLiteral{},
+	&ast.Identifier{},
+	&ast.UnaryExpr{},
+	&ast.BinaryExpr{},
+	&ast.CallExpr{},
+	&ast.MemberExpr{},
+	&ast.MethodCallExpr{},
+	&ast.CallValueExpr{},
+	&ast.FunctionLit{},
+	&ast.ArrayLiteralExpr{},
+	&ast.IndexExpr{},
+	&ast.SliceExpr{},
+	&ast.MapLiteralExpr{},
+	&ast.MatchExpr{},
+	&ast.RangeExpr{},
+	&ast.SpreadExpr{},
+
+	// statements
+	&ast.ImportStmt{},
+	&ast.PrintStmt{},
+	&ast.AssignStmt{},
+	&ast.IndexAssignStmt{},
+	&ast.MemberAssignStmt{},
+	&ast.ExprStmt{},
+	&ast.IfStmt{},
+	&ast.WhileStmt{},
+	&ast.RepeatStmt{},
+	&ast.TryStmt{},
+	&ast.RaiseStmt{},
+	&ast.ForStmt{},
+	&ast.ForEachStmt{},
+	&ast.BreakStmt{},
+	&ast.ContinueStmt{},
+	&ast.OpenStmt{},
+	&ast.CloseStmt{},
+	&ast.PrintHandleStmt{},
+	&ast.FunctionDecl{},
+	&ast.ReturnStmt{},
+	&ast.DimStmt{},
+	&ast.TypeDecl{},
+	&ast.OptionStmt{},
+	&ast.GlobalStmt{},
+	&ast.LetStmt{},
+	&ast.ExportStmt{},
+	&ast.LabelStmt{},
+	&ast.GotoStmt{},
+	&ast.GosubStmt{},
+	&ast.DataStmt{},
+	&ast.ReadStmt{},
+	&ast.RestoreStmt{},
+	&ast.SwapStmt{},
+	&ast.DestructureStmt{},
+	&ast.YieldStmt{},
+}
+
+// tags maps each node type (the struct, not the pointer) to its tag; tag 0
+// is a nil interface.
+var (
+	tags    = map[reflect.Type]uint64{}
+	tagType = []reflect.Type{nil}
+)
+
+func init() {
+	for _, n := range nodeTypes {
+		t := reflect.TypeOf(n).Elem()
+		tags[t] = uint64(len(tagType))
+		tagType = append(tagType, t)
+		addFields(t)
+	}
+	for _, t := range tagType {
+		if t == nil {
+			tagDecoders = append(tagDecoders, nil)
+			continue
+		}
+		tagDecoders = append(tagDecoders, decoderFor(t))
+	}
+	decoderFor(reflect.TypeOf([]ast.Stmt(nil)))
+}
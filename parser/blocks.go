@@ -0,0 +1,66 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"bpl-plus/ast"
+	"bpl-plus/codes"
+	"bpl-plus/lexer"
+)
+
+// openBlock is a block construct whose closing keyword has not been seen yet.
+type openBlock struct {
+	kind string
+	tok  lexer.Token
+}
+
+// BlockError reports a block that was never closed. It points back at the
+// keyword that opened the block, since the place the parser gave up (often
+// the end of the file) says little about which block is missing its 'end'.
+type BlockError struct {
+	Msg    string
+	Code   string
+	Opener ast.Span
+	Line   string // source text of the opener's line, for the caret
+}
+
+func (e *BlockError) Error() string {
+	head := e.Msg + " [" + e.Code + "]"
+	if e.Line == "" {
+		return head
+	}
+	prefix := fmt.Sprintf("  %d | ", e.Opener.Line)
+	var b strings.Builder
+	b.WriteString(head + "\n")
+	b.WriteString(prefix + e.Line + "\n")
+	b.WriteString(strings.Repeat(" ", len(prefix)+e.Opener.Col-1) + "^")
+	return b.String()
+}
+
+// openBlock records that tok starts a block of the given kind.
+func (p *Parser) openBlock(kind string, tok lexer.Token) {
+	p.blocks = append(p.blocks, openBlock{kind: kind, tok: tok})
+}
+
+// closeBlock consumes the keyword that closes the innermost open block, or
+// reports which block is left open.
+func (p *Parser) closeBlock(closer lexer.TokenType) error {
+	b := p.blocks[len(p.blocks)-1]
+	if p.cur.Type != closer {
+		return p.unclosed(b, strings.ToLower(string(closer)))
+	}
+	p.blocks = p.blocks[:len(p.blocks)-1]
+	p.next()
+	return nil
+}
+
+func (p *Parser) unclosed(b openBlock, closer string) error {
+	msg := fmt.Sprintf("Expected '%s' to close '%s' started at %d:%d", closer, b.kind, b.tok.Line, b.tok.Col)
+	if p.cur.Type == lexer.EOF {
+		msg += " before end of file"
+	} else {
+		msg += fmt.Sprintf(" (got %s at %d:%d)", p.cur.Type, p.cur.Line, p.cur.Col)
+	}
+	return &BlockError{Msg: msg, Code: codes.MissingEnd, Opener: sp(b.tok), Line: p.lx.SourceLine(b.tok.Line)}
+}
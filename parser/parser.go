@@ -3,6 +3,8 @@ package parser
 import (
 	"fmt"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"bpl-plus/ast"
 	"bpl-plus/lexer"
@@ -12,6 +14,16 @@ type Parser struct {
 	lx   *lexer.Lexer
 	cur  lexer.Token
 	peek lexer.Token
+
+	checked bool // option checked seen: mark arithmetic as checked
+	classic bool // line numbers are labels; goto/gosub/restore take numbers
+	fold    bool // option classic seen: names are read in lower case
+
+	blocks []openBlock // innermost last; used to name the block a missing 'end' belongs to
+
+	// nest counts the ( [ { open around cur. Inside them NEWLINEs are
+	// skipped, so literals and argument lists can span lines.
+	nest int
 }
 
 func New(lx *lexer.Lexer) *Parser {
@@ -21,9 +33,58 @@ func New(lx *lexer.Lexer) *Parser {
 	return p
 }
 
+// NewClassic is New for classic BASIC listings (bplplus --classic): a number
+// at the start of a statement is a line-number label, goto 100, gosub 200 and
+// restore 300 jump to one, and REM starts a comment.
+func NewClassic(lx *lexer.Lexer) *Parser {
+	lx.SetClassic(true)
+	p := New(lx)
+	p.classic = true
+	return p
+}
+
 func (p *Parser) next() {
 	p.cur = p.peek
-	p.peek = p.lx.NextToken()
+	p.peek = p.read()
+	for p.nest > 0 && p.cur.Type == lexer.NEWLINE {
+		p.cur = p.peek
+		p.peek = p.read()
+	}
+}
+
+// read takes the next token from the lexer. Under option classic names are
+// case-insensitive: Total, TOTAL and total are all read as total. A name after
+// '.' belongs to whatever is on the left, maybe a record or module defined in
+// another file, so it is kept as written and matched in any case later.
+func (p *Parser) read() lexer.Token {
+	tok := p.lx.NextToken()
+	if p.fold && isName(tok) && p.cur.Type != lexer.DOT {
+		tok.Lexeme = strings.ToLower(tok.Lexeme)
+	}
+	return tok
+}
+
+// enterNest moves past an opening bracket; leaveNest past its closing one.
+func (p *Parser) enterNest() {
+	p.nest++
+	p.next()
+}
+
+func (p *Parser) leaveNest() {
+	p.nest--
+	p.next()
+}
+
+// isName reports whether tok can be used as a variable or function name:
+// an identifier, or a contextual keyword such as `to` or `step`.
+func isName(tok lexer.Token) bool {
+	return tok.Type == lexer.IDENT || lexer.IsContextual(tok)
+}
+
+// isLabel reports whether tok can name a label: a name, or in classic mode a
+// line number.
+func (p *Parser) isLabel(tok lexer.Token) bool {
+	return isName(tok) || p.classic && tok.Type == lexer.NUMBER
 }
 
 func sp(tok lexer.Token) ast.Span { return ast.Span{Line: tok.Line, Col: tok.Col} }
@@ -48,6 +109,107 @@ func (p *Parser) ParseProgram() ([]ast.Stmt, error) {
 }
 
 func (p *Parser) parseStmt() (ast.Stmt, error) {
+	// Only a bare repeat/do opens a loop; `repeat(...)` is still the string builtin.
+	if (p.cur.Type == lexer.REPEAT || p.cur.Type == lexer.DO) && p.peek.Type == lexer.NEWLINE {
+		return p.parseRepeat()
+	}
+	if p.cur.Type == lexer.TRY && p.peek.Type == lexer.NEWLINE {
+		return p.parseTry()
+	}
+	if p.cur.Type == lexer.OVERRIDE && p.peek.Type == lexer.FUNCTION {
+		p.next()
+		st, err := p.parseFunctionDecl()
+		if err != nil {
+			return nil, err
+		}
+		st.(*ast.FunctionDecl).Override = true
+		return st, nil
+	}
+	// type Name opens a record declaration; type is otherwise a plain name
+	if p.cur.Type == lexer.TYPE && isName(p.peek) {
+		return p.parseTypeDecl()
+	}
+	// global x declares x; global is otherwise a plain name
+	if p.cur.Type == lexer.GLOBAL && isName(p.peek) {
+		return p.parseGlobal()
+	}
+	// export a, b lists a module's public names; export is otherwise a plain name
+	if p.cur.Type == lexer.EXPORT && isName(p.peek) {
+		return p.parseExport()
+	}
+	// let x declares a block-scoped variable; let is otherwise a plain name.
+	// In classic listings LET X = 1 is just an assignment.
+	if p.cur.Type == lexer.LET && isName(p.peek) {
+		if p.classic {
+			p.next()
+			return p.parseStmt()
+		}
+		return p.parseLet()
+	}
+	// 100 print "hi": a leading line number is a label in classic mode
+	if p.classic && p.cur.Type == lexer.NUMBER {
+		labelTok := p.cur
+		p.next()
+		return &ast.LabelStmt{S: sp(labelTok), Name: labelTok.Lexeme}, nil
+	}
+	if !p.classic && (p.cur.Type == lexer.GOTO || p.cur.Type == lexer.GOSUB) && p.peek.Type == lexer.NUMBER {
+		return nil, p.errAt(p.peek, "Line-number labels need classic mode (bplplus --classic)")
+	}
+	// goto/gosub label jump; both are otherwise plain names
+	if (p.cur.Type == lexer.GOTO || p.cur.Type == lexer.GOSUB) && p.isLabel(p.peek) {
+		jumpTok := p.cur
+		p.next()
+		label := p.cur.Lexeme
+		p.next()
+		if jumpTok.Type == lexer.GOSUB {
+			return &ast.GosubStmt{S: sp(jumpTok), Label: label}, nil
+		}
+		return &ast.GotoStmt{S: sp(jumpTok), Label: label}, nil
+	}
+	// data 1, "a" / read x / restore [label]; all three are otherwise plain names
+	if p.cur.Type == lexer.DATA && isDataStart(p.peek) {
+		return p.parseData()
+	}
+	if p.cur.Type == lexer.READ && isName(p.peek) {
+		readTok := p.cur
+		p.next()
+		names, err := p.parseNameList("read")
+		if err != nil {
+			return nil, err
+		}
+		return &ast.ReadStmt{S: sp(readTok), Names: names}, nil
+	}
+	if p.cur.Type == lexer.RESTORE && (p.isLabel(p.peek) || p.peek.Type == lexer.NEWLINE || p.peek.Type == lexer.EOF) {
+		st := &ast.RestoreStmt{S: sp(p.cur)}
+		p.next()
+		if p.isLabel(p.cur) {
+			st.Label = p.cur.Lexeme
+			p.next()
+		}
+		return st, nil
+	}
+	// swap a, b exchanges two places; swap is otherwise a plain name
+	if p.cur.Type == lexer.SWAP && isName(p.peek) {
+		return p.parseSwap()
+	}
+	// a name followed by ':' at the start of a statement is a label
+	if isName(p.cur) && p.peek.Type == lexer.COLON {
+		labelTok := p.cur
+		p.next()
+		p.next()
+		return &ast.LabelStmt{S: sp(labelTok), Name: labelTok.Lexeme}, nil
+	}
+	// raise is a statement unless used as a variable (raise = 1, raise[0] = 1)
+	if p.cur.Type == lexer.RAISE && p.peek.Type != lexer.ASSIGN && p.peek.Type != lexer.LBRACKET &&
+		p.peek.Type != lexer.NEWLINE && p.peek.Type != lexer.EOF {
+		return p.parseRaise()
+	}
+	// yield likewise, so code that used yield as a name keeps working
+	if p.cur.Type == lexer.YIELD && p.peek.Type != lexer.ASSIGN && p.peek.Type != lexer.LBRACKET &&
+		p.peek.Type != lexer.NEWLINE && p.peek.Type != lexer.EOF {
+		return p.parseYield()
+	}
+
 	switch p.cur.Type {
 	case lexer.PRINT:
 		return p.parsePrintOrPrintHandle()
@@ -89,19 +251,36 @@ func (p *Parser) parseStmt() (ast.Stmt, error) {
 	case lexer.IMPORT:
 		return p.parseImport()
 
+	case lexer.DIM:
+		return p.parseDim()
+
+	case lexer.OPTION:
+		return p.parseOption()
+
+	case lexer.LBRACKET, lexer.LBRACE:
+		return p.parseDestructure()
+
+	// (function() ... end)() or (pick())(x): a call on a parenthesized callee
+	case lexer.LPAREN:
+		return p.parseTargetStmt()
+
 	default:
-		// index assignment: a[i] = ...
-		if p.cur.Type == lexer.IDENT && p.peek.Type == lexer.LBRACKET {
-			return p.parseIndexAssign()
+		// index or field assignment: a[i] = ..., a[i][j] = ..., p.x = ...
+		// (or a call through the chain: fs[0](1), p.move(1, 2), math.sqrt(2))
+		if isName(p.cur) && (p.peek.Type == lexer.LBRACKET || p.peek.Type == lexer.DOT) {
+			return p.parseTargetStmt()
 		}
 		// normal assignment: a = ...
-		if p.cur.Type == lexer.IDENT && p.peek.Type == lexer.ASSIGN {
+		if isName(p.cur) && p.peek.Type == lexer.ASSIGN {
 			return p.parseAssign()
 		}
 		// expression statement: push(a, 1)
-		if p.cur.Type == lexer.IDENT && p.peek.Type == lexer.LPAREN {
+		if isName(p.cur) && p.peek.Type == lexer.LPAREN {
 			return p.parseExprStmt()
 		}
+		if p.cur.Type == lexer.NUMBER {
+			return nil, p.errAt(p.cur, "Expected a statement (line numbers need classic mode: bplplus --classic)")
+		}
 		return nil, p.errAt(p.cur, "Expected a statement")
 	}
 }
@@ -135,12 +314,25 @@ func (p *Parser) parsePrintOrPrintHandle() (ast.Stmt, error) {
 		return &ast.PrintHandleStmt{S: sp(hashTok), Handle: handle, Value: expr}, nil
 	}
 
-	// normal print expr
-	expr, err := p.parseExpr()
-	if err != nil {
-		return nil, err
+	// normal print: items separated by ';' (back to back) or ',' (a space
+	// between); a bare print writes an empty line
+	stmt := &ast.PrintStmt{S: sp(printTok)}
+	for p.cur.Type != lexer.NEWLINE && p.cur.Type != lexer.EOF {
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Values = append(stmt.Values, expr)
+		stmt.NoNewline = false
+
+		if p.cur.Type != lexer.SEMICOLON && p.cur.Type != lexer.COMMA {
+			break
+		}
+		stmt.Seps = append(stmt.Seps, p.cur.Lexeme)
+		p.next()
+		stmt.NoNewline = true
 	}
-	return &ast.PrintStmt{S: sp(printTok), Value: expr}, nil
+	return stmt, nil
 }
 
 func (p *Parser) parseOpen() (ast.Stmt, error) {
@@ -218,39 +410,214 @@ func (p *Parser) parseAssign() (ast.Stmt, error) {
 	return &ast.AssignStmt{S: sp(nameTok), Name: nameTok.Lexeme, Value: expr}, nil
 }
 
-// indexAssign = IDENT "[" expr "]" "=" expr
-func (p *Parser) parseIndexAssign() (ast.Stmt, error) {
-	nameTok := p.cur
-	name := nameTok.Lexeme
-
-	p.next() // '['
-	if p.cur.Type != lexer.LBRACKET {
-		return nil, p.errAt(p.cur, "Expected '[' after identifier")
+// destructure = ( arrayPattern | mapPattern ) "=" expr
+// arrayPattern = "[" names [ "," "..." name ] [ "," ] "]"
+// mapPattern   = "{" names [ "," ] "}"
+func (p *Parser) parseDestructure() (ast.Stmt, error) {
+	openTok := p.cur
+	st := &ast.DestructureStmt{S: sp(openTok), Map: openTok.Type == lexer.LBRACE}
+	closer, closeText := lexer.RBRACKET, "]"
+	if st.Map {
+		closer, closeText = lexer.RBRACE, "}"
+	}
+	seen := map[string]bool{}
+	p.enterNest()
+	for p.cur.Type != closer || len(seen) == 0 {
+		rest := false
+		if p.cur.Type == lexer.ELLIPSIS && !st.Map {
+			rest = true
+			p.next()
+		}
+		if !isName(p.cur) {
+			return nil, p.errAt(p.cur, "Expected a variable name to unpack into")
+		}
+		name := p.cur.Lexeme
+		if seen[name] {
+			return nil, p.errAt(p.cur, fmt.Sprintf("%s appears twice in the pattern", name))
+		}
+		seen[name] = true
+		p.next()
+		if rest {
+			st.Rest = name
+			if p.cur.Type != closer {
+				return nil, p.errAt(p.cur, "..."+name+" must be the last name in the pattern")
+			}
+		} else {
+			st.Names = append(st.Names, name)
+		}
+		if p.cur.Type == lexer.COMMA {
+			p.next()
+			continue
+		}
+		if p.cur.Type != closer {
+			return nil, p.errAt(p.cur, "Expected ',' or '"+closeText+"' in the pattern")
+		}
+	}
+	p.leaveNest()
+	if p.cur.Type != lexer.ASSIGN {
+		return nil, p.errAt(p.cur, "Expected '=' after the pattern to unpack into")
 	}
-	lbTok := p.cur
-
 	p.next()
-	indexExpr, err := p.parseExpr()
+	val, err := p.parseExpr()
 	if err != nil {
 		return nil, err
 	}
+	st.Value = val
+	return st, nil
+}
 
-	if p.cur.Type != lexer.RBRACKET {
-		return nil, p.errAt(p.cur, "Expected ']' after index expression")
+// targetStmt = postfix [ "=" expr ]
+// The left side is parsed as an expression and its last index or field is
+// what gets assigned, so any chain works: a[i] = v, grid[y, x] = v,
+// a[i][j] = v, m["a"]["b"] = v, p.pos.x = v, pts[0].x = v. The containers
+// along the chain are evaluated left to right. Without '=', the statement
+// must be a call, on any callee: push(a, 1), p.move(1, 2), fs["save"](),
+// (pick())(x).
+func (p *Parser) parseTargetStmt() (ast.Stmt, error) {
+	startTok := p.cur
+	target, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
 	}
+	if p.cur.Type != lexer.ASSIGN {
+		switch target.(type) {
+		case *ast.CallExpr, *ast.CallValueExpr, *ast.MethodCallExpr:
+			return &ast.ExprStmt{S: sp(startTok), Expr: target}, nil
+		case *ast.IndexExpr:
+			return nil, p.errAt(p.cur, "Expected '=' after index expression")
+		}
+		return nil, p.errAt(p.cur, "Expected '=' or a call")
+	}
+	return p.finishAssign(target)
+}
+
+// finishAssign parses the "=" expr that assigns to target, which must be an
+// index or field.
+func (p *Parser) finishAssign(target ast.Expr) (ast.Stmt, error) {
+	assignTok := p.cur
 	p.next()
+	value, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if ix, ok := target.(*ast.IndexExpr); ok && ix.Optional {
+		return nil, p.errAt(assignTok, "Cannot assign through an optional index")
+	}
+	switch t := target.(type) {
+	case *ast.MemberExpr:
+		return &ast.MemberAssignStmt{S: t.S, Target: t.Left, Name: t.Name, Value: value, Classic: t.Classic}, nil
+	case *ast.IndexExpr:
+		return &ast.IndexAssignStmt{S: t.S, Target: t.Left, Index: t.Index, Value: value}, nil
+	}
+	return nil, p.errAt(assignTok, "Cannot assign to this expression")
+}
 
-	if p.cur.Type != lexer.ASSIGN {
-		return nil, p.errAt(p.cur, "Expected '=' after index expression")
+// swapStmt = "swap" target "," target
+// A target is anything that can be assigned: a variable, an element
+// (a[i], m["k"]) or a record field (p.x).
+func (p *Parser) parseSwap() (ast.Stmt, error) {
+	swapTok := p.cur
+	p.next()
+	left, err := p.parseSwapTarget()
+	if err != nil {
+		return nil, err
+	}
+	if p.cur.Type != lexer.COMMA {
+		return nil, p.errAt(p.cur, "Expected ',' between the two things to swap")
 	}
 	p.next()
+	right, err := p.parseSwapTarget()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.SwapStmt{S: sp(swapTok), Left: left, Right: right}, nil
+}
 
-	valExpr, err := p.parseExpr()
+func (p *Parser) parseSwapTarget() (ast.Expr, error) {
+	startTok := p.cur
+	target, err := p.parsePostfix()
 	if err != nil {
 		return nil, err
 	}
+	switch t := target.(type) {
+	case *ast.Identifier, *ast.MemberExpr:
+		return target, nil
+	case *ast.IndexExpr:
+		if !t.Optional {
+			return target, nil
+		}
+	}
+	return nil, p.errAt(startTok, "swap needs a variable, element or field on each side")
+}
 
-	return &ast.IndexAssignStmt{S: sp(lbTok), Name: name, Index: indexExpr, Value: valExpr}, nil
+// typeDecl = "type" IDENT NEWLINE { fields NEWLINE | functionDecl NEWLINE } "end"
+// fields   = field [ "=" expr ] { "," field [ "=" expr ] }
+// Methods are functions declared in the block; they see the record as self.
+func (p *Parser) parseTypeDecl() (ast.Stmt, error) {
+	p.openBlock("type", p.cur)
+	p.next()
+	nameTok := p.cur
+	decl := &ast.TypeDecl{S: sp(nameTok), Name: nameTok.Lexeme}
+	p.next()
+	if p.cur.Type != lexer.NEWLINE {
+		return nil, p.errAt(p.cur, "Expected NEWLINE after type name")
+	}
+
+	seen := map[string]bool{}
+	for {
+		for p.cur.Type == lexer.NEWLINE {
+			p.next()
+		}
+		if p.cur.Type == lexer.END || p.cur.Type == lexer.EOF {
+			if err := p.closeBlock(lexer.END); err != nil {
+				return nil, err
+			}
+			return decl, nil
+		}
+		if p.cur.Type == lexer.FUNCTION {
+			fnTok := p.peek
+			st, err := p.parseFunctionDecl()
+			if err != nil {
+				return nil, err
+			}
+			fn := st.(*ast.FunctionDecl)
+			if seen[fn.Name] {
+				return nil, p.errAt(fnTok, fmt.Sprintf("%q declared twice in type %s", fn.Name, decl.Name))
+			}
+			seen[fn.Name] = true
+			decl.Methods = append(decl.Methods, fn)
+			continue
+		}
+		if !isName(p.cur) {
+			return nil, p.errAt(p.cur, fmt.Sprintf("Expected a field name or 'end' in type %s", decl.Name))
+		}
+		field := p.cur.Lexeme
+		if seen[field] {
+			return nil, p.errAt(p.cur, fmt.Sprintf("Field %q declared twice in type %s", field, decl.Name))
+		}
+		seen[field] = true
+		p.next()
+
+		var def ast.Expr
+		if p.cur.Type == lexer.ASSIGN {
+			p.next()
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			def = e
+		}
+		decl.Fields = append(decl.Fields, field)
+		decl.Defaults = append(decl.Defaults, def)
+
+		switch p.cur.Type {
+		case lexer.COMMA:
+			p.next()
+		case lexer.NEWLINE, lexer.END:
+		default:
+			return nil, p.errAt(p.cur, "Expected ',' or NEWLINE after field")
+		}
+	}
 }
 
 func (p *Parser) parseExprStmt() (ast.Stmt, error) {
@@ -259,12 +626,22 @@ func (p *Parser) parseExprStmt() (ast.Stmt, error) {
 	if err != nil {
 		return nil, err
 	}
+	// rows()[0] = v and make().x = v assign into what the call returned
+	if p.cur.Type == lexer.ASSIGN {
+		return p.finishAssign(expr)
+	}
 	return &ast.ExprStmt{S: sp(startTok), Expr: expr}, nil
 }
 
+// returnStmt = "return" [ expr ]   (a bare return ends a gosub)
 func (p *Parser) parseReturn() (ast.Stmt, error) {
 	retTok := p.cur
 	p.next()
+	// a bare return may also end a one-line block: if done return end
+	switch p.cur.Type {
+	case lexer.NEWLINE, lexer.EOF, lexer.END, lexer.ELSE, lexer.ELSEIF:
+		return &ast.ReturnStmt{S: sp(retTok)}, nil
+	}
 	expr, err := p.parseExpr()
 	if err != nil {
 		return nil, err
@@ -272,21 +649,227 @@ func (p *Parser) parseReturn() (ast.Stmt, error) {
 	return &ast.ReturnStmt{S: sp(retTok), Value: expr}, nil
 }
 
-// importStmt = "import" STRING
+// importStmt = "import" STRING | "import" names "from" STRING
 func (p *Parser) parseImport() (ast.Stmt, error) {
 	imTok := p.cur
 	p.next()
+	var names []string
+	if isName(p.cur) {
+		var err error
+		if names, err = p.parseNameList("import"); err != nil {
+			return nil, err
+		}
+		if p.cur.Type != lexer.IDENT || !strings.EqualFold(p.cur.Lexeme, "from") {
+			return nil, p.errAt(p.cur, "Expected 'from' after the imported names")
+		}
+		p.next()
+	}
 	if p.cur.Type != lexer.STRING {
 		return nil, p.errAt(p.cur, "Expected string path after 'import'")
 	}
 	pathTok := p.cur
 	p.next()
-	return &ast.ImportStmt{S: sp(imTok), Path: pathTok.Lexeme}, nil
+	return &ast.ImportStmt{S: sp(imTok), Path: pathTok.Lexeme, Names: names, Classic: p.fold}, nil
+}
+
+// exportStmt = "export" IDENT ( "," IDENT )*
+func (p *Parser) parseExport() (ast.Stmt, error) {
+	exTok := p.cur
+	p.next()
+	names, err := p.parseNameList("export")
+	if err != nil {
+		return nil, err
+	}
+	return &ast.ExportStmt{S: sp(exTok), Names: names}, nil
+}
+
+// parseNameList reads IDENT ( "," IDENT )* after keyword.
+func (p *Parser) parseNameList(keyword string) ([]string, error) {
+	var names []string
+	for {
+		if !isName(p.cur) {
+			return nil, p.errAt(p.cur, fmt.Sprintf("Expected a name after '%s'", keyword))
+		}
+		names = append(names, p.cur.Lexeme)
+		p.next()
+		if p.cur.Type != lexer.COMMA {
+			return names, nil
+		}
+		p.next()
+	}
+}
+
+// dimStmt = "dim" IDENT [ "(" expr ( "," expr )* ")" ] [ "=" expr ]
+func (p *Parser) parseDim() (ast.Stmt, error) {
+	dimTok := p.cur
+	p.next()
+	if !isName(p.cur) {
+		return nil, p.errAt(p.cur, "Expected variable name after 'dim'")
+	}
+	name := p.cur.Lexeme
+	p.next()
+
+	var dims []ast.Expr
+	if p.cur.Type == lexer.LPAREN {
+		p.next()
+		for {
+			d, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			dims = append(dims, d)
+
+			if p.cur.Type == lexer.COMMA {
+				p.next()
+				continue
+			}
+			if p.cur.Type == lexer.RPAREN {
+				p.next()
+				break
+			}
+			return nil, p.errAt(p.cur, "Expected ',' or ')' in dim sizes")
+		}
+	}
+
+	if p.cur.Type != lexer.ASSIGN {
+		return &ast.DimStmt{S: sp(dimTok), Name: name, Dims: dims}, nil
+	}
+	p.next()
+	val, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.DimStmt{S: sp(dimTok), Name: name, Dims: dims, Value: val}, nil
+}
+
+// letStmt = "let" IDENT [ "=" expr ]
+func (p *Parser) parseLet() (ast.Stmt, error) {
+	letTok := p.cur
+	p.next()
+	name := p.cur.Lexeme
+	p.next()
+	if p.cur.Type != lexer.ASSIGN {
+		return &ast.LetStmt{S: sp(letTok), Name: name}, nil
+	}
+	p.next()
+	val, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.LetStmt{S: sp(letTok), Name: name, Value: val}, nil
+}
+
+// globalStmt = "global" IDENT ( "," IDENT )* | "global" IDENT "=" expr
+func (p *Parser) parseGlobal() (ast.Stmt, error) {
+	globalTok := p.cur
+	p.next()
+	st := &ast.GlobalStmt{S: sp(globalTok)}
+	for {
+		if !isName(p.cur) {
+			return nil, p.errAt(p.cur, "Expected variable name after 'global'")
+		}
+		st.Names = append(st.Names, p.cur.Lexeme)
+		p.next()
+		if p.cur.Type != lexer.COMMA {
+			break
+		}
+		p.next()
+	}
+
+	if p.cur.Type != lexer.ASSIGN {
+		return st, nil
+	}
+	if len(st.Names) > 1 {
+		return nil, p.errAt(p.cur, "global with '=' declares a single variable")
+	}
+	p.next()
+	val, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	st.Value = val
+	return st, nil
+}
+
+// isDataStart reports whether tok can begin a data value.
+func isDataStart(tok lexer.Token) bool {
+	switch tok.Type {
+	case lexer.NUMBER, lexer.STRING, lexer.MINUS, lexer.TRUE, lexer.FALSE, lexer.NULL:
+		return true
+	}
+	return false
+}
+
+// dataStmt = "data" value ( "," value )*
+// value    = [ "-" ] NUMBER | STRING | "true" | "false" | "null"
+func (p *Parser) parseData() (ast.Stmt, error) {
+	st := &ast.DataStmt{S: sp(p.cur)}
+	p.next()
+	for {
+		tok := p.cur
+		switch tok.Type {
+		case lexer.MINUS:
+			p.next()
+			if p.cur.Type != lexer.NUMBER {
+				return nil, p.errAt(p.cur, "Expected a number after '-' in data")
+			}
+			st.Values = append(st.Values, &ast.NumberLiteral{S: sp(tok), Lexeme: "-" + p.cur.Lexeme})
+		case lexer.NUMBER:
+			st.Values = append(st.Values, &ast.NumberLiteral{S: sp(tok), Lexeme: tok.Lexeme})
+		case lexer.STRING:
+			st.Values = append(st.Values, &ast.StringLiteral{S: sp(tok), Value: tok.Lexeme})
+		case lexer.TRUE, lexer.FALSE:
+			st.Values = append(st.Values, &ast.BoolLiteral{S: sp(tok), Value: tok.Type == lexer.TRUE})
+		case lexer.NULL:
+			st.Values = append(st.Values, &ast.NullLiteral{S: sp(tok)})
+		default:
+			return nil, p.errAt(tok, "Expected a number, string, true, false or null in data")
+		}
+		p.next()
+		if p.cur.Type != lexer.COMMA {
+			return st, nil
+		}
+		p.next()
+	}
+}
+
+// Options understood by the analyzer/interpreter.
+var knownOptions = map[string]bool{
+	"explicit": true,
+	"checked":  true,
+	"strict":   true, // explicit + checked + analyzer strictness
+	"classic":  true, // --classic for the rest of the file, and names ignore case
+}
+
+// optionStmt = "option" IDENT
+func (p *Parser) parseOption() (ast.Stmt, error) {
+	optTok := p.cur
+	p.next()
+	if !isName(p.cur) {
+		return nil, p.errAt(p.cur, "Expected option name after 'option'")
+	}
+	name := strings.ToLower(p.cur.Lexeme)
+	if !knownOptions[name] {
+		return nil, p.errAt(p.cur, fmt.Sprintf("Unknown option %q", p.cur.Lexeme))
+	}
+	if name == "checked" || name == "strict" {
+		p.checked = true
+	}
+	if name == "classic" {
+		// the token after the name is already read; only the line break
+		// (or end of file) may follow, so the rest is lexed classic
+		p.lx.SetClassic(true)
+		p.classic, p.fold = true, true
+	}
+	p.next()
+	return &ast.OptionStmt{S: sp(optTok), Name: name}, nil
 }
 
 func (p *Parser) parseFunctionDecl() (ast.Stmt, error) {
+	doc := p.lx.CommentBlockAbove(p.cur.Line)
+	funcTok := p.cur
 	p.next()
-	if p.cur.Type != lexer.IDENT {
+	if !isName(p.cur) {
 		return nil, p.errAt(p.cur, "Expected function name after 'function'")
 	}
 	nameTok := p.cur
@@ -296,13 +879,51 @@ func (p *Parser) parseFunctionDecl() (ast.Stmt, error) {
 	if p.cur.Type != lexer.LPAREN {
 		return nil, p.errAt(p.cur, "Expected '(' after function name")
 	}
+	fn := &ast.FunctionDecl{S: sp(nameTok), Name: name, Doc: doc}
+	p.openBlock("function", funcTok)
+	if err := p.parseFunctionRest(fn, true); err != nil {
+		return nil, err
+	}
+	return fn, nil
+}
 
+// functionLit = "function" "(" params ")" block "end"
+// The body may share the header's line: function(x) return x * 2 end
+func (p *Parser) parseFunctionLit() (ast.Expr, error) {
+	fnTok := p.cur
+	// the body is made of lines even inside ( [ {
+	outer := p.nest
+	p.nest = 0
+	p.next() // '('
+	fn := &ast.FunctionDecl{S: sp(fnTok)}
+	p.openBlock("function", fnTok)
+	if err := p.parseFunctionRest(fn, false); err != nil {
+		return nil, err
+	}
+	p.nest = outer
+	if p.nest > 0 && p.cur.Type == lexer.NEWLINE {
+		p.next()
+	}
+	return &ast.FunctionLit{S: sp(fnTok), Fn: fn}, nil
+}
+
+// parseFunctionRest parses "(" params ")" block "end" into fn (cur is '(').
+// The last param may be written ...name to collect extra args.
+// Named functions need a NEWLINE after the header; lambdas may continue inline.
+func (p *Parser) parseFunctionRest(fn *ast.FunctionDecl, needNewline bool) error {
 	params := []string{}
 	p.next()
 	if p.cur.Type != lexer.RPAREN {
 		for {
-			if p.cur.Type != lexer.IDENT {
-				return nil, p.errAt(p.cur, "Expected parameter name")
+			if fn.Variadic {
+				return p.errAt(p.cur, "Variadic parameter must be the last one")
+			}
+			if p.cur.Type == lexer.ELLIPSIS {
+				fn.Variadic = true
+				p.next()
+			}
+			if !isName(p.cur) {
+				return p.errAt(p.cur, "Expected parameter name")
 			}
 			params = append(params, p.cur.Lexeme)
 
@@ -314,105 +935,280 @@ func (p *Parser) parseFunctionDecl() (ast.Stmt, error) {
 			if p.cur.Type == lexer.RPAREN {
 				break
 			}
-			return nil, p.errAt(p.cur, "Expected ',' or ')' in parameter list")
+			return p.errAt(p.cur, "Expected ',' or ')' in parameter list")
 		}
 	}
 
 	if p.cur.Type != lexer.RPAREN {
-		return nil, p.errAt(p.cur, "Expected ')' after parameters")
+		return p.errAt(p.cur, "Expected ')' after parameters")
 	}
 	p.next()
 
+	if needNewline && p.cur.Type != lexer.NEWLINE {
+		return p.errAt(p.cur, "Expected NEWLINE after function header")
+	}
+	for p.cur.Type == lexer.NEWLINE {
+		p.next()
+	}
+
+	body, err := p.parseBlockUntil(lexer.END)
+	if err != nil {
+		return err
+	}
+	if err := p.closeBlock(lexer.END); err != nil {
+		return err
+	}
+	fn.Params, fn.Body = params, body
+	fn.Generator = yields(body)
+	return nil
+}
+
+// yields reports whether body has a yield of its own, outside any nested
+// function, which makes the function a generator.
+func yields(body []ast.Stmt) bool {
+	found := false
+	for _, s := range body {
+		ast.Inspect(s, func(n ast.Node) bool {
+			switch n.(type) {
+			case *ast.YieldStmt:
+				found = true
+			case *ast.FunctionDecl, *ast.FunctionLit:
+				return false
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+// if cond NEWLINE block { (elseif | elif | else if) cond NEWLINE block } [ else NEWLINE block ] end
+func (p *Parser) parseIf() (ast.Stmt, error) {
+	ifTok := p.cur
+	p.next()
+	p.openBlock("if", ifTok)
+	cond, thenBlock, oneLine, err := p.parseCondBlock("if", false)
+	if err != nil {
+		return nil, err
+	}
+
+	var elseIfs []ast.ElseIfClause
+	elseBlock := []ast.Stmt{}
+	for p.cur.Type == lexer.ELSEIF || p.cur.Type == lexer.ELSE {
+		clauseTok := p.cur
+		p.next()
+		if clauseTok.Type == lexer.ELSE && p.cur.Type == lexer.IF {
+			p.next() // "else if" on one line is elseif
+		} else if clauseTok.Type == lexer.ELSE {
+			if oneLine {
+				elseBlock, err = p.parseLineBlock("if")
+			} else {
+				if p.cur.Type != lexer.NEWLINE {
+					return nil, p.errAt(p.cur, "Expected NEWLINE after else")
+				}
+				for p.cur.Type == lexer.NEWLINE {
+					p.next()
+				}
+				elseBlock, err = p.parseBlockUntil(lexer.END)
+			}
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		c, body, _, err := p.parseCondBlock("elseif", oneLine)
+		if err != nil {
+			return nil, err
+		}
+		elseIfs = append(elseIfs, ast.ElseIfClause{S: sp(clauseTok), Condition: c, Body: body})
+	}
+
+	if err := p.closeBlock(lexer.END); err != nil {
+		return nil, err
+	}
+
+	return &ast.IfStmt{S: sp(ifTok), Condition: cond, Then: thenBlock, ElseIfs: elseIfs, Else: elseBlock}, nil
+}
+
+// parseCondBlock parses "cond NEWLINE block" up to the next elseif/else/end.
+// The block may instead start on the condition's line (if done return end);
+// then the whole if, down to its end, has to stay on that line, and oneLine
+// reports it. inLine says an earlier clause was already one-line.
+func (p *Parser) parseCondBlock(keyword string, inLine bool) (cond ast.Expr, block []ast.Stmt, oneLine bool, err error) {
+	cond, err = p.parseExpr()
+	if err != nil {
+		return nil, nil, false, err
+	}
+	switch {
+	case p.cur.Type == lexer.ASSIGN:
+		return nil, nil, false, p.errAt(p.cur, "Expected NEWLINE after "+keyword+" condition (use == to compare)")
+	case p.cur.Type == lexer.EOF || (p.cur.Type == lexer.IDENT && strings.EqualFold(p.cur.Lexeme, "then")):
+		return nil, nil, false, p.errAt(p.cur, "Expected NEWLINE after "+keyword+" condition")
+	case inLine || p.cur.Type != lexer.NEWLINE:
+		block, err = p.parseLineBlock(keyword)
+		return cond, block, true, err
+	}
+	for p.cur.Type == lexer.NEWLINE {
+		p.next()
+	}
+
+	block, err = p.parseBlockUntil(lexer.ELSEIF, lexer.ELSE, lexer.END)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return cond, block, false, nil
+}
+
+// parseLineBlock parses the body of a one-line if up to its elseif/else/end,
+// which must come before the line ends.
+func (p *Parser) parseLineBlock(keyword string) ([]ast.Stmt, error) {
+	block := []ast.Stmt{}
+	for !p.isOneOf(p.cur.Type, lexer.ELSEIF, lexer.ELSE, lexer.END) {
+		if p.cur.Type == lexer.NEWLINE || p.cur.Type == lexer.EOF {
+			return nil, p.errAt(p.cur, "Expected 'end' on the same line as a one-line "+keyword)
+		}
+		line := p.cur.Line
+		stmt, err := p.parseStmt()
+		if err != nil {
+			return nil, err
+		}
+		if p.cur.Line != line && p.cur.Type != lexer.NEWLINE && p.cur.Type != lexer.EOF {
+			return nil, p.errAt(p.cur, "Expected 'end' on the same line as a one-line "+keyword)
+		}
+		block = append(block, stmt)
+	}
+	return block, nil
+}
+
+func (p *Parser) parseWhile() (ast.Stmt, error) {
+	wTok := p.cur
+	p.next()
+	p.openBlock("while", wTok)
+	cond, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
 	if p.cur.Type != lexer.NEWLINE {
-		return nil, p.errAt(p.cur, "Expected NEWLINE after function header")
+		return nil, p.errAt(p.cur, "Expected NEWLINE after while condition")
+	}
+	for p.cur.Type == lexer.NEWLINE {
+		p.next()
+	}
+
+	body, err := p.parseBlockUntil(lexer.END)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.closeBlock(lexer.END); err != nil {
+		return nil, err
 	}
+
+	return &ast.WhileStmt{S: sp(wTok), Condition: cond, Body: body}, nil
+}
+
+// repeatStmt = ( "repeat" | "do" ) NEWLINE block "until" expr
+func (p *Parser) parseRepeat() (ast.Stmt, error) {
+	rTok := p.cur
+	p.next()
+	p.openBlock(strings.ToLower(rTok.Lexeme), rTok)
 	for p.cur.Type == lexer.NEWLINE {
 		p.next()
 	}
 
-	body, err := p.parseBlockUntil(lexer.END)
+	body, err := p.parseBlockUntil(lexer.UNTIL)
 	if err != nil {
 		return nil, err
 	}
-	if p.cur.Type != lexer.END {
-		return nil, p.errAt(p.cur, "Expected 'end' to close function")
+	if err := p.closeBlock(lexer.UNTIL); err != nil {
+		return nil, err
 	}
-	p.next()
-
-	return &ast.FunctionDecl{S: sp(nameTok), Name: name, Params: params, Body: body}, nil
-}
 
-func (p *Parser) parseIf() (ast.Stmt, error) {
-	ifTok := p.cur
-	p.next()
 	cond, err := p.parseExpr()
 	if err != nil {
 		return nil, err
 	}
-	if p.cur.Type != lexer.NEWLINE {
-		return nil, p.errAt(p.cur, "Expected NEWLINE after if condition")
-	}
-	for p.cur.Type == lexer.NEWLINE {
-		p.next()
-	}
+	return &ast.RepeatStmt{S: sp(rTok), Body: body, Condition: cond}, nil
+}
+
+// try NEWLINE block [ catch [name] NEWLINE block ] [ finally NEWLINE block ] end
+func (p *Parser) parseTry() (ast.Stmt, error) {
+	tryTok := p.cur
+	p.next()
+	p.openBlock("try", tryTok)
+	stmt := &ast.TryStmt{S: sp(tryTok)}
 
-	thenBlock, err := p.parseBlockUntil(lexer.ELSE, lexer.END)
+	body, err := p.parseBlockUntil(lexer.CATCH, lexer.FINALLY, lexer.END)
 	if err != nil {
 		return nil, err
 	}
+	stmt.Body = body
 
-	elseBlock := []ast.Stmt{}
-	if p.cur.Type == lexer.ELSE {
+	if p.cur.Type == lexer.CATCH {
 		p.next()
-		if p.cur.Type != lexer.NEWLINE {
-			return nil, p.errAt(p.cur, "Expected NEWLINE after else")
-		}
-		for p.cur.Type == lexer.NEWLINE {
+		stmt.HasCatch = true
+		if isName(p.cur) {
+			stmt.CatchVar = p.cur.Lexeme
 			p.next()
 		}
-		elseBlock, err = p.parseBlockUntil(lexer.END)
+		if p.cur.Type != lexer.NEWLINE {
+			return nil, p.errAt(p.cur, "Expected NEWLINE after catch")
+		}
+		stmt.Catch, err = p.parseBlockUntil(lexer.FINALLY, lexer.END)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	if p.cur.Type != lexer.END {
-		return nil, p.errAt(p.cur, "Expected 'end' to close if")
+	if p.cur.Type == lexer.FINALLY {
+		p.next()
+		if p.cur.Type != lexer.NEWLINE {
+			return nil, p.errAt(p.cur, "Expected NEWLINE after finally")
+		}
+		stmt.Finally, err = p.parseBlockUntil(lexer.END)
+		if err != nil {
+			return nil, err
+		}
 	}
-	p.next()
 
-	return &ast.IfStmt{S: sp(ifTok), Condition: cond, Then: thenBlock, Else: elseBlock}, nil
+	if !stmt.HasCatch && stmt.Finally == nil && p.cur.Type == lexer.END {
+		return nil, p.errAt(tryTok, "try needs a catch or finally block")
+	}
+	if err := p.closeBlock(lexer.END); err != nil {
+		return nil, err
+	}
+	return stmt, nil
 }
 
-func (p *Parser) parseWhile() (ast.Stmt, error) {
-	wTok := p.cur
+// raise expr
+func (p *Parser) parseRaise() (ast.Stmt, error) {
+	rTok := p.cur
 	p.next()
-	cond, err := p.parseExpr()
+	val, err := p.parseExpr()
 	if err != nil {
 		return nil, err
 	}
-	if p.cur.Type != lexer.NEWLINE {
-		return nil, p.errAt(p.cur, "Expected NEWLINE after while condition")
-	}
-	for p.cur.Type == lexer.NEWLINE {
-		p.next()
-	}
+	return &ast.RaiseStmt{S: sp(rTok), Value: val}, nil
+}
 
-	body, err := p.parseBlockUntil(lexer.END)
+func (p *Parser) parseYield() (ast.Stmt, error) {
+	yTok := p.cur
+	p.next()
+	val, err := p.parseExpr()
 	if err != nil {
 		return nil, err
 	}
-	if p.cur.Type != lexer.END {
-		return nil, p.errAt(p.cur, "Expected 'end' to close while")
-	}
-	p.next()
-
-	return &ast.WhileStmt{S: sp(wTok), Condition: cond, Body: body}, nil
+	return &ast.YieldStmt{S: sp(yTok), Value: val}, nil
 }
 
 func (p *Parser) parseFor() (ast.Stmt, error) {
+	forTok := p.cur
 	p.next()
-	if p.cur.Type != lexer.IDENT {
+	// "for each x in xs" is foreach; "for each = 1 to 3" is a normal loop.
+	if p.cur.Type == lexer.EACH && p.peek.Type != lexer.ASSIGN {
+		return p.parseForEachRest(forTok)
+	}
+	p.openBlock("for", forTok)
+	if !isName(p.cur) {
 		return nil, p.errAt(p.cur, "Expected loop variable after 'for'")
 	}
 	varNameTok := p.cur
@@ -458,10 +1254,9 @@ func (p *Parser) parseFor() (ast.Stmt, error) {
 	if err != nil {
 		return nil, err
 	}
-	if p.cur.Type != lexer.END {
-		return nil, p.errAt(p.cur, "Expected 'end' to close for")
+	if err := p.closeBlock(lexer.END); err != nil {
+		return nil, err
 	}
-	p.next()
 
 	return &ast.ForStmt{S: sp(varNameTok), Var: varName, Start: startExpr, End: endExpr, Step: stepExpr, Body: body}, nil
 }
@@ -471,13 +1266,21 @@ func (p *Parser) parseFor() (ast.Stmt, error) {
 func (p *Parser) parseForEach() (ast.Stmt, error) {
 	startTok := p.cur
 	p.next()
+	return p.parseForEachRest(startTok)
+}
+
+// parseForEachRest parses a foreach loop after its opening keyword
+// (`foreach`, or `for` when followed by `each`).
+func (p *Parser) parseForEachRest(startTok lexer.Token) (ast.Stmt, error) {
+	p.openBlock(strings.ToLower(startTok.Lexeme), startTok)
 
 	// allow optional "each" keyword: foreach each x in ...
-	if p.cur.Type == lexer.EACH {
+	// (but `foreach each in xs` uses "each" as the variable)
+	if p.cur.Type == lexer.EACH && p.peek.Type != lexer.IN && p.peek.Type != lexer.COMMA {
 		p.next()
 	}
 
-	if p.cur.Type != lexer.IDENT {
+	if !isName(p.cur) {
 		return nil, p.errAt(p.cur, "Expected variable name after 'foreach'")
 	}
 	valTok := p.cur
@@ -487,7 +1290,7 @@ func (p *Parser) parseForEach() (ast.Stmt, error) {
 	idxName := ""
 	if p.cur.Type == lexer.COMMA {
 		p.next()
-		if p.cur.Type != lexer.IDENT {
+		if !isName(p.cur) {
 			return nil, p.errAt(p.cur, "Expected index variable name after ','")
 		}
 		idxName = p.cur.Lexeme
@@ -515,10 +1318,9 @@ func (p *Parser) parseForEach() (ast.Stmt, error) {
 	if err != nil {
 		return nil, err
 	}
-	if p.cur.Type != lexer.END {
-		return nil, p.errAt(p.cur, "Expected 'end' to close foreach")
+	if err := p.closeBlock(lexer.END); err != nil {
+		return nil, err
 	}
-	p.next()
 
 	return &ast.ForEachStmt{S: sp(startTok), Var: valName, IndexVar: idxName, Iterable: iterExpr, Body: body}, nil
 }
@@ -551,8 +1353,27 @@ func (p *Parser) isOneOf(t lexer.TokenType, list ...lexer.TokenType) bool {
 	return false
 }
 
-// expr = or
-func (p *Parser) parseExpr() (ast.Expr, error) { return p.parseOr() }
+// expr = coalesce
+func (p *Parser) parseExpr() (ast.Expr, error) { return p.parseCoalesce() }
+
+// coalesce = or [ "??" coalesce ]
+// Right-associative, so in a ?? b ?? c each fallback is tried in turn.
+func (p *Parser) parseCoalesce() (ast.Expr, error) {
+	left, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.cur.Type == lexer.COALESCE {
+		opTok := p.cur
+		p.next()
+		right, err := p.parseCoalesce()
+		if err != nil {
+			return nil, err
+		}
+		left = &ast.BinaryExpr{S: sp(opTok), Left: left, Op: "??", Right: right}
+	}
+	return left, nil
+}
 
 // or = and ( "or" and )*
 func (p *Parser) parseOr() (ast.Expr, error) {
@@ -590,25 +1411,62 @@ func (p *Parser) parseAnd() (ast.Expr, error) {
 	return left, nil
 }
 
-// comparison = addsub ( (==|!=|<|>|<=|>=) addsub )?
+// comparison = range ( (==|!=|<|>|<=|>=|in|not in) range )?
 func (p *Parser) parseComparison() (ast.Expr, error) {
-	left, err := p.parseAddSub()
+	left, err := p.parseRange()
 	if err != nil {
 		return nil, err
 	}
+	if p.cur.Type == lexer.IN || p.cur.Type == lexer.NOT && p.peek.Type == lexer.IN {
+		opTok := p.cur
+		op := "in"
+		if p.cur.Type == lexer.NOT {
+			op = "not in"
+			p.next()
+		}
+		p.next()
+		right, err := p.parseRange()
+		if err != nil {
+			return nil, err
+		}
+		return &ast.BinaryExpr{S: sp(opTok), Left: left, Op: op, Right: right}, nil
+	}
 	if isCompareTok(p.cur.Type) {
 		opTok := p.cur
 		op := p.cur.Lexeme
+		if p.cur.Type == lexer.NEQ {
+			op = "!=" // `<>` and `!=` are the same operator
+		}
 		p.next()
-		right, err := p.parseAddSub()
+		right, err := p.parseRange()
 		if err != nil {
 			return nil, err
 		}
-		return &ast.BinaryExpr{S: sp(opTok), Left: left, Op: op, Right: right}, nil
+		classic := opTok.Lexeme == "<>" && !p.classic
+		return &ast.BinaryExpr{S: sp(opTok), Left: left, Op: op, Right: right, ClassicOp: classic}, nil
 	}
 	return left, nil
 }
 
+// range = addsub [ ".." addsub ]
+// 1..n-1 is 1..(n-1); the range is a value, iterated without building an array.
+func (p *Parser) parseRange() (ast.Expr, error) {
+	left, err := p.parseAddSub()
+	if err != nil {
+		return nil, err
+	}
+	if p.cur.Type != lexer.RANGE {
+		return left, nil
+	}
+	opTok := p.cur
+	p.next()
+	right, err := p.parseAddSub()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.RangeExpr{S: sp(opTok), Start: left, End: right}, nil
+}
+
 func isCompareTok(t lexer.TokenType) bool {
 	return t == lexer.EQ || t == lexer.NEQ || t == lexer.LT || t == lexer.GT || t == lexer.LTE || t == lexer.GTE
 }
@@ -626,7 +1484,7 @@ func (p *Parser) parseAddSub() (ast.Expr, error) {
 		if err != nil {
 			return nil, err
 		}
-		left = &ast.BinaryExpr{S: sp(opTok), Left: left, Op: op, Right: right}
+		left = &ast.BinaryExpr{S: sp(opTok), Left: left, Op: op, Right: right, Checked: p.checked}
 	}
 	return left, nil
 }
@@ -636,15 +1494,18 @@ func (p *Parser) parseMulDiv() (ast.Expr, error) {
 	if err != nil {
 		return nil, err
 	}
-	for p.cur.Type == lexer.STAR || p.cur.Type == lexer.SLASH {
+	for p.cur.Type == lexer.STAR || p.cur.Type == lexer.SLASH || p.cur.Type == lexer.PERCENT {
 		opTok := p.cur
 		op := p.cur.Lexeme
+		if p.cur.Type == lexer.PERCENT {
+			op = "%" // `mod` and `%` are the same operator
+		}
 		p.next()
 		right, err := p.parseUnary()
 		if err != nil {
 			return nil, err
 		}
-		left = &ast.BinaryExpr{S: sp(opTok), Left: left, Op: op, Right: right}
+		left = &ast.BinaryExpr{S: sp(opTok), Left: left, Op: op, Right: right, Checked: p.checked}
 	}
 	return left, nil
 }
@@ -663,35 +1524,165 @@ func (p *Parser) parseUnary() (ast.Expr, error) {
 	return p.parsePostfix()
 }
 
-// postfix = primary ( "[" expr "]" )*
+// callArgs = "(" [ spreadable ( "," spreadable )* [ "," ] ] ")"   (cur is '(')
+func (p *Parser) parseCallArgs() ([]ast.Expr, error) {
+	args := []ast.Expr{}
+	p.enterNest()
+	if p.cur.Type != lexer.RPAREN {
+		for {
+			arg, err := p.parseSpreadable()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+
+			if p.cur.Type == lexer.COMMA {
+				p.next()
+				if p.cur.Type == lexer.RPAREN {
+					break
+				}
+				continue
+			}
+			if p.cur.Type == lexer.RPAREN {
+				break
+			}
+			return nil, p.errAt(p.cur, "Expected ',' or ')' in call arguments")
+		}
+	}
+	if p.cur.Type != lexer.RPAREN {
+		return nil, p.errAt(p.cur, "Expected ')' after call arguments")
+	}
+	p.leaveNest()
+	return args, nil
+}
+
+// postfix = primary ( "[" expr ( "," expr )* "]" [ "?" ] | callArgs | "." IDENT [ callArgs ] )*
+// a[i, j] is shorthand for a[i][j]; f(1)(2) calls the function f(1) returns;
+// p.x reads a record field and p.move(1, 2) calls a method.
+// A '?' after ']' makes the index optional: a missing key or index gives
+// null, and so does every later index in the same chain (m["a"]?["b"]).
 func (p *Parser) parsePostfix() (ast.Expr, error) {
 	left, err := p.parsePrimary()
 	if err != nil {
 		return nil, err
 	}
 
-	for p.cur.Type == lexer.LBRACKET {
+	optional := false
+
+	for p.cur.Type == lexer.LBRACKET || p.cur.Type == lexer.LPAREN || p.cur.Type == lexer.DOT {
+		if p.cur.Type == lexer.DOT {
+			dotTok := p.cur
+			p.next()
+			if !isName(p.cur) {
+				return nil, p.errAt(p.cur, "Expected a field name after '.'")
+			}
+			name := p.cur.Lexeme
+			p.next()
+			if p.cur.Type == lexer.LPAREN {
+				args, err := p.parseCallArgs()
+				if err != nil {
+					return nil, err
+				}
+				left = &ast.MethodCallExpr{S: sp(dotTok), Object: left, Name: name, Args: args, Classic: p.fold}
+				continue
+			}
+			left = &ast.MemberExpr{S: sp(dotTok), Left: left, Name: name, Classic: p.fold}
+			continue
+		}
+		if p.cur.Type == lexer.LPAREN {
+			parenTok := p.cur
+			args, err := p.parseCallArgs()
+			if err != nil {
+				return nil, err
+			}
+			left = &ast.CallValueExpr{S: sp(parenTok), Callee: left, Args: args}
+			continue
+		}
+
 		brTok := p.cur
 		p.next()
 
+		if p.cur.Type == lexer.COLON {
+			left, err = p.parseSliceRest(brTok, left, nil)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
 		indexExpr, err := p.parseExpr()
 		if err != nil {
 			return nil, err
 		}
+		if p.cur.Type == lexer.COLON {
+			left, err = p.parseSliceRest(brTok, left, indexExpr)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		group := []*ast.IndexExpr{{S: sp(brTok), Left: left, Index: indexExpr}}
+		left = group[0]
+
+		for p.cur.Type == lexer.COMMA {
+			commaTok := p.cur
+			p.next()
+			indexExpr, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			ix := &ast.IndexExpr{S: sp(commaTok), Left: left, Index: indexExpr}
+			group = append(group, ix)
+			left = ix
+		}
 
 		if p.cur.Type != lexer.RBRACKET {
 			return nil, p.errAt(p.cur, "Expected ']' after index expression")
 		}
 		p.next()
 
-		left = &ast.IndexExpr{S: sp(brTok), Left: left, Index: indexExpr}
+		if p.cur.Type == lexer.QUESTION {
+			optional = true
+			p.next()
+		}
+		if optional {
+			for _, ix := range group {
+				ix.Optional = true
+			}
+		}
 	}
 
 	return left, nil
 }
 
+// parseSliceRest parses ":" [ expr ] "]" after the start of left[start:end]
+// (cur is ':'; start is nil for left[:end]).
+func (p *Parser) parseSliceRest(brTok lexer.Token, left, start ast.Expr) (ast.Expr, error) {
+	p.next()
+	var end ast.Expr
+	if p.cur.Type != lexer.RBRACKET {
+		var err error
+		end, err = p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p.cur.Type != lexer.RBRACKET {
+		return nil, p.errAt(p.cur, "Expected ']' after slice")
+	}
+	p.next()
+	return &ast.SliceExpr{S: sp(brTok), Left: left, Start: start, End: end}, nil
+}
+
 func (p *Parser) parsePrimary() (ast.Expr, error) {
-	switch p.cur.Type {
+	// match x case ... end; match is otherwise a plain name, so match(x) calls
+	if p.cur.Type == lexer.MATCH && isMatchSubject(p.peek) {
+		return p.parseMatch()
+	}
+	typ := p.cur.Type
+	if isName(p.cur) {
+		typ = lexer.IDENT // a contextual keyword in operand position is a name
+	}
+	switch typ {
 	case lexer.STRING:
 		tok := p.cur
 		expr := &ast.StringLiteral{S: sp(tok), Value: tok.Lexeme}
@@ -704,6 +1695,11 @@ func (p *Parser) parsePrimary() (ast.Expr, error) {
 		p.next()
 		return expr, nil
 
+	case lexer.NULL:
+		tok := p.cur
+		p.next()
+		return &ast.NullLiteral{S: sp(tok)}, nil
+
 	case lexer.TRUE:
 		tok := p.cur
 		p.next()
@@ -720,37 +1716,17 @@ func (p *Parser) parsePrimary() (ast.Expr, error) {
 		p.next()
 
 		if p.cur.Type == lexer.LPAREN {
-			args := []ast.Expr{}
-			p.next()
-			if p.cur.Type != lexer.RPAREN {
-				for {
-					arg, err := p.parseExpr()
-					if err != nil {
-						return nil, err
-					}
-					args = append(args, arg)
-
-					if p.cur.Type == lexer.COMMA {
-						p.next()
-						continue
-					}
-					if p.cur.Type == lexer.RPAREN {
-						break
-					}
-					return nil, p.errAt(p.cur, "Expected ',' or ')' in call arguments")
-				}
-			}
-			if p.cur.Type != lexer.RPAREN {
-				return nil, p.errAt(p.cur, "Expected ')' after call arguments")
+			args, err := p.parseCallArgs()
+			if err != nil {
+				return nil, err
 			}
-			p.next()
 			return &ast.CallExpr{S: sp(nameTok), Callee: name, Args: args}, nil
 		}
 
 		return &ast.Identifier{S: sp(nameTok), Name: name}, nil
 
 	case lexer.LPAREN:
-		p.next()
+		p.enterNest()
 		expr, err := p.parseExpr()
 		if err != nil {
 			return nil, err
@@ -758,7 +1734,7 @@ func (p *Parser) parsePrimary() (ast.Expr, error) {
 		if p.cur.Type != lexer.RPAREN {
 			return nil, p.errAt(p.cur, "Expected ')'")
 		}
-		p.next()
+		p.leaveNest()
 		return expr, nil
 
 	case lexer.LBRACKET:
@@ -767,24 +1743,40 @@ func (p *Parser) parsePrimary() (ast.Expr, error) {
 	case lexer.LBRACE:
 		return p.parseMapLiteral()
 
+	case lexer.FUNCTION:
+		if p.peek.Type != lexer.LPAREN {
+			return nil, p.errAt(p.peek, "Expected '(' after 'function' in a function expression")
+		}
+		return p.parseFunctionLit()
+
 	default:
 		return nil, p.errAt(p.cur, "Expected an expression")
 	}
 }
 
+// spreadable = expr [ "..." ]
+// xs... in an array literal or call arguments stands for the values of xs.
+func (p *Parser) parseSpreadable() (ast.Expr, error) {
+	e, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.cur.Type == lexer.ELLIPSIS {
+		e = &ast.SpreadExpr{S: sp(p.cur), Value: e}
+		p.next()
+	}
+	return e, nil
+}
+
+// arrayLiteral = "[" [ spreadable ( "," spreadable )* [ "," ] ] "]"
 func (p *Parser) parseArrayLiteral() (ast.Expr, error) {
 	lbTok := p.cur
-	p.next()
+	p.enterNest()
 
 	elems := []ast.Expr{}
 
-	if p.cur.Type == lexer.RBRACKET {
-		p.next()
-		return &ast.ArrayLiteralExpr{S: sp(lbTok), Elements: elems}, nil
-	}
-
-	for {
-		elem, err := p.parseExpr()
+	for p.cur.Type != lexer.RBRACKET {
+		elem, err := p.parseSpreadable()
 		if err != nil {
 			return nil, err
 		}
@@ -795,64 +1787,218 @@ func (p *Parser) parseArrayLiteral() (ast.Expr, error) {
 			continue
 		}
 		if p.cur.Type == lexer.RBRACKET {
-			p.next()
 			break
 		}
 		return nil, p.errAt(p.cur, "Expected ',' or ']' in array literal")
 	}
+	p.leaveNest()
 
 	return &ast.ArrayLiteralExpr{S: sp(lbTok), Elements: elems}, nil
 }
 
-// mapLiteral = "{" [ string ":" expr ("," string ":" expr)* ] "}"
+// mapLiteral = "{" [ entry ("," entry)* [ "," ] ] "}"
+// entry      = key ":" expr | expr "..."
+// key        = string | name | "[" expr "]"
+// A bare name is the key's text: {name: "Ed"} is {"name": "Ed"}; a key in
+// brackets is computed: {[prefix + id]: v}. base... copies the entries of
+// the map base. Entries may span lines.
 func (p *Parser) parseMapLiteral() (ast.Expr, error) {
 	lbTok := p.cur // '{'
-	p.next()
+	p.enterNest()
 
 	entries := []ast.MapEntry{}
 
-	if p.cur.Type == lexer.RBRACE {
-		p.next()
-		return &ast.MapLiteralExpr{S: sp(lbTok), Entries: entries}, nil
-	}
-
-	for {
-		if p.cur.Type != lexer.STRING {
-			return nil, p.errAt(p.cur, "Expected string key in map literal")
-		}
-		keyTok := p.cur
-		key := keyTok.Lexeme
-		p.next()
-
-		if p.cur.Type != lexer.COLON {
-			return nil, p.errAt(p.cur, "Expected ':' after map key")
+	for p.cur.Type != lexer.RBRACE {
+		var entry ast.MapEntry
+		switch {
+		case p.cur.Type == lexer.LBRACKET:
+			p.enterNest()
+			keyExpr, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if p.cur.Type != lexer.RBRACKET {
+				return nil, p.errAt(p.cur, "Expected ']' after computed map key")
+			}
+			p.leaveNest()
+			entry.KeyExpr = keyExpr
+		case (p.cur.Type == lexer.STRING || isName(p.cur)) && p.peek.Type == lexer.COLON:
+			entry.Key = p.cur.Lexeme
+			p.next()
+		default:
+			val, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if p.cur.Type != lexer.ELLIPSIS {
+				return nil, p.errAt(p.cur, "Expected ':' after map key (or '...' to spread a map)")
+			}
+			p.next()
+			entry.Value, entry.Spread = val, true
 		}
-		p.next()
 
-		val, err := p.parseExpr()
-		if err != nil {
-			return nil, err
+		if !entry.Spread {
+			if p.cur.Type != lexer.COLON {
+				return nil, p.errAt(p.cur, "Expected ':' after map key")
+			}
+			p.next()
+			val, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			entry.Value = val
 		}
 
-		entries = append(entries, ast.MapEntry{Key: key, Value: val})
+		entries = append(entries, entry)
 
 		if p.cur.Type == lexer.COMMA {
 			p.next()
 			continue
 		}
 		if p.cur.Type == lexer.RBRACE {
-			p.next()
 			break
 		}
 		return nil, p.errAt(p.cur, "Expected ',' or '}' in map literal")
 	}
+	p.leaveNest()
 
 	return &ast.MapLiteralExpr{S: sp(lbTok), Entries: entries}, nil
 }
 
+// isMatchSubject reports whether tok can start the subject of a match
+// expression. A parenthesized subject is left out so that match(x) stays a
+// call, as repeat(...) does.
+func isMatchSubject(tok lexer.Token) bool {
+	switch tok.Type {
+	case lexer.IDENT, lexer.NUMBER, lexer.STRING, lexer.TRUE, lexer.FALSE, lexer.NULL, lexer.LBRACKET, lexer.LBRACE:
+		return true
+	}
+	return false
+}
+
+// match = "match" expr { "case" arm } "end"
+// arm   = ( "else" | pattern { "," pattern } ) [ "as" name ] ":" expr
+// pattern = "is" kind | expr [ "to" expr ]
+// Arms may share a line with the subject or each other.
+func (p *Parser) parseMatch() (ast.Expr, error) {
+	matchTok := p.cur
+	p.next()
+	subject, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	m := &ast.MatchExpr{S: sp(matchTok), Subject: subject}
+	p.openBlock("match", matchTok)
+
+	for {
+		for p.cur.Type == lexer.NEWLINE {
+			p.next()
+		}
+		if p.cur.Type != lexer.CASE {
+			break
+		}
+		if n := len(m.Arms); n > 0 && m.Arms[n-1].Patterns == nil {
+			return nil, p.errAt(p.cur, "'case else' must be the last case of a match")
+		}
+		arm, err := p.parseMatchArm()
+		if err != nil {
+			return nil, err
+		}
+		m.Arms = append(m.Arms, arm)
+	}
+	if len(m.Arms) == 0 && p.cur.Type != lexer.EOF {
+		return nil, p.errAt(p.cur, "Expected 'case' after the match subject")
+	}
+	if err := p.closeBlock(lexer.END); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (p *Parser) parseMatchArm() (ast.MatchArm, error) {
+	arm := ast.MatchArm{S: sp(p.cur)}
+	p.next() // case
+	if p.cur.Type == lexer.ELSE {
+		p.next()
+	} else {
+		for {
+			pat, err := p.parseMatchPattern()
+			if err != nil {
+				return arm, err
+			}
+			arm.Patterns = append(arm.Patterns, pat)
+			if p.cur.Type != lexer.COMMA {
+				break
+			}
+			p.next()
+		}
+	}
+	if p.cur.Type == lexer.IDENT && strings.EqualFold(p.cur.Lexeme, "as") {
+		p.next()
+		if !isName(p.cur) {
+			return arm, p.errAt(p.cur, "Expected a name after 'as'")
+		}
+		arm.Bind = p.cur.Lexeme
+		p.next()
+	}
+	if p.cur.Type != lexer.COLON {
+		return arm, p.errAt(p.cur, "Expected ':' before the value of a case")
+	}
+	p.next()
+	body, err := p.parseExpr()
+	if err != nil {
+		return arm, err
+	}
+	arm.Body = body
+	return arm, nil
+}
+
+func (p *Parser) parseMatchPattern() (ast.MatchPattern, error) {
+	if p.cur.Type == lexer.IDENT && strings.EqualFold(p.cur.Lexeme, "is") && isName(p.peek) {
+		p.next()
+		pat := ast.MatchPattern{Kind: p.cur.Lexeme}
+		p.next()
+		return pat, nil
+	}
+	val, err := p.parseExpr()
+	if err != nil {
+		return ast.MatchPattern{}, err
+	}
+	pat := ast.MatchPattern{Value: val}
+	if p.cur.Type == lexer.TO {
+		p.next()
+		if pat.High, err = p.parseExpr(); err != nil {
+			return ast.MatchPattern{}, err
+		}
+	}
+	return pat, nil
+}
+
+// SyntaxError is a parse error. Error() gives the usual one-line text with
+// the position; Msg and Span give the parts separately for tools.
+type SyntaxError struct {
+	Msg  string
+	Span ast.Span
+	text string
+}
+
+func (e *SyntaxError) Error() string { return e.text }
+
 func (p *Parser) errAt(tok lexer.Token, msg string) error {
-	if tok.Type == lexer.EOF {
-		return fmt.Errorf("%s at end of file", msg)
+	e := &SyntaxError{Span: sp(tok)}
+	switch {
+	case tok.Type == lexer.EOF:
+		e.Msg = msg + " at end of file"
+		e.text = e.Msg
+	case tok.Type == lexer.ILLEGAL && tok.Lexeme == `"""`:
+		e.Msg = `Unterminated """ string`
+		e.text = fmt.Sprintf("%s starting at %d:%d", e.Msg, tok.Line, tok.Col)
+	case tok.Type == lexer.ILLEGAL && !utf8.ValidString(tok.Lexeme):
+		e.Msg = fmt.Sprintf("Invalid UTF-8 (byte 0x%02X)", tok.Lexeme[0])
+		e.text = fmt.Sprintf("%s at %d:%d", e.Msg, tok.Line, tok.Col)
+	default:
+		e.Msg = fmt.Sprintf("%s (got %s)", msg, tok.Type)
+		e.text = fmt.Sprintf("%s at %d:%d (got %s)", msg, tok.Line, tok.Col, tok.Type)
 	}
-	return fmt.Errorf("%s at %d:%d (got %s)", msg, tok.Line, tok.Col, tok.Type)
+	return e
 }
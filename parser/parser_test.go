@@ -0,0 +1,116 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"bpl-plus/ast"
+	"bpl-plus/lexer"
+)
+
+func parse(t *testing.T, src string) []ast.Stmt {
+	t.Helper()
+	prog, err := New(lexer.New(src)).ParseProgram()
+	if err != nil {
+		t.Fatalf("parse %q: %v", src, err)
+	}
+	return prog
+}
+
+// tree renders the statements after any option lines, one per line.
+func tree(prog []ast.Stmt) string {
+	var out []string
+	for _, s := range prog {
+		if _, ok := s.(*ast.OptionStmt); !ok {
+			out = append(out, s.String())
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+func TestOptionClassic(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string // parsed with and without "option classic" in front
+		classic string
+		modern  string
+	}{
+		{"names are folded", "Total = TOTAL + total",
+			`AssignStmt(total = Binary(Ident(total) + Ident(total)))`,
+			`AssignStmt(Total = Binary(Ident(TOTAL) + Ident(total)))`},
+		{"keyword case", "PRINT Len(A)",
+			`PrintStmt(Call(len, args=1))`,
+			`PrintStmt(Call(Len, args=1))`},
+		{"LET is an assignment", "LET X = 1",
+			`AssignStmt(x = Number(1))`,
+			`Let(X = Number(1))`},
+		{"REM is a comment", "REM Old Listing\nX = 1",
+			`AssignStmt(x = Number(1))`,
+			""}, // REM Old Listing is three names in modern mode: an error
+		{"<> is not-equal", "IF A <> B\nEND",
+			`IfStmt(Binary(Ident(a) != Ident(b)), then=0, else=0)`,
+			`IfStmt(Binary(Ident(A) != Ident(B)), then=0, else=0)`},
+		{"member names are kept", "PRINT Person.Name",
+			`PrintStmt(Member(Ident(person), Name))`,
+			`PrintStmt(Member(Ident(Person), Name))`},
+		{"method names are kept", "X = MATH.FLOOR(Y)",
+			`AssignStmt(x = MethodCall(Ident(math).FLOOR, args=1))`,
+			`AssignStmt(X = MethodCall(Ident(MATH).FLOOR, args=1))`},
+		{"imported names are folded", `IMPORT sayHi FROM "util"`,
+			`Import(sayhi from "util")`,
+			`Import(sayHi from "util")`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tree(parse(t, "option classic\n"+tt.src)); got != tt.classic {
+				t.Errorf("option classic:\n got %s\nwant %s", got, tt.classic)
+			}
+			if tt.modern == "" {
+				if _, err := New(lexer.New(tt.src)).ParseProgram(); err == nil {
+					t.Errorf("modern: %q parsed", tt.src)
+				}
+				return
+			}
+			if got := tree(parse(t, tt.src)); got != tt.modern {
+				t.Errorf("modern:\n got %s\nwant %s", got, tt.modern)
+			}
+		})
+	}
+}
+
+// The analyzer and interpreter need to know which nodes came from an option
+// classic file, and that <> there is not the modern-mode slip.
+func TestOptionClassicFlags(t *testing.T) {
+	for _, classic := range []bool{false, true} {
+		src := "p.X = a.B + c.D(1) + (1 <> 2)\nimport f from \"m\""
+		if classic {
+			src = "option classic\n" + src
+		}
+		var member, method, assign, imp, op bool
+		visit := func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.MemberExpr:
+				member = n.Classic
+			case *ast.MethodCallExpr:
+				method = n.Classic
+			case *ast.MemberAssignStmt:
+				assign = n.Classic
+			case *ast.ImportStmt:
+				imp = n.Classic
+			case *ast.BinaryExpr:
+				if n.Op == "!=" {
+					op = !n.ClassicOp
+				}
+			}
+			return true
+		}
+		for _, s := range parse(t, src) {
+			ast.Inspect(s, visit)
+		}
+		for name, got := range map[string]bool{"MemberExpr": member, "MethodCallExpr": method, "MemberAssignStmt": assign, "ImportStmt": imp, "<> without ClassicOp": op} {
+			if got != classic {
+				t.Errorf("option classic %v: %s = %v", classic, name, got)
+			}
+		}
+	}
+}